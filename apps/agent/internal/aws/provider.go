@@ -0,0 +1,621 @@
+package aws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	efstypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+const (
+	// containerName is the single container in every workspace task definition
+	containerName = "workspace"
+	// workspaceUID owns the workspace home directory inside the access point
+	workspaceUID = 1000
+)
+
+// regionClients holds the AWS API clients and settings for one region
+type regionClients struct {
+	config config.AWSRegionConfig
+	ecs    *ecs.Client
+	efs    *efs.Client
+	ec2    *ec2.Client
+}
+
+// FargateProvider implements provider.ComputeProvider on ECS Fargate + EFS.
+// A workspace is a Fargate task; the fs-{id} file share becomes an EFS access
+// point rooted at a fresh /fs-{id}-{nonce} directory on the region's shared
+// file system.
+type FargateProvider struct {
+	config  config.AWSConfig
+	regions map[string]*regionClients
+}
+
+// NewFargateProvider creates the Fargate provider with API clients for every configured region
+func NewFargateProvider(cfg config.AWSConfig, awsCfg awssdk.Config) *FargateProvider {
+	p := &FargateProvider{
+		config:  cfg,
+		regions: make(map[string]*regionClients),
+	}
+
+	for _, region := range cfg.Regions {
+		region := region
+		p.regions[region.Name] = &regionClients{
+			config: region,
+			ecs: ecs.NewFromConfig(awsCfg, func(o *ecs.Options) {
+				o.Region = region.Name
+				if cfg.Endpoint != "" {
+					o.BaseEndpoint = awssdk.String(cfg.Endpoint)
+				}
+			}),
+			efs: efs.NewFromConfig(awsCfg, func(o *efs.Options) {
+				o.Region = region.Name
+				if cfg.Endpoint != "" {
+					o.BaseEndpoint = awssdk.String(cfg.Endpoint)
+				}
+			}),
+			ec2: ec2.NewFromConfig(awsCfg, func(o *ec2.Options) {
+				o.Region = region.Name
+				if cfg.Endpoint != "" {
+					o.BaseEndpoint = awssdk.String(cfg.Endpoint)
+				}
+			}),
+		}
+	}
+
+	return p
+}
+
+// Name returns the backend name
+func (p *FargateProvider) Name() string {
+	return "aws-fargate"
+}
+
+// HasRegion reports whether the AWS region is configured
+func (p *FargateProvider) HasRegion(region string) bool {
+	_, ok := p.regions[region]
+	return ok
+}
+
+// Regions returns the configured AWS regions
+func (p *FargateProvider) Regions() []string {
+	regions := make([]string, 0, len(p.config.Regions))
+	for _, region := range p.config.Regions {
+		regions = append(regions, region.Name)
+	}
+	return regions
+}
+
+// CreateVolume creates the workspace EFS access point.
+// EFS has no per-directory quota, so quotaGB is only recorded as a tag.
+// Every access point gets its own root directory, so re-creating a deleted
+// workspace never remounts the files a previous access point left behind.
+func (p *FargateProvider) CreateVolume(ctx context.Context, region, name string, quotaGB int32) error {
+	rc, err := p.region(region)
+	if err != nil {
+		return err
+	}
+
+	existing, err := p.findAccessPoint(ctx, rc, name)
+	if err != nil {
+		return fmt.Errorf("failed to create access point: %w", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("failed to create access point: %s already exists", name)
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return fmt.Errorf("failed to create access point: %w", err)
+	}
+	rootPath := fmt.Sprintf("/%s-%s", name, nonce)
+
+	_, err = rc.efs.CreateAccessPoint(ctx, &efs.CreateAccessPointInput{
+		ClientToken:  awssdk.String(name + "-" + nonce),
+		FileSystemId: awssdk.String(rc.config.FileSystemID),
+		PosixUser: &efstypes.PosixUser{
+			Uid: awssdk.Int64(workspaceUID),
+			Gid: awssdk.Int64(workspaceUID),
+		},
+		RootDirectory: &efstypes.RootDirectory{
+			Path: awssdk.String(rootPath),
+			CreationInfo: &efstypes.CreationInfo{
+				OwnerUid:    awssdk.Int64(workspaceUID),
+				OwnerGid:    awssdk.Int64(workspaceUID),
+				Permissions: awssdk.String("0755"),
+			},
+		},
+		Tags: []efstypes.Tag{
+			{Key: awssdk.String("Name"), Value: awssdk.String(name)},
+			{Key: awssdk.String("managed-by"), Value: awssdk.String("dev8-agent")},
+			{Key: awssdk.String("dev8.quota-gb"), Value: awssdk.String(strconv.Itoa(int(quotaGB)))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create access point: %w", err)
+	}
+
+	log.Printf("☁️  [aws] Created EFS access point %s at %s on %s", name, rootPath, rc.config.FileSystemID)
+	return nil
+}
+
+// VolumeExists checks whether the workspace EFS access point exists
+func (p *FargateProvider) VolumeExists(ctx context.Context, region, name string) (bool, error) {
+	rc, err := p.region(region)
+	if err != nil {
+		return false, err
+	}
+
+	accessPoint, err := p.findAccessPoint(ctx, rc, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to check access point existence: %w", err)
+	}
+	return accessPoint != nil, nil
+}
+
+// DeleteVolume deletes the workspace EFS access point.
+// Files under its root directory stay on the shared file system until the
+// file system's lifecycle policy (or an operator) removes them; no later
+// access point is rooted there, so they are never handed to another workspace.
+func (p *FargateProvider) DeleteVolume(ctx context.Context, region, name string) error {
+	rc, err := p.region(region)
+	if err != nil {
+		return err
+	}
+
+	accessPoint, err := p.findAccessPoint(ctx, rc, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete access point: %w", err)
+	}
+	if accessPoint == nil {
+		return fmt.Errorf("failed to delete access point: %s not found", name)
+	}
+
+	if _, err := rc.efs.DeleteAccessPoint(ctx, &efs.DeleteAccessPointInput{AccessPointId: accessPoint.AccessPointId}); err != nil {
+		return fmt.Errorf("failed to delete access point: %w", err)
+	}
+	return nil
+}
+
+// ListVolumes lists the workspace access points on the region's file system.
+// EFS does not report when an access point was created, so CreatedAt is zero.
+func (p *FargateProvider) ListVolumes(ctx context.Context, region string) ([]provider.Volume, error) {
+	rc, err := p.region(region)
+	if err != nil {
+		return nil, err
+	}
+
+	accessPoints, err := p.listAccessPoints(ctx, rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access points: %w", err)
+	}
+
+	var volumes []provider.Volume
+	for _, accessPoint := range accessPoints {
+		name := awssdk.ToString(accessPoint.Name)
+		if !strings.HasPrefix(name, provider.VolumePrefix) {
+			continue
+		}
+		volumes = append(volumes, provider.Volume{Name: name, Region: region})
+	}
+	return volumes, nil
+}
+
+// CreateRuntime registers a task definition for the workspace and runs it on Fargate
+func (p *FargateProvider) CreateRuntime(ctx context.Context, region, name string, spec provider.ContainerGroupSpec) error {
+	rc, err := p.region(region)
+	if err != nil {
+		return err
+	}
+
+	cpu, memory, err := fargateSize(spec.CPUCores, spec.MemoryGB)
+	if err != nil {
+		return err
+	}
+
+	var accessPointID *string
+	if spec.FileShareName != "" {
+		accessPoint, err := p.findAccessPoint(ctx, rc, spec.FileShareName)
+		if err != nil {
+			return fmt.Errorf("failed to look up access point: %w", err)
+		}
+		if accessPoint == nil {
+			return fmt.Errorf("access point %s not found", spec.FileShareName)
+		}
+		accessPointID = accessPoint.AccessPointId
+	}
+
+	taskDefinition, err := rc.ecs.RegisterTaskDefinition(ctx, p.taskDefinitionInput(rc, name, spec, cpu, memory, accessPointID))
+	if err != nil {
+		return fmt.Errorf("failed to register task definition: %w", err)
+	}
+	taskDefinitionARN := taskDefinition.TaskDefinition.TaskDefinitionArn
+
+	// Secure values go in the RunTask overrides so they are not stored in the task definition
+	var secureEnv []ecstypes.KeyValuePair
+	for _, envVar := range spec.EnvVars() {
+		if envVar.Secure {
+			secureEnv = append(secureEnv, ecstypes.KeyValuePair{Name: awssdk.String(envVar.Name), Value: awssdk.String(envVar.Value)})
+		}
+	}
+
+	output, err := rc.ecs.RunTask(ctx, &ecs.RunTaskInput{
+		Cluster:        awssdk.String(rc.config.Cluster),
+		TaskDefinition: taskDefinitionARN,
+		LaunchType:     ecstypes.LaunchTypeFargate,
+		Count:          awssdk.Int32(1),
+		NetworkConfiguration: &ecstypes.NetworkConfiguration{
+			AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{
+				Subnets:        rc.config.Subnets,
+				SecurityGroups: rc.config.SecurityGroups,
+				AssignPublicIp: ecstypes.AssignPublicIpEnabled,
+			},
+		},
+		Overrides: &ecstypes.TaskOverride{
+			ContainerOverrides: []ecstypes.ContainerOverride{
+				{Name: awssdk.String(containerName), Environment: secureEnv},
+			},
+		},
+		Tags:                 ecsTags(spec.Labels()),
+		EnableECSManagedTags: true,
+	})
+	if err != nil {
+		p.deregister(ctx, rc, taskDefinitionARN)
+		return fmt.Errorf("failed to run task: %w", err)
+	}
+	if len(output.Failures) > 0 {
+		p.deregister(ctx, rc, taskDefinitionARN)
+		failure := output.Failures[0]
+		return fmt.Errorf("failed to run task: %s (%s)", awssdk.ToString(failure.Reason), awssdk.ToString(failure.Detail))
+	}
+
+	log.Printf("☁️  [aws] Started Fargate task %s in %s (%d CPU units, %d MiB)", name, rc.config.Cluster, cpu, memory)
+	return nil
+}
+
+// GetRuntime returns the running workspace task and its public address
+func (p *FargateProvider) GetRuntime(ctx context.Context, region, name string) (*provider.Runtime, error) {
+	rc, err := p.region(region)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := p.findTasks(ctx, rc, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, provider.ErrRuntimeNotFound
+	}
+
+	runtime := p.taskToRuntime(ctx, rc, &tasks[0], region)
+	return &runtime, nil
+}
+
+// DeleteRuntime stops the workspace task and deregisters its task definition; the access point is kept
+func (p *FargateProvider) DeleteRuntime(ctx context.Context, region, name string) error {
+	rc, err := p.region(region)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := p.findTasks(ctx, rc, name)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return provider.ErrRuntimeNotFound
+	}
+
+	for _, task := range tasks {
+		_, err := rc.ecs.StopTask(ctx, &ecs.StopTaskInput{
+			Cluster: awssdk.String(rc.config.Cluster),
+			Task:    task.TaskArn,
+			Reason:  awssdk.String("Stopped by dev8-agent"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to stop task: %w", err)
+		}
+		p.deregister(ctx, rc, task.TaskDefinitionArn)
+	}
+
+	return nil
+}
+
+// ListRuntimes lists running tasks tagged managed-by=dev8-agent in the region's cluster
+func (p *FargateProvider) ListRuntimes(ctx context.Context, region string) ([]provider.Runtime, error) {
+	rc, err := p.region(region)
+	if err != nil {
+		return nil, err
+	}
+
+	var taskARNs []string
+	paginator := ecs.NewListTasksPaginator(rc.ecs, &ecs.ListTasksInput{
+		Cluster:       awssdk.String(rc.config.Cluster),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tasks: %w", err)
+		}
+		taskARNs = append(taskARNs, page.TaskArns...)
+	}
+
+	tasks, err := p.describeTasks(ctx, rc, taskARNs)
+	if err != nil {
+		return nil, err
+	}
+
+	var runtimes []provider.Runtime
+	for i := range tasks {
+		if tagValue(tasks[i].Tags, "managed-by") != "dev8-agent" {
+			continue
+		}
+		runtimes = append(runtimes, p.taskToRuntime(ctx, rc, &tasks[i], region))
+	}
+	return runtimes, nil
+}
+
+func (p *FargateProvider) region(region string) (*regionClients, error) {
+	rc, ok := p.regions[region]
+	if !ok {
+		return nil, fmt.Errorf("AWS region %s is not configured", region)
+	}
+	return rc, nil
+}
+
+// taskDefinitionInput builds a one-container Fargate task definition for the workspace
+func (p *FargateProvider) taskDefinitionInput(rc *regionClients, name string, spec provider.ContainerGroupSpec, cpu, memory int, accessPointID *string) *ecs.RegisterTaskDefinitionInput {
+	var env []ecstypes.KeyValuePair
+	for _, envVar := range spec.EnvVars() {
+		if !envVar.Secure {
+			env = append(env, ecstypes.KeyValuePair{Name: awssdk.String(envVar.Name), Value: awssdk.String(envVar.Value)})
+		}
+	}
+
+	var portMappings []ecstypes.PortMapping
+	for _, port := range spec.Ports() {
+		portMappings = append(portMappings, ecstypes.PortMapping{
+			ContainerPort: awssdk.Int32(int32(port)),
+			Protocol:      ecstypes.TransportProtocolTcp,
+		})
+	}
+
+	container := ecstypes.ContainerDefinition{
+		Name:         awssdk.String(containerName),
+		Image:        awssdk.String(spec.Image),
+		Essential:    awssdk.Bool(true),
+		Environment:  env,
+		PortMappings: portMappings,
+	}
+
+	input := &ecs.RegisterTaskDefinitionInput{
+		Family:                  awssdk.String(name),
+		RequiresCompatibilities: []ecstypes.Compatibility{ecstypes.CompatibilityFargate},
+		NetworkMode:             ecstypes.NetworkModeAwsvpc,
+		Cpu:                     awssdk.String(strconv.Itoa(cpu)),
+		Memory:                  awssdk.String(strconv.Itoa(memory)),
+		ExecutionRoleArn:        awssdk.String(p.config.ExecutionRoleARN),
+		Tags:                    ecsTags(spec.Labels()),
+	}
+	if p.config.TaskRoleARN != "" {
+		input.TaskRoleArn = awssdk.String(p.config.TaskRoleARN)
+	}
+
+	if accessPointID != nil {
+		// Single volume: Home directory (/home/dev8) - stores everything
+		input.Volumes = []ecstypes.Volume{
+			{
+				Name: awssdk.String("home"),
+				EfsVolumeConfiguration: &ecstypes.EFSVolumeConfiguration{
+					FileSystemId:      awssdk.String(rc.config.FileSystemID),
+					TransitEncryption: ecstypes.EFSTransitEncryptionEnabled,
+					AuthorizationConfig: &ecstypes.EFSAuthorizationConfig{
+						AccessPointId: accessPointID,
+					},
+				},
+			},
+		}
+		container.MountPoints = []ecstypes.MountPoint{
+			{SourceVolume: awssdk.String("home"), ContainerPath: awssdk.String("/home/dev8")},
+		}
+	}
+
+	input.ContainerDefinitions = []ecstypes.ContainerDefinition{container}
+	return input
+}
+
+// findAccessPoint returns the access point named name on the region's file system, or nil
+func (p *FargateProvider) findAccessPoint(ctx context.Context, rc *regionClients, name string) (*efstypes.AccessPointDescription, error) {
+	accessPoints, err := p.listAccessPoints(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range accessPoints {
+		if awssdk.ToString(accessPoints[i].Name) == name {
+			return &accessPoints[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// listAccessPoints returns the live access points on the region's file system
+func (p *FargateProvider) listAccessPoints(ctx context.Context, rc *regionClients) ([]efstypes.AccessPointDescription, error) {
+	var accessPoints []efstypes.AccessPointDescription
+	paginator := efs.NewDescribeAccessPointsPaginator(rc.efs, &efs.DescribeAccessPointsInput{
+		FileSystemId: awssdk.String(rc.config.FileSystemID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, accessPoint := range page.AccessPoints {
+			if accessPoint.LifeCycleState == efstypes.LifeCycleStateDeleting || accessPoint.LifeCycleState == efstypes.LifeCycleStateDeleted {
+				continue
+			}
+			accessPoints = append(accessPoints, accessPoint)
+		}
+	}
+	return accessPoints, nil
+}
+
+// findTasks returns the running tasks launched from the workspace's task definition family
+func (p *FargateProvider) findTasks(ctx context.Context, rc *regionClients, name string) ([]ecstypes.Task, error) {
+	list, err := rc.ecs.ListTasks(ctx, &ecs.ListTasksInput{
+		Cluster:       awssdk.String(rc.config.Cluster),
+		Family:        awssdk.String(name),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	return p.describeTasks(ctx, rc, list.TaskArns)
+}
+
+// describeTasks describes tasks (with tags) in batches of 100, the DescribeTasks limit
+func (p *FargateProvider) describeTasks(ctx context.Context, rc *regionClients, taskARNs []string) ([]ecstypes.Task, error) {
+	var tasks []ecstypes.Task
+	for start := 0; start < len(taskARNs); start += 100 {
+		end := start + 100
+		if end > len(taskARNs) {
+			end = len(taskARNs)
+		}
+
+		output, err := rc.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: awssdk.String(rc.config.Cluster),
+			Tasks:   taskARNs[start:end],
+			Include: []ecstypes.TaskField{ecstypes.TaskFieldTags},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe tasks: %w", err)
+		}
+		tasks = append(tasks, output.Tasks...)
+	}
+	return tasks, nil
+}
+
+// deregister removes a workspace task definition revision, logging failures
+func (p *FargateProvider) deregister(ctx context.Context, rc *regionClients, taskDefinitionARN *string) {
+	if taskDefinitionARN == nil {
+		return
+	}
+	if _, err := rc.ecs.DeregisterTaskDefinition(ctx, &ecs.DeregisterTaskDefinitionInput{TaskDefinition: taskDefinitionARN}); err != nil {
+		log.Printf("Warning: failed to deregister task definition %s: %v", awssdk.ToString(taskDefinitionARN), err)
+	}
+}
+
+// taskToRuntime converts an ECS task into a provider-neutral runtime, resolving its public address
+func (p *FargateProvider) taskToRuntime(ctx context.Context, rc *regionClients, task *ecstypes.Task, region string) provider.Runtime {
+	runtime := provider.Runtime{
+		Name:          strings.TrimPrefix(awssdk.ToString(task.Group), "family:"),
+		Region:        region,
+		ResourceGroup: rc.config.Cluster,
+		State:         mapTaskState(awssdk.ToString(task.LastStatus), awssdk.ToString(task.DesiredStatus), task.StopCode),
+		Tags:          make(map[string]string),
+	}
+
+	for _, tag := range task.Tags {
+		runtime.Tags[awssdk.ToString(tag.Key)] = awssdk.ToString(tag.Value)
+	}
+	runtime.EnvironmentID = runtime.Tags["environment"]
+	runtime.UserID = runtime.Tags["userId"]
+
+	var eniID string
+	for _, attachment := range task.Attachments {
+		if awssdk.ToString(attachment.Type) != "ElasticNetworkInterface" {
+			continue
+		}
+		for _, detail := range attachment.Details {
+			switch awssdk.ToString(detail.Name) {
+			case "networkInterfaceId":
+				eniID = awssdk.ToString(detail.Value)
+			case "privateIPv4Address":
+				runtime.IPAddress = awssdk.ToString(detail.Value)
+			}
+		}
+	}
+
+	// The public IP lives on the task's ENI and is only known once it is attached
+	if eniID != "" {
+		output, err := rc.ec2.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{eniID}})
+		if err != nil {
+			log.Printf("Warning: failed to describe network interface %s: %v", eniID, err)
+		} else if len(output.NetworkInterfaces) > 0 && output.NetworkInterfaces[0].Association != nil {
+			association := output.NetworkInterfaces[0].Association
+			if ip := awssdk.ToString(association.PublicIp); ip != "" {
+				runtime.IPAddress = ip
+				runtime.FQDN = ip
+			}
+			if dns := awssdk.ToString(association.PublicDnsName); dns != "" {
+				runtime.FQDN = dns
+			}
+		}
+	}
+
+	return runtime
+}
+
+// mapTaskState maps ECS task statuses to a runtime state
+func mapTaskState(lastStatus, desiredStatus string, stopCode ecstypes.TaskStopCode) provider.RuntimeState {
+	if desiredStatus == "STOPPED" && stopCode == ecstypes.TaskStopCodeTaskFailedToStart {
+		return provider.StateFailed
+	}
+
+	switch lastStatus {
+	case "PROVISIONING", "PENDING", "ACTIVATING":
+		return provider.StatePending
+	case "RUNNING":
+		if desiredStatus == "STOPPED" {
+			return provider.StateStopped
+		}
+		return provider.StateRunning
+	case "DEACTIVATING", "STOPPING", "DEPROVISIONING", "STOPPED", "DELETED":
+		return provider.StateStopped
+	default:
+		return provider.StateUnknown
+	}
+}
+
+// newNonce returns a random suffix that makes an access point's root
+// directory and client token unique to one CreateVolume call
+func newNonce() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ecsTags converts labels into ECS resource tags
+func ecsTags(labels map[string]string) []ecstypes.Tag {
+	tags := make([]ecstypes.Tag, 0, len(labels))
+	for key, value := range labels {
+		tags = append(tags, ecstypes.Tag{Key: awssdk.String(key), Value: awssdk.String(value)})
+	}
+	return tags
+}
+
+func tagValue(tags []ecstypes.Tag, key string) string {
+	for _, tag := range tags {
+		if awssdk.ToString(tag.Key) == key {
+			return awssdk.ToString(tag.Value)
+		}
+	}
+	return ""
+}
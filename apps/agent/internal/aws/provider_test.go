@@ -0,0 +1,480 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// fakeTask is a task tracked by the stand-in
+type fakeTask struct {
+	arn               string
+	family            string
+	taskDefinitionARN string
+	lastStatus        string
+	desiredStatus     string
+	tags              []map[string]string
+	overrides         map[string]interface{}
+}
+
+// fakeAWS is a minimal local stand-in for the ECS (JSON 1.1), EFS (REST JSON)
+// and EC2 (query) APIs used by the Fargate provider
+type fakeAWS struct {
+	mu              sync.Mutex
+	accessPoints    map[string]map[string]interface{}
+	taskDefinitions map[string]map[string]interface{}
+	tasks           map[string]*fakeTask
+	deregistered    []string
+	nextID          int
+}
+
+func newFakeAWS() *fakeAWS {
+	return &fakeAWS{
+		accessPoints:    make(map[string]map[string]interface{}),
+		taskDefinitions: make(map[string]map[string]interface{}),
+		tasks:           make(map[string]*fakeTask),
+	}
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Header.Get("X-Amz-Target") != "":
+		f.serveECS(w, r)
+	case strings.HasPrefix(r.URL.Path, "/2015-02-01/access-points"):
+		f.serveEFS(w, r)
+	default:
+		f.serveEC2(w, r)
+	}
+}
+
+func (f *fakeAWS) serveECS(w http.ResponseWriter, r *http.Request) {
+	var input map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&input)
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AmazonEC2ContainerServiceV20141113.")
+
+	switch action {
+	case "RegisterTaskDefinition":
+		f.nextID++
+		arn := fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:task-definition/%s:%d", input["family"], f.nextID)
+		f.taskDefinitions[arn] = input
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"taskDefinition": map[string]interface{}{"taskDefinitionArn": arn, "family": input["family"]},
+		})
+	case "DeregisterTaskDefinition":
+		f.deregistered = append(f.deregistered, input["taskDefinition"].(string))
+		w.Write([]byte(`{}`))
+	case "RunTask":
+		taskDefinitionARN := input["taskDefinition"].(string)
+		family := f.taskDefinitions[taskDefinitionARN]["family"].(string)
+		f.nextID++
+		task := &fakeTask{
+			arn:               fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:task/dev8/%d", f.nextID),
+			family:            family,
+			taskDefinitionARN: taskDefinitionARN,
+			lastStatus:        "RUNNING",
+			desiredStatus:     "RUNNING",
+			overrides:         input["overrides"].(map[string]interface{}),
+		}
+		for _, tag := range input["tags"].([]interface{}) {
+			t := tag.(map[string]interface{})
+			task.tags = append(task.tags, map[string]string{"key": t["key"].(string), "value": t["value"].(string)})
+		}
+		f.tasks[task.arn] = task
+		json.NewEncoder(w).Encode(map[string]interface{}{"tasks": []interface{}{f.taskJSON(task)}})
+	case "ListTasks":
+		arns := []string{}
+		for arn, task := range f.tasks {
+			if task.desiredStatus != "RUNNING" {
+				continue
+			}
+			if family, ok := input["family"]; ok && family != task.family {
+				continue
+			}
+			arns = append(arns, arn)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"taskArns": arns})
+	case "DescribeTasks":
+		tasks := []interface{}{}
+		for _, arn := range input["tasks"].([]interface{}) {
+			if task, ok := f.tasks[arn.(string)]; ok {
+				tasks = append(tasks, f.taskJSON(task))
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tasks": tasks})
+	case "StopTask":
+		task := f.tasks[input["task"].(string)]
+		task.desiredStatus = "STOPPED"
+		task.lastStatus = "STOPPED"
+		json.NewEncoder(w).Encode(map[string]interface{}{"task": f.taskJSON(task)})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"InvalidParameterException","message":"unexpected action ` + action + `"}`))
+	}
+}
+
+func (f *fakeAWS) taskJSON(task *fakeTask) map[string]interface{} {
+	return map[string]interface{}{
+		"taskArn":           task.arn,
+		"group":             "family:" + task.family,
+		"taskDefinitionArn": task.taskDefinitionARN,
+		"lastStatus":        task.lastStatus,
+		"desiredStatus":     task.desiredStatus,
+		"tags":              task.tags,
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"type": "ElasticNetworkInterface",
+				"details": []interface{}{
+					map[string]string{"name": "networkInterfaceId", "value": "eni-0abc"},
+					map[string]string{"name": "privateIPv4Address", "value": "172.31.5.10"},
+				},
+			},
+		},
+	}
+}
+
+func (f *fakeAWS) serveEFS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodPost:
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+
+		f.nextID++
+		id := fmt.Sprintf("fsap-%04d", f.nextID)
+		var name string
+		for _, tag := range input["Tags"].([]interface{}) {
+			if t := tag.(map[string]interface{}); t["Key"] == "Name" {
+				name = t["Value"].(string)
+			}
+		}
+		accessPoint := map[string]interface{}{
+			"AccessPointId":  id,
+			"FileSystemId":   input["FileSystemId"],
+			"Name":           name,
+			"LifeCycleState": "available",
+			"RootDirectory":  input["RootDirectory"],
+		}
+		f.accessPoints[id] = accessPoint
+		json.NewEncoder(w).Encode(accessPoint)
+	case http.MethodGet:
+		accessPoints := []interface{}{}
+		for _, accessPoint := range f.accessPoints {
+			if accessPoint["FileSystemId"] == r.URL.Query().Get("FileSystemId") {
+				accessPoints = append(accessPoints, accessPoint)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"AccessPoints": accessPoints})
+	case http.MethodDelete:
+		id := strings.TrimPrefix(r.URL.Path, "/2015-02-01/access-points/")
+		if _, ok := f.accessPoints[id]; !ok {
+			w.Header().Set("X-Amzn-ErrorType", "AccessPointNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"ErrorCode":"AccessPointNotFound","Message":"not found"}`))
+			return
+		}
+		delete(f.accessPoints, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeAWS) serveEC2(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if r.Form.Get("Action") != "DescribeNetworkInterfaces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<DescribeNetworkInterfacesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>req-1</requestId>
+  <networkInterfaceSet>
+    <item>
+      <networkInterfaceId>%s</networkInterfaceId>
+      <privateIpAddress>172.31.5.10</privateIpAddress>
+      <association>
+        <publicIp>3.91.10.20</publicIp>
+        <publicDnsName>ec2-3-91-10-20.compute-1.amazonaws.com</publicDnsName>
+      </association>
+    </item>
+  </networkInterfaceSet>
+</DescribeNetworkInterfacesResponse>`, r.Form.Get("NetworkInterfaceId.1"))
+}
+
+func newTestProvider(t *testing.T) (*FargateProvider, *fakeAWS) {
+	t.Helper()
+
+	stub := newFakeAWS()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	cfg := config.AWSConfig{
+		ExecutionRoleARN: "arn:aws:iam::123456789012:role/ecsTaskExecutionRole",
+		Endpoint:         server.URL,
+		Regions: []config.AWSRegionConfig{
+			{Name: "us-east-1", Cluster: "dev8", Subnets: []string{"subnet-a", "subnet-b"}, SecurityGroups: []string{"sg-1"}, FileSystemID: "fs-123"},
+		},
+	}
+	awsCfg := awssdk.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+
+	return NewFargateProvider(cfg, awsCfg), stub
+}
+
+func testSpec() provider.ContainerGroupSpec {
+	return provider.ContainerGroupSpec{
+		Image:              "vaibhavsing/dev8-workspace:latest",
+		CPUCores:           2,
+		MemoryGB:           4,
+		FileShareName:      "fs-ws-1",
+		EnvironmentID:      "ws-1",
+		UserID:             "user-1",
+		CodeServerPassword: "secret",
+		GitUserName:        "dev8",
+	}
+}
+
+func TestFargateSize(t *testing.T) {
+	tests := []struct {
+		cpuCores   int
+		memoryGB   int
+		wantCPU    int
+		wantMemory int
+		wantErr    bool
+	}{
+		{cpuCores: 1, memoryGB: 1, wantCPU: 1024, wantMemory: 2048},
+		{cpuCores: 2, memoryGB: 4, wantCPU: 2048, wantMemory: 4096},
+		{cpuCores: 2, memoryGB: 20, wantCPU: 4096, wantMemory: 20480},
+		{cpuCores: 3, memoryGB: 4, wantCPU: 4096, wantMemory: 8192},
+		{cpuCores: 8, memoryGB: 18, wantCPU: 8192, wantMemory: 20480},
+		{cpuCores: 16, memoryGB: 33, wantCPU: 16384, wantMemory: 40960},
+		{cpuCores: 0, memoryGB: 0, wantCPU: 1024, wantMemory: 2048},
+		{cpuCores: 32, memoryGB: 64, wantErr: true},
+		{cpuCores: 4, memoryGB: 128, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dvCPU-%dGB", tt.cpuCores, tt.memoryGB), func(t *testing.T) {
+			cpu, memory, err := fargateSize(tt.cpuCores, tt.memoryGB)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fargateSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cpu != tt.wantCPU || memory != tt.wantMemory {
+				t.Errorf("fargateSize() = %d/%d, want %d/%d", cpu, memory, tt.wantCPU, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestMapTaskState(t *testing.T) {
+	tests := []struct {
+		lastStatus    string
+		desiredStatus string
+		stopCode      ecstypes.TaskStopCode
+		want          provider.RuntimeState
+	}{
+		{"PROVISIONING", "RUNNING", "", provider.StatePending},
+		{"RUNNING", "RUNNING", "", provider.StateRunning},
+		{"RUNNING", "STOPPED", ecstypes.TaskStopCodeUserInitiated, provider.StateStopped},
+		{"STOPPED", "STOPPED", ecstypes.TaskStopCodeTaskFailedToStart, provider.StateFailed},
+		{"STOPPED", "STOPPED", ecstypes.TaskStopCodeEssentialContainerExited, provider.StateStopped},
+		{"", "", "", provider.StateUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := mapTaskState(tt.lastStatus, tt.desiredStatus, tt.stopCode); got != tt.want {
+			t.Errorf("mapTaskState(%q, %q, %q) = %v, want %v", tt.lastStatus, tt.desiredStatus, tt.stopCode, got, tt.want)
+		}
+	}
+}
+
+func TestFargateProvider_HasRegion(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	if !p.HasRegion("us-east-1") {
+		t.Error("HasRegion(us-east-1) = false, want true")
+	}
+	if p.HasRegion("eastus") {
+		t.Error("HasRegion(eastus) = true, want false")
+	}
+}
+
+func TestFargateProvider_Volumes(t *testing.T) {
+	p, stub := newTestProvider(t)
+	ctx := context.Background()
+
+	exists, err := p.VolumeExists(ctx, "us-east-1", "fs-ws-1")
+	if err != nil || exists {
+		t.Fatalf("VolumeExists() = %v, %v, want false", exists, err)
+	}
+
+	if err := p.CreateVolume(ctx, "us-east-1", "fs-ws-1", 25); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := p.CreateVolume(ctx, "us-east-1", "fs-ws-1", 25); err == nil {
+		t.Error("CreateVolume() should fail for an existing access point")
+	}
+
+	firstRoot := rootPaths(stub)
+	if len(firstRoot) != 1 || !strings.HasPrefix(firstRoot[0], "/fs-ws-1-") {
+		t.Errorf("RootDirectory.Path = %v, want one /fs-ws-1-<nonce>", firstRoot)
+	}
+
+	exists, err = p.VolumeExists(ctx, "us-east-1", "fs-ws-1")
+	if err != nil || !exists {
+		t.Fatalf("VolumeExists() = %v, %v, want true", exists, err)
+	}
+
+	volumes, err := p.ListVolumes(ctx, "us-east-1")
+	if err != nil || len(volumes) != 1 || volumes[0].Name != "fs-ws-1" {
+		t.Errorf("ListVolumes() = %v, %v, want [fs-ws-1]", volumes, err)
+	}
+
+	if err := p.DeleteVolume(ctx, "us-east-1", "fs-ws-1"); err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+	if err := p.DeleteVolume(ctx, "us-east-1", "fs-ws-1"); err == nil {
+		t.Error("DeleteVolume() should fail for a missing access point")
+	}
+
+	// Re-creating the workspace must not remount the previous directory
+	if err := p.CreateVolume(ctx, "us-east-1", "fs-ws-1", 25); err != nil {
+		t.Fatalf("CreateVolume() after delete error = %v", err)
+	}
+	secondRoot := rootPaths(stub)
+	if len(secondRoot) != 1 || len(firstRoot) != 1 || secondRoot[0] == firstRoot[0] {
+		t.Errorf("RootDirectory.Path after re-create = %v, want a path other than %v", secondRoot, firstRoot)
+	}
+}
+
+// rootPaths returns the root directory of every access point in the stub
+func rootPaths(stub *fakeAWS) []string {
+	var paths []string
+	for _, accessPoint := range stub.accessPoints {
+		root := accessPoint["RootDirectory"].(map[string]interface{})
+		paths = append(paths, root["Path"].(string))
+	}
+	return paths
+}
+
+func TestFargateProvider_RuntimeLifecycle(t *testing.T) {
+	p, stub := newTestProvider(t)
+	ctx := context.Background()
+
+	if err := p.CreateRuntime(ctx, "us-east-1", "aci-ws-1", testSpec()); err == nil {
+		t.Fatal("CreateRuntime() should fail without the workspace access point")
+	}
+
+	if err := p.CreateVolume(ctx, "us-east-1", "fs-ws-1", 25); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := p.CreateRuntime(ctx, "us-east-1", "aci-ws-1", testSpec()); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	if len(stub.taskDefinitions) != 1 {
+		t.Fatalf("task definitions = %d, want 1", len(stub.taskDefinitions))
+	}
+	for _, taskDefinition := range stub.taskDefinitions {
+		if taskDefinition["cpu"] != "2048" || taskDefinition["memory"] != "4096" {
+			t.Errorf("task size = %v/%v, want 2048/4096", taskDefinition["cpu"], taskDefinition["memory"])
+		}
+		if taskDefinition["networkMode"] != "awsvpc" {
+			t.Errorf("networkMode = %v, want awsvpc", taskDefinition["networkMode"])
+		}
+
+		volume := taskDefinition["volumes"].([]interface{})[0].(map[string]interface{})
+		efsConfig := volume["efsVolumeConfiguration"].(map[string]interface{})
+		authorization := efsConfig["authorizationConfig"].(map[string]interface{})
+		if efsConfig["fileSystemId"] != "fs-123" || !strings.HasPrefix(authorization["accessPointId"].(string), "fsap-") {
+			t.Errorf("efsVolumeConfiguration = %v, want fs-123 with the workspace access point", efsConfig)
+		}
+
+		container := taskDefinition["containerDefinitions"].([]interface{})[0].(map[string]interface{})
+		body, _ := json.Marshal(container["environment"])
+		if strings.Contains(string(body), "CODE_SERVER_PASSWORD") {
+			t.Error("secure variables must not be stored in the task definition")
+		}
+		if !strings.Contains(string(body), "GIT_USER_NAME") {
+			t.Errorf("environment = %s, want GIT_USER_NAME", body)
+		}
+		if len(container["portMappings"].([]interface{})) != 3 {
+			t.Errorf("portMappings = %v, want ide/ssh/supervisor", container["portMappings"])
+		}
+	}
+
+	for _, task := range stub.tasks {
+		body, _ := json.Marshal(task.overrides)
+		if !strings.Contains(string(body), "CODE_SERVER_PASSWORD") {
+			t.Errorf("overrides = %s, want secure variables", body)
+		}
+	}
+
+	runtime, err := p.GetRuntime(ctx, "us-east-1", "aci-ws-1")
+	if err != nil {
+		t.Fatalf("GetRuntime() error = %v", err)
+	}
+	if runtime.State != provider.StateRunning {
+		t.Errorf("State = %v, want %v", runtime.State, provider.StateRunning)
+	}
+	if runtime.FQDN != "ec2-3-91-10-20.compute-1.amazonaws.com" || runtime.IPAddress != "3.91.10.20" {
+		t.Errorf("FQDN/IPAddress = %v/%v, want the ENI public address", runtime.FQDN, runtime.IPAddress)
+	}
+	if runtime.Name != "aci-ws-1" || runtime.ResourceGroup != "dev8" {
+		t.Errorf("Name/ResourceGroup = %v/%v", runtime.Name, runtime.ResourceGroup)
+	}
+	if runtime.EnvironmentID != "ws-1" || runtime.UserID != "user-1" {
+		t.Errorf("EnvironmentID/UserID = %v/%v", runtime.EnvironmentID, runtime.UserID)
+	}
+
+	runtimes, err := p.ListRuntimes(ctx, "us-east-1")
+	if err != nil || len(runtimes) != 1 {
+		t.Fatalf("ListRuntimes() = %v, %v, want 1 runtime", runtimes, err)
+	}
+
+	if err := p.DeleteRuntime(ctx, "us-east-1", "aci-ws-1"); err != nil {
+		t.Fatalf("DeleteRuntime() error = %v", err)
+	}
+	if len(stub.deregistered) != 1 {
+		t.Errorf("deregistered task definitions = %v, want 1", stub.deregistered)
+	}
+	if _, err := p.GetRuntime(ctx, "us-east-1", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Errorf("GetRuntime() after delete error = %v, want ErrRuntimeNotFound", err)
+	}
+	if err := p.DeleteRuntime(ctx, "us-east-1", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Errorf("DeleteRuntime() twice error = %v, want ErrRuntimeNotFound", err)
+	}
+}
+
+func TestFargateProvider_UnsupportedSize(t *testing.T) {
+	p, stub := newTestProvider(t)
+
+	spec := testSpec()
+	spec.CPUCores = 32
+	if err := p.CreateRuntime(context.Background(), "us-east-1", "aci-ws-1", spec); err == nil {
+		t.Fatal("CreateRuntime() should reject sizes Fargate cannot run")
+	}
+	if len(stub.taskDefinitions) != 0 {
+		t.Error("no task definition should be registered for an invalid size")
+	}
+}
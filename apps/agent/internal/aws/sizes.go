@@ -0,0 +1,47 @@
+package aws
+
+import "fmt"
+
+// fargateTier is a Fargate CPU size and the memory range it supports (in MiB)
+type fargateTier struct {
+	cpu       int
+	minMemory int
+	maxMemory int
+	step      int
+}
+
+// fargateTiers lists the valid Fargate task sizes from 1 vCPU upwards
+// (https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task_definition_parameters.html#task_size)
+var fargateTiers = []fargateTier{
+	{cpu: 1024, minMemory: 2048, maxMemory: 8192, step: 1024},
+	{cpu: 2048, minMemory: 4096, maxMemory: 16384, step: 1024},
+	{cpu: 4096, minMemory: 8192, maxMemory: 30720, step: 1024},
+	{cpu: 8192, minMemory: 16384, maxMemory: 61440, step: 4096},
+	{cpu: 16384, minMemory: 32768, maxMemory: 122880, step: 8192},
+}
+
+// fargateSize returns the smallest valid Fargate CPU units / memory (MiB) pair
+// that provides at least the requested cores and memory
+func fargateSize(cpuCores, memoryGB int) (cpu int, memoryMiB int, err error) {
+	if cpuCores < 1 {
+		cpuCores = 1
+	}
+	memory := memoryGB * 1024
+
+	for _, tier := range fargateTiers {
+		if tier.cpu < cpuCores*1024 || memory > tier.maxMemory {
+			continue
+		}
+
+		memoryMiB = memory
+		if memoryMiB < tier.minMemory {
+			memoryMiB = tier.minMemory
+		}
+		if rem := memoryMiB % tier.step; rem != 0 {
+			memoryMiB += tier.step - rem
+		}
+		return tier.cpu, memoryMiB, nil
+	}
+
+	return 0, 0, fmt.Errorf("no Fargate task size fits %d vCPU / %dGB (maximum is 16 vCPU / 120GB)", cpuCores, memoryGB)
+}
@@ -5,10 +5,13 @@ import (
 	"fmt"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance/v2"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
 )
 
 // Client provides Azure service operations
@@ -16,6 +19,8 @@ type Client struct {
 	config     *config.Config
 	credential azcore.TokenCredential
 	aciClients map[string]*armcontainerinstance.ContainerGroupsClient
+	arm        *arm.Client // Resource Manager calls without a generated client (private DNS records)
+	calls      *caller     // Retries, deadlines and per-region circuit breakers
 }
 
 // NewClient creates a new Azure client
@@ -30,10 +35,17 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to create Azure credential: %w", err)
 	}
 
+	armClient, err := arm.NewClient("dev8-agent", "v1.0.0", cred, &arm.ClientOptions{ClientOptions: sdkOptions()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Resource Manager client: %w", err)
+	}
+
 	client := &Client{
 		config:     cfg,
 		credential: cred,
 		aciClients: make(map[string]*armcontainerinstance.ContainerGroupsClient),
+		arm:        armClient,
+		calls:      newCaller(cfg.Azure.Resilience),
 	}
 
 	// Initialize ACI clients for all enabled regions
@@ -57,7 +69,7 @@ func (c *Client) initACIClient(region string) error {
 	client, err := armcontainerinstance.NewContainerGroupsClient(
 		c.config.Azure.SubscriptionID,
 		c.credential,
-		nil,
+		&arm.ClientOptions{ClientOptions: sdkOptions()},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create ACI client: %w", err)
@@ -104,78 +116,42 @@ func (c *Client) CreateContainerGroup(ctx context.Context, region, resourceGroup
 		})
 	}
 
-	// Build environment variables dynamically
-	envVars := []*armcontainerinstance.EnvironmentVariable{
-		// Always required
-		{Name: to.Ptr("WORKSPACE_ID"), Value: to.Ptr(spec.EnvironmentID)},
-		{Name: to.Ptr("USER_ID"), Value: to.Ptr(spec.UserID)},
-		{Name: to.Ptr("WORKSPACE_DIR"), Value: to.Ptr("/home/dev8/workspace")},
-		{Name: to.Ptr("AGENT_BASE_URL"), Value: to.Ptr(spec.AgentBaseURL)},
-		{Name: to.Ptr("AGENT_ENABLED"), Value: to.Ptr("true")},
-		{Name: to.Ptr("MONITOR_INTERVAL"), Value: to.Ptr("30s")},
-		{Name: to.Ptr("LOG_FILE_PATH"), Value: to.Ptr("/var/log/supervisor.log")},
-	}
-
-	// Add optional environment variables only if provided
-	if spec.GitHubToken != "" {
-		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:        to.Ptr("GITHUB_TOKEN"),
-			SecureValue: to.Ptr(spec.GitHubToken),
-		})
-	}
-	if spec.CodeServerPassword != "" {
-		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:        to.Ptr("CODE_SERVER_PASSWORD"),
-			SecureValue: to.Ptr(spec.CodeServerPassword),
-		})
-	}
-	if spec.SSHPublicKey != "" {
-		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:  to.Ptr("SSH_PUBLIC_KEY"),
-			Value: to.Ptr(spec.SSHPublicKey),
-		})
-	}
-	if spec.GitUserName != "" {
-		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:  to.Ptr("GIT_USER_NAME"),
-			Value: to.Ptr(spec.GitUserName),
-		})
-	}
-	if spec.GitUserEmail != "" {
+	// Build environment variables (secrets are passed as SecureValue)
+	var envVars []*armcontainerinstance.EnvironmentVariable
+	for _, envVar := range spec.EnvVars() {
+		if envVar.Secure {
+			envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
+				Name:        to.Ptr(envVar.Name),
+				SecureValue: to.Ptr(envVar.Value),
+			})
+			continue
+		}
 		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:  to.Ptr("GIT_USER_EMAIL"),
-			Value: to.Ptr(spec.GitUserEmail),
+			Name:  to.Ptr(envVar.Name),
+			Value: to.Ptr(envVar.Value),
 		})
 	}
-	if spec.AnthropicAPIKey != "" {
-		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:        to.Ptr("ANTHROPIC_API_KEY"),
-			SecureValue: to.Ptr(spec.AnthropicAPIKey),
-		})
+
+	tags := make(map[string]*string)
+	for key, value := range spec.Labels() {
+		tags[key] = to.Ptr(value)
 	}
-	if spec.OpenAIAPIKey != "" {
-		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:        to.Ptr("OPENAI_API_KEY"),
-			SecureValue: to.Ptr(spec.OpenAIAPIKey),
+
+	// Open every port on both the container and the public IP, so each
+	// connection URL the agent hands out is reachable
+	var containerPorts []*armcontainerinstance.ContainerPort
+	var groupPorts []*armcontainerinstance.Port
+	for _, port := range spec.Ports() {
+		containerPorts = append(containerPorts, &armcontainerinstance.ContainerPort{
+			Port:     to.Ptr(int32(port)),
+			Protocol: to.Ptr(armcontainerinstance.ContainerNetworkProtocolTCP),
 		})
-	}
-	if spec.GeminiAPIKey != "" {
-		envVars = append(envVars, &armcontainerinstance.EnvironmentVariable{
-			Name:        to.Ptr("GEMINI_API_KEY"),
-			SecureValue: to.Ptr(spec.GeminiAPIKey),
+		groupPorts = append(groupPorts, &armcontainerinstance.Port{
+			Port:     to.Ptr(int32(port)),
+			Protocol: to.Ptr(armcontainerinstance.ContainerGroupNetworkProtocolTCP),
 		})
 	}
 
-	// Backup configuration (always enabled)
-	if spec.StorageAccountName != "" {
-		envVars = append(envVars,
-			&armcontainerinstance.EnvironmentVariable{Name: to.Ptr("BACKUP_ENABLED"), Value: to.Ptr("true")},
-			&armcontainerinstance.EnvironmentVariable{Name: to.Ptr("BACKUP_INTERVAL"), Value: to.Ptr("1h")},
-			&armcontainerinstance.EnvironmentVariable{Name: to.Ptr("BACKUP_STORAGE_ACCOUNT"), Value: to.Ptr(spec.StorageAccountName)},
-			&armcontainerinstance.EnvironmentVariable{Name: to.Ptr("BACKUP_CONTAINER"), Value: to.Ptr("backups")},
-		)
-	}
-
 	// Build container group configuration
 	containerGroup := armcontainerinstance.ContainerGroup{
 		Location: to.Ptr(region),
@@ -192,29 +168,24 @@ func (c *Client) CreateContainerGroup(ctx context.Context, region, resourceGroup
 								MemoryInGB: to.Ptr(float64(spec.MemoryGB)),
 							},
 						},
-						Ports: []*armcontainerinstance.ContainerPort{
-							{Port: to.Ptr(int32(8080)), Protocol: to.Ptr(armcontainerinstance.ContainerNetworkProtocolTCP)},
-						},
+						Ports:                containerPorts,
 						VolumeMounts:         volumeMounts,
 						EnvironmentVariables: envVars,
 					},
 				},
 			},
-			IPAddress: &armcontainerinstance.IPAddress{
-				Type: to.Ptr(armcontainerinstance.ContainerGroupIPAddressTypePublic),
-				Ports: []*armcontainerinstance.Port{
-					{Port: to.Ptr(int32(8080)), Protocol: to.Ptr(armcontainerinstance.ContainerGroupNetworkProtocolTCP)},
-				},
-				DNSNameLabel: to.Ptr(spec.DNSNameLabel),
-			},
+			IPAddress:     ipAddress(spec, groupPorts),
 			RestartPolicy: to.Ptr(armcontainerinstance.ContainerGroupRestartPolicyOnFailure),
 			Volumes:       volumes,
 		},
-		Tags: map[string]*string{
-			"environment": to.Ptr(spec.EnvironmentID),
-			"userId":      to.Ptr(spec.UserID),
-			"managed-by":  to.Ptr("dev8-agent"),
-		},
+		Tags: tags,
+	}
+
+	// Private container groups join the delegated subnet
+	if spec.SubnetID != "" {
+		containerGroup.Properties.SubnetIDs = []*armcontainerinstance.ContainerGroupSubnetID{
+			{ID: to.Ptr(spec.SubnetID)},
+		}
 	}
 
 	// Add image registry credentials if username is provided (for private Docker Hub)
@@ -228,19 +199,31 @@ func (c *Client) CreateContainerGroup(ctx context.Context, region, resourceGroup
 		}
 	}
 
-	// Start the container group creation
-	poller, err := client.BeginCreateOrUpdate(ctx, resourceGroup, name, containerGroup, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin container group creation: %w", err)
-	}
+	// Create the container group and wait for the operation to complete
+	return c.calls.callLong(ctx, region, "failed to create container group", func(ctx context.Context) error {
+		poller, err := client.BeginCreateOrUpdate(ctx, resourceGroup, name, containerGroup, nil)
+		if err != nil {
+			return err
+		}
+		_, err = poller.PollUntilDone(ctx, nil)
+		return err
+	})
+}
 
-	// Wait for the operation to complete
-	_, err = poller.PollUntilDone(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create container group: %w", err)
+// ipAddress returns the container group's public IP with its DNS name label,
+// or a private IP in the spec's subnet, which ACI gives no DNS name
+func ipAddress(spec ContainerGroupSpec, ports []*armcontainerinstance.Port) *armcontainerinstance.IPAddress {
+	if spec.SubnetID != "" {
+		return &armcontainerinstance.IPAddress{
+			Type:  to.Ptr(armcontainerinstance.ContainerGroupIPAddressTypePrivate),
+			Ports: ports,
+		}
+	}
+	return &armcontainerinstance.IPAddress{
+		Type:         to.Ptr(armcontainerinstance.ContainerGroupIPAddressTypePublic),
+		Ports:        ports,
+		DNSNameLabel: to.Ptr(spec.DNSNameLabel),
 	}
-
-	return nil
 }
 
 // GetContainerGroup retrieves an ACI container group
@@ -250,32 +233,61 @@ func (c *Client) GetContainerGroup(ctx context.Context, region, resourceGroup, n
 		return nil, err
 	}
 
-	resp, err := client.Get(ctx, resourceGroup, name, nil)
+	var group armcontainerinstance.ContainerGroup
+	err = c.calls.call(ctx, region, "failed to get container group", func(ctx context.Context) error {
+		resp, err := client.Get(ctx, resourceGroup, name, nil)
+		group = resp.ContainerGroup
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get container group: %w", err)
+		return nil, err
 	}
 
-	return &resp.ContainerGroup, nil
+	return &group, nil
 }
 
-// DeleteContainerGroup deletes an ACI container group
-func (c *Client) DeleteContainerGroup(ctx context.Context, region, resourceGroup, name string) error {
+// ListContainerGroups lists the ACI container groups in a resource group
+func (c *Client) ListContainerGroups(ctx context.Context, region, resourceGroup string) ([]*armcontainerinstance.ContainerGroup, error) {
 	client, err := c.GetACIClient(region)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	poller, err := client.BeginDelete(ctx, resourceGroup, name, nil)
+	var groups []*armcontainerinstance.ContainerGroup
+	err = c.calls.call(ctx, region, "failed to list container groups", func(ctx context.Context) error {
+		groups = nil
+		pager := client.NewListByResourceGroupPager(resourceGroup, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, page.Value...)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to begin container group deletion: %w", err)
+		return nil, err
 	}
 
-	_, err = poller.PollUntilDone(ctx, nil)
+	return groups, nil
+}
+
+// DeleteContainerGroup deletes an ACI container group
+func (c *Client) DeleteContainerGroup(ctx context.Context, region, resourceGroup, name string) error {
+	client, err := c.GetACIClient(region)
 	if err != nil {
-		return fmt.Errorf("failed to delete container group: %w", err)
+		return err
 	}
 
-	return nil
+	return c.calls.callLong(ctx, region, "failed to delete container group", func(ctx context.Context) error {
+		poller, err := client.BeginDelete(ctx, resourceGroup, name, nil)
+		if err != nil {
+			return err
+		}
+		_, err = poller.PollUntilDone(ctx, nil)
+		return err
+	})
 }
 
 // StartContainerGroup starts a stopped ACI container group
@@ -299,40 +311,17 @@ func (c *Client) StopContainerGroup(ctx context.Context, region, resourceGroup,
 		return err
 	}
 
-	_, err = client.Stop(ctx, resourceGroup, name, nil)
-	if err != nil {
-		return fmt.Errorf("failed to stop container group: %w", err)
-	}
-
-	return nil
+	return c.calls.call(ctx, region, "failed to stop container group", func(ctx context.Context) error {
+		_, err := client.Stop(ctx, resourceGroup, name, nil)
+		return err
+	})
 }
 
 // ContainerGroupSpec defines the specification for creating a container group
-type ContainerGroupSpec struct {
-	ContainerName      string
-	Image              string
-	CPUCores           int
-	MemoryGB           int
-	DNSNameLabel       string
-	FileShareName      string // Single file share for all persistent data - mounts to /home/dev8 (includes workspace subdirectory)
-	StorageAccountName string
-	StorageAccountKey  string
-	EnvironmentID      string
-	UserID             string
-
-	// Container Registry Credentials (static from Agent config)
-	RegistryServer   string
-	RegistryUsername string
-	RegistryPassword string
-
-	// Dynamic per-workspace values (from API request)
-	AgentBaseURL       string
-	GitHubToken        string
-	GitUserName        string
-	GitUserEmail       string
-	SSHPublicKey       string
-	CodeServerPassword string
-	AnthropicAPIKey    string
-	OpenAIAPIKey       string
-	GeminiAPIKey       string
+type ContainerGroupSpec = provider.ContainerGroupSpec
+
+// sdkOptions disables the SDK's own retries; caller retries every Azure call
+// so that its circuit breakers see each failure
+func sdkOptions() policy.ClientOptions {
+	return policy.ClientOptions{Retry: policy.RetryOptions{MaxRetries: -1}}
 }
@@ -0,0 +1,112 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// Azure error codes that do not follow from the HTTP status alone
+var (
+	// Subscription or regional capacity is exhausted; retrying the same
+	// region does not help until quota is raised or capacity frees up
+	quotaErrorCodes = map[string]bool{
+		"QuotaExceeded":              true,
+		"ContainerGroupQuotaReached": true,
+		"StandardCoresQuotaExceeded": true,
+		"RegionalQuotaExceeded":      true,
+		"SkuNotAvailable":            true,
+	}
+
+	// Rate limits, including storage's 503 ServerBusy
+	throttledErrorCodes = map[string]bool{
+		"TooManyRequests":           true,
+		"ResourceRequestsThrottled": true,
+		"ServerBusy":                true,
+	}
+
+	// Conflicts that clear up on their own once an in-flight operation finishes
+	retryableConflictCodes = map[string]bool{
+		"AnotherOperationInProgress":  true,
+		"ContainerGroupTransitioning": true,
+		"ShareBeingDeleted":           true,
+		"LeaseIdMissing":              true,
+		"LeaseAlreadyPresent":         true,
+	}
+)
+
+// classifyError maps an Azure SDK error onto the agent's error taxonomy as a
+// *models.AppError that wraps err. Context cancellation is passed through so
+// callers still see deadlines.
+func classifyError(action string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		return cloudError(models.CodeCloudAuthFailed, fmt.Sprintf("%s: Azure authentication failed", action), err)
+	}
+
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return cloudError(models.CodeCloudUnavailable, fmt.Sprintf("%s: Azure unreachable: %v", action, netErr), err)
+		}
+		return cloudError(models.CodeInternalServer, fmt.Sprintf("%s: %v", action, err), err)
+	}
+
+	message := fmt.Sprintf("%s: Azure %s", action, describeResponseError(respErr))
+	code, status := respErr.ErrorCode, respErr.StatusCode
+
+	// Quota codes come with 403 or 409, so they are checked before the status
+	switch {
+	case quotaErrorCodes[code] || strings.HasSuffix(code, "QuotaExceeded"):
+		return cloudError(models.CodeCloudQuotaExceeded, message, err)
+	case throttledErrorCodes[code] || status == http.StatusTooManyRequests:
+		return cloudError(models.CodeThrottled, message, err)
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return cloudError(models.CodeCloudAuthFailed, message, err)
+	case status == http.StatusNotFound:
+		return cloudError(models.CodeNotFound, message, err)
+	case status == http.StatusConflict || status == http.StatusPreconditionFailed:
+		appErr := cloudError(models.CodeConflict, message, err)
+		appErr.Retryable = retryableConflictCodes[code]
+		return appErr
+	case status == http.StatusRequestTimeout || status >= http.StatusInternalServerError:
+		return cloudError(models.CodeCloudUnavailable, message, err)
+	}
+	return cloudError(models.CodeInternalServer, message, err)
+}
+
+// describeResponseError summarises a response error without the request and
+// response dump that ResponseError.Error includes
+func describeResponseError(respErr *azcore.ResponseError) string {
+	if respErr.ErrorCode == "" {
+		return fmt.Sprintf("request failed (HTTP %d)", respErr.StatusCode)
+	}
+	return fmt.Sprintf("%s (HTTP %d)", respErr.ErrorCode, respErr.StatusCode)
+}
+
+// cloudError builds the AppError for a classified Azure failure. Throttling
+// and transient failures are retryable.
+func cloudError(code, message string, cause error) *models.AppError {
+	return &models.AppError{
+		Message:   message,
+		Code:      code,
+		Retryable: code == models.CodeThrottled || code == models.CodeCloudUnavailable,
+		Err:       cause,
+	}
+}
@@ -0,0 +1,127 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantCode      string
+		wantRetryable bool
+	}{
+		{
+			name:     "container group quota",
+			err:      &azcore.ResponseError{ErrorCode: "ContainerGroupQuotaReached", StatusCode: http.StatusConflict},
+			wantCode: models.CodeCloudQuotaExceeded,
+		},
+		{
+			name:     "cores quota",
+			err:      &azcore.ResponseError{ErrorCode: "StandardCoresQuotaExceeded", StatusCode: http.StatusForbidden},
+			wantCode: models.CodeCloudQuotaExceeded,
+		},
+		{
+			name:          "throttled by status",
+			err:           &azcore.ResponseError{StatusCode: http.StatusTooManyRequests},
+			wantCode:      models.CodeThrottled,
+			wantRetryable: true,
+		},
+		{
+			name:          "storage server busy",
+			err:           &azcore.ResponseError{ErrorCode: "ServerBusy", StatusCode: http.StatusServiceUnavailable},
+			wantCode:      models.CodeThrottled,
+			wantRetryable: true,
+		},
+		{
+			name:     "authorization failed",
+			err:      &azcore.ResponseError{ErrorCode: "AuthorizationFailed", StatusCode: http.StatusForbidden},
+			wantCode: models.CodeCloudAuthFailed,
+		},
+		{
+			name:     "not found",
+			err:      &azcore.ResponseError{ErrorCode: "ResourceNotFound", StatusCode: http.StatusNotFound},
+			wantCode: models.CodeNotFound,
+		},
+		{
+			name:     "share already exists",
+			err:      &azcore.ResponseError{ErrorCode: "ShareAlreadyExists", StatusCode: http.StatusConflict},
+			wantCode: models.CodeConflict,
+		},
+		{
+			name:          "share being deleted",
+			err:           &azcore.ResponseError{ErrorCode: "ShareBeingDeleted", StatusCode: http.StatusConflict},
+			wantCode:      models.CodeConflict,
+			wantRetryable: true,
+		},
+		{
+			name:          "server error",
+			err:           &azcore.ResponseError{ErrorCode: "InternalServerError", StatusCode: http.StatusInternalServerError},
+			wantCode:      models.CodeCloudUnavailable,
+			wantRetryable: true,
+		},
+		{
+			name:     "bad request",
+			err:      &azcore.ResponseError{ErrorCode: "InvalidParameter", StatusCode: http.StatusBadRequest},
+			wantCode: models.CodeInternalServer,
+		},
+		{
+			name:     "wrapped response error",
+			err:      fmt.Errorf("poll: %w", &azcore.ResponseError{ErrorCode: "QuotaExceeded", StatusCode: http.StatusBadRequest}),
+			wantCode: models.CodeCloudQuotaExceeded,
+		},
+		{
+			name:     "plain error",
+			err:      errors.New("boom"),
+			wantCode: models.CodeInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError("failed to create container group", tt.err)
+
+			var appErr *models.AppError
+			if !errors.As(err, &appErr) {
+				t.Fatalf("classifyError() = %T, want *models.AppError", err)
+			}
+			if appErr.Code != tt.wantCode {
+				t.Errorf("classifyError() code = %v, want %v", appErr.Code, tt.wantCode)
+			}
+			if appErr.Retryable != tt.wantRetryable {
+				t.Errorf("classifyError() retryable = %v, want %v", appErr.Retryable, tt.wantRetryable)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("classifyError() does not wrap the Azure error")
+			}
+		})
+	}
+}
+
+func TestClassifyError_PassesThroughDeadlines(t *testing.T) {
+	err := classifyError("failed to get container group", context.DeadlineExceeded)
+
+	var appErr *models.AppError
+	if errors.As(err, &appErr) {
+		t.Errorf("classifyError() = %v, want an unclassified error", appErr.Code)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("classifyError() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClassifyError_KeepsNotFoundDetectable(t *testing.T) {
+	err := classifyError("failed to get container group", &azcore.ResponseError{StatusCode: http.StatusNotFound})
+
+	if !isNotFoundError(err) {
+		t.Errorf("isNotFoundError(classifyError()) = false, want true")
+	}
+}
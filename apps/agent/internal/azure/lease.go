@@ -0,0 +1,115 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/lease"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/lock"
+)
+
+// BlobLeaseClient implements lock.LeaseClient with Azure Blob leases. Every
+// lock is an empty blob in one container; holding the lock is holding the
+// blob's lease, which Azure expires unless it is renewed.
+type BlobLeaseClient struct {
+	containerClient *container.Client
+}
+
+// NewBlobLeaseClient creates a lease client on a container of the storage
+// account, creating the container if needed
+func NewBlobLeaseClient(ctx context.Context, accountName, accountKey, containerName string) (*BlobLeaseClient, error) {
+	credential, err := container.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shared key credential: %w", err)
+	}
+
+	containerURL := fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, containerName)
+	client, err := container.NewClientWithSharedKeyCredential(containerURL, credential, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob container client: %w", err)
+	}
+
+	if _, err := client.Create(ctx, nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		return nil, fmt.Errorf("failed to create lock container %s: %w", containerName, err)
+	}
+
+	return &BlobLeaseClient{containerClient: client}, nil
+}
+
+// Acquire implements lock.LeaseClient. Azure accepts lease durations of 15-60 seconds.
+func (c *BlobLeaseClient) Acquire(ctx context.Context, name string, duration time.Duration) (string, error) {
+	blobClient := c.containerClient.NewBlockBlobClient(name)
+
+	// A lease needs an existing blob; If-None-Match leaves a leased blob alone
+	_, err := blobClient.Upload(ctx, streaming.NopCloser(bytes.NewReader(nil)), &blockblob.UploadOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+		},
+	})
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet, bloberror.LeaseIDMissing) {
+		return "", fmt.Errorf("failed to create lock blob %s: %w", name, err)
+	}
+
+	leaseClient, err := lease.NewBlobClient(blobClient, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create lease client: %w", err)
+	}
+
+	resp, err := leaseClient.AcquireLease(ctx, int32(duration/time.Second), nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.LeaseAlreadyPresent) {
+			return "", lock.ErrLeaseHeld
+		}
+		return "", fmt.Errorf("failed to acquire lease on %s: %w", name, err)
+	}
+	if resp.LeaseID == nil {
+		return "", fmt.Errorf("acquiring lease on %s returned no lease ID", name)
+	}
+
+	return *resp.LeaseID, nil
+}
+
+// Renew implements lock.LeaseClient
+func (c *BlobLeaseClient) Renew(ctx context.Context, name, leaseID string) error {
+	leaseClient, err := c.leaseClient(name, leaseID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := leaseClient.RenewLease(ctx, nil); err != nil {
+		return fmt.Errorf("failed to renew lease on %s: %w", name, err)
+	}
+	return nil
+}
+
+// Release implements lock.LeaseClient
+func (c *BlobLeaseClient) Release(ctx context.Context, name, leaseID string) error {
+	leaseClient, err := c.leaseClient(name, leaseID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := leaseClient.ReleaseLease(ctx, nil); err != nil {
+		return fmt.Errorf("failed to release lease on %s: %w", name, err)
+	}
+	return nil
+}
+
+// leaseClient returns a client for an existing lease on the named blob
+func (c *BlobLeaseClient) leaseClient(name, leaseID string) (*lease.BlobClient, error) {
+	leaseClient, err := lease.NewBlobClient(c.containerClient.NewBlockBlobClient(name), &lease.BlobClientOptions{LeaseID: &leaseID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lease client: %w", err)
+	}
+	return leaseClient, nil
+}
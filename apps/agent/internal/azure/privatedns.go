@@ -0,0 +1,84 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// privateDNSAPIVersion is the Microsoft.Network/privateDnsZones API version
+const privateDNSAPIVersion = "2018-09-01"
+
+// privateDNSRecordTTL keeps records short-lived: a workspace gets a new private IP on every start
+const privateDNSRecordTTL = 60
+
+// privateDNSZoneName returns the zone name at the end of a private DNS zone resource ID
+func privateDNSZoneName(zoneID string) string {
+	return zoneID[strings.LastIndex(zoneID, "/")+1:]
+}
+
+// SetPrivateDNSRecord points the A record {name}.{zone} at a private IP
+func (c *Client) SetPrivateDNSRecord(ctx context.Context, region, zoneID, name, ip string) error {
+	body := map[string]any{
+		"properties": map[string]any{
+			"ttl":      privateDNSRecordTTL,
+			"aRecords": []map[string]string{{"ipv4Address": ip}},
+		},
+	}
+
+	return c.calls.call(ctx, region, "failed to set private DNS record", func(ctx context.Context) error {
+		req, err := c.privateDNSRequest(ctx, http.MethodPut, zoneID, name)
+		if err != nil {
+			return err
+		}
+		if err := runtime.MarshalAsJSON(req, body); err != nil {
+			return err
+		}
+
+		resp, err := c.arm.Pipeline().Do(req)
+		if err != nil {
+			return err
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusCreated) {
+			return runtime.NewResponseError(resp)
+		}
+		return nil
+	})
+}
+
+// DeletePrivateDNSRecord removes the A record {name}.{zone}; a missing record is not an error
+func (c *Client) DeletePrivateDNSRecord(ctx context.Context, region, zoneID, name string) error {
+	return c.calls.call(ctx, region, "failed to delete private DNS record", func(ctx context.Context) error {
+		req, err := c.privateDNSRequest(ctx, http.MethodDelete, zoneID, name)
+		if err != nil {
+			return err
+		}
+
+		resp, err := c.arm.Pipeline().Do(req)
+		if err != nil {
+			return err
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusNoContent, http.StatusNotFound) {
+			return runtime.NewResponseError(resp)
+		}
+		return nil
+	})
+}
+
+// privateDNSRequest builds a request for the A record set {name} in a private DNS zone
+func (c *Client) privateDNSRequest(ctx context.Context, method, zoneID, name string) (*policy.Request, error) {
+	endpoint := runtime.JoinPaths(c.arm.Endpoint(), zoneID, "A", name)
+	req, err := runtime.NewRequest(ctx, method, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private DNS zone %q: %w", zoneID, err)
+	}
+	query := req.Raw().URL.Query()
+	query.Set("api-version", privateDNSAPIVersion)
+	req.Raw().URL.RawQuery = query.Encode()
+	req.Raw().Header.Set("Accept", "application/json")
+	return req, nil
+}
@@ -0,0 +1,144 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance/v2"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+const testPrivateDNSZone = "/subscriptions/sub/resourceGroups/rg-net/providers/Microsoft.Network/privateDnsZones/ws.dev8.internal"
+
+// staticCredential hands out a fixed token
+type staticCredential struct{}
+
+func (staticCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "test-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestIPAddress(t *testing.T) {
+	ports := []*armcontainerinstance.Port{{Port: to.Ptr(int32(8080))}}
+
+	public := ipAddress(ContainerGroupSpec{DNSNameLabel: "aci-ws-1"}, ports)
+	if *public.Type != armcontainerinstance.ContainerGroupIPAddressTypePublic || public.DNSNameLabel == nil || *public.DNSNameLabel != "aci-ws-1" {
+		t.Errorf("ipAddress() without subnet = %v %v, want a public IP labelled aci-ws-1", *public.Type, public.DNSNameLabel)
+	}
+
+	private := ipAddress(ContainerGroupSpec{DNSNameLabel: "aci-ws-1", SubnetID: "/subscriptions/sub/subnets/workspaces"}, ports)
+	if *private.Type != armcontainerinstance.ContainerGroupIPAddressTypePrivate || private.DNSNameLabel != nil {
+		t.Errorf("ipAddress() with subnet = %v %v, want a private IP without DNS name label", *private.Type, private.DNSNameLabel)
+	}
+	if len(private.Ports) != 1 {
+		t.Errorf("ipAddress() with subnet opened %d ports, want 1", len(private.Ports))
+	}
+}
+
+func TestPrivateAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   config.RegionConfig
+		runtime  provider.Runtime
+		wantFQDN string
+	}{
+		{
+			name:     "public",
+			region:   config.RegionConfig{},
+			runtime:  provider.Runtime{Name: "aci-ws-1", FQDN: "aci-ws-1.eastus.azurecontainer.io", IPAddress: "20.1.2.3"},
+			wantFQDN: "aci-ws-1.eastus.azurecontainer.io",
+		},
+		{
+			name:     "private IP",
+			region:   config.RegionConfig{SubnetID: "subnet"},
+			runtime:  provider.Runtime{Name: "aci-ws-1", IPAddress: "10.0.1.4"},
+			wantFQDN: "10.0.1.4",
+		},
+		{
+			name:     "private DNS zone",
+			region:   config.RegionConfig{SubnetID: "subnet", PrivateDNSZone: testPrivateDNSZone},
+			runtime:  provider.Runtime{Name: "aci-ws-1", IPAddress: "10.0.1.4"},
+			wantFQDN: "aci-ws-1.ws.dev8.internal",
+		},
+		{
+			name:     "no IP yet",
+			region:   config.RegionConfig{SubnetID: "subnet", PrivateDNSZone: testPrivateDNSZone},
+			runtime:  provider.Runtime{Name: "aci-ws-1"},
+			wantFQDN: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privateAddress(&tt.runtime, &tt.region)
+			if tt.runtime.FQDN != tt.wantFQDN {
+				t.Errorf("FQDN = %q, want %q", tt.runtime.FQDN, tt.wantFQDN)
+			}
+		})
+	}
+}
+
+func TestClient_PrivateDNSRecord(t *testing.T) {
+	type request struct {
+		method, path, apiVersion string
+		body                     map[string]any
+	}
+	var requests []request
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.Path, apiVersion: r.URL.Query().Get("api-version")}
+		json.NewDecoder(r.Body).Decode(&req.body)
+		requests = append(requests, req)
+
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	armClient, err := arm.NewClient("dev8-agent", "v1.0.0", staticCredential{}, &arm.ClientOptions{ClientOptions: policy.ClientOptions{
+		Cloud: cloud.Configuration{Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {Endpoint: server.URL, Audience: "https://management.azure.com"},
+		}},
+		Transport: server.Client(),
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	}})
+	if err != nil {
+		t.Fatalf("arm.NewClient() error = %v", err)
+	}
+	client := &Client{arm: armClient, calls: testCaller(1, 5)}
+	ctx := context.Background()
+
+	if err := client.SetPrivateDNSRecord(ctx, "eastus", testPrivateDNSZone, "aci-ws-1", "10.0.1.4"); err != nil {
+		t.Fatalf("SetPrivateDNSRecord() error = %v", err)
+	}
+	if err := client.DeletePrivateDNSRecord(ctx, "eastus", testPrivateDNSZone, "aci-ws-1"); err != nil {
+		t.Errorf("DeletePrivateDNSRecord() of a missing record error = %v, want nil", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(requests))
+	}
+	wantPath := testPrivateDNSZone + "/A/aci-ws-1"
+	for i, wantMethod := range []string{http.MethodPut, http.MethodDelete} {
+		if requests[i].method != wantMethod || requests[i].path != wantPath || requests[i].apiVersion != privateDNSAPIVersion {
+			t.Errorf("request %d = %s %s?api-version=%s, want %s %s?api-version=%s", i,
+				requests[i].method, requests[i].path, requests[i].apiVersion, wantMethod, wantPath, privateDNSAPIVersion)
+		}
+	}
+	properties, _ := requests[0].body["properties"].(map[string]any)
+	records, _ := properties["aRecords"].([]any)
+	if len(records) != 1 || records[0].(map[string]any)["ipv4Address"] != "10.0.1.4" {
+		t.Errorf("record body = %v, want one A record for 10.0.1.4", requests[0].body)
+	}
+}
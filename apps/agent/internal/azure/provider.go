@@ -0,0 +1,365 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance/v2"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/resilience"
+)
+
+// rollbackTimeout bounds deleting a container group whose creation failed
+// half-way, which often happens after the create's own deadline has passed
+const rollbackTimeout = 2 * time.Minute
+
+// ACIProvider implements provider.ComputeProvider on Azure Container Instances + Azure Files
+type ACIProvider struct {
+	config         *config.Config
+	client         *Client
+	storageClients map[string]*StorageClient
+}
+
+// NewACIProvider creates the ACI compute provider with storage clients for all enabled regions
+func NewACIProvider(cfg *config.Config, client *Client) (*ACIProvider, error) {
+	p := &ACIProvider{
+		config:         cfg,
+		client:         client,
+		storageClients: make(map[string]*StorageClient),
+	}
+
+	// Initialize storage clients for all regions
+	for _, region := range cfg.Azure.Regions {
+		if region.Enabled && region.StorageAccount != "" {
+			storageClient, err := NewStorageClient(region.StorageAccount, cfg.Azure.StorageAccountKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create storage client for region %s: %w", region.Name, err)
+			}
+			storageClient.region, storageClient.calls = region.Name, client.calls
+			p.storageClients[region.Name] = storageClient
+		}
+	}
+
+	return p, nil
+}
+
+// Name returns the backend name
+func (p *ACIProvider) Name() string {
+	return "azure-aci"
+}
+
+// HasRegion reports whether the region is configured and enabled
+func (p *ACIProvider) HasRegion(region string) bool {
+	return p.config.GetRegion(region) != nil
+}
+
+// Regions returns the enabled Azure regions
+func (p *ACIProvider) Regions() []string {
+	var regions []string
+	for _, region := range p.config.GetEnabledRegions() {
+		regions = append(regions, region.Name)
+	}
+	return regions
+}
+
+// RegionHealth implements provider.RegionHealthReporter from the circuit
+// breakers guarding each enabled region's ACI and storage calls
+func (p *ACIProvider) RegionHealth() []models.RegionHealth {
+	var health []models.RegionHealth
+	for _, region := range p.config.GetEnabledRegions() {
+		status := p.client.calls.breakerStatus(region.Name)
+		regionHealth := models.RegionHealth{
+			Region:   region.Name,
+			Healthy:  status.State != resilience.StateOpen,
+			State:    string(status.State),
+			Failures: status.Failures,
+		}
+		if !status.OpenUntil.IsZero() {
+			openUntil := status.OpenUntil
+			regionHealth.OpenUntil = &openUntil
+		}
+		health = append(health, regionHealth)
+	}
+	return health
+}
+
+// CreateVolume creates the workspace Azure File share
+func (p *ACIProvider) CreateVolume(ctx context.Context, region, name string, quotaGB int32) error {
+	storageClient, err := p.storageClient(region)
+	if err != nil {
+		return err
+	}
+	return storageClient.CreateFileShare(ctx, name, quotaGB)
+}
+
+// VolumeExists checks whether the workspace Azure File share exists
+func (p *ACIProvider) VolumeExists(ctx context.Context, region, name string) (bool, error) {
+	storageClient, err := p.storageClient(region)
+	if err != nil {
+		return false, err
+	}
+	return storageClient.FileShareExists(ctx, name)
+}
+
+// DeleteVolume deletes the workspace Azure File share
+func (p *ACIProvider) DeleteVolume(ctx context.Context, region, name string) error {
+	storageClient, err := p.storageClient(region)
+	if err != nil {
+		return err
+	}
+	return storageClient.DeleteFileShare(ctx, name)
+}
+
+// ListVolumes lists the fs-* Azure File shares in the region's storage account
+func (p *ACIProvider) ListVolumes(ctx context.Context, region string) ([]provider.Volume, error) {
+	storageClient, err := p.storageClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := storageClient.ListFileShares(ctx, provider.VolumePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]provider.Volume, 0, len(shares))
+	for _, share := range shares {
+		volumes = append(volumes, provider.Volume{Name: share.Name, Region: region, CreatedAt: share.LastModified})
+	}
+	return volumes, nil
+}
+
+// CreateRuntime creates the ACI container group, mounting the region's storage account.
+// A group that was created but could not be registered is deleted again, so
+// a failed create never leaves a billed container group behind.
+func (p *ACIProvider) CreateRuntime(ctx context.Context, region, name string, spec provider.ContainerGroupSpec) error {
+	regionConfig := p.config.GetRegion(region)
+	if regionConfig == nil {
+		return fmt.Errorf("region %s is not available", region)
+	}
+
+	spec.StorageAccountName = regionConfig.StorageAccount
+	spec.StorageAccountKey = p.config.Azure.StorageAccountKey
+	spec.SubnetID = regionConfig.SubnetID
+
+	resourceGroup := p.resourceGroup(regionConfig)
+	if err := p.client.CreateContainerGroup(ctx, region, resourceGroup, name, spec); err != nil {
+		return err
+	}
+	if regionConfig.PrivateDNSZone == "" {
+		return nil
+	}
+
+	if err := p.registerPrivateIP(ctx, regionConfig, resourceGroup, name); err != nil {
+		rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+		defer cancel()
+		if deleteErr := p.DeleteRuntime(rollbackCtx, region, name); deleteErr != nil {
+			log.Printf("Warning: failed to delete container group %s after a failed create: %v", name, deleteErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// registerPrivateIP points the container group's private DNS record at its private IP
+func (p *ACIProvider) registerPrivateIP(ctx context.Context, regionConfig *config.RegionConfig, resourceGroup, name string) error {
+	// The private IP changes every time the container group is created
+	group, err := p.client.GetContainerGroup(ctx, regionConfig.Name, resourceGroup, name)
+	if err != nil {
+		return err
+	}
+	runtime := containerGroupToRuntime(group, regionConfig.Name, resourceGroup)
+	if runtime.IPAddress == "" {
+		return fmt.Errorf("container group %s has no private IP to register in %s", name, privateDNSZoneName(regionConfig.PrivateDNSZone))
+	}
+	return p.client.SetPrivateDNSRecord(ctx, regionConfig.Name, regionConfig.PrivateDNSZone, name, runtime.IPAddress)
+}
+
+// GetRuntime returns the container group details
+func (p *ACIProvider) GetRuntime(ctx context.Context, region, name string) (*provider.Runtime, error) {
+	regionConfig := p.config.GetRegion(region)
+	if regionConfig == nil {
+		return nil, fmt.Errorf("region %s is not available", region)
+	}
+
+	resourceGroup := p.resourceGroup(regionConfig)
+	group, err := p.client.GetContainerGroup(ctx, region, resourceGroup, name)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, provider.ErrRuntimeNotFound
+		}
+		return nil, err
+	}
+
+	runtime := containerGroupToRuntime(group, region, resourceGroup)
+	privateAddress(&runtime, regionConfig)
+	return &runtime, nil
+}
+
+// DeleteRuntime deletes the container group
+func (p *ACIProvider) DeleteRuntime(ctx context.Context, region, name string) error {
+	regionConfig := p.config.GetRegion(region)
+	if regionConfig == nil {
+		return fmt.Errorf("region %s is not available", region)
+	}
+
+	if err := p.client.DeleteContainerGroup(ctx, region, p.resourceGroup(regionConfig), name); err != nil {
+		return err
+	}
+	if regionConfig.PrivateDNSZone == "" {
+		return nil
+	}
+	return p.client.DeletePrivateDNSRecord(ctx, region, regionConfig.PrivateDNSZone, name)
+}
+
+// ListRuntimes lists container groups tagged managed-by=dev8-agent in the region
+func (p *ACIProvider) ListRuntimes(ctx context.Context, region string) ([]provider.Runtime, error) {
+	regionConfig := p.config.GetRegion(region)
+	if regionConfig == nil {
+		return nil, fmt.Errorf("region %s is not available", region)
+	}
+
+	resourceGroup := p.resourceGroup(regionConfig)
+	groups, err := p.client.ListContainerGroups(ctx, region, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	var runtimes []provider.Runtime
+	for _, group := range groups {
+		if group == nil || group.Tags == nil {
+			continue
+		}
+		if managedBy := group.Tags["managed-by"]; managedBy == nil || *managedBy != "dev8-agent" {
+			continue
+		}
+		if group.Location != nil && !sameLocation(*group.Location, regionConfig.Location, region) {
+			continue
+		}
+		runtime := containerGroupToRuntime(group, region, resourceGroup)
+		privateAddress(&runtime, regionConfig)
+		runtimes = append(runtimes, runtime)
+	}
+
+	return runtimes, nil
+}
+
+func (p *ACIProvider) storageClient(region string) (*StorageClient, error) {
+	storageClient, ok := p.storageClients[region]
+	if !ok {
+		return nil, fmt.Errorf("storage client not found for region %s", region)
+	}
+	return storageClient, nil
+}
+
+func (p *ACIProvider) resourceGroup(regionConfig *config.RegionConfig) string {
+	if regionConfig.ResourceGroupName != "" {
+		return regionConfig.ResourceGroupName
+	}
+	return p.config.Azure.ResourceGroupName
+}
+
+// containerGroupToRuntime converts an ACI container group into a provider-neutral runtime
+func containerGroupToRuntime(group *armcontainerinstance.ContainerGroup, region, resourceGroup string) provider.Runtime {
+	runtime := provider.Runtime{
+		Region:        region,
+		ResourceGroup: resourceGroup,
+		State:         provider.StateUnknown,
+		Tags:          make(map[string]string),
+	}
+
+	if group.Name != nil {
+		runtime.Name = *group.Name
+	}
+
+	for key, value := range group.Tags {
+		if value != nil {
+			runtime.Tags[key] = *value
+		}
+	}
+	runtime.EnvironmentID = runtime.Tags["environment"]
+	runtime.UserID = runtime.Tags["userId"]
+
+	props := group.Properties
+	if props == nil {
+		return runtime
+	}
+
+	if props.IPAddress != nil {
+		if props.IPAddress.Fqdn != nil {
+			runtime.FQDN = *props.IPAddress.Fqdn
+		}
+		if props.IPAddress.IP != nil {
+			runtime.IPAddress = *props.IPAddress.IP
+		}
+	}
+
+	var instanceState, provisioningState string
+	if props.InstanceView != nil && props.InstanceView.State != nil {
+		instanceState = *props.InstanceView.State
+	}
+	if props.ProvisioningState != nil {
+		provisioningState = *props.ProvisioningState
+	}
+	runtime.State = mapContainerGroupState(provisioningState, instanceState)
+
+	return runtime
+}
+
+// privateAddress gives a private container group, which ACI names no FQDN,
+// its record in the region's private DNS zone or else its private IP, so
+// connection URLs are built from them
+func privateAddress(runtime *provider.Runtime, regionConfig *config.RegionConfig) {
+	if !regionConfig.Private() || runtime.FQDN != "" || runtime.IPAddress == "" {
+		return
+	}
+	if regionConfig.PrivateDNSZone != "" {
+		runtime.FQDN = runtime.Name + "." + privateDNSZoneName(regionConfig.PrivateDNSZone)
+		return
+	}
+	runtime.FQDN = runtime.IPAddress
+}
+
+// mapContainerGroupState maps ACI provisioning/instance states to a runtime state
+func mapContainerGroupState(provisioningState, instanceState string) provider.RuntimeState {
+	switch strings.ToLower(provisioningState) {
+	case "failed", "unhealthy":
+		return provider.StateFailed
+	case "pending", "creating", "repairing", "updating":
+		return provider.StatePending
+	}
+
+	switch strings.ToLower(instanceState) {
+	case "running":
+		return provider.StateRunning
+	case "pending", "waiting":
+		return provider.StatePending
+	case "stopped", "succeeded", "terminated":
+		return provider.StateStopped
+	case "failed":
+		return provider.StateFailed
+	}
+
+	if strings.EqualFold(provisioningState, "succeeded") {
+		return provider.StateRunning
+	}
+	return provider.StateUnknown
+}
+
+// sameLocation compares an ARM location ("eastus") with a configured location ("East US")
+func sameLocation(location string, candidates ...string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	}
+	for _, candidate := range candidates {
+		if normalize(location) == normalize(candidate) {
+			return true
+		}
+	}
+	return false
+}
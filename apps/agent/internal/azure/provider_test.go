@@ -0,0 +1,179 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance/v2"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+func TestMapContainerGroupState(t *testing.T) {
+	tests := []struct {
+		name              string
+		provisioningState string
+		instanceState     string
+		want              provider.RuntimeState
+	}{
+		{name: "running", provisioningState: "Succeeded", instanceState: "Running", want: provider.StateRunning},
+		{name: "still creating", provisioningState: "Creating", instanceState: "", want: provider.StatePending},
+		{name: "provisioning failed", provisioningState: "Failed", instanceState: "Running", want: provider.StateFailed},
+		{name: "stopped", provisioningState: "Succeeded", instanceState: "Stopped", want: provider.StateStopped},
+		{name: "succeeded without instance view", provisioningState: "Succeeded", instanceState: "", want: provider.StateRunning},
+		{name: "nothing known", provisioningState: "", instanceState: "", want: provider.StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapContainerGroupState(tt.provisioningState, tt.instanceState); got != tt.want {
+				t.Errorf("mapContainerGroupState(%q, %q) = %v, want %v", tt.provisioningState, tt.instanceState, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerGroupToRuntime(t *testing.T) {
+	group := &armcontainerinstance.ContainerGroup{
+		Name:     to.Ptr("aci-ws-123"),
+		Location: to.Ptr("eastus"),
+		Tags: map[string]*string{
+			"environment": to.Ptr("ws-123"),
+			"userId":      to.Ptr("user-1"),
+			"managed-by":  to.Ptr("dev8-agent"),
+		},
+		Properties: &armcontainerinstance.ContainerGroupPropertiesProperties{
+			ProvisioningState: to.Ptr("Succeeded"),
+			InstanceView:      &armcontainerinstance.ContainerGroupPropertiesInstanceView{State: to.Ptr("Running")},
+			IPAddress: &armcontainerinstance.IPAddress{
+				Fqdn: to.Ptr("ws-123.eastus.azurecontainer.io"),
+				IP:   to.Ptr("20.1.2.3"),
+			},
+		},
+	}
+
+	runtime := containerGroupToRuntime(group, "eastus", "rg-eastus")
+
+	if runtime.Name != "aci-ws-123" {
+		t.Errorf("Name = %v, want aci-ws-123", runtime.Name)
+	}
+	if runtime.EnvironmentID != "ws-123" || runtime.UserID != "user-1" {
+		t.Errorf("EnvironmentID/UserID = %v/%v, want ws-123/user-1", runtime.EnvironmentID, runtime.UserID)
+	}
+	if runtime.FQDN != "ws-123.eastus.azurecontainer.io" || runtime.IPAddress != "20.1.2.3" {
+		t.Errorf("FQDN/IP = %v/%v", runtime.FQDN, runtime.IPAddress)
+	}
+	if runtime.State != provider.StateRunning {
+		t.Errorf("State = %v, want %v", runtime.State, provider.StateRunning)
+	}
+	if runtime.ResourceGroup != "rg-eastus" {
+		t.Errorf("ResourceGroup = %v, want rg-eastus", runtime.ResourceGroup)
+	}
+}
+
+func TestACIProvider_HasRegion(t *testing.T) {
+	p, err := NewACIProvider(&config.Config{
+		Azure: config.AzureConfig{
+			Regions: []config.RegionConfig{
+				{Name: "eastus", Location: "East US", Enabled: true},
+				{Name: "westus", Location: "West US", Enabled: false},
+			},
+		},
+	}, &Client{})
+	if err != nil {
+		t.Fatalf("NewACIProvider() error = %v", err)
+	}
+
+	if !p.HasRegion("eastus") {
+		t.Error("HasRegion(eastus) = false, want true")
+	}
+	if p.HasRegion("westus") {
+		t.Error("HasRegion(westus) = true, want false for disabled region")
+	}
+	if _, err := p.storageClient("eastus"); err == nil {
+		t.Error("storageClient(eastus) should fail without a storage account")
+	}
+}
+
+func TestSameLocation(t *testing.T) {
+	if !sameLocation("eastus", "East US") {
+		t.Error("sameLocation(eastus, East US) = false, want true")
+	}
+	if sameLocation("westeurope", "East US", "eastus") {
+		t.Error("sameLocation(westeurope, ...) = true, want false")
+	}
+}
+
+func TestACIProvider_CreateRuntimeDeletesUnregisteredGroup(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{}`))
+		case strings.Contains(r.URL.Path, "/containerGroups/"):
+			// Created, but without the private IP the DNS record needs
+			w.Write([]byte(`{"name":"aci-ws-1","properties":{"provisioningState":"Succeeded","containers":[]}}`))
+		default:
+			http.Error(w, "unexpected request", http.StatusTeapot)
+		}
+	}))
+	defer server.Close()
+
+	options := &arm.ClientOptions{ClientOptions: policy.ClientOptions{
+		Cloud: cloud.Configuration{Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {Endpoint: server.URL, Audience: "https://management.azure.com"},
+		}},
+		Transport: server.Client(),
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	}}
+	aciClient, err := armcontainerinstance.NewContainerGroupsClient("sub", staticCredential{}, options)
+	if err != nil {
+		t.Fatalf("NewContainerGroupsClient() error = %v", err)
+	}
+	armClient, err := arm.NewClient("dev8-agent", "v1.0.0", staticCredential{}, options)
+	if err != nil {
+		t.Fatalf("arm.NewClient() error = %v", err)
+	}
+	client := &Client{
+		aciClients: map[string]*armcontainerinstance.ContainerGroupsClient{"eastus": aciClient},
+		arm:        armClient,
+		calls:      testCaller(1, 5),
+	}
+
+	p, err := NewACIProvider(&config.Config{
+		Azure: config.AzureConfig{
+			ResourceGroupName: "rg",
+			Regions: []config.RegionConfig{
+				{Name: "eastus", Enabled: true, SubnetID: "subnet", PrivateDNSZone: testPrivateDNSZone},
+			},
+		},
+	}, client)
+	if err != nil {
+		t.Fatalf("NewACIProvider() error = %v", err)
+	}
+
+	if err := p.CreateRuntime(context.Background(), "eastus", "aci-ws-1", provider.ContainerGroupSpec{}); err == nil {
+		t.Fatal("CreateRuntime() should fail without a private IP to register")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(deleted) == 0 || !strings.HasSuffix(deleted[0], "/containerGroups/aci-ws-1") {
+		t.Errorf("deleted %v, want the container group deleted after the failed create", deleted)
+	}
+}
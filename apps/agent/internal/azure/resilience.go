@@ -0,0 +1,112 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/resilience"
+)
+
+// caller runs Azure API calls with a deadline, retries transient failures with
+// backoff and short-circuits regions whose circuit breaker is open. ACI and
+// storage calls in a region share its breaker.
+type caller struct {
+	attempts    int
+	backoff     resilience.Backoff
+	timeout     time.Duration
+	longTimeout time.Duration
+	breakers    *resilience.Breakers
+}
+
+// newCaller creates a caller from the Azure resilience settings
+func newCaller(cfg config.ResilienceConfig) *caller {
+	return &caller{
+		attempts:    cfg.MaxAttempts,
+		backoff:     resilience.Backoff{Base: cfg.BaseDelay, Max: cfg.MaxDelay},
+		timeout:     cfg.CallTimeout,
+		longTimeout: cfg.LongCallTimeout,
+		breakers:    resilience.NewBreakers(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// call runs fn against region and returns its error classified by
+// classifyError. A nil caller runs fn once without a deadline.
+func (c *caller) call(ctx context.Context, region, action string, fn func(ctx context.Context) error) error {
+	return c.run(ctx, region, action, c.timeout, fn)
+}
+
+// callLong is call for long-running operations that poll until done
+func (c *caller) callLong(ctx context.Context, region, action string, fn func(ctx context.Context) error) error {
+	return c.run(ctx, region, action, c.longTimeout, fn)
+}
+
+func (c *caller) run(ctx context.Context, region, action string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if c == nil {
+		return classifyError(action, fn(ctx))
+	}
+
+	// Only our own deadline says something about the region; the caller's
+	// (a readiness poll giving up, a client disconnecting) does not
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	breaker := c.breakers.Get(region)
+	return resilience.Retry(ctx, c.attempts, c.backoff, shouldRetry, func(ctx context.Context) error {
+		if err := breaker.Allow(); err != nil {
+			return &models.AppError{
+				Message:   fmt.Sprintf("%s: Azure region %s is unavailable after repeated failures", action, region),
+				Code:      models.CodeCloudUnavailable,
+				Retryable: true,
+				Err:       err,
+			}
+		}
+
+		err := classifyError(action, fn(ctx))
+		switch {
+		case err == nil:
+			breaker.Success()
+		case parent.Err() != nil || errors.Is(err, context.Canceled):
+			breaker.Release()
+		case !unhealthy(err):
+			breaker.Success()
+		default:
+			breaker.Failure()
+		}
+		return err
+	})
+}
+
+// breakerStatus returns the circuit breaker status of a region
+func (c *caller) breakerStatus(region string) resilience.Status {
+	if c == nil {
+		return resilience.Status{Name: region, State: resilience.StateClosed}
+	}
+	return c.breakers.Get(region).Status()
+}
+
+// shouldRetry reports whether a classified error is worth another attempt
+// within the same call. An open breaker is retryable for clients, but not
+// until its cooldown ends.
+func shouldRetry(err error) bool {
+	var appErr *models.AppError
+	return errors.As(err, &appErr) && appErr.Retryable && !errors.Is(err, resilience.ErrOpen)
+}
+
+// unhealthy reports whether an error says the region itself is failing, as
+// opposed to the request (not found, conflict, quota...). run only asks once
+// the caller's context is known to be live, so a deadline is the per-call one.
+func unhealthy(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var appErr *models.AppError
+	return errors.As(err, &appErr) && (appErr.Code == models.CodeThrottled || appErr.Code == models.CodeCloudUnavailable)
+}
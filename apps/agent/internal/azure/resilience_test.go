@@ -0,0 +1,164 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/resilience"
+)
+
+func testCaller(attempts, threshold int) *caller {
+	return newCaller(config.ResilienceConfig{
+		MaxAttempts:      attempts,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         time.Millisecond,
+		CallTimeout:      time.Second,
+		LongCallTimeout:  time.Second,
+		BreakerThreshold: threshold,
+		BreakerCooldown:  time.Minute,
+	})
+}
+
+func TestCaller_RetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		results   []error
+		wantCode  string
+		wantCalls int
+	}{
+		{
+			name:      "throttled then success",
+			results:   []error{&azcore.ResponseError{StatusCode: http.StatusTooManyRequests}, nil},
+			wantCalls: 2,
+		},
+		{
+			name: "server errors exhaust attempts",
+			results: []error{
+				&azcore.ResponseError{StatusCode: http.StatusServiceUnavailable},
+				&azcore.ResponseError{StatusCode: http.StatusBadGateway},
+				&azcore.ResponseError{StatusCode: http.StatusInternalServerError},
+			},
+			wantCode:  models.CodeCloudUnavailable,
+			wantCalls: 3,
+		},
+		{
+			name:      "conflict is not retried",
+			results:   []error{&azcore.ResponseError{ErrorCode: "ShareAlreadyExists", StatusCode: http.StatusConflict}, nil},
+			wantCode:  models.CodeConflict,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := testCaller(3, 10).call(context.Background(), "eastus", "failed to get container group", func(ctx context.Context) error {
+				calls++
+				return tt.results[calls-1]
+			})
+
+			var appErr *models.AppError
+			switch {
+			case tt.wantCode == "" && err != nil:
+				t.Errorf("call() error = %v, want nil", err)
+			case tt.wantCode != "" && (!errors.As(err, &appErr) || appErr.Code != tt.wantCode):
+				t.Errorf("call() error = %v, want code %s", err, tt.wantCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("call() calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCaller_OpensBreakerPerRegion(t *testing.T) {
+	calls := testCaller(1, 2)
+	failing := func(ctx context.Context) error {
+		return &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}
+	}
+
+	for i := 0; i < 2; i++ {
+		_ = calls.call(context.Background(), "eastus", "failed to list container groups", failing)
+	}
+	if got := calls.breakerStatus("eastus").State; got != resilience.StateOpen {
+		t.Fatalf("breaker state = %v after 2 failures, want %v", got, resilience.StateOpen)
+	}
+
+	invoked := false
+	err := calls.call(context.Background(), "eastus", "failed to list container groups", func(ctx context.Context) error {
+		invoked = true
+		return nil
+	})
+	if invoked {
+		t.Error("call() reached Azure while the breaker was open")
+	}
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) || appErr.Code != models.CodeCloudUnavailable || !appErr.Retryable {
+		t.Errorf("call() error = %v, want retryable %s", err, models.CodeCloudUnavailable)
+	}
+
+	// Other regions are unaffected
+	if err := calls.call(context.Background(), "westeurope", "failed to list container groups", func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("call() in another region error = %v, want nil", err)
+	}
+}
+
+func TestCaller_RequestErrorsKeepBreakerClosed(t *testing.T) {
+	calls := testCaller(1, 1)
+	_ = calls.call(context.Background(), "eastus", "failed to get container group", func(ctx context.Context) error {
+		return &azcore.ResponseError{StatusCode: http.StatusNotFound}
+	})
+
+	if got := calls.breakerStatus("eastus").State; got != resilience.StateClosed {
+		t.Errorf("breaker state = %v after a 404, want %v", got, resilience.StateClosed)
+	}
+}
+
+func TestCaller_AppliesDeadline(t *testing.T) {
+	calls := testCaller(1, 10)
+	calls.timeout = 10 * time.Millisecond
+
+	err := calls.call(context.Background(), "eastus", "failed to get container group", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("call() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCaller_CallerDeadlineKeepsBreakerClosed(t *testing.T) {
+	calls := testCaller(1, 1)
+	calls.timeout = time.Minute
+
+	// The caller gives up long before the per-call deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := calls.call(ctx, "eastus", "failed to get container group", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("call() error = %v, want context.DeadlineExceeded", err)
+	}
+	if got := calls.breakerStatus("eastus").State; got != resilience.StateClosed {
+		t.Errorf("breaker state = %v after the caller's deadline, want %v", got, resilience.StateClosed)
+	}
+
+	// The per-call deadline still counts against the region
+	calls.timeout = 10 * time.Millisecond
+	_ = calls.call(context.Background(), "eastus", "failed to get container group", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if got := calls.breakerStatus("eastus").State; got != resilience.StateOpen {
+		t.Errorf("breaker state = %v after the per-call deadline, want %v", got, resilience.StateOpen)
+	}
+}
@@ -6,17 +6,27 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/service"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/share"
 )
 
+// FileShare is a file share returned by ListFileShares
+type FileShare struct {
+	Name         string
+	LastModified time.Time
+}
+
 // StorageClient provides Azure Files operations
 type StorageClient struct {
 	serviceClient *service.Client
 	accountName   string
 	accountKey    string
+
+	region string  // Region whose circuit breaker guards the calls
+	calls  *caller // Retries, deadlines and circuit breakers; nil calls once
 }
 
 // NewStorageClient creates a new Azure Files storage client
@@ -31,7 +41,7 @@ func NewStorageClient(accountName, accountKey string) (*StorageClient, error) {
 	}
 
 	// Create service client
-	client, err := service.NewClientWithSharedKeyCredential(serviceURL, credential, nil)
+	client, err := service.NewClientWithSharedKeyCredential(serviceURL, credential, &service.ClientOptions{ClientOptions: sdkOptions()})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create service client: %w", err)
 	}
@@ -47,51 +57,94 @@ func NewStorageClient(accountName, accountKey string) (*StorageClient, error) {
 func (s *StorageClient) CreateFileShare(ctx context.Context, shareName string, quotaGB int32) error {
 	shareClient := s.serviceClient.NewShareClient(shareName)
 
-	_, err := shareClient.Create(ctx, &share.CreateOptions{
-		Quota: &quotaGB,
+	return s.calls.call(ctx, s.region, "failed to create file share", func(ctx context.Context) error {
+		_, err := shareClient.Create(ctx, &share.CreateOptions{
+			Quota: &quotaGB,
+		})
+		return err
 	})
-	if err != nil {
-		return fmt.Errorf("failed to create file share: %w", err)
-	}
-
-	return nil
 }
 
 // DeleteFileShare deletes an Azure File share
 func (s *StorageClient) DeleteFileShare(ctx context.Context, shareName string) error {
 	shareClient := s.serviceClient.NewShareClient(shareName)
 
-	_, err := shareClient.Delete(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to delete file share: %w", err)
-	}
-
-	return nil
+	return s.calls.call(ctx, s.region, "failed to delete file share", func(ctx context.Context) error {
+		_, err := shareClient.Delete(ctx, nil)
+		return err
+	})
 }
 
 // FileShareExists checks if a file share exists
 func (s *StorageClient) FileShareExists(ctx context.Context, shareName string) (bool, error) {
 	shareClient := s.serviceClient.NewShareClient(shareName)
 
-	_, err := shareClient.GetProperties(ctx, nil)
+	err := s.calls.call(ctx, s.region, "failed to check file share existence", func(ctx context.Context) error {
+		_, err := shareClient.GetProperties(ctx, nil)
+		return err
+	})
 	if err != nil {
 		// Check if error is "share not found"
 		if isNotFoundError(err) {
 			return false, nil
 		}
-		return false, fmt.Errorf("failed to check file share existence: %w", err)
+		return false, err
 	}
 
 	return true, nil
 }
 
+// ListFileShares lists the file shares whose names start with prefix
+func (s *StorageClient) ListFileShares(ctx context.Context, prefix string) ([]FileShare, error) {
+	var shares []FileShare
+
+	err := s.calls.call(ctx, s.region, "failed to list file shares", func(ctx context.Context) error {
+		shares = nil
+		pager := s.serviceClient.NewListSharesPager(&service.ListSharesOptions{Prefix: &prefix})
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			shares = append(shares, sharesFromPage(page.Shares)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// sharesFromPage converts a page of listed shares
+func sharesFromPage(items []*service.Share) []FileShare {
+	var shares []FileShare
+	for _, item := range items {
+		if item == nil || item.Name == nil {
+			continue
+		}
+		fileShare := FileShare{Name: *item.Name}
+		if item.Properties != nil && item.Properties.LastModified != nil {
+			fileShare.LastModified = *item.Properties.LastModified
+		}
+		shares = append(shares, fileShare)
+	}
+	return shares
+}
+
 // GetFileShareProperties gets the properties of a file share
 func (s *StorageClient) GetFileShareProperties(ctx context.Context, shareName string) (map[string]interface{}, error) {
 	shareClient := s.serviceClient.NewShareClient(shareName)
 
-	resp, err := shareClient.GetProperties(ctx, nil)
+	var resp share.GetPropertiesResponse
+	err := s.calls.call(ctx, s.region, "failed to get file share properties", func(ctx context.Context) error {
+		var err error
+		resp, err = shareClient.GetProperties(ctx, nil)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file share properties: %w", err)
+		return nil, err
 	}
 
 	properties := map[string]interface{}{
@@ -0,0 +1,99 @@
+package bastion
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// forwardRequests relays global requests, e.g. keepalives and port-forward
+// setup, to the other side of the connection
+func forwardRequests(in <-chan *ssh.Request, out ssh.Conn) {
+	for req := range in {
+		ok, payload, err := out.SendRequest(req.Type, req.WantReply, req.Payload)
+		if err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(ok, payload)
+	}
+}
+
+// forwardChannels opens every incoming channel on the other side of the
+// connection and relays it
+func forwardChannels(in <-chan ssh.NewChannel, out ssh.Conn) {
+	for newChannel := range in {
+		go forwardChannel(newChannel, out)
+	}
+}
+
+// forwardChannel relays one channel: data, stderr and channel requests such
+// as pty-req, shell, exec and exit-status
+func forwardChannel(newChannel ssh.NewChannel, out ssh.Conn) {
+	target, targetReqs, err := out.OpenChannel(newChannel.ChannelType(), newChannel.ExtraData())
+	if err != nil {
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) {
+			newChannel.Reject(openErr.Reason, openErr.Message)
+		} else {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		}
+		return
+	}
+	source, sourceReqs, err := newChannel.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+
+	output := make(chan struct{})
+	go copyStream(target, source, source.Stderr(), target.Stderr())
+	go func() {
+		copyStream(source, target, target.Stderr(), source.Stderr())
+		close(output)
+	}()
+
+	// Whichever side closes first closes the other. The client's requests
+	// are answered before its channel closes, and exit-status arrives on the
+	// target's requests after its output, before its requests end.
+	var replying sync.Mutex
+	go func() {
+		for req := range sourceReqs {
+			replying.Lock()
+			forwardChannelRequest(req, target)
+			replying.Unlock()
+		}
+		target.Close()
+	}()
+	for req := range targetReqs {
+		forwardChannelRequest(req, source)
+	}
+	<-output
+	replying.Lock()
+	defer replying.Unlock()
+	source.Close()
+	target.Close()
+}
+
+// copyStream copies a channel's data and stderr to dst and signals EOF when done
+func copyStream(dst, src ssh.Channel, srcStderr io.Reader, dstStderr io.Writer) {
+	var stderr sync.WaitGroup
+	stderr.Add(1)
+	go func() {
+		defer stderr.Done()
+		io.Copy(dstStderr, srcStderr)
+	}()
+	io.Copy(dst, src)
+	stderr.Wait()
+	dst.CloseWrite()
+}
+
+// forwardChannelRequest relays a channel request to the other side
+func forwardChannelRequest(req *ssh.Request, out ssh.Channel) {
+	ok, err := out.SendRequest(req.Type, req.WantReply, req.Payload)
+	if req.WantReply {
+		req.Reply(ok && err == nil, nil)
+	}
+}
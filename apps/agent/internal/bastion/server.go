@@ -0,0 +1,235 @@
+// Package bastion is an SSH jump server: it accepts "ssh {workspaceId}@{host}",
+// authenticates the workspace owner by their registered public keys, wakes the
+// workspace if needed and relays the connection to the workspace's own SSH server.
+package bastion
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"golang.org/x/crypto/ssh"
+)
+
+// WorkspaceUser is the account the bastion signs in to workspaces as
+const WorkspaceUser = "dev8"
+
+// Permission extensions recorded on authenticated connections
+const (
+	extUserID      = "dev8-user-id"
+	extFingerprint = "dev8-key-fingerprint"
+)
+
+// handshakeTimeout bounds how long a client may take to authenticate
+const handshakeTimeout = 30 * time.Second
+
+// Server is the SSH bastion
+type Server struct {
+	service     *services.EnvironmentService
+	config      *ssh.ServerConfig
+	signer      ssh.Signer // Signs the bastion in to workspaces
+	wakeTimeout time.Duration
+	dial        func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// NewServer creates the bastion, generating the host and client keys that are
+// not configured
+func NewServer(service *services.EnvironmentService, cfg config.SSHBastionConfig) (*Server, error) {
+	hostKey, err := loadOrGenerateKey(cfg.HostKeyFile, "SSH_BASTION_HOST_KEY_FILE not set - clients will see a new host key after every restart")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH bastion host key: %w", err)
+	}
+	clientKey, err := loadOrGenerateKey(cfg.ClientKeyFile, "SSH_BASTION_CLIENT_KEY_FILE not set - workspaces must restart after every agent restart to accept the bastion")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH bastion client key: %w", err)
+	}
+
+	s := &Server{
+		service:     service,
+		signer:      clientKey,
+		wakeTimeout: cfg.WakeTimeout,
+		dial:        (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+	}
+	s.config = &ssh.ServerConfig{PublicKeyCallback: s.authenticate}
+	s.config.AddHostKey(hostKey)
+	return s, nil
+}
+
+// ClientKey returns the authorized_keys line workspaces must accept for the bastion
+func (s *Server) ClientKey() string {
+	return string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(s.signer.PublicKey()))) + " dev8-bastion"
+}
+
+// ListenAndServe accepts SSH connections on addr until Close is called
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts SSH connections on listener until Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops accepting connections; established sessions run until they end
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// authenticate accepts the public keys registered by the owner of the
+// workspace named by the SSH user
+func (s *Server) authenticate(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	workspaceID := meta.User()
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	env, err := s.service.GetEnvironment(context.Background(), workspaceID)
+	if err != nil {
+		log.Printf("🔒 SSH bastion rejected %s for workspace %s: %v", meta.RemoteAddr(), workspaceID, err)
+		return nil, fmt.Errorf("unknown workspace %q", workspaceID)
+	}
+	keys, err := s.service.SSHKeys(env.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range keys {
+		registered, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err == nil && bytes.Equal(registered.Marshal(), key.Marshal()) {
+			return &ssh.Permissions{Extensions: map[string]string{
+				extUserID:      env.UserID,
+				extFingerprint: fingerprint,
+			}}, nil
+		}
+	}
+	log.Printf("🔒 SSH bastion rejected %s for workspace %s: key %s is not registered for user %s", meta.RemoteAddr(), workspaceID, fingerprint, env.UserID)
+	return nil, fmt.Errorf("key %s is not registered for the owner of workspace %q", fingerprint, workspaceID)
+}
+
+// handle authenticates a client connection and relays it to its workspace
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	clientConn, clientChans, clientReqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer clientConn.Close()
+	conn.SetDeadline(time.Time{})
+
+	workspaceID := clientConn.User()
+	userID := clientConn.Permissions.Extensions[extUserID]
+	started := time.Now()
+	log.Printf("🔑 SSH session opened: user %s (key %s) from %s to workspace %s",
+		userID, clientConn.Permissions.Extensions[extFingerprint], clientConn.RemoteAddr(), workspaceID)
+	defer func() {
+		log.Printf("🔑 SSH session closed: user %s on workspace %s after %s", userID, workspaceID, time.Since(started).Round(time.Second))
+	}()
+
+	workspaceConn, workspaceChans, workspaceReqs, err := s.connectWorkspace(workspaceID)
+	if err != nil {
+		log.Printf("Warning: SSH bastion could not reach workspace %s: %v", workspaceID, err)
+		go ssh.DiscardRequests(clientReqs)
+		for newChannel := range clientChans {
+			newChannel.Reject(ssh.ConnectionFailed, fmt.Sprintf("workspace %s is unavailable: %v", workspaceID, err))
+		}
+		return
+	}
+	defer workspaceConn.Close()
+
+	// Relay in both directions: port forwards open channels from the workspace side too
+	go forwardRequests(clientReqs, workspaceConn)
+	go forwardRequests(workspaceReqs, clientConn)
+	go forwardChannels(workspaceChans, clientConn)
+	go func() {
+		workspaceConn.Wait()
+		clientConn.Close()
+	}()
+	forwardChannels(clientChans, workspaceConn)
+}
+
+// connectWorkspace wakes a workspace and signs in to its SSH server
+func (s *Server) connectWorkspace(workspaceID string) (ssh.Conn, <-chan ssh.NewChannel, <-chan *ssh.Request, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.wakeTimeout)
+	defer cancel()
+
+	env, err := s.service.WakeEnvironment(ctx, workspaceID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if env.SSHAddress == "" {
+		return nil, nil, nil, fmt.Errorf("workspace %s has no SSH address", workspaceID)
+	}
+
+	conn, err := s.dial(ctx, "tcp", env.SSHAddress)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	workspaceConn, chans, reqs, err := ssh.NewClientConn(conn, env.SSHAddress, &ssh.ClientConfig{
+		User: WorkspaceUser,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(s.signer)},
+		// Workspace host keys are generated inside each container and never
+		// recorded; the address comes from the compute provider, not the client
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return workspaceConn, chans, reqs, nil
+}
+
+// loadOrGenerateKey reads a PEM private key, or generates an ed25519 key when
+// path is empty and logs warning
+func loadOrGenerateKey(path, warning string) (ssh.Signer, error) {
+	if path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return ssh.ParsePrivateKey(pem)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Warning: %s", warning)
+	return ssh.NewSignerFromKey(key)
+}
@@ -0,0 +1,212 @@
+package bastion
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey() error = %v", err)
+	}
+	return signer
+}
+
+// fakeWorkspace is a workspace SSH server that accepts the bastion as dev8
+// and answers every exec request with "ran: {command}"
+func fakeWorkspace(t *testing.T, bastionKey ssh.PublicKey) net.Listener {
+	t.Helper()
+
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() != WorkspaceUser || string(key.Marshal()) != string(bastionKey.Marshal()) {
+				return nil, fmt.Errorf("unexpected login %s", meta.User())
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(newSigner(t))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range requests {
+							var exec struct{ Command string }
+							if req.Type != "exec" || ssh.Unmarshal(req.Payload, &exec) != nil {
+								req.Reply(false, nil)
+								continue
+							}
+							req.Reply(true, nil)
+							fmt.Fprintf(channel, "ran: %s\n", exec.Command)
+							channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+							channel.Close()
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestServer(t *testing.T) {
+	cfg := &config.Config{
+		Provider:       config.ProviderFake,
+		ContainerImage: "vaibhavsing/dev8-workspace:latest",
+		RegistryServer: "index.docker.io",
+		AgentBaseURL:   "http://localhost:8080",
+		StatePath:      filepath.Join(t.TempDir(), "agent.db"),
+		Azure: config.AzureConfig{
+			Regions: []config.RegionConfig{{Name: "eastus", Location: "eastus", Enabled: true}},
+		},
+		SSHBastion: config.SSHBastionConfig{Host: "ssh.dev8.test", WakeTimeout: 30 * time.Second},
+	}
+	fakeProvider := fake.NewProvider(fake.Options{Regions: []string{"eastus"}})
+	providers := provider.NewRegistry(models.ProviderAzure)
+	providers.Register(models.ProviderAzure, fakeProvider)
+	service, err := services.NewEnvironmentService(cfg, providers)
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	t.Cleanup(service.Close)
+
+	server, err := NewServer(service, cfg.SSHBastion)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	service.SetBastionKey(server.ClientKey())
+	workspace := fakeWorkspace(t, server.signer.PublicKey())
+	// Every workspace answers on the fake workspace server
+	server.dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, workspace.Addr().String())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	owner, stranger := newSigner(t), newSigner(t)
+	if _, err := service.SetSSHKeys("user-1", []string{string(ssh.MarshalAuthorizedKey(owner.PublicKey()))}); err != nil {
+		t.Fatalf("SetSSHKeys() error = %v", err)
+	}
+
+	ctx := context.Background()
+	workspaceID := "550e8400-e29b-41d4-a716-446655440040"
+	env, err := service.CreateEnvironment(ctx, &models.CreateEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		UserID:      "user-1",
+		Name:        "Bastion Test",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+		StorageGB:   20,
+	})
+	if err != nil {
+		t.Fatalf("CreateEnvironment() error = %v", err)
+	}
+	if want := "ssh://" + workspaceID + "@ssh.dev8.test"; env.ConnectionURLs.SSHURL != want {
+		t.Errorf("SSH URL = %q, want %q", env.ConnectionURLs.SSHURL, want)
+	}
+	if spec, _ := fakeProvider.Spec("eastus", "aci-"+workspaceID); !strings.Contains(spec.SSHPublicKey, server.ClientKey()) {
+		t.Errorf("workspace authorized keys = %q, want the bastion key", spec.SSHPublicKey)
+	}
+	if err := service.StopEnvironment(ctx, &models.StopEnvironmentRequest{WorkspaceID: workspaceID, CloudRegion: "eastus"}); err != nil {
+		t.Fatalf("StopEnvironment() error = %v", err)
+	}
+
+	connect := func(user string, signer ssh.Signer) (*ssh.Client, error) {
+		return ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         10 * time.Second,
+		})
+	}
+
+	rejected := []struct {
+		name   string
+		user   string
+		signer ssh.Signer
+	}{
+		{"unregistered key", workspaceID, stranger},
+		{"unknown workspace", "550e8400-e29b-41d4-a716-446655440099", owner},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if client, err := connect(tt.user, tt.signer); err == nil {
+				client.Close()
+				t.Errorf("connect() succeeded, want authentication to fail")
+			}
+		})
+	}
+
+	client, err := connect(workspaceID, owner)
+	if err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	defer session.Close()
+	output, err := session.Output("whoami")
+	if err != nil {
+		t.Fatalf("Output() error = %v", err)
+	}
+	if string(output) != "ran: whoami\n" {
+		t.Errorf("Output() = %q, want %q", output, "ran: whoami\n")
+	}
+
+	woken, err := service.GetEnvironment(ctx, workspaceID)
+	if err != nil {
+		t.Fatalf("GetEnvironment() error = %v", err)
+	}
+	if woken.Status != models.StatusRunning {
+		t.Errorf("status after connecting = %v, want %v", woken.Status, models.StatusRunning)
+	}
+}
@@ -6,6 +6,15 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+)
+
+// Compute provider backends selectable with AGENT_PROVIDER
+const (
+	ProviderAzure      = "azure"
+	ProviderFake       = "fake"
+	ProviderDocker     = "docker"
+	ProviderKubernetes = "kubernetes"
 )
 
 // Config holds the application configuration
@@ -17,9 +26,21 @@ type Config struct {
 	// Database Configuration
 	DatabaseURL string // Optional - not used by Agent, kept for future
 
+	// Compute Provider Configuration
+	Provider   string // Backend used for workspaces: "azure" (default), "fake", "docker" or "kubernetes"
+	Fake       FakeConfig
+	Docker     DockerConfig
+	Kubernetes KubernetesConfig
+
 	// Azure Configuration
 	Azure AzureConfig
 
+	// AWS Configuration (optional second provider for cloudProvider "AWS")
+	AWS AWSConfig
+
+	// GCP Configuration (optional provider for cloudProvider "GCP")
+	GCP GCPConfig
+
 	// Container Image Configuration
 	ContainerImage     string
 	ContainerImageName string // Image name without registry (e.g., "dev8-workspace:latest")
@@ -28,9 +49,50 @@ type Config struct {
 	RegistryPassword   string
 	AgentBaseURL       string
 
+	// Lifecycle Operations (create/start/stop/delete run asynchronously)
+	OperationTimeout   time.Duration // Deadline for a single operation
+	OperationRetention time.Duration // How long completed operations stay queryable
+	IdempotencyTTL     time.Duration // How long Idempotency-Key results are replayed
+
+	// Readiness checks run before a created/started workspace is reported RUNNING
+	Readiness ReadinessConfig
+
+	// App ports clients may ask workspaces to expose
+	Ports PortsConfig
+
+	// Preview URLs proxying browsers to app ports
+	Preview PreviewConfig
+
+	// TLS gateway proxying browsers to workspace IDEs
+	Gateway GatewayConfig
+
+	// SSH jump server routing connections to workspaces
+	SSHBastion SSHBastionConfig
+
+	// State Store (optional bbolt file; empty keeps the agent stateless)
+	StatePath string
+
+	// Per-workspace locks serialising lifecycle operations
+	Lock LockConfig
+
+	// Reconciler (finds leaked aci-*/fs-* resources)
+	Reconcile ReconcileConfig
+
+	// Idle auto-stop policy
+	Idle IdleConfig
+
+	// Per-user and per-organisation resource quotas
+	Quota QuotaConfig
+
 	// CORS Configuration
 	CORSAllowedOrigins []string
 
+	// API Authentication
+	Auth AuthConfig
+
+	// Per-workspace supervisor credentials for activity reports
+	SupervisorToken SupervisorTokenConfig
+
 	// Application Settings
 	Environment string
 	LogLevel    string
@@ -47,6 +109,270 @@ type AzureConfig struct {
 	// Multi-region support
 	Regions       []RegionConfig
 	DefaultRegion string
+
+	// Retries, deadlines and circuit breakers around Azure API calls
+	Resilience ResilienceConfig
+}
+
+// ResilienceConfig holds how Azure calls are retried, bounded and short-circuited
+type ResilienceConfig struct {
+	MaxAttempts      int           // Attempts per call, including the first
+	BaseDelay        time.Duration // Backoff before the first retry; doubles per retry
+	MaxDelay         time.Duration // Backoff cap
+	CallTimeout      time.Duration // Deadline for a single Azure operation, retries included
+	LongCallTimeout  time.Duration // Deadline for long-running operations (container group create/delete)
+	BreakerThreshold int           // Consecutive failures that open a region's circuit breaker
+	BreakerCooldown  time.Duration // How long an open breaker fails calls before probing the region
+}
+
+// AWSConfig holds configuration for the ECS Fargate + EFS provider (enabled when AWS_REGIONS is set)
+type AWSConfig struct {
+	Regions          []AWSRegionConfig
+	ExecutionRoleARN string // Role ECS uses to pull images and write logs
+	TaskRoleARN      string // Optional role assumed by the workspace container
+	Endpoint         string // Overrides the ECS/EFS/EC2 endpoints (local stand-ins)
+}
+
+// AWSRegionConfig holds the per-region Fargate networking and EFS file system
+type AWSRegionConfig struct {
+	Name           string // AWS region, e.g. "us-east-1"
+	Cluster        string
+	Subnets        []string
+	SecurityGroups []string
+	FileSystemID   string // Shared EFS file system; each workspace gets an access point
+}
+
+// GCPConfig holds configuration for the Cloud Run + GCS provider (enabled when GCP_REGIONS is set)
+type GCPConfig struct {
+	ProjectID            string
+	Regions              []GCPRegionConfig
+	ServiceAccount       string // Optional service account the workspace runs as
+	AllowUnauthenticated bool   // Opt-in: grant allUsers run.invoker (code-server has its own password)
+	RunEndpoint          string // Overrides https://run.googleapis.com (local fakes)
+	StorageEndpoint      string // Overrides https://storage.googleapis.com (local fakes)
+	SecretsEndpoint      string // Overrides https://secretmanager.googleapis.com (local fakes)
+}
+
+// GCPRegionConfig holds the per-region home volume bucket
+type GCPRegionConfig struct {
+	Name   string // GCP region, e.g. "us-central1"
+	Bucket string // Shared GCS bucket; each workspace home is the fs-{id}/ prefix
+}
+
+// AuthConfig holds the accepted API credentials. With none configured the API is unauthenticated.
+type AuthConfig struct {
+	APIKeys    []string // Shared service keys (Next.js); callers act as admin
+	JWTSecret  string   // HS256 secret for user tokens
+	JWKSFile   string   // JWKS file with the RS256 public keys for user tokens
+	Issuer     string   // Required token issuer, if set
+	Audience   string   // Required token audience, if set
+	AdminScope string   // Token scope that may act for any user
+}
+
+// Enabled reports whether any credential is configured
+func (c AuthConfig) Enabled() bool {
+	return len(c.APIKeys) > 0 || c.JWTSecret != "" || c.JWKSFile != ""
+}
+
+// SupervisorTokenConfig holds the key used to mint per-workspace supervisor tokens.
+// Without a secret the agent uses a random one, so tokens do not survive agent restarts.
+type SupervisorTokenConfig struct {
+	Secret          string        // HMAC key for new tokens
+	PreviousSecrets []string      // Still accepted while rotating the key
+	TTL             time.Duration // Token lifetime; workspaces get a fresh token on every start
+}
+
+// ReadinessConfig holds how long create/start wait for a workspace to serve traffic.
+// A probe timeout of 0 skips that probe.
+type ReadinessConfig struct {
+	PollInterval      time.Duration // Time between readiness polls
+	RuntimeTimeout    time.Duration // How long to wait for the runtime to run with an FQDN
+	IDETimeout        time.Duration // How long to wait for the IDE port
+	SupervisorTimeout time.Duration // How long to wait for the supervisor /health endpoint
+}
+
+// PortsConfig holds the allowlist for user-requested app ports
+type PortsConfig struct {
+	Allowed         []PortRange // Ports a create or start request may expose
+	MaxPerWorkspace int         // App ports per workspace; 0 disables app ports
+}
+
+// PreviewConfig holds the preview proxy serving https://{port}-{workspaceId}.preview.{Domain}
+type PreviewConfig struct {
+	Domain     string        // Base domain of preview hosts; empty disables previews
+	Secret     string        // HMAC key for private preview links and sessions
+	LinkTTL    time.Duration // How long a private preview link can be opened
+	SessionTTL time.Duration // How long a browser stays signed in to a private preview
+}
+
+// Enabled reports whether preview URLs are served
+func (c PreviewConfig) Enabled() bool {
+	return c.Domain != ""
+}
+
+// GatewayConfig holds the authenticated gateway serving https://{workspaceId}.{Domain}
+type GatewayConfig struct {
+	Domain      string        // Base domain of workspace hosts; empty disables the gateway
+	Addr        string        // Listen address of the gateway
+	TLSCertFile string        // PEM certificate for *.{Domain}; empty serves plain HTTP behind a TLS proxy
+	TLSKeyFile  string        // PEM private key of the certificate
+	Secret      string        // HMAC key for gateway links and sessions
+	LinkTTL     time.Duration // How long a gateway link can be opened
+	SessionTTL  time.Duration // How long a browser stays signed in to a workspace
+}
+
+// Enabled reports whether the gateway is served
+func (c GatewayConfig) Enabled() bool {
+	return c.Domain != ""
+}
+
+// TLS reports whether the gateway terminates TLS itself
+func (c GatewayConfig) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// SSHBastionConfig holds the SSH jump server accepting ssh {workspaceId}@{Host}
+type SSHBastionConfig struct {
+	Host          string        // Public host[:port] of the bastion; empty disables it
+	Addr          string        // Listen address of the bastion
+	HostKeyFile   string        // PEM private key identifying the bastion to clients
+	ClientKeyFile string        // PEM private key the bastion signs in to workspaces with
+	WakeTimeout   time.Duration // How long a connection waits for a stopped workspace to start
+}
+
+// Enabled reports whether the bastion is served
+func (c SSHBastionConfig) Enabled() bool {
+	return c.Host != ""
+}
+
+// PortRange is an inclusive range of TCP ports
+type PortRange struct {
+	From int
+	To   int
+}
+
+// Allows reports whether port is in the allowlist
+func (c PortsConfig) Allows(port int) bool {
+	for _, r := range c.Allowed {
+		if port >= r.From && port <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// Lock backends selectable with AGENT_LOCK_BACKEND
+const (
+	LockBackendLocal     = "local"      // In-process locks; one agent replica only
+	LockBackendAzureBlob = "azure-blob" // Azure Blob leases shared by every replica
+)
+
+// LockConfig holds the per-workspace lock backend
+type LockConfig struct {
+	Backend       string        // "local" (default) or "azure-blob"
+	Container     string        // Blob container holding the lock blobs
+	LeaseDuration time.Duration // Lease length; Azure accepts 15s-60s
+}
+
+// ReconcileConfig holds configuration for the background orphaned-resource reconciler
+type ReconcileConfig struct {
+	Interval time.Duration // Time between passes; 0 disables the background loop
+	Cleanup  bool          // Delete orphans instead of only reporting them
+	MinAge   time.Duration // Volumes younger than this are never treated as orphaned
+}
+
+// IdleConfig holds the idle auto-stop policy. A timeout of 0 never stops the workspace.
+type IdleConfig struct {
+	Timeout       time.Duration            // Global idle timeout
+	UserTimeouts  map[string]time.Duration // Per-user overrides of the global timeout
+	Warning       time.Duration            // How long before the stop the workspace is warned
+	CheckInterval time.Duration            // Time between idle checks; 0 disables the policy
+	WebhookURL    string                   // Receives the warnings; empty only logs them
+	WebhookSecret string                   // Signs webhook bodies when set
+}
+
+// TimeoutFor returns the idle timeout for a user's workspace without a per-workspace override
+func (c IdleConfig) TimeoutFor(userID string) time.Duration {
+	if timeout, ok := c.UserTimeouts[userID]; ok {
+		return timeout
+	}
+	return c.Timeout
+}
+
+// QuotaLimits caps the resources of a user or organisation; 0 means unlimited
+type QuotaLimits struct {
+	RunningWorkspaces int // Workspaces creating, starting or running
+	CPUCores          int // Total cores of running workspaces
+	MemoryGB          int // Total memory of running workspaces
+	StorageGB         int // Total volume size of all workspaces, running or stopped
+}
+
+// Unlimited reports whether no limit is set
+func (l QuotaLimits) Unlimited() bool {
+	return l == QuotaLimits{}
+}
+
+// QuotaConfig holds the quota limits. Quotas need the state store to account usage.
+type QuotaConfig struct {
+	Default QuotaLimits            // Applies to every user without an override
+	Users   map[string]QuotaLimits // Per-user overrides (unset fields inherit Default)
+	Orgs    map[string]QuotaLimits // Per-organisation limits; other orgs are unlimited
+}
+
+// Enabled reports whether any limit is configured
+func (c QuotaConfig) Enabled() bool {
+	if !c.Default.Unlimited() {
+		return true
+	}
+	for _, limits := range c.Users {
+		if !limits.Unlimited() {
+			return true
+		}
+	}
+	for _, limits := range c.Orgs {
+		if !limits.Unlimited() {
+			return true
+		}
+	}
+	return false
+}
+
+// ForUser returns the limits of a user
+func (c QuotaConfig) ForUser(userID string) QuotaLimits {
+	if limits, ok := c.Users[userID]; ok {
+		return limits
+	}
+	return c.Default
+}
+
+// ForOrg returns the limits of an organisation
+func (c QuotaConfig) ForOrg(orgID string) QuotaLimits {
+	return c.Orgs[orgID]
+}
+
+// FakeConfig holds configuration for the in-memory provider (AGENT_PROVIDER=fake)
+type FakeConfig struct {
+	VolumeDelay  time.Duration
+	RuntimeDelay time.Duration
+	DomainSuffix string
+}
+
+// DockerConfig holds configuration for the Docker Engine provider (AGENT_PROVIDER=docker)
+type DockerConfig struct {
+	Host       string // DOCKER_HOST style address, e.g. unix:///var/run/docker.sock
+	APIVersion string
+	PublicHost string // Host name clients use to reach published workspace ports
+	Network    string
+	AlwaysPull bool
+}
+
+// KubernetesConfig holds configuration for the Kubernetes provider (AGENT_PROVIDER=kubernetes)
+type KubernetesConfig struct {
+	Kubeconfig   string // Path to a kubeconfig file; empty uses the in-cluster service account
+	Namespace    string
+	StorageClass string // StorageClass for workspace PVCs; empty uses the cluster default
+	ServiceType  string // ClusterIP, NodePort or LoadBalancer
+	PublicHost   string // Host name clients use to reach NodePort services
 }
 
 // RegionConfig holds region-specific configuration
@@ -56,6 +382,20 @@ type RegionConfig struct {
 	Enabled           bool
 	ResourceGroupName string
 	StorageAccount    string
+
+	// Automatic placement ("cloudRegion": "auto")
+	Weight   int // Relative share of auto-placed workspaces; 0 excludes the region
+	MaxCores int // CPU cores the agent may run in the region; 0 = unlimited
+
+	// Private networking: container groups get a private IP in the delegated
+	// subnet and are reached through the gateway and SSH bastion only
+	SubnetID       string // Resource ID of a subnet delegated to Microsoft.ContainerInstance/containerGroups
+	PrivateDNSZone string // Resource ID of a private DNS zone for {containerGroup}.{zone} records; empty = private IP
+}
+
+// Private reports whether container groups in the region get a private IP
+func (r RegionConfig) Private() bool {
+	return r.SubnetID != ""
 }
 
 // Load loads configuration from environment variables
@@ -74,11 +414,166 @@ func Load() (*Config, error) {
 		RegistryUsername:   getEnv("REGISTRY_USERNAME", ""), // Optional
 		RegistryPassword:   getEnv("REGISTRY_PASSWORD", ""), // Optional
 		AgentBaseURL:       getEnv("AGENT_BASE_URL", "http://localhost:8080"),
+
+		// Lifecycle operations
+		OperationTimeout:   getDurationEnv("OPERATION_TIMEOUT", 10*time.Minute),
+		OperationRetention: getDurationEnv("OPERATION_RETENTION", time.Hour),
+		IdempotencyTTL:     getDurationEnv("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		// Readiness checks
+		Readiness: ReadinessConfig{
+			PollInterval:      getDurationEnv("READINESS_POLL_INTERVAL", 2*time.Second),
+			RuntimeTimeout:    getDurationEnv("READINESS_RUNTIME_TIMEOUT", 5*time.Minute),
+			IDETimeout:        getDurationEnv("IDE_READY_TIMEOUT", 2*time.Minute),
+			SupervisorTimeout: getDurationEnv("SUPERVISOR_READY_TIMEOUT", time.Minute),
+		},
+
+		// State store
+		StatePath: getEnv("AGENT_STATE_PATH", ""),
+
+		// Preview URLs
+		Preview: PreviewConfig{
+			Domain:     strings.ToLower(strings.Trim(getEnv("PREVIEW_DOMAIN", ""), ".")),
+			Secret:     getEnv("PREVIEW_SECRET", ""),
+			LinkTTL:    getDurationEnv("PREVIEW_LINK_TTL", 5*time.Minute),
+			SessionTTL: getDurationEnv("PREVIEW_SESSION_TTL", 12*time.Hour),
+		},
+
+		// Workspace gateway
+		Gateway: GatewayConfig{
+			Domain:      strings.ToLower(strings.Trim(getEnv("GATEWAY_DOMAIN", ""), ".")),
+			Addr:        getEnv("GATEWAY_ADDR", ":8443"),
+			TLSCertFile: getEnv("GATEWAY_TLS_CERT_FILE", ""),
+			TLSKeyFile:  getEnv("GATEWAY_TLS_KEY_FILE", ""),
+			Secret:      getEnv("GATEWAY_SECRET", ""),
+			LinkTTL:     getDurationEnv("GATEWAY_LINK_TTL", 5*time.Minute),
+			SessionTTL:  getDurationEnv("GATEWAY_SESSION_TTL", 12*time.Hour),
+		},
+
+		// SSH bastion
+		SSHBastion: SSHBastionConfig{
+			Host:          strings.ToLower(getEnv("SSH_BASTION_HOST", "")),
+			Addr:          getEnv("SSH_BASTION_ADDR", ":2022"),
+			HostKeyFile:   getEnv("SSH_BASTION_HOST_KEY_FILE", ""),
+			ClientKeyFile: getEnv("SSH_BASTION_CLIENT_KEY_FILE", ""),
+			WakeTimeout:   getDurationEnv("SSH_BASTION_WAKE_TIMEOUT", 3*time.Minute),
+		},
+
+		// API authentication
+		Auth: AuthConfig{
+			APIKeys:    splitCSV(getEnv("AGENT_API_KEYS", "")),
+			JWTSecret:  getEnv("AUTH_JWT_SECRET", ""),
+			JWKSFile:   getEnv("AUTH_JWKS_FILE", ""),
+			Issuer:     getEnv("AUTH_JWT_ISSUER", ""),
+			Audience:   getEnv("AUTH_JWT_AUDIENCE", ""),
+			AdminScope: getEnv("AUTH_ADMIN_SCOPE", "dev8:admin"),
+		},
+
+		// Supervisor tokens
+		SupervisorToken: SupervisorTokenConfig{
+			Secret:          getEnv("SUPERVISOR_TOKEN_SECRET", ""),
+			PreviousSecrets: splitCSV(getEnv("SUPERVISOR_TOKEN_PREVIOUS_SECRETS", "")),
+			TTL:             getDurationEnv("SUPERVISOR_TOKEN_TTL", 7*24*time.Hour),
+		},
+
+		// Per-workspace locks
+		Lock: LockConfig{
+			Backend:       strings.ToLower(getEnv("AGENT_LOCK_BACKEND", LockBackendLocal)),
+			Container:     getEnv("AGENT_LOCK_CONTAINER", "dev8-locks"),
+			LeaseDuration: getDurationEnv("AGENT_LOCK_LEASE_DURATION", 30*time.Second),
+		},
+
+		// Reconciler
+		Reconcile: ReconcileConfig{
+			Interval: getDurationEnv("RECONCILE_INTERVAL", 15*time.Minute),
+			Cleanup:  getBoolEnv("RECONCILE_CLEANUP", false),
+			MinAge:   getDurationEnv("RECONCILE_MIN_AGE", time.Hour),
+		},
+
+		// Idle auto-stop policy (IDLE_TIMEOUT=0 keeps workspaces running)
+		Idle: IdleConfig{
+			Timeout:       getDurationEnv("IDLE_TIMEOUT", 0),
+			Warning:       getDurationEnv("IDLE_WARNING", 5*time.Minute),
+			CheckInterval: getDurationEnv("IDLE_CHECK_INTERVAL", time.Minute),
+			WebhookURL:    getEnv("IDLE_WEBHOOK_URL", ""),
+			WebhookSecret: getEnv("IDLE_WEBHOOK_SECRET", ""),
+		},
+
+		// Default per-user quota (0 = unlimited)
+		Quota: QuotaConfig{
+			Default: QuotaLimits{
+				RunningWorkspaces: getIntEnv("QUOTA_MAX_RUNNING_WORKSPACES", 0),
+				CPUCores:          getIntEnv("QUOTA_MAX_CPU_CORES", 0),
+				MemoryGB:          getIntEnv("QUOTA_MAX_MEMORY_GB", 0),
+				StorageGB:         getIntEnv("QUOTA_MAX_STORAGE_GB", 0),
+			},
+		},
+
+		// Compute Provider Configuration
+		Provider: strings.ToLower(getEnv("AGENT_PROVIDER", ProviderAzure)),
+		Fake: FakeConfig{
+			VolumeDelay:  getDurationEnv("FAKE_VOLUME_DELAY", 500*time.Millisecond),
+			RuntimeDelay: getDurationEnv("FAKE_RUNTIME_DELAY", 2*time.Second),
+			DomainSuffix: getEnv("FAKE_DOMAIN_SUFFIX", "localhost"),
+		},
+		Docker: DockerConfig{
+			Host:       getEnv("DOCKER_HOST", "unix:///var/run/docker.sock"),
+			APIVersion: getEnv("DOCKER_API_VERSION", "v1.43"),
+			PublicHost: getEnv("DOCKER_PUBLIC_HOST", "localhost"),
+			Network:    getEnv("DOCKER_NETWORK", ""),
+			AlwaysPull: getBoolEnv("DOCKER_ALWAYS_PULL", false),
+		},
+		Kubernetes: KubernetesConfig{
+			Kubeconfig:   getEnv("KUBECONFIG", ""),
+			Namespace:    getEnv("K8S_NAMESPACE", "dev8-workspaces"),
+			StorageClass: getEnv("K8S_STORAGE_CLASS", ""),
+			ServiceType:  getEnv("K8S_SERVICE_TYPE", "ClusterIP"),
+			PublicHost:   getEnv("K8S_PUBLIC_HOST", ""),
+		},
+	}
+
+	// Fake workspaces have no IDE or supervisor to probe
+	if config.Provider == ProviderFake {
+		if os.Getenv("IDE_READY_TIMEOUT") == "" {
+			config.Readiness.IDETimeout = 0
+		}
+		if os.Getenv("SUPERVISOR_READY_TIMEOUT") == "" {
+			config.Readiness.SupervisorTimeout = 0
+		}
 	}
 
 	// Load CORS configuration
 	config.CORSAllowedOrigins = loadCORSAllowedOrigins()
 
+	// Load the app port allowlist
+	allowedPorts, err := parsePortRanges(getEnv("WORKSPACE_ALLOWED_PORTS", "3000-5999,8000-8999"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WORKSPACE_ALLOWED_PORTS: %w", err)
+	}
+	config.Ports = PortsConfig{
+		Allowed:         allowedPorts,
+		MaxPerWorkspace: getIntEnv("WORKSPACE_MAX_APP_PORTS", 5),
+	}
+
+	// Load idle policy overrides
+	userTimeouts, err := loadIdleUserTimeouts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idle policy: %w", err)
+	}
+	config.Idle.UserTimeouts = userTimeouts
+
+	// Load quota overrides
+	userQuotas, err := loadQuotaLimits("QUOTA_USER_LIMITS", config.Quota.Default)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quotas: %w", err)
+	}
+	config.Quota.Users = userQuotas
+	orgQuotas, err := loadQuotaLimits("QUOTA_ORG_LIMITS", QuotaLimits{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quotas: %w", err)
+	}
+	config.Quota.Orgs = orgQuotas
+
 	// Load Azure configuration
 	azureConfig, err := loadAzureConfig()
 	if err != nil {
@@ -86,6 +581,20 @@ func Load() (*Config, error) {
 	}
 	config.Azure = azureConfig
 
+	// Load AWS configuration
+	awsConfig, err := loadAWSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	config.AWS = awsConfig
+
+	// Load GCP configuration
+	gcpConfig, err := loadGCPConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GCP configuration: %w", err)
+	}
+	config.GCP = gcpConfig
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
@@ -103,6 +612,15 @@ func loadAzureConfig() (AzureConfig, error) {
 		StorageAccountKey:  getEnv("AZURE_STORAGE_KEY", ""),
 		ContainerRegistry:  getEnv("AZURE_CONTAINER_REGISTRY", ""),
 		DefaultRegion:      getEnv("AZURE_DEFAULT_REGION", "eastus"),
+		Resilience: ResilienceConfig{
+			MaxAttempts:      getIntEnv("AZURE_RETRY_MAX_ATTEMPTS", 4),
+			BaseDelay:        getDurationEnv("AZURE_RETRY_BASE_DELAY", 500*time.Millisecond),
+			MaxDelay:         getDurationEnv("AZURE_RETRY_MAX_DELAY", 10*time.Second),
+			CallTimeout:      getDurationEnv("AZURE_CALL_TIMEOUT", 30*time.Second),
+			LongCallTimeout:  getDurationEnv("AZURE_LONG_CALL_TIMEOUT", 5*time.Minute),
+			BreakerThreshold: getIntEnv("AZURE_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getDurationEnv("AZURE_BREAKER_COOLDOWN", 30*time.Second),
+		},
 	}
 
 	// Load multi-region configuration
@@ -112,9 +630,102 @@ func loadAzureConfig() (AzureConfig, error) {
 	}
 	config.Regions = regions
 
+	if err := loadRegionPlacement(config.Regions); err != nil {
+		return config, fmt.Errorf("failed to load region placement: %w", err)
+	}
+
+	if err := loadRegionNetworks(config.Regions); err != nil {
+		return config, fmt.Errorf("failed to load region networks: %w", err)
+	}
+
 	return config, nil
 }
 
+// loadRegionPlacement applies the per-region placement weights and core limits
+func loadRegionPlacement(regions []RegionConfig) error {
+	for i := range regions {
+		regions[i].Weight = 1
+	}
+
+	// AZURE_REGION_WEIGHTS format: "eastus=3,westeurope=1"
+	weights, err := loadRegionInts("AZURE_REGION_WEIGHTS")
+	if err != nil {
+		return err
+	}
+	// AZURE_REGION_MAX_CORES format: "eastus=100,westeurope=40"
+	maxCores, err := loadRegionInts("AZURE_REGION_MAX_CORES")
+	if err != nil {
+		return err
+	}
+
+	for i := range regions {
+		if weight, ok := weights[regions[i].Name]; ok {
+			regions[i].Weight = weight
+		}
+		regions[i].MaxCores = maxCores[regions[i].Name]
+	}
+	return nil
+}
+
+// loadRegionInts parses a "region=value" list of non-negative integers
+func loadRegionInts(key string) (map[string]int, error) {
+	values := make(map[string]int)
+	for _, entry := range strings.Split(getEnv(key, ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		region, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(region) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q (expected 'region=value')", key, entry)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s value for region %s: %q", key, region, value)
+		}
+		values[strings.TrimSpace(region)] = n
+	}
+	return values, nil
+}
+
+// loadRegionNetworks applies the per-region private subnets and DNS zones
+func loadRegionNetworks(regions []RegionConfig) error {
+	// AZURE_REGION_SUBNETS format: "eastus=/subscriptions/.../virtualNetworks/vnet/subnets/workspaces"
+	subnets, err := loadRegionStrings("AZURE_REGION_SUBNETS")
+	if err != nil {
+		return err
+	}
+	// AZURE_REGION_PRIVATE_DNS_ZONES format: "eastus=/subscriptions/.../privateDnsZones/ws.dev8.internal"
+	zones, err := loadRegionStrings("AZURE_REGION_PRIVATE_DNS_ZONES")
+	if err != nil {
+		return err
+	}
+
+	for i := range regions {
+		regions[i].SubnetID = subnets[regions[i].Name]
+		regions[i].PrivateDNSZone = zones[regions[i].Name]
+	}
+	return nil
+}
+
+// loadRegionStrings parses a "region=value" list
+func loadRegionStrings(key string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, entry := range strings.Split(getEnv(key, ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		region, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(region) == "" || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q (expected 'region=value')", key, entry)
+		}
+		values[strings.TrimSpace(region)] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
 // loadRegions loads multi-region configuration from environment variables
 func loadRegions() ([]RegionConfig, error) {
 	// AZURE_REGIONS format: "eastus:East US:true:rg-eastus:storageeastus,westus:West US:true:rg-westus:storagewestus"
@@ -173,6 +784,149 @@ func loadRegions() ([]RegionConfig, error) {
 	return regions, nil
 }
 
+// loadAWSConfig loads the optional AWS Fargate configuration
+func loadAWSConfig() (AWSConfig, error) {
+	config := AWSConfig{
+		ExecutionRoleARN: getEnv("AWS_EXECUTION_ROLE_ARN", ""),
+		TaskRoleARN:      getEnv("AWS_TASK_ROLE_ARN", ""),
+		Endpoint:         getEnv("AWS_ENDPOINT_URL", ""),
+	}
+
+	// AWS_REGIONS format: "us-east-1:dev8-cluster:subnet-a|subnet-b:sg-1|sg-2:fs-123,us-west-2:..."
+	regionsEnv := getEnv("AWS_REGIONS", "")
+	if regionsEnv == "" {
+		return config, nil
+	}
+
+	splitList := func(s string) []string {
+		var items []string
+		for _, item := range strings.Split(s, "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+
+	for _, regionStr := range strings.Split(regionsEnv, ",") {
+		parts := strings.Split(strings.TrimSpace(regionStr), ":")
+		if len(parts) != 5 {
+			log.Printf("WARNING: Skipping malformed AWS region config (expected format 'region:cluster:subnets:securityGroups:fileSystemId'): %s", regionStr)
+			continue
+		}
+
+		config.Regions = append(config.Regions, AWSRegionConfig{
+			Name:           parts[0],
+			Cluster:        parts[1],
+			Subnets:        splitList(parts[2]),
+			SecurityGroups: splitList(parts[3]),
+			FileSystemID:   parts[4],
+		})
+	}
+
+	if len(config.Regions) == 0 {
+		return config, fmt.Errorf("no valid regions could be parsed from AWS_REGIONS environment variable")
+	}
+
+	return config, nil
+}
+
+// loadGCPConfig loads the optional GCP Cloud Run configuration
+func loadGCPConfig() (GCPConfig, error) {
+	config := GCPConfig{
+		ProjectID:            getEnv("GCP_PROJECT_ID", ""),
+		ServiceAccount:       getEnv("GCP_SERVICE_ACCOUNT", ""),
+		AllowUnauthenticated: getBoolEnv("GCP_ALLOW_UNAUTHENTICATED", false),
+		RunEndpoint:          getEnv("GCP_RUN_ENDPOINT", ""),
+		StorageEndpoint:      getEnv("GCP_STORAGE_ENDPOINT", ""),
+		SecretsEndpoint:      getEnv("GCP_SECRETS_ENDPOINT", ""),
+	}
+
+	// GCP_REGIONS format: "us-central1:dev8-homes-us,europe-west1:dev8-homes-eu"
+	regionsEnv := getEnv("GCP_REGIONS", "")
+	if regionsEnv == "" {
+		return config, nil
+	}
+
+	for _, regionStr := range strings.Split(regionsEnv, ",") {
+		parts := strings.Split(strings.TrimSpace(regionStr), ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Printf("WARNING: Skipping malformed GCP region config (expected format 'region:bucket'): %s", regionStr)
+			continue
+		}
+		config.Regions = append(config.Regions, GCPRegionConfig{Name: parts[0], Bucket: parts[1]})
+	}
+
+	if len(config.Regions) == 0 {
+		return config, fmt.Errorf("no valid regions could be parsed from GCP_REGIONS environment variable")
+	}
+
+	return config, nil
+}
+
+// loadIdleUserTimeouts loads per-user idle timeouts from IDLE_USER_TIMEOUTS
+func loadIdleUserTimeouts() (map[string]time.Duration, error) {
+	// IDLE_USER_TIMEOUTS format: "user-1=2h,user-2=0" (0 never stops the user's workspaces)
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(getEnv("IDLE_USER_TIMEOUTS", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		userID, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(userID) == "" {
+			return nil, fmt.Errorf("invalid IDLE_USER_TIMEOUTS entry %q (expected 'userId=duration')", entry)
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid idle timeout for user %s: %q", userID, value)
+		}
+		timeouts[strings.TrimSpace(userID)] = timeout
+	}
+	return timeouts, nil
+}
+
+// loadQuotaLimits loads per-user or per-org quota limits; unset fields inherit base
+func loadQuotaLimits(key string, base QuotaLimits) (map[string]QuotaLimits, error) {
+	// Format: "user-1:workspaces=5;cpu=16;memory=64;storage=500,user-2:cpu=4"
+	quotas := make(map[string]QuotaLimits)
+	for _, entry := range strings.Split(getEnv(key, ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		id, fields, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(id) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q (expected 'id:resource=limit;...')", key, entry)
+		}
+
+		limits := base
+		for _, field := range strings.Split(fields, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			limit, err := strconv.Atoi(strings.TrimSpace(value))
+			if !ok || err != nil || limit < 0 {
+				return nil, fmt.Errorf("invalid %s limit %q for %s", key, field, id)
+			}
+
+			switch strings.TrimSpace(name) {
+			case "workspaces":
+				limits.RunningWorkspaces = limit
+			case "cpu":
+				limits.CPUCores = limit
+			case "memory":
+				limits.MemoryGB = limit
+			case "storage":
+				limits.StorageGB = limit
+			default:
+				return nil, fmt.Errorf("unknown %s resource %q (expected workspaces, cpu, memory or storage)", key, name)
+			}
+		}
+		quotas[strings.TrimSpace(id)] = limits
+	}
+	return quotas, nil
+}
+
 // loadCORSAllowedOrigins loads CORS allowed origins from environment variables
 func loadCORSAllowedOrigins() []string {
 	// CORS_ALLOWED_ORIGINS format: comma-separated list of origins
@@ -203,8 +957,28 @@ func (c *Config) Validate() error {
 
 	// DATABASE_URL is now optional - Agent is stateless
 
-	if c.Azure.SubscriptionID == "" {
-		return fmt.Errorf("AZURE_SUBSCRIPTION_ID is required")
+	switch c.Provider {
+	case ProviderAzure:
+		if c.Azure.SubscriptionID == "" {
+			return fmt.Errorf("AZURE_SUBSCRIPTION_ID is required")
+		}
+	case ProviderFake:
+		// In-memory provider needs no cloud credentials
+	case ProviderDocker:
+		if c.Docker.Host == "" {
+			return fmt.Errorf("DOCKER_HOST is required when AGENT_PROVIDER=docker")
+		}
+	case ProviderKubernetes:
+		if c.Kubernetes.Namespace == "" {
+			return fmt.Errorf("K8S_NAMESPACE is required when AGENT_PROVIDER=kubernetes")
+		}
+		switch c.Kubernetes.ServiceType {
+		case "ClusterIP", "NodePort", "LoadBalancer":
+		default:
+			return fmt.Errorf("K8S_SERVICE_TYPE %q is not supported (expected ClusterIP, NodePort or LoadBalancer)", c.Kubernetes.ServiceType)
+		}
+	default:
+		return fmt.Errorf("AGENT_PROVIDER %q is not supported (expected one of %q, %q, %q, %q)", c.Provider, ProviderAzure, ProviderFake, ProviderDocker, ProviderKubernetes)
 	}
 
 	if len(c.Azure.Regions) == 0 {
@@ -224,6 +998,94 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("AGENT_BASE_URL is required")
 	}
 
+	if c.Ports.MaxPerWorkspace < 0 {
+		return fmt.Errorf("WORKSPACE_MAX_APP_PORTS must not be negative, got %d", c.Ports.MaxPerWorkspace)
+	}
+
+	if c.Preview.Enabled() {
+		if c.StatePath == "" {
+			return fmt.Errorf("PREVIEW_DOMAIN requires the state store - set AGENT_STATE_PATH")
+		}
+		if c.Preview.LinkTTL <= 0 || c.Preview.SessionTTL <= 0 {
+			return fmt.Errorf("PREVIEW_LINK_TTL and PREVIEW_SESSION_TTL must be positive")
+		}
+	}
+
+	if c.Gateway.Enabled() {
+		if c.StatePath == "" {
+			return fmt.Errorf("GATEWAY_DOMAIN requires the state store - set AGENT_STATE_PATH")
+		}
+		if (c.Gateway.TLSCertFile == "") != (c.Gateway.TLSKeyFile == "") {
+			return fmt.Errorf("GATEWAY_TLS_CERT_FILE and GATEWAY_TLS_KEY_FILE must be set together")
+		}
+		if c.Gateway.LinkTTL <= 0 || c.Gateway.SessionTTL <= 0 {
+			return fmt.Errorf("GATEWAY_LINK_TTL and GATEWAY_SESSION_TTL must be positive")
+		}
+	}
+
+	if c.SSHBastion.Enabled() {
+		if c.StatePath == "" {
+			return fmt.Errorf("SSH_BASTION_HOST requires the state store - set AGENT_STATE_PATH")
+		}
+		if c.SSHBastion.WakeTimeout <= 0 {
+			return fmt.Errorf("SSH_BASTION_WAKE_TIMEOUT must be positive")
+		}
+	}
+
+	if c.AWS.Enabled() {
+		if c.AWS.ExecutionRoleARN == "" {
+			return fmt.Errorf("AWS_EXECUTION_ROLE_ARN is required when AWS_REGIONS is set")
+		}
+		for _, region := range c.AWS.Regions {
+			if region.Cluster == "" || len(region.Subnets) == 0 || region.FileSystemID == "" {
+				return fmt.Errorf("AWS region %s requires a cluster, at least one subnet and an EFS file system", region.Name)
+			}
+		}
+	}
+
+	if c.GCP.Enabled() && c.GCP.ProjectID == "" {
+		return fmt.Errorf("GCP_PROJECT_ID is required when GCP_REGIONS is set")
+	}
+
+	if c.Provider == ProviderAzure {
+		resilience := c.Azure.Resilience
+		if resilience.MaxAttempts < 1 {
+			return fmt.Errorf("AZURE_RETRY_MAX_ATTEMPTS must be at least 1, got %d", resilience.MaxAttempts)
+		}
+		if resilience.BreakerThreshold < 1 {
+			return fmt.Errorf("AZURE_BREAKER_THRESHOLD must be at least 1, got %d", resilience.BreakerThreshold)
+		}
+		if resilience.CallTimeout <= 0 || resilience.LongCallTimeout <= 0 {
+			return fmt.Errorf("AZURE_CALL_TIMEOUT and AZURE_LONG_CALL_TIMEOUT must be positive")
+		}
+
+		for _, region := range c.GetEnabledRegions() {
+			if region.PrivateDNSZone != "" && !region.Private() {
+				return fmt.Errorf("Azure region %s has a private DNS zone but no subnet - set AZURE_REGION_SUBNETS", region.Name)
+			}
+			if region.PrivateDNSZone != "" && !strings.Contains(strings.ToLower(region.PrivateDNSZone), "/privatednszones/") {
+				return fmt.Errorf("private DNS zone of Azure region %s must be a resource ID, got %q", region.Name, region.PrivateDNSZone)
+			}
+			// Private workspaces have no public address, so the agent must be their ingress
+			if region.Private() && (!c.Gateway.Enabled() || !c.SSHBastion.Enabled()) {
+				return fmt.Errorf("Azure region %s is private - set GATEWAY_DOMAIN and SSH_BASTION_HOST so workspaces can be reached", region.Name)
+			}
+		}
+	}
+
+	switch c.Lock.Backend {
+	case LockBackendLocal:
+	case LockBackendAzureBlob:
+		if c.Azure.StorageAccountName == "" || c.Azure.StorageAccountKey == "" {
+			return fmt.Errorf("AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY are required when AGENT_LOCK_BACKEND=%s", LockBackendAzureBlob)
+		}
+		if c.Lock.LeaseDuration < 15*time.Second || c.Lock.LeaseDuration > 60*time.Second {
+			return fmt.Errorf("AGENT_LOCK_LEASE_DURATION must be between 15s and 60s, got %s", c.Lock.LeaseDuration)
+		}
+	default:
+		return fmt.Errorf("AGENT_LOCK_BACKEND %q is not supported (expected %q or %q)", c.Lock.Backend, LockBackendLocal, LockBackendAzureBlob)
+	}
+
 	return nil
 }
 
@@ -248,6 +1110,71 @@ func (c *Config) GetEnabledRegions() []RegionConfig {
 	return enabled
 }
 
+// Enabled reports whether the AWS provider is configured
+func (c AWSConfig) Enabled() bool {
+	return len(c.Regions) > 0
+}
+
+// GetRegion returns the AWS region configuration for the given region name
+func (c AWSConfig) GetRegion(name string) *AWSRegionConfig {
+	for _, region := range c.Regions {
+		if region.Name == name {
+			return &region
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether the GCP provider is configured
+func (c GCPConfig) Enabled() bool {
+	return len(c.Regions) > 0
+}
+
+// GetRegion returns the GCP region configuration for the given region name
+func (c GCPConfig) GetRegion(name string) *GCPRegionConfig {
+	for _, region := range c.Regions {
+		if region.Name == name {
+			return &region
+		}
+	}
+	return nil
+}
+
+// splitCSV splits a comma-separated list, dropping empty entries
+func splitCSV(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePortRanges parses comma-separated ports and ranges, e.g. "3000,8000-8999"
+func parsePortRanges(value string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, item := range splitCSV(value) {
+		from, to, isRange := strings.Cut(item, "-")
+		if !isRange {
+			to = from
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		end, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		if start < 1 || end > 65535 || start > end {
+			return nil, fmt.Errorf("invalid port range %q", item)
+		}
+		ranges = append(ranges, PortRange{From: start, To: end})
+	}
+	return ranges, nil
+}
+
 // getEnv gets an environment variable with a fallback default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -255,3 +1182,48 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getBoolEnv gets a boolean environment variable with a fallback default value
+func getBoolEnv(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("WARNING: Invalid boolean for %s (%q): %v - using default %t", key, value, err, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getIntEnv gets an integer environment variable with a fallback default value
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("WARNING: Invalid integer for %s (%q): %v - using default %d", key, value, err, defaultValue)
+		return defaultValue
+	}
+	return i
+}
+
+// getDurationEnv gets a duration environment variable with a fallback default value
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("WARNING: Invalid duration for %s (%q): %v - using default %s", key, value, err, defaultValue)
+		return defaultValue
+	}
+	return d
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -38,6 +40,177 @@ func TestLoad(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "fake provider without Azure credentials",
+			envVars: map[string]string{
+				"AGENT_PORT":     "8080",
+				"AGENT_PROVIDER": "fake",
+			},
+			wantErr: false,
+		},
+		{
+			name: "docker provider without Azure credentials",
+			envVars: map[string]string{
+				"AGENT_PORT":     "8080",
+				"AGENT_PROVIDER": "docker",
+			},
+			wantErr: false,
+		},
+		{
+			name: "kubernetes provider",
+			envVars: map[string]string{
+				"AGENT_PORT":     "8080",
+				"AGENT_PROVIDER": "kubernetes",
+			},
+			wantErr: false,
+		},
+		{
+			name: "kubernetes provider with unsupported service type",
+			envVars: map[string]string{
+				"AGENT_PORT":       "8080",
+				"AGENT_PROVIDER":   "kubernetes",
+				"K8S_SERVICE_TYPE": "ExternalName",
+			},
+			wantErr: true,
+		},
+		{
+			name: "aws regions without execution role",
+			envVars: map[string]string{
+				"AGENT_PORT":     "8080",
+				"AGENT_PROVIDER": "fake",
+				"AWS_REGIONS":    "us-east-1:dev8:subnet-a:sg-1:fs-123",
+			},
+			wantErr: true,
+		},
+		{
+			name: "gcp regions without project",
+			envVars: map[string]string{
+				"AGENT_PORT":     "8080",
+				"AGENT_PROVIDER": "fake",
+				"GCP_REGIONS":    "us-central1:dev8-homes",
+			},
+			wantErr: true,
+		},
+		{
+			name: "azure blob locks",
+			envVars: map[string]string{
+				"AGENT_PORT":            "8080",
+				"AZURE_SUBSCRIPTION_ID": "test-sub-id",
+				"AZURE_STORAGE_ACCOUNT": "dev8storage",
+				"AZURE_STORAGE_KEY":     "dGVzdGtleQ==",
+				"AGENT_LOCK_BACKEND":    "azure-blob",
+			},
+			wantErr: false,
+		},
+		{
+			name: "azure blob locks without storage account",
+			envVars: map[string]string{
+				"AGENT_PORT":         "8080",
+				"AGENT_PROVIDER":     "fake",
+				"AGENT_LOCK_BACKEND": "azure-blob",
+			},
+			wantErr: true,
+		},
+		{
+			name: "azure blob lease too long",
+			envVars: map[string]string{
+				"AGENT_PORT":                "8080",
+				"AZURE_SUBSCRIPTION_ID":     "test-sub-id",
+				"AZURE_STORAGE_ACCOUNT":     "dev8storage",
+				"AZURE_STORAGE_KEY":         "dGVzdGtleQ==",
+				"AGENT_LOCK_BACKEND":        "azure-blob",
+				"AGENT_LOCK_LEASE_DURATION": "5m",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown lock backend",
+			envVars: map[string]string{
+				"AGENT_PORT":         "8080",
+				"AGENT_PROVIDER":     "fake",
+				"AGENT_LOCK_BACKEND": "redis",
+			},
+			wantErr: true,
+		},
+		{
+			name: "azure retries disabled",
+			envVars: map[string]string{
+				"AGENT_PORT":               "8080",
+				"AZURE_SUBSCRIPTION_ID":    "test-sub-id",
+				"AZURE_RETRY_MAX_ATTEMPTS": "0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "previews without state store",
+			envVars: map[string]string{
+				"AGENT_PORT":     "8080",
+				"AGENT_PROVIDER": "fake",
+				"PREVIEW_DOMAIN": "dev8.dev",
+			},
+			wantErr: true,
+		},
+		{
+			name: "previews with state store",
+			envVars: map[string]string{
+				"AGENT_PORT":       "8080",
+				"AGENT_PROVIDER":   "fake",
+				"AGENT_STATE_PATH": "/tmp/agent.db",
+				"PREVIEW_DOMAIN":   "dev8.dev",
+			},
+			wantErr: false,
+		},
+		{
+			name: "gateway certificate without key",
+			envVars: map[string]string{
+				"AGENT_PORT":            "8080",
+				"AGENT_PROVIDER":        "fake",
+				"AGENT_STATE_PATH":      "/tmp/agent.db",
+				"GATEWAY_DOMAIN":        "ws.dev8.dev",
+				"GATEWAY_TLS_CERT_FILE": "/etc/dev8/tls.crt",
+			},
+			wantErr: true,
+		},
+		{
+			name: "gateway with TLS",
+			envVars: map[string]string{
+				"AGENT_PORT":            "8080",
+				"AGENT_PROVIDER":        "fake",
+				"AGENT_STATE_PATH":      "/tmp/agent.db",
+				"GATEWAY_DOMAIN":        "ws.dev8.dev",
+				"GATEWAY_TLS_CERT_FILE": "/etc/dev8/tls.crt",
+				"GATEWAY_TLS_KEY_FILE":  "/etc/dev8/tls.key",
+			},
+			wantErr: false,
+		},
+		{
+			name: "ssh bastion without state store",
+			envVars: map[string]string{
+				"AGENT_PORT":       "8080",
+				"AGENT_PROVIDER":   "fake",
+				"SSH_BASTION_HOST": "ssh.dev8.dev",
+			},
+			wantErr: true,
+		},
+		{
+			name: "ssh bastion with state store",
+			envVars: map[string]string{
+				"AGENT_PORT":       "8080",
+				"AGENT_PROVIDER":   "fake",
+				"AGENT_STATE_PATH": "/tmp/agent.db",
+				"SSH_BASTION_HOST": "ssh.dev8.dev",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown provider",
+			envVars: map[string]string{
+				"AGENT_PORT":            "8080",
+				"AGENT_PROVIDER":        "openstack",
+				"AZURE_SUBSCRIPTION_ID": "test-sub-id",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -212,3 +385,338 @@ func TestLoadCORSAllowedOrigins(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad_FakeProviderDelays(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("AGENT_PROVIDER", "FAKE")
+	os.Setenv("FAKE_RUNTIME_DELAY", "5s")
+	os.Setenv("FAKE_VOLUME_DELAY", "not-a-duration")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Provider != ProviderFake {
+		t.Errorf("Provider = %v, want %v", cfg.Provider, ProviderFake)
+	}
+	if cfg.Fake.RuntimeDelay.String() != "5s" {
+		t.Errorf("Fake.RuntimeDelay = %v, want 5s", cfg.Fake.RuntimeDelay)
+	}
+	if cfg.Fake.VolumeDelay.String() != "500ms" {
+		t.Errorf("Fake.VolumeDelay = %v, want default 500ms", cfg.Fake.VolumeDelay)
+	}
+	if cfg.Readiness.IDETimeout != 0 || cfg.Readiness.SupervisorTimeout != 0 {
+		t.Errorf("Readiness = %+v, want IDE and supervisor probes skipped", cfg.Readiness)
+	}
+}
+
+func TestLoad_AWSRegions(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("AGENT_PROVIDER", "fake")
+	os.Setenv("AWS_EXECUTION_ROLE_ARN", "arn:aws:iam::123456789012:role/ecsTaskExecutionRole")
+	os.Setenv("AWS_REGIONS", "us-east-1:dev8:subnet-a|subnet-b:sg-1:fs-123,malformed,eu-west-1:dev8-eu:subnet-c::fs-456")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if !cfg.AWS.Enabled() || len(cfg.AWS.Regions) != 2 {
+		t.Fatalf("AWS.Regions = %+v, want 2 regions", cfg.AWS.Regions)
+	}
+
+	region := cfg.AWS.GetRegion("us-east-1")
+	if region == nil {
+		t.Fatal("GetRegion(us-east-1) = nil")
+	}
+	if region.Cluster != "dev8" || len(region.Subnets) != 2 || region.SecurityGroups[0] != "sg-1" || region.FileSystemID != "fs-123" {
+		t.Errorf("region = %+v", region)
+	}
+	if eu := cfg.AWS.GetRegion("eu-west-1"); eu == nil || len(eu.SecurityGroups) != 0 {
+		t.Errorf("GetRegion(eu-west-1) = %+v, want region without security groups", eu)
+	}
+	if cfg.AWS.GetRegion("eastus") != nil {
+		t.Error("GetRegion(eastus) should be nil for an Azure region")
+	}
+}
+
+func TestLoad_GCPRegions(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("AGENT_PROVIDER", "fake")
+	os.Setenv("GCP_PROJECT_ID", "dev8-prod")
+	os.Setenv("GCP_REGIONS", "us-central1:dev8-homes-us,broken,europe-west1:dev8-homes-eu")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if !cfg.GCP.Enabled() || len(cfg.GCP.Regions) != 2 {
+		t.Fatalf("GCP.Regions = %+v, want 2 regions", cfg.GCP.Regions)
+	}
+	if region := cfg.GCP.GetRegion("europe-west1"); region == nil || region.Bucket != "dev8-homes-eu" {
+		t.Errorf("GetRegion(europe-west1) = %+v, want bucket dev8-homes-eu", region)
+	}
+	if cfg.GCP.AllowUnauthenticated {
+		t.Error("AllowUnauthenticated should default to false")
+	}
+	if cfg.GCP.GetRegion("eastus") != nil {
+		t.Error("GetRegion(eastus) should be nil for an Azure region")
+	}
+}
+
+func TestLoad_IdlePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		userTimeouts string
+		wantErr      bool
+		want         map[string]time.Duration
+	}{
+		{name: "unset", want: map[string]time.Duration{"user-1": 30 * time.Minute}},
+		{name: "overrides", userTimeouts: "user-1=2h, user-2=0", want: map[string]time.Duration{"user-1": 2 * time.Hour, "user-2": 0, "user-3": 30 * time.Minute}},
+		{name: "missing duration", userTimeouts: "user-1", wantErr: true},
+		{name: "invalid duration", userTimeouts: "user-1=soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv("AGENT_PROVIDER", "fake")
+			os.Setenv("IDLE_TIMEOUT", "30m")
+			if tt.userTimeouts != "" {
+				os.Setenv("IDLE_USER_TIMEOUTS", tt.userTimeouts)
+			}
+
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for userID, want := range tt.want {
+				if got := cfg.Idle.TimeoutFor(userID); got != want {
+					t.Errorf("TimeoutFor(%s) = %v, want %v", userID, got, want)
+				}
+			}
+			if cfg.Idle.Warning != 5*time.Minute || cfg.Idle.CheckInterval != time.Minute {
+				t.Errorf("Idle = %+v, want 5m warning and 1m check interval", cfg.Idle)
+			}
+		})
+	}
+}
+
+func TestLoad_Quotas(t *testing.T) {
+	tests := []struct {
+		name       string
+		userLimits string
+		orgLimits  string
+		wantErr    bool
+		wantUsers  map[string]QuotaLimits
+		wantOrgs   map[string]QuotaLimits
+	}{
+		{
+			name:      "defaults only",
+			wantUsers: map[string]QuotaLimits{"user-1": {RunningWorkspaces: 2, CPUCores: 8}},
+		},
+		{
+			name:       "overrides inherit defaults",
+			userLimits: "user-2:workspaces=5;storage=500",
+			orgLimits:  "org-1:cpu=64;memory=256",
+			wantUsers: map[string]QuotaLimits{
+				"user-1": {RunningWorkspaces: 2, CPUCores: 8},
+				"user-2": {RunningWorkspaces: 5, CPUCores: 8, StorageGB: 500},
+			},
+			wantOrgs: map[string]QuotaLimits{
+				"org-1": {CPUCores: 64, MemoryGB: 256},
+				"org-2": {},
+			},
+		},
+		{name: "unknown resource", userLimits: "user-2:gpus=1", wantErr: true},
+		{name: "negative limit", userLimits: "user-2:cpu=-1", wantErr: true},
+		{name: "missing id", orgLimits: "cpu=4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv("AGENT_PROVIDER", "fake")
+			os.Setenv("QUOTA_MAX_RUNNING_WORKSPACES", "2")
+			os.Setenv("QUOTA_MAX_CPU_CORES", "8")
+			os.Setenv("QUOTA_USER_LIMITS", tt.userLimits)
+			os.Setenv("QUOTA_ORG_LIMITS", tt.orgLimits)
+
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !cfg.Quota.Enabled() {
+				t.Error("Quota.Enabled() = false, want true")
+			}
+			for userID, want := range tt.wantUsers {
+				if got := cfg.Quota.ForUser(userID); got != want {
+					t.Errorf("ForUser(%s) = %+v, want %+v", userID, got, want)
+				}
+			}
+			for orgID, want := range tt.wantOrgs {
+				if got := cfg.Quota.ForOrg(orgID); got != want {
+					t.Errorf("ForOrg(%s) = %+v, want %+v", orgID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_RegionPlacement(t *testing.T) {
+	tests := []struct {
+		name         string
+		weights      string
+		maxCores     string
+		wantErr      bool
+		wantWeight   map[string]int
+		wantMaxCores map[string]int
+	}{
+		{
+			name:         "defaults",
+			wantWeight:   map[string]int{"eastus": 1, "westeurope": 1},
+			wantMaxCores: map[string]int{"eastus": 0, "westeurope": 0},
+		},
+		{
+			name:         "overrides",
+			weights:      "eastus=3, westeurope=0",
+			maxCores:     "westeurope=40",
+			wantWeight:   map[string]int{"eastus": 3, "westeurope": 0},
+			wantMaxCores: map[string]int{"eastus": 0, "westeurope": 40},
+		},
+		{name: "missing value", weights: "eastus", wantErr: true},
+		{name: "negative cores", maxCores: "eastus=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv("AGENT_PROVIDER", "fake")
+			os.Setenv("AZURE_REGIONS", "eastus:East US:true,westeurope:West Europe:true")
+			if tt.weights != "" {
+				os.Setenv("AZURE_REGION_WEIGHTS", tt.weights)
+			}
+			if tt.maxCores != "" {
+				os.Setenv("AZURE_REGION_MAX_CORES", tt.maxCores)
+			}
+
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for _, region := range cfg.Azure.Regions {
+				if region.Weight != tt.wantWeight[region.Name] {
+					t.Errorf("%s Weight = %d, want %d", region.Name, region.Weight, tt.wantWeight[region.Name])
+				}
+				if region.MaxCores != tt.wantMaxCores[region.Name] {
+					t.Errorf("%s MaxCores = %d, want %d", region.Name, region.MaxCores, tt.wantMaxCores[region.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_PrivateRegions(t *testing.T) {
+	const (
+		subnet = "/subscriptions/sub/resourceGroups/rg-net/providers/Microsoft.Network/virtualNetworks/vnet/subnets/workspaces"
+		zone   = "/subscriptions/sub/resourceGroups/rg-net/providers/Microsoft.Network/privateDnsZones/ws.dev8.internal"
+	)
+	ingress := map[string]string{"GATEWAY_DOMAIN": "ws.dev8.dev", "SSH_BASTION_HOST": "ssh.dev8.dev"}
+
+	tests := []struct {
+		name        string
+		envVars     map[string]string
+		wantErr     bool
+		wantSubnets map[string]string
+		wantZones   map[string]string
+	}{
+		{name: "public by default"},
+		{
+			name:        "private with ingress",
+			envVars:     map[string]string{"AZURE_REGION_SUBNETS": "eastus=" + subnet, "AZURE_REGION_PRIVATE_DNS_ZONES": "eastus=" + zone},
+			wantSubnets: map[string]string{"eastus": subnet},
+			wantZones:   map[string]string{"eastus": zone},
+		},
+		{name: "private without gateway", envVars: map[string]string{"AZURE_REGION_SUBNETS": "eastus=" + subnet, "GATEWAY_DOMAIN": ""}, wantErr: true},
+		{name: "private without bastion", envVars: map[string]string{"AZURE_REGION_SUBNETS": "eastus=" + subnet, "SSH_BASTION_HOST": ""}, wantErr: true},
+		{name: "dns zone without subnet", envVars: map[string]string{"AZURE_REGION_PRIVATE_DNS_ZONES": "eastus=" + zone}, wantErr: true},
+		{name: "dns zone name only", envVars: map[string]string{"AZURE_REGION_SUBNETS": "eastus=" + subnet, "AZURE_REGION_PRIVATE_DNS_ZONES": "eastus=ws.dev8.internal"}, wantErr: true},
+		{name: "missing subnet", envVars: map[string]string{"AZURE_REGION_SUBNETS": "eastus="}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv("AZURE_SUBSCRIPTION_ID", "test-sub-id")
+			os.Setenv("AZURE_REGIONS", "eastus:East US:true,westeurope:West Europe:true")
+			os.Setenv("AGENT_STATE_PATH", "/tmp/agent.db")
+			for key, value := range ingress {
+				os.Setenv(key, value)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for _, region := range cfg.Azure.Regions {
+				if region.SubnetID != tt.wantSubnets[region.Name] {
+					t.Errorf("%s SubnetID = %q, want %q", region.Name, region.SubnetID, tt.wantSubnets[region.Name])
+				}
+				if region.PrivateDNSZone != tt.wantZones[region.Name] {
+					t.Errorf("%s PrivateDNSZone = %q, want %q", region.Name, region.PrivateDNSZone, tt.wantZones[region.Name])
+				}
+				if region.Private() != (tt.wantSubnets[region.Name] != "") {
+					t.Errorf("%s Private() = %v, want %v", region.Name, region.Private(), !region.Private())
+				}
+			}
+		})
+	}
+}
+
+func TestParsePortRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []PortRange
+		wantErr bool
+	}{
+		{name: "single ports and ranges", value: "3000, 8000-8999", want: []PortRange{{3000, 3000}, {8000, 8999}}},
+		{name: "empty", value: "", want: nil},
+		{name: "not a number", value: "http", wantErr: true},
+		{name: "reversed range", value: "9000-8000", wantErr: true},
+		{name: "out of range", value: "70000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePortRanges(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePortRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePortRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
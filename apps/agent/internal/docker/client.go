@@ -0,0 +1,333 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultAPIVersion = "v1.43"
+
+// APIError is an error response from the Docker Engine API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker engine API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a Docker Engine 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client is a minimal Docker Engine API client (unix socket or TCP)
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewClient creates a client for a DOCKER_HOST style address
+// ("unix:///var/run/docker.sock", "tcp://127.0.0.1:2375" or "http://...")
+func NewClient(host, apiVersion string) (*Client, error) {
+	if apiVersion == "" {
+		apiVersion = defaultAPIVersion
+	}
+	if !strings.HasPrefix(apiVersion, "v") {
+		apiVersion = "v" + apiVersion
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:    10,
+		IdleConnTimeout: 90 * time.Second,
+	}
+
+	var baseURL string
+	switch u.Scheme {
+	case "unix":
+		socketPath := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+		// Host is ignored when dialing the socket
+		baseURL = "http://docker"
+	case "tcp", "http":
+		baseURL = "http://" + u.Host
+	case "https":
+		baseURL = "https://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %q", u.Scheme)
+	}
+
+	return &Client{
+		httpClient: &http.Client{Transport: transport},
+		baseURL:    baseURL + "/" + apiVersion,
+	}, nil
+}
+
+// do sends a request and decodes a JSON response into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, headers map[string]string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker engine request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var errBody struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &errBody) != nil || errBody.Message == "" {
+			errBody.Message = strings.TrimSpace(string(data))
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: errBody.Message}
+	}
+
+	if out == nil {
+		// Drain streaming responses (e.g. image pulls) so the operation completes
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode docker engine response: %w", err)
+	}
+	return nil
+}
+
+// Volume is a Docker named volume
+type Volume struct {
+	Name      string            `json:"Name"`
+	Driver    string            `json:"Driver,omitempty"`
+	Labels    map[string]string `json:"Labels,omitempty"`
+	CreatedAt string            `json:"CreatedAt,omitempty"` // RFC 3339, set by the engine
+}
+
+// CreateVolume creates a named volume
+func (c *Client) CreateVolume(ctx context.Context, volume Volume) error {
+	return c.do(ctx, http.MethodPost, "/volumes/create", nil, nil, volume, &Volume{})
+}
+
+// InspectVolume returns a named volume
+func (c *Client) InspectVolume(ctx context.Context, name string) (*Volume, error) {
+	var volume Volume
+	if err := c.do(ctx, http.MethodGet, "/volumes/"+url.PathEscape(name), nil, nil, nil, &volume); err != nil {
+		return nil, err
+	}
+	return &volume, nil
+}
+
+// ListVolumes lists named volumes matching the label filters
+func (c *Client) ListVolumes(ctx context.Context, labels []string) ([]Volume, error) {
+	filters, err := json.Marshal(map[string][]string{"label": labels})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode filters: %w", err)
+	}
+
+	var list struct {
+		Volumes []Volume `json:"Volumes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/volumes", url.Values{"filters": {string(filters)}}, nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Volumes, nil
+}
+
+// RemoveVolume removes a named volume
+func (c *Client) RemoveVolume(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/volumes/"+url.PathEscape(name), nil, nil, nil, nil)
+}
+
+// RegistryAuth holds credentials for pulling private images
+type RegistryAuth struct {
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	ServerAddress string `json:"serveraddress"`
+}
+
+// ImageExists checks whether an image is present locally
+func (c *Client) ImageExists(ctx context.Context, image string) (bool, error) {
+	err := c.do(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil, nil, &struct{}{})
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// PullImage pulls an image, waiting for the pull to finish
+func (c *Client) PullImage(ctx context.Context, image string, auth *RegistryAuth) error {
+	query := url.Values{"fromImage": {image}}
+	if !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") && !strings.Contains(image, "@") {
+		query.Set("tag", "latest")
+	}
+
+	headers := map[string]string{}
+	if auth != nil && auth.Username != "" {
+		data, err := json.Marshal(auth)
+		if err != nil {
+			return fmt.Errorf("failed to encode registry auth: %w", err)
+		}
+		headers["X-Registry-Auth"] = base64.URLEncoding.EncodeToString(data)
+	}
+
+	return c.do(ctx, http.MethodPost, "/images/create", query, headers, nil, nil)
+}
+
+// PortBinding binds a container port to a host port
+type PortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// HostConfig is the subset of the container host configuration used by the agent
+type HostConfig struct {
+	NanoCPUs      int64                    `json:"NanoCpus,omitempty"`
+	Memory        int64                    `json:"Memory,omitempty"`
+	Binds         []string                 `json:"Binds,omitempty"`
+	PortBindings  map[string][]PortBinding `json:"PortBindings,omitempty"`
+	RestartPolicy RestartPolicy            `json:"RestartPolicy"`
+	NetworkMode   string                   `json:"NetworkMode,omitempty"`
+}
+
+// RestartPolicy is the container restart policy
+type RestartPolicy struct {
+	Name string `json:"Name"`
+}
+
+// ContainerConfig is the body of a container create request
+type ContainerConfig struct {
+	Image        string              `json:"Image"`
+	Hostname     string              `json:"Hostname,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig   HostConfig          `json:"HostConfig"`
+}
+
+// CreateContainer creates a container and returns its ID
+func (c *Client) CreateContainer(ctx context.Context, name string, config ContainerConfig) (string, error) {
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, nil, config, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// StartContainer starts a created container
+func (c *Client) StartContainer(ctx context.Context, id string) error {
+	err := c.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/start", nil, nil, nil, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotModified {
+		return nil // Already started
+	}
+	return err
+}
+
+// ContainerJSON is the subset of a container inspect response used by the agent
+type ContainerJSON struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	State struct {
+		Status   string `json:"Status"`
+		Running  bool   `json:"Running"`
+		ExitCode int    `json:"ExitCode"`
+		Error    string `json:"Error"`
+	} `json:"State"`
+	NetworkSettings struct {
+		IPAddress string                   `json:"IPAddress"`
+		Ports     map[string][]PortBinding `json:"Ports"`
+		Networks  map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// InspectContainer returns container details
+func (c *Client) InspectContainer(ctx context.Context, name string) (*ContainerJSON, error) {
+	var container ContainerJSON
+	if err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil, nil, nil, &container); err != nil {
+		return nil, err
+	}
+	return &container, nil
+}
+
+// RemoveContainer force-removes a container (named volumes are kept)
+func (c *Client) RemoveContainer(ctx context.Context, name string) error {
+	query := url.Values{"force": {"true"}}
+	return c.do(ctx, http.MethodDelete, "/containers/"+url.PathEscape(name), query, nil, nil, nil)
+}
+
+// ContainerSummary is an entry of the container list response
+type ContainerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+	State  string            `json:"State"`
+}
+
+// ListContainers lists all containers (including stopped ones) matching the label filters
+func (c *Client) ListContainers(ctx context.Context, labels []string) ([]ContainerSummary, error) {
+	filters, err := json.Marshal(map[string][]string{"label": labels})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode filters: %w", err)
+	}
+
+	var containers []ContainerSummary
+	query := url.Values{"all": {"true"}, "filters": {string(filters)}}
+	if err := c.do(ctx, http.MethodGet, "/containers/json", query, nil, nil, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
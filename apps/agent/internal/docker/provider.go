@@ -0,0 +1,316 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// regionLabel records which agent region a container or volume belongs to
+const regionLabel = "dev8.region"
+
+// Options configures the Docker provider
+type Options struct {
+	// Regions served by this Docker host (agent region names)
+	Regions []string
+	// PublicHost is the host name or IP clients use to reach published ports
+	PublicHost string
+	// Network attaches workspace containers to a user-defined network (optional)
+	Network string
+	// AlwaysPull pulls the image on every create instead of only when missing
+	AlwaysPull bool
+}
+
+// Provider implements provider.ComputeProvider on a single Docker Engine host.
+// Workspaces run as containers; the fs-{id} file share becomes a named volume.
+type Provider struct {
+	client *Client
+	opts   Options
+}
+
+// NewProvider creates a Docker provider
+func NewProvider(client *Client, opts Options) *Provider {
+	if opts.PublicHost == "" {
+		opts.PublicHost = "localhost"
+	}
+	return &Provider{client: client, opts: opts}
+}
+
+// Name returns the backend name
+func (p *Provider) Name() string {
+	return "docker"
+}
+
+// HasRegion reports whether the region is served by this host
+func (p *Provider) HasRegion(region string) bool {
+	for _, r := range p.opts.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// Regions returns the regions served by this host
+func (p *Provider) Regions() []string {
+	return append([]string(nil), p.opts.Regions...)
+}
+
+// CreateVolume creates a named volume (quota is not enforced by the local driver)
+func (p *Provider) CreateVolume(ctx context.Context, region, name string, quotaGB int32) error {
+	if _, err := p.client.InspectVolume(ctx, name); err == nil {
+		return fmt.Errorf("failed to create volume: %s already exists", name)
+	}
+
+	err := p.client.CreateVolume(ctx, Volume{
+		Name: name,
+		Labels: map[string]string{
+			"managed-by":    "dev8-agent",
+			regionLabel:     region,
+			"dev8.quota-gb": strconv.Itoa(int(quotaGB)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create volume: %w", err)
+	}
+	return nil
+}
+
+// VolumeExists checks whether the named volume exists
+func (p *Provider) VolumeExists(ctx context.Context, region, name string) (bool, error) {
+	if _, err := p.client.InspectVolume(ctx, name); err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check volume existence: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteVolume removes the named volume
+func (p *Provider) DeleteVolume(ctx context.Context, region, name string) error {
+	if err := p.client.RemoveVolume(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete volume: %w", err)
+	}
+	return nil
+}
+
+// ListVolumes lists the agent's fs-* named volumes for the region
+func (p *Provider) ListVolumes(ctx context.Context, region string) ([]provider.Volume, error) {
+	list, err := p.client.ListVolumes(ctx, []string{"managed-by=dev8-agent", regionLabel + "=" + region})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	volumes := make([]provider.Volume, 0, len(list))
+	for _, volume := range list {
+		if !strings.HasPrefix(volume.Name, provider.VolumePrefix) {
+			continue
+		}
+		createdAt, _ := time.Parse(time.RFC3339, volume.CreatedAt)
+		volumes = append(volumes, provider.Volume{Name: volume.Name, Region: region, CreatedAt: createdAt})
+	}
+	return volumes, nil
+}
+
+// CreateRuntime pulls the image if needed, then creates and starts the workspace container
+func (p *Provider) CreateRuntime(ctx context.Context, region, name string, spec provider.ContainerGroupSpec) error {
+	if err := p.ensureImage(ctx, spec); err != nil {
+		return err
+	}
+
+	if _, err := p.client.CreateContainer(ctx, name, p.containerConfig(region, spec)); err != nil {
+		return fmt.Errorf("failed to create container: %w", err)
+	}
+
+	if err := p.client.StartContainer(ctx, name); err != nil {
+		// Don't leave a created-but-never-started container behind
+		_ = p.client.RemoveContainer(ctx, name)
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	log.Printf("🐳 [docker] Started container %s (%s)", name, spec.Image)
+	return nil
+}
+
+// GetRuntime inspects the workspace container
+func (p *Provider) GetRuntime(ctx context.Context, region, name string) (*provider.Runtime, error) {
+	container, err := p.client.InspectContainer(ctx, name)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, provider.ErrRuntimeNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	runtime := p.containerToRuntime(container, region)
+	return &runtime, nil
+}
+
+// DeleteRuntime force-removes the workspace container; the named volume is kept
+func (p *Provider) DeleteRuntime(ctx context.Context, region, name string) error {
+	if err := p.client.RemoveContainer(ctx, name); err != nil {
+		if IsNotFound(err) {
+			return provider.ErrRuntimeNotFound
+		}
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+// ListRuntimes lists agent-managed containers for the region
+func (p *Provider) ListRuntimes(ctx context.Context, region string) ([]provider.Runtime, error) {
+	containers, err := p.client.ListContainers(ctx, []string{"managed-by=dev8-agent", regionLabel + "=" + region})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	runtimes := make([]provider.Runtime, 0, len(containers))
+	for _, summary := range containers {
+		name := summary.ID
+		if len(summary.Names) > 0 {
+			name = strings.TrimPrefix(summary.Names[0], "/")
+		}
+
+		runtime, err := p.GetRuntime(ctx, region, name)
+		if err != nil {
+			log.Printf("Warning: failed to inspect container %s: %v", name, err)
+			continue
+		}
+		runtimes = append(runtimes, *runtime)
+	}
+	return runtimes, nil
+}
+
+// ensureImage pulls the workspace image when it is missing (or always, if configured)
+func (p *Provider) ensureImage(ctx context.Context, spec provider.ContainerGroupSpec) error {
+	if !p.opts.AlwaysPull {
+		exists, err := p.client.ImageExists(ctx, spec.Image)
+		if err != nil {
+			return fmt.Errorf("failed to inspect image: %w", err)
+		}
+		if exists {
+			return nil
+		}
+	}
+
+	var auth *RegistryAuth
+	if spec.RegistryUsername != "" {
+		auth = &RegistryAuth{
+			Username:      spec.RegistryUsername,
+			Password:      spec.RegistryPassword,
+			ServerAddress: spec.RegistryServer,
+		}
+	}
+
+	log.Printf("🐳 [docker] Pulling image %s", spec.Image)
+	if err := p.client.PullImage(ctx, spec.Image, auth); err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	return nil
+}
+
+// containerConfig maps a ContainerGroupSpec onto a Docker container create request
+func (p *Provider) containerConfig(region string, spec provider.ContainerGroupSpec) ContainerConfig {
+	// Docker has no secure environment variables; secrets are passed as plain env
+	var env []string
+	for _, envVar := range spec.EnvVars() {
+		env = append(env, envVar.Name+"="+envVar.Value)
+	}
+
+	labels := spec.Labels()
+	labels[regionLabel] = region
+
+	exposedPorts := make(map[string]struct{})
+	portBindings := make(map[string][]PortBinding)
+	for _, port := range spec.Ports() {
+		key := fmt.Sprintf("%d/tcp", port)
+		exposedPorts[key] = struct{}{}
+		// Empty HostPort lets Docker pick a free port so several workspaces share a host
+		portBindings[key] = []PortBinding{{HostIP: "", HostPort: ""}}
+	}
+
+	config := ContainerConfig{
+		Image:        spec.Image,
+		Hostname:     spec.DNSNameLabel,
+		Env:          env,
+		Labels:       labels,
+		ExposedPorts: exposedPorts,
+		HostConfig: HostConfig{
+			NanoCPUs:      int64(spec.CPUCores) * 1_000_000_000,
+			Memory:        int64(spec.MemoryGB) * 1024 * 1024 * 1024,
+			PortBindings:  portBindings,
+			RestartPolicy: RestartPolicy{Name: "on-failure"},
+			NetworkMode:   p.opts.Network,
+		},
+	}
+
+	if spec.FileShareName != "" {
+		// Single volume: Home directory (/home/dev8) - stores everything
+		config.HostConfig.Binds = []string{spec.FileShareName + ":/home/dev8"}
+	}
+
+	return config
+}
+
+// containerToRuntime converts a container inspect response into a provider-neutral runtime
+func (p *Provider) containerToRuntime(container *ContainerJSON, region string) provider.Runtime {
+	runtime := provider.Runtime{
+		Name:          strings.TrimPrefix(container.Name, "/"),
+		Region:        region,
+		ResourceGroup: "docker",
+		FQDN:          p.opts.PublicHost,
+		IPAddress:     container.NetworkSettings.IPAddress,
+		State:         mapContainerState(container.State.Status),
+		Tags:          container.Config.Labels,
+		Ports:         make(map[int]int),
+	}
+
+	if runtime.Tags == nil {
+		runtime.Tags = make(map[string]string)
+	}
+	runtime.EnvironmentID = runtime.Tags["environment"]
+	runtime.UserID = runtime.Tags["userId"]
+
+	if runtime.IPAddress == "" {
+		for _, network := range container.NetworkSettings.Networks {
+			if network.IPAddress != "" {
+				runtime.IPAddress = network.IPAddress
+				break
+			}
+		}
+	}
+
+	for containerPort, bindings := range container.NetworkSettings.Ports {
+		port, err := strconv.Atoi(strings.TrimSuffix(containerPort, "/tcp"))
+		if err != nil || len(bindings) == 0 {
+			continue
+		}
+		if hostPort, err := strconv.Atoi(bindings[0].HostPort); err == nil {
+			runtime.Ports[port] = hostPort
+		}
+	}
+
+	return runtime
+}
+
+// mapContainerState maps a Docker container status to a runtime state
+func mapContainerState(status string) provider.RuntimeState {
+	switch status {
+	case "running":
+		return provider.StateRunning
+	case "created", "restarting":
+		return provider.StatePending
+	case "exited", "paused", "removing":
+		return provider.StateStopped
+	case "dead":
+		return provider.StateFailed
+	default:
+		return provider.StateUnknown
+	}
+}
@@ -0,0 +1,323 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// fakeEngine is a minimal in-memory stand-in for the Docker Engine API
+type fakeEngine struct {
+	mu         sync.Mutex
+	volumes    map[string]Volume
+	containers map[string]ContainerConfig
+	running    map[string]bool
+	images     map[string]bool
+	pulls      []string
+}
+
+func newFakeEngine() *fakeEngine {
+	return &fakeEngine{
+		volumes:    make(map[string]Volume),
+		containers: make(map[string]ContainerConfig),
+		running:    make(map[string]bool),
+		images:     make(map[string]bool),
+	}
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1.43")
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "no such object"})
+	}
+
+	switch {
+	case r.Method == "POST" && path == "/volumes/create":
+		var v Volume
+		json.NewDecoder(r.Body).Decode(&v)
+		v.CreatedAt = "2025-01-02T03:04:05Z"
+		e.volumes[v.Name] = v
+		json.NewEncoder(w).Encode(v)
+	case r.Method == "GET" && path == "/volumes":
+		list := struct{ Volumes []Volume }{}
+		for _, v := range e.volumes {
+			list.Volumes = append(list.Volumes, v)
+		}
+		json.NewEncoder(w).Encode(list)
+	case strings.HasPrefix(path, "/volumes/"):
+		name := strings.TrimPrefix(path, "/volumes/")
+		v, ok := e.volumes[name]
+		if !ok {
+			notFound()
+			return
+		}
+		if r.Method == "DELETE" {
+			delete(e.volumes, name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(v)
+	case r.Method == "GET" && strings.HasPrefix(path, "/images/"):
+		if !e.images[strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")] {
+			notFound()
+			return
+		}
+		w.Write([]byte(`{}`))
+	case r.Method == "POST" && path == "/images/create":
+		image := r.URL.Query().Get("fromImage")
+		e.pulls = append(e.pulls, image)
+		e.images[image] = true
+		w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Done"}`))
+	case r.Method == "POST" && path == "/containers/create":
+		var c ContainerConfig
+		json.NewDecoder(r.Body).Decode(&c)
+		e.containers[r.URL.Query().Get("name")] = c
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"abc123"}`))
+	case r.Method == "GET" && path == "/containers/json":
+		var list []ContainerSummary
+		for name, c := range e.containers {
+			list = append(list, ContainerSummary{ID: name, Names: []string{"/" + name}, Labels: c.Labels})
+		}
+		json.NewEncoder(w).Encode(list)
+	case strings.HasPrefix(path, "/containers/"):
+		rest := strings.TrimPrefix(path, "/containers/")
+		name := strings.Split(rest, "/")[0]
+		c, ok := e.containers[name]
+		if !ok {
+			notFound()
+			return
+		}
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(rest, "/start"):
+			e.running[name] = true
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "DELETE":
+			delete(e.containers, name)
+			delete(e.running, name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			status := "created"
+			if e.running[name] {
+				status = "running"
+			}
+			resp := map[string]interface{}{
+				"Id":     "abc123",
+				"Name":   "/" + name,
+				"Config": map[string]interface{}{"Labels": c.Labels},
+				"State":  map[string]interface{}{"Status": status, "Running": e.running[name]},
+				"NetworkSettings": map[string]interface{}{
+					"IPAddress": "172.17.0.2",
+					"Ports": map[string][]PortBinding{
+						"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "49153"}},
+						"2222/tcp": {{HostIP: "0.0.0.0", HostPort: "49154"}},
+					},
+				},
+			}
+			json.NewEncoder(w).Encode(resp)
+		}
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+path, http.StatusTeapot)
+	}
+}
+
+// newTestProvider serves the fake engine on a unix socket, like the real daemon
+func newTestProvider(t *testing.T) (*Provider, *fakeEngine) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+
+	engine := newFakeEngine()
+	server := httptest.NewUnstartedServer(engine)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("unix://"+socketPath, "1.43")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	return NewProvider(client, Options{Regions: []string{"local"}, PublicHost: "build-01.internal"}), engine
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		wantURL string
+		wantErr bool
+	}{
+		{name: "unix socket", host: "unix:///var/run/docker.sock", wantURL: "http://docker/v1.43"},
+		{name: "tcp", host: "tcp://10.0.0.5:2375", wantURL: "http://10.0.0.5:2375/v1.43"},
+		{name: "unsupported scheme", host: "npipe:////./pipe/docker_engine", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.host, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && client.baseURL != tt.wantURL {
+				t.Errorf("baseURL = %v, want %v", client.baseURL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestProvider_Volumes(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	exists, err := p.VolumeExists(ctx, "local", "fs-ws-1")
+	if err != nil || exists {
+		t.Fatalf("VolumeExists() = %v, %v, want false", exists, err)
+	}
+
+	if err := p.CreateVolume(ctx, "local", "fs-ws-1", 25); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := p.CreateVolume(ctx, "local", "fs-ws-1", 25); err == nil {
+		t.Error("CreateVolume() should fail for an existing volume")
+	}
+
+	exists, err = p.VolumeExists(ctx, "local", "fs-ws-1")
+	if err != nil || !exists {
+		t.Fatalf("VolumeExists() = %v, %v, want true", exists, err)
+	}
+
+	volumes, err := p.ListVolumes(ctx, "local")
+	if err != nil {
+		t.Fatalf("ListVolumes() error = %v", err)
+	}
+	if len(volumes) != 1 || volumes[0].Name != "fs-ws-1" || volumes[0].CreatedAt.IsZero() {
+		t.Errorf("ListVolumes() = %+v, want fs-ws-1 with creation time", volumes)
+	}
+
+	if err := p.DeleteVolume(ctx, "local", "fs-ws-1"); err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+}
+
+func TestProvider_RuntimeLifecycle(t *testing.T) {
+	p, engine := newTestProvider(t)
+	ctx := context.Background()
+
+	spec := provider.ContainerGroupSpec{
+		Image:              "vaibhavsing/dev8-workspace:latest",
+		CPUCores:           2,
+		MemoryGB:           4,
+		DNSNameLabel:       "ws-1",
+		FileShareName:      "fs-ws-1",
+		EnvironmentID:      "ws-1",
+		UserID:             "user-1",
+		CodeServerPassword: "secret",
+	}
+
+	if err := p.CreateRuntime(ctx, "local", "aci-ws-1", spec); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	if len(engine.pulls) != 1 {
+		t.Errorf("image pulls = %v, want exactly one pull of a missing image", engine.pulls)
+	}
+
+	created := engine.containers["aci-ws-1"]
+	if created.HostConfig.NanoCPUs != 2_000_000_000 || created.HostConfig.Memory != 4*1024*1024*1024 {
+		t.Errorf("resources = %d CPU / %d memory, want 2 cores / 4GB", created.HostConfig.NanoCPUs, created.HostConfig.Memory)
+	}
+	if len(created.HostConfig.Binds) != 1 || created.HostConfig.Binds[0] != "fs-ws-1:/home/dev8" {
+		t.Errorf("Binds = %v, want [fs-ws-1:/home/dev8]", created.HostConfig.Binds)
+	}
+	for _, port := range []string{"8080/tcp", "2222/tcp", "9000/tcp"} {
+		if _, ok := created.ExposedPorts[port]; !ok {
+			t.Errorf("port %s is not exposed", port)
+		}
+	}
+	if created.Labels["managed-by"] != "dev8-agent" || created.Labels[regionLabel] != "local" {
+		t.Errorf("Labels = %v, want managed-by and region labels", created.Labels)
+	}
+	foundSecret := false
+	for _, env := range created.Env {
+		if env == "CODE_SERVER_PASSWORD=secret" {
+			foundSecret = true
+		}
+	}
+	if !foundSecret {
+		t.Errorf("Env = %v, want CODE_SERVER_PASSWORD", created.Env)
+	}
+
+	runtime, err := p.GetRuntime(ctx, "local", "aci-ws-1")
+	if err != nil {
+		t.Fatalf("GetRuntime() error = %v", err)
+	}
+	if runtime.State != provider.StateRunning || runtime.FQDN != "build-01.internal" {
+		t.Errorf("runtime = %+v, want running on build-01.internal", runtime)
+	}
+	if runtime.PublicPort(8080) != 49153 || runtime.PublicPort(2222) != 49154 || runtime.PublicPort(9000) != 9000 {
+		t.Errorf("Ports = %v, want published host ports", runtime.Ports)
+	}
+	if runtime.EnvironmentID != "ws-1" || runtime.UserID != "user-1" {
+		t.Errorf("EnvironmentID/UserID = %v/%v", runtime.EnvironmentID, runtime.UserID)
+	}
+
+	runtimes, err := p.ListRuntimes(ctx, "local")
+	if err != nil || len(runtimes) != 1 {
+		t.Fatalf("ListRuntimes() = %v, %v, want 1 runtime", runtimes, err)
+	}
+
+	if err := p.DeleteRuntime(ctx, "local", "aci-ws-1"); err != nil {
+		t.Fatalf("DeleteRuntime() error = %v", err)
+	}
+	if _, err := p.GetRuntime(ctx, "local", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Errorf("GetRuntime() after delete error = %v, want ErrRuntimeNotFound", err)
+	}
+	if err := p.DeleteRuntime(ctx, "local", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Errorf("DeleteRuntime() twice error = %v, want ErrRuntimeNotFound", err)
+	}
+
+	// Image is present now, so a restart does not pull again
+	if err := p.CreateRuntime(ctx, "local", "aci-ws-1", spec); err != nil {
+		t.Fatalf("CreateRuntime() restart error = %v", err)
+	}
+	if len(engine.pulls) != 1 {
+		t.Errorf("image pulls after restart = %v, want no additional pull", engine.pulls)
+	}
+}
+
+func TestMapContainerState(t *testing.T) {
+	tests := []struct {
+		status string
+		want   provider.RuntimeState
+	}{
+		{"running", provider.StateRunning},
+		{"created", provider.StatePending},
+		{"exited", provider.StateStopped},
+		{"dead", provider.StateFailed},
+		{"weird", provider.StateUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := mapContainerState(tt.status); got != tt.want {
+			t.Errorf("mapContainerState(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// Operation identifies a provider operation for failure injection
+type Operation string
+
+const (
+	OpCreateVolume  Operation = "create-volume"
+	OpVolumeExists  Operation = "volume-exists"
+	OpDeleteVolume  Operation = "delete-volume"
+	OpListVolumes   Operation = "list-volumes"
+	OpCreateRuntime Operation = "create-runtime"
+	OpGetRuntime    Operation = "get-runtime"
+	OpDeleteRuntime Operation = "delete-runtime"
+	OpListRuntimes  Operation = "list-runtimes"
+)
+
+// Options configures the in-memory provider
+type Options struct {
+	// Regions served by the provider (empty means every region)
+	Regions []string
+	// VolumeDelay simulates file share provisioning time
+	VolumeDelay time.Duration
+	// RuntimeDelay simulates container group provisioning time
+	RuntimeDelay time.Duration
+	// DomainSuffix is appended to "{dnsLabel}.{region}" to build FQDNs
+	DomainSuffix string
+}
+
+// Volume is an in-memory file share
+type Volume struct {
+	Name      string
+	Region    string
+	QuotaGB   int32
+	CreatedAt time.Time
+}
+
+// Provider is an in-memory provider.ComputeProvider for local development and tests
+type Provider struct {
+	opts Options
+
+	mu        sync.Mutex
+	volumes   map[string]Volume
+	runtimes  map[string]provider.Runtime
+	specs     map[string]provider.ContainerGroupSpec
+	failures  map[Operation]error
+	failNext  map[Operation]error
+	nextIPOct int
+}
+
+// NewProvider creates an empty in-memory provider
+func NewProvider(opts Options) *Provider {
+	if opts.DomainSuffix == "" {
+		opts.DomainSuffix = "localhost"
+	}
+
+	return &Provider{
+		opts:      opts,
+		volumes:   make(map[string]Volume),
+		runtimes:  make(map[string]provider.Runtime),
+		specs:     make(map[string]provider.ContainerGroupSpec),
+		failures:  make(map[Operation]error),
+		failNext:  make(map[Operation]error),
+		nextIPOct: 10,
+	}
+}
+
+// SetFailure makes every call to op fail with err until cleared with a nil error
+func (p *Provider) SetFailure(op Operation, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		delete(p.failures, op)
+		return
+	}
+	p.failures[op] = err
+}
+
+// FailNext makes only the next call to op fail with err
+func (p *Provider) FailNext(op Operation, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failNext[op] = err
+}
+
+// Spec returns the spec the runtime was created with (for assertions in tests)
+func (p *Provider) Spec(region, name string) (provider.ContainerGroupSpec, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spec, ok := p.specs[key(region, name)]
+	return spec, ok
+}
+
+// SetRuntimeState changes the state of an existing runtime (e.g. to simulate a failed container group)
+func (p *Provider) SetRuntimeState(region, name string, state provider.RuntimeState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := key(region, name)
+	if runtime, exists := p.runtimes[k]; exists {
+		runtime.State = state
+		p.runtimes[k] = runtime
+	}
+}
+
+// Volumes returns a snapshot of all volumes
+func (p *Provider) Volumes() []Volume {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	volumes := make([]Volume, 0, len(p.volumes))
+	for _, volume := range p.volumes {
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// Name returns the backend name
+func (p *Provider) Name() string {
+	return "fake"
+}
+
+// HasRegion reports whether the region is served
+func (p *Provider) HasRegion(region string) bool {
+	if len(p.opts.Regions) == 0 {
+		return region != ""
+	}
+	for _, r := range p.opts.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// Regions returns the configured regions (nil when every region is served)
+func (p *Provider) Regions() []string {
+	return append([]string(nil), p.opts.Regions...)
+}
+
+// CreateVolume creates an in-memory file share
+func (p *Provider) CreateVolume(ctx context.Context, region, name string, quotaGB int32) error {
+	if err := p.wait(ctx, OpCreateVolume, p.opts.VolumeDelay); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := key(region, name)
+	if _, exists := p.volumes[k]; exists {
+		return fmt.Errorf("failed to create file share: share %s already exists", name)
+	}
+
+	p.volumes[k] = Volume{Name: name, Region: region, QuotaGB: quotaGB, CreatedAt: time.Now()}
+	log.Printf("🧪 [fake] Created volume %s (%dGB) in %s", name, quotaGB, region)
+	return nil
+}
+
+// VolumeExists checks whether an in-memory file share exists
+func (p *Provider) VolumeExists(ctx context.Context, region, name string) (bool, error) {
+	if err := p.wait(ctx, OpVolumeExists, 0); err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, exists := p.volumes[key(region, name)]
+	return exists, nil
+}
+
+// DeleteVolume deletes an in-memory file share
+func (p *Provider) DeleteVolume(ctx context.Context, region, name string) error {
+	if err := p.wait(ctx, OpDeleteVolume, 0); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := key(region, name)
+	if _, exists := p.volumes[k]; !exists {
+		return fmt.Errorf("failed to delete file share: share %s not found", name)
+	}
+
+	delete(p.volumes, k)
+	log.Printf("🧪 [fake] Deleted volume %s in %s", name, region)
+	return nil
+}
+
+// ListVolumes lists in-memory file shares in a region
+func (p *Provider) ListVolumes(ctx context.Context, region string) ([]provider.Volume, error) {
+	if err := p.wait(ctx, OpListVolumes, 0); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var volumes []provider.Volume
+	for _, volume := range p.volumes {
+		if volume.Region == region && strings.HasPrefix(volume.Name, provider.VolumePrefix) {
+			volumes = append(volumes, provider.Volume{Name: volume.Name, Region: volume.Region, CreatedAt: volume.CreatedAt})
+		}
+	}
+	return volumes, nil
+}
+
+// CreateRuntime creates an in-memory container group in the Running state
+func (p *Provider) CreateRuntime(ctx context.Context, region, name string, spec provider.ContainerGroupSpec) error {
+	if err := p.wait(ctx, OpCreateRuntime, p.opts.RuntimeDelay); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := key(region, name)
+	if existing, exists := p.runtimes[k]; exists && existing.State == provider.StateRunning {
+		return fmt.Errorf("failed to create container group: %s already exists", name)
+	}
+
+	runtime := provider.Runtime{
+		Name:          name,
+		Region:        region,
+		ResourceGroup: "fake-" + region,
+		EnvironmentID: spec.EnvironmentID,
+		UserID:        spec.UserID,
+		IPAddress:     fmt.Sprintf("10.0.0.%d", p.nextIPOct),
+		State:         provider.StateRunning,
+		Tags: map[string]string{
+			"environment": spec.EnvironmentID,
+			"userId":      spec.UserID,
+			"managed-by":  "dev8-agent",
+		},
+	}
+	if spec.DNSNameLabel != "" {
+		runtime.FQDN = fmt.Sprintf("%s.%s.%s", spec.DNSNameLabel, region, p.opts.DomainSuffix)
+	}
+	p.nextIPOct = p.nextIPOct%250 + 1
+
+	p.runtimes[k] = runtime
+	p.specs[k] = spec
+	log.Printf("🧪 [fake] Created container group %s in %s (%s)", name, region, runtime.FQDN)
+	return nil
+}
+
+// GetRuntime returns an in-memory container group
+func (p *Provider) GetRuntime(ctx context.Context, region, name string) (*provider.Runtime, error) {
+	if err := p.wait(ctx, OpGetRuntime, 0); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	runtime, exists := p.runtimes[key(region, name)]
+	if !exists {
+		return nil, provider.ErrRuntimeNotFound
+	}
+	return &runtime, nil
+}
+
+// DeleteRuntime deletes an in-memory container group
+func (p *Provider) DeleteRuntime(ctx context.Context, region, name string) error {
+	if err := p.wait(ctx, OpDeleteRuntime, p.opts.RuntimeDelay/4); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := key(region, name)
+	if _, exists := p.runtimes[k]; !exists {
+		return provider.ErrRuntimeNotFound
+	}
+
+	delete(p.runtimes, k)
+	delete(p.specs, k)
+	log.Printf("🧪 [fake] Deleted container group %s in %s", name, region)
+	return nil
+}
+
+// ListRuntimes lists in-memory container groups in a region
+func (p *Provider) ListRuntimes(ctx context.Context, region string) ([]provider.Runtime, error) {
+	if err := p.wait(ctx, OpListRuntimes, 0); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var runtimes []provider.Runtime
+	for _, runtime := range p.runtimes {
+		if runtime.Region == region {
+			runtimes = append(runtimes, runtime)
+		}
+	}
+	return runtimes, nil
+}
+
+// wait simulates latency and returns any injected failure for op
+func (p *Provider) wait(ctx context.Context, op Operation, delay time.Duration) error {
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err, ok := p.failNext[op]; ok {
+		delete(p.failNext, op)
+		return err
+	}
+	return p.failures[op]
+}
+
+func key(region, name string) string {
+	return region + "/" + name
+}
@@ -0,0 +1,103 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+func TestProvider_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := NewProvider(Options{Regions: []string{"eastus"}})
+
+	if !p.HasRegion("eastus") || p.HasRegion("westus") {
+		t.Fatal("HasRegion() does not honour configured regions")
+	}
+
+	if err := p.CreateVolume(ctx, "eastus", "fs-ws-1", 25); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := p.CreateVolume(ctx, "eastus", "fs-ws-1", 25); err == nil {
+		t.Error("CreateVolume() should fail for an existing share")
+	}
+
+	exists, err := p.VolumeExists(ctx, "eastus", "fs-ws-1")
+	if err != nil || !exists {
+		t.Fatalf("VolumeExists() = %v, %v, want true", exists, err)
+	}
+
+	spec := provider.ContainerGroupSpec{DNSNameLabel: "ws-1", EnvironmentID: "ws-1", UserID: "user-1"}
+	if err := p.CreateRuntime(ctx, "eastus", "aci-ws-1", spec); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	runtime, err := p.GetRuntime(ctx, "eastus", "aci-ws-1")
+	if err != nil {
+		t.Fatalf("GetRuntime() error = %v", err)
+	}
+	if runtime.FQDN != "ws-1.eastus.localhost" {
+		t.Errorf("FQDN = %v, want ws-1.eastus.localhost", runtime.FQDN)
+	}
+	if runtime.State != provider.StateRunning || runtime.Tags["managed-by"] != "dev8-agent" {
+		t.Errorf("runtime = %+v, want running and tagged", runtime)
+	}
+
+	runtimes, _ := p.ListRuntimes(ctx, "eastus")
+	if len(runtimes) != 1 {
+		t.Errorf("ListRuntimes() returned %d runtimes, want 1", len(runtimes))
+	}
+
+	if err := p.DeleteRuntime(ctx, "eastus", "aci-ws-1"); err != nil {
+		t.Fatalf("DeleteRuntime() error = %v", err)
+	}
+	if _, err := p.GetRuntime(ctx, "eastus", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Errorf("GetRuntime() after delete error = %v, want ErrRuntimeNotFound", err)
+	}
+
+	if err := p.DeleteVolume(ctx, "eastus", "fs-ws-1"); err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+	if len(p.Volumes()) != 0 {
+		t.Errorf("Volumes() = %v, want empty", p.Volumes())
+	}
+}
+
+func TestProvider_FailureInjection(t *testing.T) {
+	ctx := context.Background()
+	p := NewProvider(Options{})
+	boom := errors.New("boom")
+
+	p.FailNext(OpCreateVolume, boom)
+	if err := p.CreateVolume(ctx, "eastus", "fs-1", 10); !errors.Is(err, boom) {
+		t.Errorf("CreateVolume() error = %v, want injected failure", err)
+	}
+	if err := p.CreateVolume(ctx, "eastus", "fs-1", 10); err != nil {
+		t.Errorf("CreateVolume() after FailNext error = %v, want nil", err)
+	}
+
+	p.SetFailure(OpVolumeExists, boom)
+	for i := 0; i < 2; i++ {
+		if _, err := p.VolumeExists(ctx, "eastus", "fs-1"); !errors.Is(err, boom) {
+			t.Errorf("VolumeExists() call %d error = %v, want persistent failure", i, err)
+		}
+	}
+	p.SetFailure(OpVolumeExists, nil)
+	if _, err := p.VolumeExists(ctx, "eastus", "fs-1"); err != nil {
+		t.Errorf("VolumeExists() after clearing error = %v, want nil", err)
+	}
+}
+
+func TestProvider_DelayHonoursContext(t *testing.T) {
+	p := NewProvider(Options{RuntimeDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := p.CreateRuntime(ctx, "eastus", "aci-1", provider.ContainerGroupSpec{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CreateRuntime() error = %v, want context deadline exceeded", err)
+	}
+}
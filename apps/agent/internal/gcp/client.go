@@ -0,0 +1,467 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRunEndpoint     = "https://run.googleapis.com"
+	defaultStorageEndpoint = "https://storage.googleapis.com"
+	defaultSecretsEndpoint = "https://secretmanager.googleapis.com"
+)
+
+// APIError is an error response from a Google Cloud REST API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("google cloud API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a Google Cloud 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a Google Cloud 409 (the resource already exists)
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// Client is a minimal REST client for Cloud Run Admin API v2, the Cloud Storage
+// JSON API and Secret Manager v1
+type Client struct {
+	httpClient      *http.Client
+	runEndpoint     string
+	storageEndpoint string
+	secretsEndpoint string
+	pollInterval    time.Duration
+}
+
+// NewClient creates a client; httpClient must attach credentials (e.g. google.DefaultClient).
+// Empty endpoints use the public Google APIs.
+func NewClient(httpClient *http.Client, runEndpoint, storageEndpoint, secretsEndpoint string) *Client {
+	if runEndpoint == "" {
+		runEndpoint = defaultRunEndpoint
+	}
+	if storageEndpoint == "" {
+		storageEndpoint = defaultStorageEndpoint
+	}
+	if secretsEndpoint == "" {
+		secretsEndpoint = defaultSecretsEndpoint
+	}
+	return &Client{
+		httpClient:      httpClient,
+		runEndpoint:     strings.TrimSuffix(runEndpoint, "/"),
+		storageEndpoint: strings.TrimSuffix(storageEndpoint, "/"),
+		secretsEndpoint: strings.TrimSuffix(secretsEndpoint, "/"),
+		pollInterval:    2 * time.Second,
+	}
+}
+
+// do sends a request and decodes a JSON response into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, endpoint string, body io.Reader, contentType string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("google cloud request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var errBody struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &errBody) == nil && errBody.Error.Message != "" {
+			message = errBody.Error.Message
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode google cloud response: %w", err)
+	}
+	return nil
+}
+
+// doJSON sends a JSON body (if non-nil) and decodes the JSON response
+func (c *Client) doJSON(ctx context.Context, method, endpoint string, in, out interface{}) error {
+	if in == nil {
+		return c.do(ctx, method, endpoint, nil, "", out)
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	return c.do(ctx, method, endpoint, bytes.NewReader(data), "application/json", out)
+}
+
+// Operation is a Cloud Run long-running operation
+type Operation struct {
+	Name  string `json:"name"`
+	Done  bool   `json:"done"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// waitOperation polls a long-running operation until it is done
+func (c *Client) waitOperation(ctx context.Context, op *Operation) error {
+	for !op.Done {
+		timer := time.NewTimer(c.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		next := &Operation{}
+		if err := c.doJSON(ctx, http.MethodGet, c.runEndpoint+"/v2/"+op.Name, nil, next); err != nil {
+			return fmt.Errorf("failed to poll operation: %w", err)
+		}
+		op = next
+	}
+
+	if op.Error != nil {
+		return fmt.Errorf("operation %s failed: %s", op.Name, op.Error.Message)
+	}
+	return nil
+}
+
+// Service is the subset of a Cloud Run v2 Service used by the agent
+type Service struct {
+	Name              string            `json:"name,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	Ingress           string            `json:"ingress,omitempty"`
+	Template          RevisionTemplate  `json:"template"`
+	URI               string            `json:"uri,omitempty"`
+	Reconciling       bool              `json:"reconciling,omitempty"`
+	TerminalCondition *Condition        `json:"terminalCondition,omitempty"`
+}
+
+// Condition is a Cloud Run resource condition
+type Condition struct {
+	Type    string `json:"type"`
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// RevisionTemplate describes the revision Cloud Run creates for a service
+type RevisionTemplate struct {
+	Labels                        map[string]string `json:"labels,omitempty"`
+	Scaling                       *Scaling          `json:"scaling,omitempty"`
+	ExecutionEnvironment          string            `json:"executionEnvironment,omitempty"`
+	ServiceAccount                string            `json:"serviceAccount,omitempty"`
+	Timeout                       string            `json:"timeout,omitempty"`
+	MaxInstanceRequestConcurrency int               `json:"maxInstanceRequestConcurrency,omitempty"`
+	Containers                    []Container       `json:"containers"`
+	Volumes                       []Volume          `json:"volumes,omitempty"`
+}
+
+// Scaling bounds the number of instances of a revision
+type Scaling struct {
+	MinInstanceCount int `json:"minInstanceCount"`
+	MaxInstanceCount int `json:"maxInstanceCount"`
+}
+
+// Container is a Cloud Run container
+type Container struct {
+	Name         string               `json:"name,omitempty"`
+	Image        string               `json:"image"`
+	Env          []EnvVar             `json:"env,omitempty"`
+	Ports        []ContainerPort      `json:"ports,omitempty"`
+	Resources    *ResourceRequirement `json:"resources,omitempty"`
+	VolumeMounts []VolumeMount        `json:"volumeMounts,omitempty"`
+}
+
+// EnvVar is a container environment variable, set directly or read from Secret Manager
+type EnvVar struct {
+	Name        string        `json:"name"`
+	Value       string        `json:"value,omitempty"`
+	ValueSource *EnvVarSource `json:"valueSource,omitempty"`
+}
+
+// EnvVarSource is where an environment variable's value comes from
+type EnvVarSource struct {
+	SecretKeyRef *SecretKeySelector `json:"secretKeyRef"`
+}
+
+// SecretKeySelector names a Secret Manager secret version in the service's project
+type SecretKeySelector struct {
+	Secret  string `json:"secret"`
+	Version string `json:"version"`
+}
+
+// ContainerPort is the port Cloud Run routes requests to
+type ContainerPort struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int    `json:"containerPort"`
+}
+
+// ResourceRequirement holds container CPU/memory limits
+type ResourceRequirement struct {
+	Limits          map[string]string `json:"limits,omitempty"`
+	CPUIdle         bool              `json:"cpuIdle"`
+	StartupCPUBoost bool              `json:"startupCpuBoost,omitempty"`
+}
+
+// Volume is a Cloud Run volume (only Cloud Storage volumes are used)
+type Volume struct {
+	Name string     `json:"name"`
+	GCS  *GCSVolume `json:"gcs,omitempty"`
+}
+
+// GCSVolume mounts a Cloud Storage bucket with Cloud Storage FUSE
+type GCSVolume struct {
+	Bucket       string   `json:"bucket"`
+	ReadOnly     bool     `json:"readOnly"`
+	MountOptions []string `json:"mountOptions,omitempty"`
+}
+
+// VolumeMount mounts a volume into a container
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+}
+
+func (c *Client) servicesURL(project, region string) string {
+	return fmt.Sprintf("%s/v2/projects/%s/locations/%s/services", c.runEndpoint, url.PathEscape(project), url.PathEscape(region))
+}
+
+// CreateService creates a Cloud Run service and waits for it to be deployed
+func (c *Client) CreateService(ctx context.Context, project, region, serviceID string, service *Service) error {
+	op := &Operation{}
+	endpoint := c.servicesURL(project, region) + "?serviceId=" + url.QueryEscape(serviceID)
+	if err := c.doJSON(ctx, http.MethodPost, endpoint, service, op); err != nil {
+		return err
+	}
+	return c.waitOperation(ctx, op)
+}
+
+// GetService returns a Cloud Run service
+func (c *Client) GetService(ctx context.Context, project, region, serviceID string) (*Service, error) {
+	service := &Service{}
+	if err := c.doJSON(ctx, http.MethodGet, c.servicesURL(project, region)+"/"+url.PathEscape(serviceID), nil, service); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// DeleteService deletes a Cloud Run service and waits for the deletion
+func (c *Client) DeleteService(ctx context.Context, project, region, serviceID string) error {
+	op := &Operation{}
+	if err := c.doJSON(ctx, http.MethodDelete, c.servicesURL(project, region)+"/"+url.PathEscape(serviceID), nil, op); err != nil {
+		return err
+	}
+	return c.waitOperation(ctx, op)
+}
+
+// ListServices lists all Cloud Run services in a region
+func (c *Client) ListServices(ctx context.Context, project, region string) ([]Service, error) {
+	var services []Service
+	pageToken := ""
+	for {
+		var page struct {
+			Services      []Service `json:"services"`
+			NextPageToken string    `json:"nextPageToken"`
+		}
+
+		endpoint := c.servicesURL(project, region)
+		if pageToken != "" {
+			endpoint += "?pageToken=" + url.QueryEscape(pageToken)
+		}
+		if err := c.doJSON(ctx, http.MethodGet, endpoint, nil, &page); err != nil {
+			return nil, err
+		}
+
+		services = append(services, page.Services...)
+		if page.NextPageToken == "" {
+			return services, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// AllowUnauthenticated grants allUsers the run.invoker role on a service
+func (c *Client) AllowUnauthenticated(ctx context.Context, project, region, serviceID string) error {
+	policy := map[string]interface{}{
+		"policy": map[string]interface{}{
+			"bindings": []map[string]interface{}{
+				{"role": "roles/run.invoker", "members": []string{"allUsers"}},
+			},
+		},
+	}
+	endpoint := c.servicesURL(project, region) + "/" + url.PathEscape(serviceID) + ":setIamPolicy"
+	return c.doJSON(ctx, http.MethodPost, endpoint, policy, nil)
+}
+
+// Object is a Cloud Storage object
+type Object struct {
+	Name string `json:"name"`
+}
+
+// CreateObject uploads an empty object, failing if it already exists
+func (c *Client) CreateObject(ctx context.Context, bucket, name string) error {
+	query := url.Values{"uploadType": {"media"}, "name": {name}, "ifGenerationMatch": {"0"}}
+	endpoint := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", c.storageEndpoint, url.PathEscape(bucket), query.Encode())
+	return c.do(ctx, http.MethodPost, endpoint, bytes.NewReader(nil), "application/octet-stream", nil)
+}
+
+// ListObjects lists objects with the given prefix; limit > 0 stops after that many objects
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix string, limit int) ([]Object, error) {
+	var objects []Object
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if limit > 0 {
+			query.Set("maxResults", fmt.Sprint(limit))
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Items         []Object `json:"items"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+		endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", c.storageEndpoint, url.PathEscape(bucket), query.Encode())
+		if err := c.doJSON(ctx, http.MethodGet, endpoint, nil, &page); err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, page.Items...)
+		if page.NextPageToken == "" || (limit > 0 && len(objects) >= limit) {
+			return objects, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// ListPrefixes lists the distinct "directories" directly below prefix, each ending in "/"
+func (c *Client) ListPrefixes(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var prefixes []string
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "delimiter": {"/"}, "fields": {"prefixes,nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Prefixes      []string `json:"prefixes"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+		endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", c.storageEndpoint, url.PathEscape(bucket), query.Encode())
+		if err := c.doJSON(ctx, http.MethodGet, endpoint, nil, &page); err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, page.Prefixes...)
+		if page.NextPageToken == "" {
+			return prefixes, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// DeleteObject deletes a Cloud Storage object
+func (c *Client) DeleteObject(ctx context.Context, bucket, name string) error {
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", c.storageEndpoint, url.PathEscape(bucket), url.PathEscape(name))
+	return c.do(ctx, http.MethodDelete, endpoint, nil, "", nil)
+}
+
+// Secret is a Secret Manager secret
+type Secret struct {
+	Name        string                 `json:"name,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Replication map[string]interface{} `json:"replication,omitempty"`
+}
+
+func (c *Client) secretsURL(project string) string {
+	return fmt.Sprintf("%s/v1/projects/%s/secrets", c.secretsEndpoint, url.PathEscape(project))
+}
+
+// CreateSecret creates an automatically replicated secret with no versions
+func (c *Client) CreateSecret(ctx context.Context, project, secretID string, labels map[string]string) error {
+	secret := &Secret{Labels: labels, Replication: map[string]interface{}{"automatic": map[string]interface{}{}}}
+	endpoint := c.secretsURL(project) + "?secretId=" + url.QueryEscape(secretID)
+	return c.doJSON(ctx, http.MethodPost, endpoint, secret, nil)
+}
+
+// AddSecretVersion stores data as a new version of a secret and returns the version ID
+func (c *Client) AddSecretVersion(ctx context.Context, project, secretID string, data []byte) (string, error) {
+	payload := map[string]interface{}{
+		"payload": map[string]string{"data": base64.StdEncoding.EncodeToString(data)},
+	}
+	var version struct {
+		Name string `json:"name"`
+	}
+	endpoint := c.secretsURL(project) + "/" + url.PathEscape(secretID) + ":addVersion"
+	if err := c.doJSON(ctx, http.MethodPost, endpoint, payload, &version); err != nil {
+		return "", err
+	}
+	return version.Name[strings.LastIndex(version.Name, "/")+1:], nil
+}
+
+// ListSecrets lists the secrets matching a Secret Manager filter expression
+func (c *Client) ListSecrets(ctx context.Context, project, filter string) ([]Secret, error) {
+	var secrets []Secret
+	pageToken := ""
+	for {
+		query := url.Values{"filter": {filter}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Secrets       []Secret `json:"secrets"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+		if err := c.doJSON(ctx, http.MethodGet, c.secretsURL(project)+"?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, page.Secrets...)
+		if page.NextPageToken == "" {
+			return secrets, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// DeleteSecret deletes a secret and all of its versions
+func (c *Client) DeleteSecret(ctx context.Context, project, secretID string) error {
+	return c.doJSON(ctx, http.MethodDelete, c.secretsURL(project)+"/"+url.PathEscape(secretID), nil, nil)
+}
@@ -0,0 +1,444 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// idePort is the only port Cloud Run routes to; SSH and the supervisor are not publicly reachable
+const idePort = provider.PortIDE
+
+// cloudRunCPUs are the CPU limits Cloud Run accepts
+var cloudRunCPUs = []int{1, 2, 4, 6, 8}
+
+// secretLabel labels the Secret Manager secrets that hold a runtime's secure env vars
+const secretLabel = "dev8-runtime"
+
+// invalidLabelChars matches characters GCP labels do not allow
+var invalidLabelChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// CloudRunProvider implements provider.ComputeProvider on Cloud Run + Cloud Storage.
+// A workspace is a single-instance Cloud Run service; the fs-{id} file share
+// becomes the fs-{id}/ prefix of the region's bucket, mounted with GCS FUSE.
+type CloudRunProvider struct {
+	config config.GCPConfig
+	client *Client
+}
+
+// NewCloudRunProvider creates the Cloud Run provider
+func NewCloudRunProvider(cfg config.GCPConfig, client *Client) *CloudRunProvider {
+	return &CloudRunProvider{config: cfg, client: client}
+}
+
+// Name returns the backend name
+func (p *CloudRunProvider) Name() string {
+	return "gcp-cloudrun"
+}
+
+// HasRegion reports whether the GCP region is configured
+func (p *CloudRunProvider) HasRegion(region string) bool {
+	return p.config.GetRegion(region) != nil
+}
+
+// Regions returns the configured GCP regions
+func (p *CloudRunProvider) Regions() []string {
+	regions := make([]string, 0, len(p.config.Regions))
+	for _, region := range p.config.Regions {
+		regions = append(regions, region.Name)
+	}
+	return regions
+}
+
+// CreateVolume creates the fs-{id}/ prefix in the region bucket.
+// Cloud Storage has no prefix quota, so quotaGB is not enforced.
+func (p *CloudRunProvider) CreateVolume(ctx context.Context, region, name string, quotaGB int32) error {
+	regionConfig, err := p.region(region)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.CreateObject(ctx, regionConfig.Bucket, name+"/"); err != nil {
+		return fmt.Errorf("failed to create home volume: %w", err)
+	}
+
+	log.Printf("☁️  [gcp] Created home volume gs://%s/%s/", regionConfig.Bucket, name)
+	return nil
+}
+
+// VolumeExists checks whether any object exists under the fs-{id}/ prefix
+func (p *CloudRunProvider) VolumeExists(ctx context.Context, region, name string) (bool, error) {
+	regionConfig, err := p.region(region)
+	if err != nil {
+		return false, err
+	}
+
+	objects, err := p.client.ListObjects(ctx, regionConfig.Bucket, name+"/", 1)
+	if err != nil {
+		return false, fmt.Errorf("failed to check home volume existence: %w", err)
+	}
+	return len(objects) > 0, nil
+}
+
+// DeleteVolume deletes every object under the fs-{id}/ prefix
+func (p *CloudRunProvider) DeleteVolume(ctx context.Context, region, name string) error {
+	regionConfig, err := p.region(region)
+	if err != nil {
+		return err
+	}
+
+	objects, err := p.client.ListObjects(ctx, regionConfig.Bucket, name+"/", 0)
+	if err != nil {
+		return fmt.Errorf("failed to delete home volume: %w", err)
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("failed to delete home volume: %s not found", name)
+	}
+
+	for _, object := range objects {
+		if err := p.client.DeleteObject(ctx, regionConfig.Bucket, object.Name); err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to delete home volume: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListVolumes lists the fs-{id}/ prefixes of the region bucket.
+// A prefix has no creation time of its own, so CreatedAt is zero.
+func (p *CloudRunProvider) ListVolumes(ctx context.Context, region string) ([]provider.Volume, error) {
+	regionConfig, err := p.region(region)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes, err := p.client.ListPrefixes(ctx, regionConfig.Bucket, provider.VolumePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list home volumes: %w", err)
+	}
+
+	volumes := make([]provider.Volume, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		volumes = append(volumes, provider.Volume{Name: strings.TrimSuffix(prefix, "/"), Region: region})
+	}
+	return volumes, nil
+}
+
+// CreateRuntime deploys the workspace as a Cloud Run service. Secure env vars
+// are stored in Secret Manager and referenced from the service, so they never
+// appear in the service spec.
+func (p *CloudRunProvider) CreateRuntime(ctx context.Context, region, name string, spec provider.ContainerGroupSpec) error {
+	regionConfig, err := p.region(region)
+	if err != nil {
+		return err
+	}
+
+	cpu, memoryGB, err := cloudRunSize(spec.CPUCores, spec.MemoryGB)
+	if err != nil {
+		return err
+	}
+	if len(spec.AppPorts) > 0 {
+		return models.ErrInvalidRequest(fmt.Sprintf("Cloud Run only routes the IDE port; app ports %v cannot be exposed", spec.AppPorts))
+	}
+
+	env, err := p.containerEnv(ctx, name, spec)
+	if err != nil {
+		_ = p.deleteSecrets(ctx, name)
+		return fmt.Errorf("failed to store secure env vars: %w", err)
+	}
+
+	if err := p.client.CreateService(ctx, p.config.ProjectID, region, name, p.service(regionConfig, spec, env, cpu, memoryGB)); err != nil {
+		_ = p.deleteSecrets(ctx, name)
+		return fmt.Errorf("failed to create Cloud Run service: %w", err)
+	}
+
+	if p.config.AllowUnauthenticated {
+		if err := p.client.AllowUnauthenticated(ctx, p.config.ProjectID, region, name); err != nil {
+			// Without the binding the IDE is unreachable, so don't leave the service behind
+			_ = p.client.DeleteService(ctx, p.config.ProjectID, region, name)
+			_ = p.deleteSecrets(ctx, name)
+			return fmt.Errorf("failed to allow unauthenticated access: %w", err)
+		}
+	}
+
+	log.Printf("☁️  [gcp] Deployed Cloud Run service %s in %s (%d CPU, %dGi)", name, region, cpu, memoryGB)
+	return nil
+}
+
+// GetRuntime returns the Cloud Run service details
+func (p *CloudRunProvider) GetRuntime(ctx context.Context, region, name string) (*provider.Runtime, error) {
+	if _, err := p.region(region); err != nil {
+		return nil, err
+	}
+
+	service, err := p.client.GetService(ctx, p.config.ProjectID, region, name)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, provider.ErrRuntimeNotFound
+		}
+		return nil, fmt.Errorf("failed to get Cloud Run service: %w", err)
+	}
+
+	runtime := serviceToRuntime(service, region, p.config.ProjectID)
+	return &runtime, nil
+}
+
+// DeleteRuntime deletes the Cloud Run service and its secrets; the home volume is kept
+func (p *CloudRunProvider) DeleteRuntime(ctx context.Context, region, name string) error {
+	if _, err := p.region(region); err != nil {
+		return err
+	}
+
+	err := p.client.DeleteService(ctx, p.config.ProjectID, region, name)
+	if err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete Cloud Run service: %w", err)
+	}
+	// Secrets left by an earlier partial delete are removed even when the service is gone
+	if secretErr := p.deleteSecrets(ctx, name); secretErr != nil {
+		return fmt.Errorf("failed to delete Cloud Run service secrets: %w", secretErr)
+	}
+	if err != nil {
+		return provider.ErrRuntimeNotFound
+	}
+	return nil
+}
+
+// ListRuntimes lists Cloud Run services labelled managed-by=dev8-agent in the region
+func (p *CloudRunProvider) ListRuntimes(ctx context.Context, region string) ([]provider.Runtime, error) {
+	if _, err := p.region(region); err != nil {
+		return nil, err
+	}
+
+	services, err := p.client.ListServices(ctx, p.config.ProjectID, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Cloud Run services: %w", err)
+	}
+
+	var runtimes []provider.Runtime
+	for i := range services {
+		if services[i].Labels["managed-by"] != "dev8-agent" {
+			continue
+		}
+		runtimes = append(runtimes, serviceToRuntime(&services[i], region, p.config.ProjectID))
+	}
+	return runtimes, nil
+}
+
+func (p *CloudRunProvider) region(region string) (*config.GCPRegionConfig, error) {
+	regionConfig := p.config.GetRegion(region)
+	if regionConfig == nil {
+		return nil, fmt.Errorf("GCP region %s is not configured", region)
+	}
+	return regionConfig, nil
+}
+
+// containerEnv returns the container environment for the service. Each
+// secure value becomes a version of its own secret, labelled with the runtime
+// name so DeleteRuntime can find it, and is referenced by version.
+func (p *CloudRunProvider) containerEnv(ctx context.Context, name string, spec provider.ContainerGroupSpec) ([]EnvVar, error) {
+	labels := map[string]string{"managed-by": "dev8-agent", secretLabel: sanitizeLabel(name)}
+
+	var env []EnvVar
+	for _, envVar := range spec.EnvVars() {
+		if !envVar.Secure {
+			env = append(env, EnvVar{Name: envVar.Name, Value: envVar.Value})
+			continue
+		}
+
+		// A secret left by an earlier failed create just gets a new version
+		secretID := name + "-" + strings.ReplaceAll(strings.ToLower(envVar.Name), "_", "-")
+		if err := p.client.CreateSecret(ctx, p.config.ProjectID, secretID, labels); err != nil && !IsConflict(err) {
+			return nil, err
+		}
+		version, err := p.client.AddSecretVersion(ctx, p.config.ProjectID, secretID, []byte(envVar.Value))
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, EnvVar{
+			Name:        envVar.Name,
+			ValueSource: &EnvVarSource{SecretKeyRef: &SecretKeySelector{Secret: secretID, Version: version}},
+		})
+	}
+	return env, nil
+}
+
+// deleteSecrets deletes the secrets holding a runtime's secure env vars
+func (p *CloudRunProvider) deleteSecrets(ctx context.Context, name string) error {
+	secrets, err := p.client.ListSecrets(ctx, p.config.ProjectID, "labels."+secretLabel+"="+sanitizeLabel(name))
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		secretID := secret.Name[strings.LastIndex(secret.Name, "/")+1:]
+		if err := p.client.DeleteSecret(ctx, p.config.ProjectID, secretID); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// service maps a ContainerGroupSpec onto a single-instance Cloud Run service
+func (p *CloudRunProvider) service(regionConfig *config.GCPRegionConfig, spec provider.ContainerGroupSpec, env []EnvVar, cpu, memoryGB int) *Service {
+	container := Container{
+		Name:  "workspace",
+		Image: spec.Image,
+		Env:   env,
+		Ports: []ContainerPort{{Name: "http1", ContainerPort: idePort}},
+		Resources: &ResourceRequirement{
+			Limits: map[string]string{
+				"cpu":    fmt.Sprint(cpu),
+				"memory": fmt.Sprintf("%dGi", memoryGB),
+			},
+			// Keep CPU allocated between requests so background processes keep running
+			CPUIdle: false,
+		},
+	}
+
+	service := &Service{
+		// The original label values (userId is camelCase) are kept as annotations
+		Labels:      gcpLabels(spec.Labels()),
+		Annotations: spec.Labels(),
+		Ingress:     "INGRESS_TRAFFIC_ALL",
+		Template: RevisionTemplate{
+			Labels:               gcpLabels(spec.Labels()),
+			Scaling:              &Scaling{MinInstanceCount: 1, MaxInstanceCount: 1},
+			ExecutionEnvironment: "EXECUTION_ENVIRONMENT_GEN2",
+			ServiceAccount:       p.config.ServiceAccount,
+			// Longest request (and WebSocket) duration Cloud Run allows
+			Timeout: "3600s",
+		},
+	}
+
+	if spec.FileShareName != "" {
+		// Single volume: Home directory (/home/dev8) - stores everything
+		service.Template.Volumes = []Volume{
+			{
+				Name: "home",
+				GCS: &GCSVolume{
+					Bucket:       regionConfig.Bucket,
+					MountOptions: []string{"only-dir=" + spec.FileShareName, "uid=1000", "gid=1000", "implicit-dirs"},
+				},
+			},
+		}
+		container.VolumeMounts = []VolumeMount{{Name: "home", MountPath: "/home/dev8"}}
+	}
+
+	service.Template.Containers = []Container{container}
+	return service
+}
+
+// serviceToRuntime converts a Cloud Run service into a provider-neutral runtime
+func serviceToRuntime(service *Service, region, project string) provider.Runtime {
+	runtime := provider.Runtime{
+		Name:          service.Name[strings.LastIndex(service.Name, "/")+1:],
+		Region:        region,
+		ResourceGroup: project,
+		State:         mapServiceState(service),
+		Tags:          make(map[string]string),
+		// Cloud Run serves the IDE over HTTPS on 443 and routes no other port
+		Ports:     map[int]int{idePort: 443},
+		Scheme:    "https",
+		Reachable: []int{idePort},
+	}
+
+	for key, value := range service.Labels {
+		runtime.Tags[key] = value
+	}
+	for key, value := range service.Annotations {
+		runtime.Tags[key] = value
+	}
+	runtime.EnvironmentID = runtime.Tags["environment"]
+	runtime.UserID = runtime.Tags["userId"]
+
+	if u, err := url.Parse(service.URI); err == nil {
+		runtime.FQDN = u.Hostname()
+	}
+
+	return runtime
+}
+
+// mapServiceState maps a Cloud Run service's terminal condition to a runtime state
+func mapServiceState(service *Service) provider.RuntimeState {
+	if service.Reconciling {
+		return provider.StatePending
+	}
+	if service.TerminalCondition == nil {
+		return provider.StateUnknown
+	}
+
+	switch service.TerminalCondition.State {
+	case "CONDITION_SUCCEEDED":
+		return provider.StateRunning
+	case "CONDITION_PENDING", "CONDITION_RECONCILING":
+		return provider.StatePending
+	case "CONDITION_FAILED":
+		return provider.StateFailed
+	default:
+		return provider.StateUnknown
+	}
+}
+
+// cloudRunSize returns the smallest valid Cloud Run CPU/memory (GiB) limits
+// that provide at least the requested cores and memory
+func cloudRunSize(cpuCores, memoryGB int) (cpu int, memory int, err error) {
+	if memoryGB < 1 {
+		memoryGB = 1
+	}
+
+	// Larger memory limits require more CPUs
+	minCPU := cpuCores
+	switch {
+	case memoryGB > 24:
+		minCPU = max(minCPU, 8)
+	case memoryGB > 16:
+		minCPU = max(minCPU, 6)
+	case memoryGB > 8:
+		minCPU = max(minCPU, 4)
+	case memoryGB > 4:
+		minCPU = max(minCPU, 2)
+	}
+
+	for _, candidate := range cloudRunCPUs {
+		if candidate < minCPU {
+			continue
+		}
+
+		// And larger CPU limits require more memory
+		memory = memoryGB
+		switch {
+		case candidate >= 6:
+			memory = max(memory, 4)
+		case candidate >= 4:
+			memory = max(memory, 2)
+		}
+
+		if memory <= 32 {
+			return candidate, memory, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("no Cloud Run size fits %d vCPU / %dGB (maximum is 8 vCPU / 32GB)", cpuCores, memoryGB)
+}
+
+// gcpLabels lowercases keys and values and replaces characters GCP labels do not allow
+func gcpLabels(labels map[string]string) map[string]string {
+	sanitized := make(map[string]string, len(labels))
+	for key, value := range labels {
+		sanitized[sanitizeLabel(key)] = sanitizeLabel(value)
+	}
+	return sanitized
+}
+
+func sanitizeLabel(s string) string {
+	s = invalidLabelChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
@@ -0,0 +1,450 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// fakeGCP is a local fake of the Cloud Run v2, Cloud Storage JSON and Secret Manager REST endpoints
+type fakeGCP struct {
+	mu         sync.Mutex
+	services   map[string]*Service
+	objects    map[string]bool
+	secrets    map[string]*Secret
+	versions   map[string][]string // Secret name -> payloads, oldest first
+	iamGrants  []string
+	operations map[string]int // Remaining polls before an operation is done
+}
+
+func newFakeGCP() *fakeGCP {
+	return &fakeGCP{
+		services:   make(map[string]*Service),
+		objects:    make(map[string]bool),
+		secrets:    make(map[string]*Secret),
+		versions:   make(map[string][]string),
+		operations: make(map[string]int),
+	}
+}
+
+func (f *fakeGCP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":404,"message":"not found"}}`))
+	}
+	path := r.URL.Path
+
+	switch {
+	case strings.HasPrefix(path, "/v2/projects/") && strings.Contains(path, "/operations/"):
+		name := strings.TrimPrefix(path, "/v2/")
+		f.operations[name]--
+		json.NewEncoder(w).Encode(Operation{Name: name, Done: f.operations[name] <= 0})
+
+	case strings.HasPrefix(path, "/v2/projects/") && strings.HasSuffix(path, ":setIamPolicy"):
+		f.iamGrants = append(f.iamGrants, strings.TrimSuffix(path, ":setIamPolicy"))
+		w.Write([]byte(`{}`))
+
+	case strings.HasPrefix(path, "/v2/projects/") && strings.HasSuffix(path, "/services"):
+		if r.Method == http.MethodPost {
+			var service Service
+			json.NewDecoder(r.Body).Decode(&service)
+			service.Name = strings.TrimPrefix(path, "/v2/") + "/" + r.URL.Query().Get("serviceId")
+			service.URI = "https://" + r.URL.Query().Get("serviceId") + "-abc123-uc.a.run.app"
+			service.TerminalCondition = &Condition{Type: "Ready", State: "CONDITION_SUCCEEDED"}
+			f.services[service.Name] = &service
+			json.NewEncoder(w).Encode(f.operation(strings.TrimSuffix(strings.TrimPrefix(path, "/v2/"), "/services")))
+			return
+		}
+
+		var services []Service
+		for name, service := range f.services {
+			if strings.HasPrefix(name, strings.TrimPrefix(path, "/v2/")+"/") {
+				services = append(services, *service)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"services": services})
+
+	case strings.HasPrefix(path, "/v2/projects/"):
+		name := strings.TrimPrefix(path, "/v2/")
+		service, ok := f.services[name]
+		if !ok {
+			notFound()
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.services, name)
+			json.NewEncoder(w).Encode(f.operation(name[:strings.Index(name, "/services/")]))
+			return
+		}
+		json.NewEncoder(w).Encode(service)
+
+	case strings.HasPrefix(path, "/v1/projects/") && strings.HasSuffix(path, ":addVersion"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/v1/"), ":addVersion")
+		if f.secrets[name] == nil {
+			notFound()
+			return
+		}
+		var body struct {
+			Payload struct {
+				Data []byte `json:"data"`
+			} `json:"payload"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.versions[name] = append(f.versions[name], string(body.Payload.Data))
+		json.NewEncoder(w).Encode(map[string]string{"name": fmt.Sprintf("%s/versions/%d", name, len(f.versions[name]))})
+
+	case strings.HasPrefix(path, "/v1/projects/") && strings.HasSuffix(path, "/secrets"):
+		if r.Method == http.MethodPost {
+			name := strings.TrimPrefix(path, "/v1/") + "/" + r.URL.Query().Get("secretId")
+			if f.secrets[name] != nil {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"error":{"code":409,"message":"already exists"}}`))
+				return
+			}
+			var secret Secret
+			json.NewDecoder(r.Body).Decode(&secret)
+			secret.Name = name
+			f.secrets[name] = &secret
+			json.NewEncoder(w).Encode(secret)
+			return
+		}
+
+		// Only "labels.<key>=<value>" filters are supported
+		key, value, _ := strings.Cut(strings.TrimPrefix(r.URL.Query().Get("filter"), "labels."), "=")
+		var secrets []Secret
+		for _, secret := range f.secrets {
+			if secret.Labels[key] == value {
+				secrets = append(secrets, *secret)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"secrets": secrets})
+
+	case strings.HasPrefix(path, "/v1/projects/") && r.Method == http.MethodDelete:
+		name := strings.TrimPrefix(path, "/v1/")
+		if f.secrets[name] == nil {
+			notFound()
+			return
+		}
+		delete(f.secrets, name)
+		delete(f.versions, name)
+		w.Write([]byte(`{}`))
+
+	case strings.HasPrefix(path, "/upload/storage/v1/b/"):
+		bucket := strings.TrimSuffix(strings.TrimPrefix(path, "/upload/storage/v1/b/"), "/o")
+		key := bucket + "/" + r.URL.Query().Get("name")
+		if f.objects[key] && r.URL.Query().Get("ifGenerationMatch") == "0" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"error":{"code":412,"message":"conditionNotMet"}}`))
+			return
+		}
+		f.objects[key] = true
+		w.Write([]byte(`{}`))
+
+	case strings.HasPrefix(path, "/storage/v1/b/"):
+		rest := strings.TrimPrefix(path, "/storage/v1/b/")
+		bucket, object, _ := strings.Cut(rest, "/o")
+		if r.Method == http.MethodDelete {
+			key := bucket + "/" + strings.TrimPrefix(object, "/")
+			if !f.objects[key] {
+				notFound()
+				return
+			}
+			delete(f.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		prefix, delimiter := r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter")
+		var items []Object
+		prefixes := map[string]bool{}
+		for key := range f.objects {
+			name := strings.TrimPrefix(key, bucket+"/")
+			if !strings.HasPrefix(key, bucket+"/") || !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if delimiter != "" {
+				if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+					prefixes[name[:len(prefix)+i+1]] = true
+					continue
+				}
+			}
+			items = append(items, Object{Name: name})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+		var prefixList []string
+		for p := range prefixes {
+			prefixList = append(prefixList, p)
+		}
+		sort.Strings(prefixList)
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "prefixes": prefixList})
+
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+path, http.StatusTeapot)
+	}
+}
+
+// operation starts a long-running operation that needs two polls to finish
+func (f *fakeGCP) operation(parent string) Operation {
+	name := fmt.Sprintf("%s/operations/op-%d", parent, len(f.operations)+1)
+	f.operations[name] = 2
+	return Operation{Name: name}
+}
+
+func newTestProvider(t *testing.T) (*CloudRunProvider, *fakeGCP) {
+	t.Helper()
+
+	fake := newFakeGCP()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Client(), server.URL, server.URL, server.URL)
+	client.pollInterval = time.Millisecond
+
+	cfg := config.GCPConfig{
+		ProjectID:            "dev8-test",
+		Regions:              []config.GCPRegionConfig{{Name: "us-central1", Bucket: "dev8-homes"}},
+		AllowUnauthenticated: true,
+	}
+	return NewCloudRunProvider(cfg, client), fake
+}
+
+func testSpec() provider.ContainerGroupSpec {
+	return provider.ContainerGroupSpec{
+		Image:              "vaibhavsing/dev8-workspace:latest",
+		CPUCores:           2,
+		MemoryGB:           4,
+		FileShareName:      "fs-ws-1",
+		EnvironmentID:      "ws-1",
+		UserID:             "User_1",
+		CodeServerPassword: "secret",
+	}
+}
+
+func TestCloudRunSize(t *testing.T) {
+	tests := []struct {
+		cpuCores   int
+		memoryGB   int
+		wantCPU    int
+		wantMemory int
+		wantErr    bool
+	}{
+		{cpuCores: 1, memoryGB: 2, wantCPU: 1, wantMemory: 2},
+		{cpuCores: 1, memoryGB: 8, wantCPU: 2, wantMemory: 8},
+		{cpuCores: 3, memoryGB: 1, wantCPU: 4, wantMemory: 2},
+		{cpuCores: 2, memoryGB: 20, wantCPU: 6, wantMemory: 20},
+		{cpuCores: 8, memoryGB: 2, wantCPU: 8, wantMemory: 4},
+		{cpuCores: 0, memoryGB: 0, wantCPU: 1, wantMemory: 1},
+		{cpuCores: 16, memoryGB: 8, wantErr: true},
+		{cpuCores: 8, memoryGB: 64, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dvCPU-%dGB", tt.cpuCores, tt.memoryGB), func(t *testing.T) {
+			cpu, memory, err := cloudRunSize(tt.cpuCores, tt.memoryGB)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cloudRunSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cpu != tt.wantCPU || memory != tt.wantMemory {
+				t.Errorf("cloudRunSize() = %d/%d, want %d/%d", cpu, memory, tt.wantCPU, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestGCPLabels(t *testing.T) {
+	got := gcpLabels(map[string]string{"userId": "User@Example.com", "managed-by": "dev8-agent"})
+	if got["userid"] != "user-example-com" || got["managed-by"] != "dev8-agent" {
+		t.Errorf("gcpLabels() = %v", got)
+	}
+}
+
+func TestCloudRunProvider_HasRegion(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	if !p.HasRegion("us-central1") {
+		t.Error("HasRegion(us-central1) = false, want true")
+	}
+	if p.HasRegion("eastus") {
+		t.Error("HasRegion(eastus) = true, want false")
+	}
+}
+
+func TestCloudRunProvider_Volumes(t *testing.T) {
+	p, fake := newTestProvider(t)
+	ctx := context.Background()
+
+	exists, err := p.VolumeExists(ctx, "us-central1", "fs-ws-1")
+	if err != nil || exists {
+		t.Fatalf("VolumeExists() = %v, %v, want false", exists, err)
+	}
+
+	if err := p.CreateVolume(ctx, "us-central1", "fs-ws-1", 25); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := p.CreateVolume(ctx, "us-central1", "fs-ws-1", 25); err == nil {
+		t.Error("CreateVolume() should fail for an existing volume")
+	}
+
+	// Files written by the workspace live under the same prefix
+	fake.objects["dev8-homes/fs-ws-1/workspace/main.go"] = true
+	fake.objects["dev8-homes/fs-ws-10/keep.txt"] = true
+
+	exists, err = p.VolumeExists(ctx, "us-central1", "fs-ws-1")
+	if err != nil || !exists {
+		t.Fatalf("VolumeExists() = %v, %v, want true", exists, err)
+	}
+
+	volumes, err := p.ListVolumes(ctx, "us-central1")
+	if err != nil || len(volumes) != 2 || volumes[0].Name != "fs-ws-1" || volumes[1].Name != "fs-ws-10" {
+		t.Errorf("ListVolumes() = %v, %v, want [fs-ws-1 fs-ws-10]", volumes, err)
+	}
+
+	if err := p.DeleteVolume(ctx, "us-central1", "fs-ws-1"); err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+	if len(fake.objects) != 1 || !fake.objects["dev8-homes/fs-ws-10/keep.txt"] {
+		t.Errorf("objects after delete = %v, want only the other workspace", fake.objects)
+	}
+	if err := p.DeleteVolume(ctx, "us-central1", "fs-ws-1"); err == nil {
+		t.Error("DeleteVolume() should fail for a missing volume")
+	}
+}
+
+func TestCloudRunProvider_RuntimeLifecycle(t *testing.T) {
+	p, fake := newTestProvider(t)
+	ctx := context.Background()
+
+	if _, err := p.GetRuntime(ctx, "us-central1", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Fatalf("GetRuntime() error = %v, want ErrRuntimeNotFound", err)
+	}
+
+	if err := p.CreateRuntime(ctx, "us-central1", "aci-ws-1", testSpec()); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	service := fake.services["projects/dev8-test/locations/us-central1/services/aci-ws-1"]
+	if service == nil {
+		t.Fatal("service was not created")
+	}
+	container := service.Template.Containers[0]
+	if container.Resources.Limits["cpu"] != "2" || container.Resources.Limits["memory"] != "4Gi" {
+		t.Errorf("limits = %v, want 2 CPU / 4Gi", container.Resources.Limits)
+	}
+	if service.Template.Scaling.MinInstanceCount != 1 || service.Template.Scaling.MaxInstanceCount != 1 {
+		t.Errorf("scaling = %+v, want exactly one instance", service.Template.Scaling)
+	}
+	volume := service.Template.Volumes[0]
+	if volume.GCS == nil || volume.GCS.Bucket != "dev8-homes" || volume.GCS.MountOptions[0] != "only-dir=fs-ws-1" {
+		t.Errorf("volume = %+v, want the fs-ws-1 prefix of dev8-homes", volume)
+	}
+	if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].MountPath != "/home/dev8" {
+		t.Errorf("VolumeMounts = %v, want /home/dev8", container.VolumeMounts)
+	}
+	if service.Labels["userid"] != "user_1" || service.Labels["managed-by"] != "dev8-agent" {
+		t.Errorf("Labels = %v, want sanitized workspace labels", service.Labels)
+	}
+	if len(fake.iamGrants) != 1 {
+		t.Errorf("IAM grants = %v, want allUsers invoker on the service", fake.iamGrants)
+	}
+
+	// Secure values are only referenced from the service, never written into it
+	env := map[string]EnvVar{}
+	for _, envVar := range container.Env {
+		env[envVar.Name] = envVar
+	}
+	if env["WORKSPACE_ID"].Value != "ws-1" {
+		t.Errorf("WORKSPACE_ID = %+v, want a plain value", env["WORKSPACE_ID"])
+	}
+	password := env["CODE_SERVER_PASSWORD"]
+	if password.Value != "" || password.ValueSource == nil || password.ValueSource.SecretKeyRef == nil {
+		t.Fatalf("CODE_SERVER_PASSWORD = %+v, want a Secret Manager reference", password)
+	}
+	ref := password.ValueSource.SecretKeyRef
+	secretName := "projects/dev8-test/secrets/" + ref.Secret
+	if ref.Secret != "aci-ws-1-code-server-password" || ref.Version != "1" {
+		t.Errorf("secretKeyRef = %+v, want version 1 of aci-ws-1-code-server-password", ref)
+	}
+	if versions := fake.versions[secretName]; len(versions) != 1 || versions[0] != "secret" {
+		t.Errorf("secret versions = %v, want the password", versions)
+	}
+
+	runtime, err := p.GetRuntime(ctx, "us-central1", "aci-ws-1")
+	if err != nil {
+		t.Fatalf("GetRuntime() error = %v", err)
+	}
+	if runtime.State != provider.StateRunning || runtime.Name != "aci-ws-1" {
+		t.Errorf("runtime = %+v, want running aci-ws-1", runtime)
+	}
+	if runtime.FQDN != "aci-ws-1-abc123-uc.a.run.app" || runtime.HTTPURL(8080) != "https://aci-ws-1-abc123-uc.a.run.app" {
+		t.Errorf("FQDN/IDE URL = %v/%v, want the run.app host over https", runtime.FQDN, runtime.HTTPURL(8080))
+	}
+	if !runtime.Exposes(8080) || runtime.Exposes(2222) || runtime.Exposes(9000) {
+		t.Errorf("Reachable = %v, want only the IDE port", runtime.Reachable)
+	}
+	if runtime.EnvironmentID != "ws-1" || runtime.UserID != "User_1" {
+		t.Errorf("EnvironmentID/UserID = %v/%v, want original values", runtime.EnvironmentID, runtime.UserID)
+	}
+
+	runtimes, err := p.ListRuntimes(ctx, "us-central1")
+	if err != nil || len(runtimes) != 1 {
+		t.Fatalf("ListRuntimes() = %v, %v, want 1 runtime", runtimes, err)
+	}
+
+	if err := p.DeleteRuntime(ctx, "us-central1", "aci-ws-1"); err != nil {
+		t.Fatalf("DeleteRuntime() error = %v", err)
+	}
+	if len(fake.secrets) != 0 {
+		t.Errorf("secrets after delete = %v, want none", fake.secrets)
+	}
+	if err := p.DeleteRuntime(ctx, "us-central1", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Errorf("DeleteRuntime() twice error = %v, want ErrRuntimeNotFound", err)
+	}
+}
+
+func TestCloudRunProvider_PrivateByDefault(t *testing.T) {
+	p, fake := newTestProvider(t)
+	p.config.AllowUnauthenticated = false
+
+	if err := p.CreateRuntime(context.Background(), "us-central1", "aci-ws-1", testSpec()); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+	if len(fake.iamGrants) != 0 {
+		t.Errorf("IAM grants = %v, want none unless AllowUnauthenticated is set", fake.iamGrants)
+	}
+}
+
+func TestMapServiceState(t *testing.T) {
+	tests := []struct {
+		name    string
+		service Service
+		want    provider.RuntimeState
+	}{
+		{"ready", Service{TerminalCondition: &Condition{State: "CONDITION_SUCCEEDED"}}, provider.StateRunning},
+		{"reconciling", Service{Reconciling: true, TerminalCondition: &Condition{State: "CONDITION_SUCCEEDED"}}, provider.StatePending},
+		{"failed", Service{TerminalCondition: &Condition{State: "CONDITION_FAILED"}}, provider.StateFailed},
+		{"no condition", Service{}, provider.StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapServiceState(&tt.service); got != tt.want {
+				t.Errorf("mapServiceState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// Authorization
+//
+// Every API request carries a principal: the authenticated caller, or an
+// anonymous admin when authentication is disabled. A request without one
+// skipped the authentication middleware and is rejected. Admin principals
+// (service API keys, tokens with the admin scope) may act for any user;
+// everyone else only for themselves.
+
+// requestPrincipal returns the caller of a request
+func requestPrincipal(r *http.Request) (*middleware.Principal, error) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return nil, models.ErrUnauthorized("request was not authenticated")
+	}
+	return principal, nil
+}
+
+// resolveUserID returns the user a request acts for: the requested user for
+// admins, otherwise the principal's own ID
+func resolveUserID(r *http.Request, requested string) (string, error) {
+	principal, err := requestPrincipal(r)
+	if err != nil {
+		return "", err
+	}
+	if principal.Admin {
+		return requested, nil
+	}
+	if requested != "" && requested != principal.Subject {
+		return "", models.ErrForbidden(fmt.Sprintf("userId %s does not match the authenticated user", requested))
+	}
+	return principal.Subject, nil
+}
+
+// authorizeUser fails unless the caller may act on resources owned by ownerID
+func authorizeUser(r *http.Request, ownerID string) error {
+	principal, err := requestPrincipal(r)
+	if err != nil {
+		return err
+	}
+	if principal.Admin || (ownerID != "" && ownerID == principal.Subject) {
+		return nil
+	}
+	return models.ErrForbidden("workspace belongs to another user")
+}
+
+// requireAdmin fails unless the caller is an admin
+func requireAdmin(r *http.Request) error {
+	principal, err := requestPrincipal(r)
+	if err != nil {
+		return err
+	}
+	if !principal.Admin {
+		return models.ErrForbidden("admin scope required")
+	}
+	return nil
+}
+
+// authorizeWorkspace fails unless the caller may act on the workspace.
+// userID is the owner claimed in the request body, if any.
+func (h *EnvironmentHandler) authorizeWorkspace(r *http.Request, cloud models.CloudProvider, region, workspaceID, userID string) error {
+	principal, err := requestPrincipal(r)
+	if err != nil {
+		return err
+	}
+	if principal.Admin {
+		return nil
+	}
+	if _, err := resolveUserID(r, userID); err != nil {
+		return err
+	}
+
+	owner, err := h.service.WorkspaceOwner(r.Context(), cloud, region, workspaceID)
+	if err != nil {
+		return err
+	}
+	if owner == "" {
+		// Only the state store or a running container records the owner
+		return models.ErrForbidden(fmt.Sprintf("cannot verify the owner of workspace %s", workspaceID))
+	}
+	return authorizeUser(r, owner)
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header, or ""
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
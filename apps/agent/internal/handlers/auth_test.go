@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+)
+
+func TestResolveUserID(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *middleware.Principal
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{name: "unauthenticated", requested: "user-1", wantErr: true},
+		{name: "authentication disabled", principal: &middleware.Principal{Subject: middleware.AnonymousPrincipal, Admin: true, Method: middleware.AuthMethodNone}, requested: "user-1", want: "user-1"},
+		{name: "admin acts for anyone", principal: &middleware.Principal{Subject: "service", Admin: true}, requested: "user-1", want: "user-1"},
+		{name: "own user", principal: &middleware.Principal{Subject: "user-1"}, requested: "user-1", want: "user-1"},
+		{name: "defaults to principal", principal: &middleware.Principal{Subject: "user-1"}, want: "user-1"},
+		{name: "other user", principal: &middleware.Principal{Subject: "user-1"}, requested: "user-2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.principal != nil {
+				req = req.WithContext(middleware.WithPrincipal(req.Context(), tt.principal))
+			}
+
+			got, err := resolveUserID(req, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveUserID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveUserID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateEnvironment_ForbidsOtherUser(t *testing.T) {
+	service, err := services.NewEnvironmentService(&config.Config{}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	defer service.Close()
+	handler := NewEnvironmentHandler(service)
+
+	body, _ := json.Marshal(models.CreateEnvironmentRequest{
+		Name:          "workspace",
+		WorkspaceID:   "ws-1",
+		UserID:        "user-2",
+		CloudProvider: models.ProviderAzure,
+		CloudRegion:   "eastus",
+		CPUCores:      2,
+		MemoryGB:      4,
+		StorageGB:     10,
+		BaseImage:     "node",
+	})
+	req := httptest.NewRequest("POST", "/api/v1/environments", bytes.NewReader(body))
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), &middleware.Principal{Subject: "user-1"}))
+	w := httptest.NewRecorder()
+	handler.CreateEnvironment(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("CreateEnvironment() status = %v, want %v", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestReconcileHandler_RequiresAdmin(t *testing.T) {
+	service, err := services.NewEnvironmentService(&config.Config{}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	defer service.Close()
+	handler := NewReconcileHandler(services.NewReconciler(service, config.ReconcileConfig{}))
+
+	req := httptest.NewRequest("POST", "/api/v1/reconciliation", nil)
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), &middleware.Principal{Subject: "user-1"}))
+	w := httptest.NewRecorder()
+	handler.RunReconciliation(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("RunReconciliation() status = %v, want %v", w.Code, http.StatusForbidden)
+	}
+}
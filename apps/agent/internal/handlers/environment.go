@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
@@ -23,93 +25,141 @@ func NewEnvironmentHandler(service *services.EnvironmentService) *EnvironmentHan
 	}
 }
 
+// RegisterSupervisorRoutes registers the routes called by workspace supervisors on
+// the root router. They authenticate with per-workspace tokens instead of the API
+// credentials, so they must be registered before the API v1 subrouter.
+func (h *EnvironmentHandler) RegisterSupervisorRoutes(router *mux.Router) {
+	router.HandleFunc("/api/v1/environments/{id}/activity", h.ReportActivity).Methods("POST")
+}
+
+// RegisterRoutes registers the environment routes on the API v1 subrouter
+func (h *EnvironmentHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/environments", h.CreateEnvironment).Methods("POST")
+	api.HandleFunc("/environments", h.ListEnvironments).Methods("GET")
+	api.HandleFunc("/environments/{id}", h.GetEnvironment).Methods("GET")
+	api.HandleFunc("/environments", h.DeleteEnvironment).Methods("DELETE")
+	api.HandleFunc("/environments/start", h.StartEnvironment).Methods("POST")
+	api.HandleFunc("/environments/stop", h.StopEnvironment).Methods("POST")
+}
+
 // CreateEnvironment handles POST /api/v1/environments
 func (h *EnvironmentHandler) CreateEnvironment(w http.ResponseWriter, r *http.Request) {
-	var req models.CreateEnvironmentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body", "Please check your JSON payload", err)
+	h.runLifecycle(w, r, "Workspace creation started", func(r *http.Request) (*models.Operation, error) {
+		var req models.CreateEnvironmentRequest
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+
+		// The workspace belongs to the authenticated user unless an admin creates it for someone else
+		userID, err := resolveUserID(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if userID == "" {
+			return nil, models.ErrInvalidRequest("userId is required")
+		}
+		req.UserID = userID
+
+		return h.service.CreateEnvironmentAsync(r.Context(), &req)
+	})
+}
+
+// GetEnvironment handles GET /api/v1/environments/{id}
+func (h *EnvironmentHandler) GetEnvironment(w http.ResponseWriter, r *http.Request) {
+	env, err := h.service.GetEnvironment(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, services.ErrStateless) {
+		// Without AGENT_STATE_PATH Next.js is the source of truth
+		respondWithError(w, http.StatusNotImplemented, "Get Environment Not Supported", "This agent doesn't store state. Query Next.js API for environment details.", err)
 		return
 	}
-
-	// TODO: Extract user ID from authentication context
-	if req.UserID == "" {
-		req.UserID = "default-user"
+	if err == nil {
+		err = authorizeUser(r, env.UserID)
 	}
-
-	env, err := h.service.CreateEnvironment(r.Context(), &req)
 	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
 
-	respondWithSuccess(w, http.StatusCreated, "Workspace created successfully", map[string]interface{}{
-		"environment": env,
-		"message":     "Your development environment is ready to use",
+	respondWithSuccess(w, http.StatusOK, "Environment retrieved successfully", models.EnvironmentResponse{
+		Environment: env,
 	})
 }
 
-// GetEnvironment handles GET /api/v1/environments/{id}
-func (h *EnvironmentHandler) GetEnvironment(w http.ResponseWriter, r *http.Request) {
-	// GetEnvironment is removed - Next.js is the source of truth
-	// Agent is stateless and doesn't store environment data
-	err := models.ErrInvalidRequest("Agent is stateless - query Next.js for environment details")
-	respondWithError(w, http.StatusNotImplemented, "Get Environment Not Supported", "This agent doesn't store state. Query Next.js API for environment details.", err)
-}
-
-// ListEnvironments handles GET /api/v1/environments
+// ListEnvironments handles GET /api/v1/environments?userId=&status=&cloudRegion=&page=&pageSize=
 func (h *EnvironmentHandler) ListEnvironments(w http.ResponseWriter, r *http.Request) {
-	// ListEnvironments is removed - Next.js is the source of truth
-	// Agent is stateless and doesn't store environment data
-	err := models.ErrInvalidRequest("Agent is stateless - query Next.js for environment list")
-	respondWithError(w, http.StatusNotImplemented, "List Environments Not Supported", "This agent doesn't store state. Query Next.js API for environment list.", err)
-}
+	query := r.URL.Query()
+	req := models.ListEnvironmentsRequest{
+		UserID:      query.Get("userId"),
+		Status:      models.EnvironmentStatus(query.Get("status")),
+		CloudRegion: query.Get("cloudRegion"),
+	}
 
-// StartEnvironment handles POST /api/v1/environments/start
-func (h *EnvironmentHandler) StartEnvironment(w http.ResponseWriter, r *http.Request) {
-	var req models.StartEnvironmentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body", "Please check your JSON payload", err)
+	// Users only see their own workspaces
+	userID, err := resolveUserID(r, req.UserID)
+	if err != nil {
+		handleServiceError(w, err)
 		return
 	}
+	req.UserID = userID
 
-	if err := req.Validate(); err != nil {
-		handleServiceError(w, err)
+	if req.Page, err = queryInt(query.Get("page")); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid Request", "page must be a number", err)
+		return
+	}
+	if req.PageSize, err = queryInt(query.Get("pageSize")); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid Request", "pageSize must be a number", err)
 		return
 	}
 
-	env, err := h.service.StartEnvironment(r.Context(), &req)
+	list, err := h.service.ListEnvironments(r.Context(), &req)
+	if errors.Is(err, services.ErrStateless) {
+		// Without AGENT_STATE_PATH Next.js is the source of truth
+		respondWithError(w, http.StatusNotImplemented, "List Environments Not Supported", "This agent doesn't store state. Query Next.js API for environment list.", err)
+		return
+	}
 	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
 
-	respondWithSuccess(w, http.StatusOK, "Workspace started successfully", map[string]interface{}{
-		"environment": env,
-		"message":     "Your workspace is now running with existing data",
+	respondWithSuccess(w, http.StatusOK, "Environments retrieved successfully", list)
+}
+
+// StartEnvironment handles POST /api/v1/environments/start
+func (h *EnvironmentHandler) StartEnvironment(w http.ResponseWriter, r *http.Request) {
+	h.runLifecycle(w, r, "Workspace starting", func(r *http.Request) (*models.Operation, error) {
+		var req models.StartEnvironmentRequest
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		if err := h.authorizeWorkspace(r, req.CloudProvider, req.CloudRegion, req.WorkspaceID, req.UserID); err != nil {
+			return nil, err
+		}
+
+		return h.service.StartEnvironmentAsync(r.Context(), &req)
 	})
 }
 
 // StopEnvironment handles POST /api/v1/environments/stop
 func (h *EnvironmentHandler) StopEnvironment(w http.ResponseWriter, r *http.Request) {
-	var req models.StopEnvironmentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body", "Please check your JSON payload", err)
-		return
-	}
-
-	if err := req.Validate(); err != nil {
-		handleServiceError(w, err)
-		return
-	}
+	h.runLifecycle(w, r, "Workspace stopping", func(r *http.Request) (*models.Operation, error) {
+		var req models.StopEnvironmentRequest
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
 
-	if err := h.service.StopEnvironment(r.Context(), req.WorkspaceID, req.CloudRegion); err != nil {
-		handleServiceError(w, err)
-		return
-	}
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		if err := h.authorizeWorkspace(r, req.CloudProvider, req.CloudRegion, req.WorkspaceID, ""); err != nil {
+			return nil, err
+		}
 
-	respondWithSuccess(w, http.StatusOK, "Workspace stopped successfully", map[string]interface{}{
-		"workspaceId": req.WorkspaceID,
-		"message":     "Container deleted, volumes preserved. Restart anytime to resume work.",
+		return h.service.StopEnvironmentAsync(r.Context(), &req)
 	})
 }
 
@@ -118,6 +168,13 @@ func (h *EnvironmentHandler) ReportActivity(w http.ResponseWriter, r *http.Reque
 	vars := mux.Vars(r)
 	envID := vars["id"]
 
+	// Only the workspace's own supervisor may report its activity
+	if err := h.service.SupervisorTokens().Verify(envID, bearerToken(r)); err != nil {
+		log.Printf("🔒 Rejected activity report for %s: %v", envID, err)
+		handleServiceError(w, err)
+		return
+	}
+
 	var payload models.ActivityReport
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid Request Body", "Please check your JSON payload", err)
@@ -129,44 +186,52 @@ func (h *EnvironmentHandler) ReportActivity(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.service.RecordActivity(r.Context(), &payload); err != nil {
+	idle, err := h.service.RecordActivity(r.Context(), &payload)
+	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
 
-	respondWithSuccess(w, http.StatusOK, "Activity recorded successfully", map[string]interface{}{
+	data := map[string]interface{}{
 		"environmentId": payload.EnvironmentID,
 		"snapshot":      payload.Snapshot,
 		"timestamp":     payload.Timestamp,
-	})
+	}
+	if idle != nil {
+		data["idle"] = idle
+	}
+	respondWithSuccess(w, http.StatusOK, "Activity recorded successfully", data)
 }
 
 // DeleteEnvironment handles DELETE /api/v1/environments
 func (h *EnvironmentHandler) DeleteEnvironment(w http.ResponseWriter, r *http.Request) {
-	var req models.DeleteEnvironmentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body", "Please check your JSON payload", err)
-		return
-	}
-
-	if err := req.Validate(); err != nil {
-		handleServiceError(w, err)
-		return
-	}
+	h.runLifecycle(w, r, "Workspace deletion started", func(r *http.Request) (*models.Operation, error) {
+		var req models.DeleteEnvironmentRequest
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
 
-	if err := h.service.DeleteEnvironment(r.Context(), req.WorkspaceID, req.CloudRegion, req.Force); err != nil {
-		handleServiceError(w, err)
-		return
-	}
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
+		if err := h.authorizeWorkspace(r, req.CloudProvider, req.CloudRegion, req.WorkspaceID, ""); err != nil {
+			return nil, err
+		}
 
-	respondWithSuccess(w, http.StatusOK, "Workspace deleted permanently", map[string]interface{}{
-		"workspaceId": req.WorkspaceID,
-		"message":     "All data and resources have been permanently removed",
+		return h.service.DeleteEnvironmentAsync(r.Context(), &req)
 	})
 }
 
 // Helper functions
 
+// queryInt parses an optional integer query parameter; empty means 0
+func queryInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
@@ -188,6 +253,19 @@ func respondWithSuccess(w http.ResponseWriter, code int, message string, data in
 	})
 }
 
+// respondWithOperation responds 202 Accepted pointing the client at the operation
+func respondWithOperation(w http.ResponseWriter, message string, op *models.Operation) {
+	statusURL := fmt.Sprintf("/api/v1/operations/%s", op.ID)
+	w.Header().Set("Location", statusURL)
+	respondWithSuccess(w, http.StatusAccepted, message, map[string]interface{}{
+		"operationId": op.ID,
+		"workspaceId": op.WorkspaceID,
+		"operation":   op,
+		"statusUrl":   statusURL,
+		"eventsUrl":   statusURL + "/events",
+	})
+}
+
 func respondWithError(w http.ResponseWriter, code int, error string, message string, err error) {
 	log.Printf("❌ %s: %v", error, err)
 	respondWithJSON(w, code, models.ErrorResponse{
@@ -199,20 +277,45 @@ func respondWithError(w http.ResponseWriter, code int, error string, message str
 }
 
 func handleServiceError(w http.ResponseWriter, err error) {
-	if appErr, ok := err.(*models.AppError); ok {
-		switch appErr.Code {
-		case "INVALID_REQUEST":
-			respondWithError(w, http.StatusBadRequest, "Invalid Request", appErr.Message, err)
-		case "NOT_FOUND":
-			respondWithError(w, http.StatusNotFound, "Resource Not Found", appErr.Message, err)
-		case "UNAUTHORIZED":
-			respondWithError(w, http.StatusUnauthorized, "Unauthorized", appErr.Message, err)
-		case "CONFLICT":
-			respondWithError(w, http.StatusConflict, "Conflict", appErr.Message, err)
-		default:
-			respondWithError(w, http.StatusInternalServerError, "Internal Server Error", "An unexpected error occurred. Please try again later.", err)
-		}
-	} else {
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) {
 		respondWithError(w, http.StatusInternalServerError, "Internal Server Error", "An unexpected error occurred. Please try again later.", err)
+		return
 	}
+
+	code, title, message := http.StatusInternalServerError, "Internal Server Error", appErr.Message
+	switch appErr.Code {
+	case models.CodeInvalidRequest:
+		code, title = http.StatusBadRequest, "Invalid Request"
+	case models.CodeNotFound:
+		code, title = http.StatusNotFound, "Resource Not Found"
+	case models.CodeUnauthorized:
+		code, title = http.StatusUnauthorized, "Unauthorized"
+	case models.CodeForbidden:
+		code, title = http.StatusForbidden, "Forbidden"
+	case models.CodeConflict:
+		code, title = http.StatusConflict, "Conflict"
+	case models.CodeQuotaExceeded:
+		code, title = http.StatusTooManyRequests, "Quota Exceeded"
+	case models.CodeThrottled:
+		code, title = http.StatusTooManyRequests, "Throttled"
+	case models.CodeCloudQuotaExceeded:
+		code, title = http.StatusServiceUnavailable, "Cloud Quota Exceeded"
+	case models.CodeCloudUnavailable:
+		code, title = http.StatusServiceUnavailable, "Cloud Unavailable"
+	case models.CodeCloudAuthFailed:
+		code, title = http.StatusBadGateway, "Cloud Authentication Failed"
+	default:
+		message = "An unexpected error occurred. Please try again later."
+	}
+
+	log.Printf("❌ %s: %v", title, err)
+	respondWithJSON(w, code, models.ErrorResponse{
+		Success:   false,
+		Error:     title,
+		Message:   message,
+		Code:      fmt.Sprintf("ERR_%d", code),
+		ErrorCode: appErr.Code,
+		Retryable: appErr.Retryable,
+	})
 }
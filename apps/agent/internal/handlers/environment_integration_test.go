@@ -0,0 +1,783 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// newFakeRouter wires the real handlers and service to the in-memory provider
+func newFakeRouter(t *testing.T, configure ...func(cfg *config.Config)) (*mux.Router, *fake.Provider) {
+	t.Helper()
+
+	service, fakeProvider := newFakeService(t, configure...)
+	return fakeRouter(service), fakeProvider
+}
+
+// newFakeService creates the real service on top of the in-memory provider
+func newFakeService(t *testing.T, configure ...func(cfg *config.Config)) (*services.EnvironmentService, *fake.Provider) {
+	t.Helper()
+
+	cfg := &config.Config{
+		Provider:       config.ProviderFake,
+		ContainerImage: "vaibhavsing/dev8-workspace:latest",
+		RegistryServer: "index.docker.io",
+		AgentBaseURL:   "http://localhost:8080",
+		Azure: config.AzureConfig{
+			Regions: []config.RegionConfig{{Name: "eastus", Location: "eastus", Enabled: true}},
+		},
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	fakeProvider := fake.NewProvider(fake.Options{
+		Regions:      []string{"eastus"},
+		VolumeDelay:  cfg.Fake.VolumeDelay,
+		RuntimeDelay: cfg.Fake.RuntimeDelay,
+	})
+	providers := provider.NewRegistry(models.ProviderAzure)
+	providers.Register(models.ProviderAzure, fakeProvider)
+
+	service, err := services.NewEnvironmentService(cfg, providers)
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	t.Cleanup(service.Close)
+	return service, fakeProvider
+}
+
+// fakeRouter registers the API routes of the real handlers for a service
+func fakeRouter(service *services.EnvironmentService) *mux.Router {
+	router := mux.NewRouter()
+	envHandler := NewEnvironmentHandler(service)
+	envHandler.RegisterSupervisorRoutes(router)
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware.DisabledAuthMiddleware())
+	envHandler.RegisterRoutes(api)
+	NewOperationHandler(service.Operations()).RegisterRoutes(api)
+	NewQuotaHandler(service.Quotas()).RegisterRoutes(api)
+	NewPreviewHandler(service).RegisterRoutes(api)
+	NewSSHKeyHandler(service).RegisterRoutes(api)
+	return router
+}
+
+func doJSON(t *testing.T, router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal body: %v", err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// supervisorToken returns the supervisor token injected into the workspace's runtime
+func supervisorToken(t *testing.T, fakeProvider *fake.Provider, workspaceID string) string {
+	t.Helper()
+
+	spec, ok := fakeProvider.Spec("eastus", "aci-"+workspaceID)
+	if !ok || spec.SupervisorToken == "" {
+		t.Fatalf("runtime aci-%s has no supervisor token", workspaceID)
+	}
+	return spec.SupervisorToken
+}
+
+// reportActivity posts an activity report as the workspace supervisor
+func reportActivity(t *testing.T, router http.Handler, workspaceID, token string, report models.ActivityReport) *httptest.ResponseRecorder {
+	t.Helper()
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Failed to marshal body: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/environments/"+workspaceID+"/activity", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// awaitOperation checks a lifecycle request was accepted and polls its operation until it completes
+func awaitOperation(t *testing.T, router http.Handler, w *httptest.ResponseRecorder) models.Operation {
+	t.Helper()
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %v, want %v: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+
+	var accepted struct {
+		Data struct {
+			OperationID string `json:"operationId"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil {
+		t.Fatalf("accepted response is not valid JSON: %v", err)
+	}
+	if location := w.Header().Get("Location"); location != "/api/v1/operations/"+accepted.Data.OperationID {
+		t.Errorf("Location = %q, want operation URL", location)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		req := httptest.NewRequest("GET", "/api/v1/operations/"+accepted.Data.OperationID, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("get operation status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+
+		var got struct {
+			Data struct {
+				Operation models.Operation `json:"operation"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("operation response is not valid JSON: %v", err)
+		}
+		if got.Data.Operation.Done() {
+			return got.Data.Operation
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Fatalf("operation %s did not complete", accepted.Data.OperationID)
+	return models.Operation{}
+}
+
+func TestEnvironmentLifecycle_FakeProvider(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	router, fakeProvider := newFakeRouter(t)
+	workspaceID := "550e8400-e29b-41d4-a716-446655440000"
+
+	// Create
+	w := doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+		WorkspaceID:   workspaceID,
+		UserID:        "user-1",
+		Name:          "lifecycle",
+		CloudProvider: models.ProviderAzure,
+		CloudRegion:   "eastus",
+		CPUCores:      2,
+		MemoryGB:      4,
+		StorageGB:     20,
+	})
+	created := awaitOperation(t, router, w)
+	if created.Status != models.OperationSucceeded {
+		t.Fatalf("create operation status = %v, want %v: %+v", created.Status, models.OperationSucceeded, created.Error)
+	}
+	if created.Environment == nil || created.Environment.AzureFQDN == "" || created.Environment.ConnectionURLs.VSCodeWebURL == "" {
+		t.Errorf("create returned no FQDN/connection URLs: %+v", created.Environment)
+	}
+	// The reported password is the one code-server runs with
+	if spec, _ := fakeProvider.Spec("eastus", "aci-"+workspaceID); spec.CodeServerPassword == "" || created.Environment.ConnectionURLs.CodeServerPassword != spec.CodeServerPassword {
+		t.Errorf("create reported code-server password %q, runtime got %q", created.Environment.ConnectionURLs.CodeServerPassword, spec.CodeServerPassword)
+	}
+
+	wantSteps := map[string]models.StepStatus{
+		models.StepVolumeCreated:     models.StepDone,
+		models.StepContainerCreated:  models.StepDone,
+		models.StepRuntimeRunning:    models.StepDone,
+		models.StepFQDNAssigned:      models.StepDone,
+		models.StepIDEReachable:      models.StepSkipped, // IDE_READY_TIMEOUT unset
+		models.StepSupervisorHealthy: models.StepSkipped, // SUPERVISOR_READY_TIMEOUT unset
+	}
+	for _, step := range created.Steps {
+		if step.Status != wantSteps[step.Name] {
+			t.Errorf("create step %s = %v, want %v", step.Name, step.Status, wantSteps[step.Name])
+		}
+	}
+
+	// Start while running conflicts
+	startReq := models.StartEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		CloudRegion: "eastus",
+		UserID:      "user-1",
+		Name:        "lifecycle",
+		CPUCores:    2,
+		MemoryGB:    4,
+	}
+	if w := doJSON(t, router, "POST", "/api/v1/environments/start", startReq); w.Code != http.StatusBadRequest {
+		t.Errorf("start while running status = %v, want %v", w.Code, http.StatusBadRequest)
+	}
+
+	// Stop keeps the volume
+	stopReq := models.StopEnvironmentRequest{WorkspaceID: workspaceID, CloudRegion: "eastus"}
+	if op := awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/stop", stopReq)); op.Status != models.OperationSucceeded {
+		t.Fatalf("stop operation status = %v, want %v: %+v", op.Status, models.OperationSucceeded, op.Error)
+	}
+	if len(fakeProvider.Volumes()) != 1 {
+		t.Errorf("volumes after stop = %d, want 1", len(fakeProvider.Volumes()))
+	}
+	if w := doJSON(t, router, "POST", "/api/v1/environments/stop", stopReq); w.Code != http.StatusNotFound {
+		t.Errorf("second stop status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+
+	// Start reuses the volume
+	started := awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/start", startReq))
+	if started.Status != models.OperationSucceeded || started.Environment == nil {
+		t.Fatalf("start operation status = %v, want %v: %+v", started.Status, models.OperationSucceeded, started.Error)
+	}
+
+	// Delete without force refuses a running workspace
+	deleteReq := models.DeleteEnvironmentRequest{WorkspaceID: workspaceID, CloudRegion: "eastus"}
+	if w := doJSON(t, router, "DELETE", "/api/v1/environments", deleteReq); w.Code != http.StatusBadRequest {
+		t.Errorf("delete while running status = %v, want %v", w.Code, http.StatusBadRequest)
+	}
+
+	deleteReq.Force = true
+	if op := awaitOperation(t, router, doJSON(t, router, "DELETE", "/api/v1/environments", deleteReq)); op.Status != models.OperationSucceeded {
+		t.Fatalf("force delete operation status = %v, want %v: %+v", op.Status, models.OperationSucceeded, op.Error)
+	}
+	if len(fakeProvider.Volumes()) != 0 {
+		t.Errorf("volumes after delete = %d, want 0", len(fakeProvider.Volumes()))
+	}
+}
+
+func TestCreateEnvironment_FakeProviderFailureCleansUp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	router, fakeProvider := newFakeRouter(t)
+	fakeProvider.FailNext(fake.OpCreateRuntime, &models.AppError{Code: "INTERNAL_SERVER_ERROR", Message: "quota exceeded"})
+
+	w := doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+		WorkspaceID: "550e8400-e29b-41d4-a716-446655440001",
+		UserID:      "user-1",
+		Name:        "failing",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+		StorageGB:   20,
+	})
+	op := awaitOperation(t, router, w)
+	if op.Status != models.OperationFailed || op.Error == nil {
+		t.Fatalf("create operation status = %v, want %v", op.Status, models.OperationFailed)
+	}
+	if op.Error.Message == "" {
+		t.Errorf("failed operation has no error message")
+	}
+	for _, step := range op.Steps {
+		if step.Name == models.StepContainerCreated && step.Status != models.StepFailed {
+			t.Errorf("step %s = %v, want %v", step.Name, step.Status, models.StepFailed)
+		}
+	}
+	if len(fakeProvider.Volumes()) != 0 {
+		t.Errorf("volumes after failed create = %d, want 0 (cleanup)", len(fakeProvider.Volumes()))
+	}
+}
+
+func TestEnvironmentState_FakeProvider(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	router, fakeProvider := newFakeRouter(t, func(cfg *config.Config) {
+		cfg.StatePath = filepath.Join(t.TempDir(), "agent.db")
+	})
+	workspaceID := "550e8400-e29b-41d4-a716-446655440002"
+
+	getEnvironment := func() (int, models.Environment) {
+		req := httptest.NewRequest("GET", "/api/v1/environments/"+workspaceID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var got struct {
+			Data models.EnvironmentResponse `json:"data"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("get response is not valid JSON: %v", err)
+			}
+			return w.Code, *got.Data.Environment
+		}
+		return w.Code, models.Environment{}
+	}
+
+	if code, _ := getEnvironment(); code != http.StatusNotFound {
+		t.Errorf("get before create status = %v, want %v", code, http.StatusNotFound)
+	}
+
+	awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		UserID:      "user-1",
+		Name:        "stateful",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+		StorageGB:   20,
+	}))
+
+	code, env := getEnvironment()
+	if code != http.StatusOK || env.Status != models.StatusRunning || env.AzureFQDN == "" {
+		t.Errorf("get after create = %v %+v, want RUNNING with FQDN", code, env)
+	}
+
+	// Activity updates the last access time
+	lastActivity := time.Now().UTC().Truncate(time.Second)
+	reportActivity(t, router, workspaceID, supervisorToken(t, fakeProvider, workspaceID), models.ActivityReport{
+		Snapshot: models.ActivitySnapshot{LastIDEActivity: lastActivity},
+	})
+	if _, env := getEnvironment(); !env.LastAccessedAt.Equal(lastActivity) {
+		t.Errorf("lastAccessedAt = %v, want %v", env.LastAccessedAt, lastActivity)
+	}
+
+	awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/stop", models.StopEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		CloudRegion: "eastus",
+	}))
+	if _, env := getEnvironment(); env.Status != models.StatusStopped || env.AzureFQDN != "" {
+		t.Errorf("get after stop = %+v, want STOPPED without FQDN", env)
+	}
+
+	list := func(query string) models.EnvironmentListResponse {
+		req := httptest.NewRequest("GET", "/api/v1/environments"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list%s status = %v, want %v: %s", query, w.Code, http.StatusOK, w.Body.String())
+		}
+
+		var got struct {
+			Data models.EnvironmentListResponse `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("list response is not valid JSON: %v", err)
+		}
+		return got.Data
+	}
+
+	if got := list("?userId=user-1&status=stopped"); got.Total != 1 || got.Page != 1 || got.PageSize != models.DefaultPageSize {
+		t.Errorf("list stopped = %+v, want 1 environment on page 1", got)
+	}
+	if got := list("?status=RUNNING"); got.Total != 0 {
+		t.Errorf("list running total = %d, want 0", got.Total)
+	}
+
+	awaitOperation(t, router, doJSON(t, router, "DELETE", "/api/v1/environments", models.DeleteEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		CloudRegion: "eastus",
+	}))
+	if code, _ := getEnvironment(); code != http.StatusNotFound {
+		t.Errorf("get after delete status = %v, want %v", code, http.StatusNotFound)
+	}
+}
+
+func TestEnvironmentLifecycle_SupervisorTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	router, fakeProvider := newFakeRouter(t)
+	workspaceID := "550e8400-e29b-41d4-a716-446655440003"
+	otherID := "550e8400-e29b-41d4-a716-446655440004"
+
+	for _, id := range []string{workspaceID, otherID} {
+		awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+			WorkspaceID: id,
+			UserID:      "user-1",
+			Name:        "Token Test",
+			CloudRegion: "eastus",
+			CPUCores:    2,
+			MemoryGB:    4,
+			StorageGB:   20,
+		}))
+	}
+	firstToken := supervisorToken(t, fakeProvider, workspaceID)
+
+	// Restarting the workspace rotates its token
+	awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/stop", models.StopEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		CloudRegion: "eastus",
+	}))
+	awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/start", models.StartEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		UserID:      "user-1",
+		Name:        "Token Test",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+	}))
+	currentToken := supervisorToken(t, fakeProvider, workspaceID)
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{name: "current token", token: currentToken, wantCode: http.StatusOK},
+		{name: "missing token", wantCode: http.StatusUnauthorized},
+		{name: "token before restart", token: firstToken, wantCode: http.StatusUnauthorized},
+		{name: "another workspace's token", token: supervisorToken(t, fakeProvider, otherID), wantCode: http.StatusUnauthorized},
+		{name: "garbage", token: "not-a-token", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := reportActivity(t, router, workspaceID, tt.token, models.ActivityReport{})
+			if w.Code != tt.wantCode {
+				t.Errorf("activity status = %v, want %v: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestEnvironmentLifecycle_Quotas(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	router, _ := newFakeRouter(t, func(cfg *config.Config) {
+		cfg.StatePath = filepath.Join(t.TempDir(), "agent.db")
+		cfg.Quota = config.QuotaConfig{Default: config.QuotaLimits{RunningWorkspaces: 1, CPUCores: 3}}
+	})
+
+	create := func(workspaceID string, cpuCores int) *httptest.ResponseRecorder {
+		return doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+			WorkspaceID: workspaceID,
+			UserID:      "user-1",
+			Name:        "Quota Test",
+			CloudRegion: "eastus",
+			CPUCores:    cpuCores,
+			MemoryGB:    4,
+			StorageGB:   20,
+		})
+	}
+
+	if w := create("550e8400-e29b-41d4-a716-446655440005", 4); w.Code != http.StatusTooManyRequests {
+		t.Errorf("create over CPU quota status = %v, want %v", w.Code, http.StatusTooManyRequests)
+	}
+	awaitOperation(t, router, create("550e8400-e29b-41d4-a716-446655440006", 2))
+	if w := create("550e8400-e29b-41d4-a716-446655440007", 2); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second running workspace status = %v, want %v", w.Code, http.StatusTooManyRequests)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/quotas?userId=user-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var got struct {
+		Data models.QuotaUsageResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /quotas = %v %s", w.Code, w.Body.String())
+	}
+	want := models.QuotaResources{RunningWorkspaces: 1, CPUCores: 2, MemoryGB: 4, StorageGB: 20}
+	if got.Data.User == nil || got.Data.User.Usage != want {
+		t.Errorf("quota usage = %+v, want %+v", got.Data.User, want)
+	}
+}
+
+func TestEnvironmentLifecycle_IdempotencyKey(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	router, fakeProvider := newFakeRouter(t)
+	workspaceID := "550e8400-e29b-41d4-a716-446655440008"
+	body := models.CreateEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		UserID:      "user-1",
+		Name:        "Idempotent",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+		StorageGB:   20,
+	}
+
+	create := func(key string, body models.CreateEnvironmentRequest) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/v1/environments", bytes.NewReader(payload))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	operationID := func(w *httptest.ResponseRecorder) string {
+		var accepted struct {
+			Data struct {
+				OperationID string `json:"operationId"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &accepted)
+		return accepted.Data.OperationID
+	}
+
+	first := create("create-1", body)
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("first create status = %v: %s", first.Code, first.Body.String())
+	}
+
+	// A retry while the create is running attaches to it
+	retry := create("create-1", body)
+	if retry.Code != http.StatusAccepted || operationID(retry) != operationID(first) || retry.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Errorf("retry = %v %s (replayed %q), want operation %s", retry.Code, operationID(retry), retry.Header().Get(IdempotencyReplayedHeader), operationID(first))
+	}
+
+	changed := body
+	changed.CPUCores = 4
+	if w := create("create-1", changed); w.Code != http.StatusConflict {
+		t.Errorf("same key with a different body status = %v, want %v", w.Code, http.StatusConflict)
+	}
+
+	awaitOperation(t, router, first)
+
+	// After completion the retry gets the finished operation, not a second create
+	replay := create("create-1", body)
+	if replay.Code != http.StatusAccepted || operationID(replay) != operationID(first) {
+		t.Errorf("replay after completion = %v %s, want operation %s", replay.Code, operationID(replay), operationID(first))
+	}
+	if volumes := fakeProvider.Volumes(); len(volumes) != 1 {
+		t.Errorf("volumes = %d, want 1", len(volumes))
+	}
+}
+
+func TestEnvironmentLifecycle_WorkspaceLock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	// Keep the create in flight while the other requests arrive
+	router, _ := newFakeRouter(t, func(cfg *config.Config) {
+		cfg.Fake.RuntimeDelay = 500 * time.Millisecond
+	})
+	workspaceID := "550e8400-e29b-41d4-a716-446655440009"
+	create := doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		UserID:      "user-1",
+		Name:        "Locked",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+		StorageGB:   20,
+	})
+	if create.Code != http.StatusAccepted {
+		t.Fatalf("create status = %v: %s", create.Code, create.Body.String())
+	}
+
+	// The create holds the workspace lock until its operation completes
+	stop := models.StopEnvironmentRequest{WorkspaceID: workspaceID, CloudRegion: "eastus"}
+	if w := doJSON(t, router, "POST", "/api/v1/environments/stop", stop); w.Code != http.StatusConflict {
+		t.Errorf("stop during create status = %v, want %v: %s", w.Code, http.StatusConflict, w.Body.String())
+	}
+	deleteReq := models.DeleteEnvironmentRequest{WorkspaceID: workspaceID, CloudRegion: "eastus", Force: true}
+	if w := doJSON(t, router, "DELETE", "/api/v1/environments", deleteReq); w.Code != http.StatusConflict {
+		t.Errorf("delete during create status = %v, want %v: %s", w.Code, http.StatusConflict, w.Body.String())
+	}
+
+	if op := awaitOperation(t, router, create); op.Status != models.OperationSucceeded {
+		t.Fatalf("create status = %v, want %v", op.Status, models.OperationSucceeded)
+	}
+
+	// Released once the operation has finished
+	if op := awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/stop", stop)); op.Status != models.OperationSucceeded {
+		t.Errorf("stop after create status = %v, want %v", op.Status, models.OperationSucceeded)
+	}
+}
+
+func TestEnvironmentLifecycle_AppPorts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	router, fakeProvider := newFakeRouter(t, func(cfg *config.Config) {
+		cfg.StatePath = filepath.Join(t.TempDir(), "agent.db")
+		cfg.Ports = config.PortsConfig{Allowed: []config.PortRange{{From: 3000, To: 3999}}, MaxPerWorkspace: 2}
+	})
+	workspaceID := "550e8400-e29b-41d4-a716-446655440030"
+
+	create := func(ports []int) *httptest.ResponseRecorder {
+		return doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+			WorkspaceID: workspaceID,
+			UserID:      "user-1",
+			Name:        "Ports Test",
+			CloudRegion: "eastus",
+			CPUCores:    2,
+			MemoryGB:    4,
+			StorageGB:   20,
+			Ports:       ports,
+		})
+	}
+
+	for _, ports := range [][]int{{8000}, {provider.PortSSH}, {3000, 3001, 3002}} {
+		if w := create(ports); w.Code != http.StatusBadRequest {
+			t.Errorf("create with ports %v status = %v, want %v", ports, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	created := awaitOperation(t, router, create([]int{3000}))
+	if created.Status != models.OperationSucceeded {
+		t.Fatalf("create operation status = %v, want %v: %+v", created.Status, models.OperationSucceeded, created.Error)
+	}
+	wantPorts := []int{provider.PortIDE, provider.PortSSH, provider.PortSupervisor, 3000}
+	if spec, _ := fakeProvider.Spec("eastus", "aci-"+workspaceID); !slices.Equal(spec.Ports(), wantPorts) {
+		t.Errorf("runtime ports = %v, want %v", spec.Ports(), wantPorts)
+	}
+	if url := created.Environment.ConnectionURLs.AppURLs[3000]; !strings.HasSuffix(url, ":3000") {
+		t.Errorf("app URL for 3000 = %q, want the runtime FQDN on port 3000", url)
+	}
+
+	// Start without ports reopens the recorded ones
+	stopReq := models.StopEnvironmentRequest{WorkspaceID: workspaceID, CloudRegion: "eastus"}
+	awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/stop", stopReq))
+	started := awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments/start", models.StartEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		CloudRegion: "eastus",
+		UserID:      "user-1",
+		Name:        "Ports Test",
+		CPUCores:    2,
+		MemoryGB:    4,
+	}))
+	if started.Status != models.OperationSucceeded {
+		t.Fatalf("start operation status = %v, want %v: %+v", started.Status, models.OperationSucceeded, started.Error)
+	}
+	if spec, _ := fakeProvider.Spec("eastus", "aci-"+workspaceID); !slices.Equal(spec.AppPorts, []int{3000}) {
+		t.Errorf("app ports after start = %v, want [3000]", spec.AppPorts)
+	}
+}
+
+func TestEnvironmentLifecycle_Previews(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	var upstreamCookies []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCookies = append(upstreamCookies, r.Header.Get("Cookie"))
+		w.Write([]byte("hello from " + r.Header.Get("X-Forwarded-Host")))
+	}))
+	defer upstream.Close()
+
+	service, _ := newFakeService(t, func(cfg *config.Config) {
+		cfg.StatePath = filepath.Join(t.TempDir(), "agent.db")
+		cfg.Ports = config.PortsConfig{Allowed: []config.PortRange{{From: 3000, To: 3999}}, MaxPerWorkspace: 2}
+		cfg.Preview = config.PreviewConfig{Domain: "dev8.test", Secret: "test-secret", LinkTTL: time.Minute, SessionTTL: time.Hour}
+	})
+	previews := NewPreviewHandler(service)
+	// Every workspace app answers on the upstream test server
+	previews.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, upstream.Listener.Addr().String())
+		},
+	}
+	router := previews.Wrap(fakeRouter(service))
+	workspaceID := "550e8400-e29b-41d4-a716-446655440031"
+
+	created := awaitOperation(t, router, doJSON(t, router, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		UserID:      "user-1",
+		Name:        "Preview Test",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+		StorageGB:   20,
+		Ports:       []int{3000, 3001},
+		PublicPorts: []int{3001},
+	}))
+	if created.Status != models.OperationSucceeded {
+		t.Fatalf("create operation status = %v, want %v: %+v", created.Status, models.OperationSucceeded, created.Error)
+	}
+	wantPreviews := map[int]models.PreviewURL{
+		3000: {URL: "https://3000-" + workspaceID + ".preview.dev8.test", Visibility: models.PreviewPrivate},
+		3001: {URL: "https://3001-" + workspaceID + ".preview.dev8.test", Visibility: models.PreviewPublic},
+	}
+	if got := created.Environment.ConnectionURLs.Previews; !maps.Equal(got, wantPreviews) {
+		t.Errorf("previews = %v, want %v", got, wantPreviews)
+	}
+
+	browse := func(port int, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", fmt.Sprintf("https://%d-%s.preview.dev8.test%s", port, workspaceID, path), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Public ports are open to anyone, unexposed ones don't exist
+	if w := browse(3001, "/"); w.Code != http.StatusOK || w.Body.String() != "hello from 3001-"+workspaceID+".preview.dev8.test" {
+		t.Errorf("public preview = %v %q, want the forwarded app response", w.Code, w.Body.String())
+	}
+	if w := browse(3002, "/"); w.Code != http.StatusNotFound {
+		t.Errorf("unexposed port status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+
+	// Private ports need a session from a link minted for the owner
+	if w := browse(3000, "/"); w.Code != http.StatusUnauthorized {
+		t.Errorf("private preview without session status = %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+	w := doJSON(t, router, "POST", "/api/v1/environments/"+workspaceID+"/previews/3000/link", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create link status = %v, want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var linked struct {
+		Data models.AccessLink `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &linked); err != nil {
+		t.Fatalf("link response is not valid JSON: %v", err)
+	}
+	link, err := url.Parse(linked.Data.URL)
+	if err != nil {
+		t.Fatalf("link URL %q is invalid: %v", linked.Data.URL, err)
+	}
+	if w := browse(3001, link.RequestURI()); w.Code != http.StatusUnauthorized {
+		t.Errorf("link for another port status = %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+
+	w = browse(3000, link.RequestURI())
+	if w.Code != http.StatusFound {
+		t.Fatalf("open link status = %v, want %v: %s", w.Code, http.StatusFound, w.Body.String())
+	}
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == services.PreviewCookie {
+			session = cookie
+		}
+	}
+	if session == nil || !session.HttpOnly || !session.Secure {
+		t.Fatalf("open link cookie = %+v, want a secure HttpOnly session", session)
+	}
+
+	upstreamCookies = nil
+	if w := browse(3000, "/", session, &http.Cookie{Name: "app", Value: "1"}); w.Code != http.StatusOK {
+		t.Errorf("private preview with session status = %v, want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !slices.Equal(upstreamCookies, []string{"app=1"}) {
+		t.Errorf("app received cookies %q, want only its own", upstreamCookies)
+	}
+	if w := doJSON(t, router, "POST", "/api/v1/environments/"+workspaceID+"/previews/3002/link", nil); w.Code != http.StatusNotFound {
+		t.Errorf("link for unexposed port status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
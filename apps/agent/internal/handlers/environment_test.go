@@ -3,11 +3,16 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
 	"github.com/gorilla/mux"
 )
 
@@ -80,9 +85,11 @@ func (e *testError) Error() string {
 
 func TestHandleServiceError(t *testing.T) {
 	tests := []struct {
-		name       string
-		err        error
-		wantStatus int
+		name          string
+		err           error
+		wantStatus    int
+		wantCode      string
+		wantRetryable bool
 	}{
 		{
 			name:       "invalid request error",
@@ -99,6 +106,48 @@ func TestHandleServiceError(t *testing.T) {
 			err:        &models.AppError{Code: "UNAUTHORIZED", Message: "unauthorized"},
 			wantStatus: http.StatusUnauthorized,
 		},
+		{
+			name:       "forbidden error",
+			err:        &models.AppError{Code: "FORBIDDEN", Message: "forbidden"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "quota exceeded error",
+			err:        &models.AppError{Code: "QUOTA_EXCEEDED", Message: "quota exceeded"},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:          "throttled error",
+			err:           models.ErrThrottled("slow down"),
+			wantStatus:    http.StatusTooManyRequests,
+			wantCode:      "THROTTLED",
+			wantRetryable: true,
+		},
+		{
+			name:       "cloud quota exceeded error",
+			err:        models.ErrCloudQuotaExceeded("out of cores"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   "CLOUD_QUOTA_EXCEEDED",
+		},
+		{
+			name:          "cloud unavailable error",
+			err:           models.ErrCloudUnavailable("azure down"),
+			wantStatus:    http.StatusServiceUnavailable,
+			wantCode:      "CLOUD_UNAVAILABLE",
+			wantRetryable: true,
+		},
+		{
+			name:       "cloud auth error",
+			err:        models.ErrCloudAuthFailed("bad credentials"),
+			wantStatus: http.StatusBadGateway,
+			wantCode:   "CLOUD_AUTH_FAILED",
+		},
+		{
+			name:       "wrapped app error",
+			err:        fmt.Errorf("create: %w", models.ErrConflict("dns label taken")),
+			wantStatus: http.StatusConflict,
+			wantCode:   "CONFLICT",
+		},
 		{
 			name:       "generic error",
 			err:        &testError{msg: "generic error"},
@@ -114,14 +163,29 @@ func TestHandleServiceError(t *testing.T) {
 			if w.Code != tt.wantStatus {
 				t.Errorf("handleServiceError() status = %v, want %v", w.Code, tt.wantStatus)
 			}
+
+			var resp models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if tt.wantCode != "" && resp.ErrorCode != tt.wantCode {
+				t.Errorf("handleServiceError() errorCode = %v, want %v", resp.ErrorCode, tt.wantCode)
+			}
+			if resp.Retryable != tt.wantRetryable {
+				t.Errorf("handleServiceError() retryable = %v, want %v", resp.Retryable, tt.wantRetryable)
+			}
 		})
 	}
 }
 
 // Need to import models package
 func TestEnvironmentHandler_Routes(t *testing.T) {
-	// Create a mock environment service (would need proper mocking in production)
-	handler := &EnvironmentHandler{}
+	// A stateless service with no compute providers
+	service, err := services.NewEnvironmentService(&config.Config{}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	handler := NewEnvironmentHandler(service)
 
 	tests := []struct {
 		name      string
@@ -173,7 +237,11 @@ func TestEnvironmentHandler_Routes(t *testing.T) {
 			// Note: This is a basic structure test
 			// In a real test, you'd call the actual handler methods
 			if tt.method == "GET" && tt.path == "/api/v1/environments" {
+				req = req.WithContext(middleware.WithPrincipal(req.Context(), &middleware.Principal{Subject: "user-1"}))
 				handler.ListEnvironments(w, req)
+				if w.Code != http.StatusNotImplemented {
+					t.Errorf("ListEnvironments() without a state store status = %v, want %v", w.Code, http.StatusNotImplemented)
+				}
 			}
 		})
 	}
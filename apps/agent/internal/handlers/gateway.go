@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// GatewayHandler serves workspace IDEs at https://{workspaceId}.{GATEWAY_DOMAIN}
+// to their owners and the API minting links to them
+type GatewayHandler struct {
+	service       *services.EnvironmentService
+	authenticator *middleware.Authenticator // nil when API authentication is disabled
+	transport     http.RoundTripper         // Reaches workspaces; nil uses http.DefaultTransport
+}
+
+// NewGatewayHandler creates a new gateway handler. Callers holding API
+// credentials for the workspace owner may use them on workspace hosts too.
+func NewGatewayHandler(service *services.EnvironmentService, authenticator *middleware.Authenticator) *GatewayHandler {
+	return &GatewayHandler{
+		service:       service,
+		authenticator: authenticator,
+	}
+}
+
+// RegisterRoutes registers the gateway routes on the API v1 subrouter
+func (h *GatewayHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/environments/{id}/gateway/link", h.CreateLink).Methods("POST")
+}
+
+// Wrap serves requests for workspace hosts and passes every other request to next
+func (h *GatewayHandler) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workspaceID, ok := h.service.Gateway().ParseHost(r.Host)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.proxy(w, r, workspaceID)
+	})
+}
+
+// CreateLink handles POST /api/v1/environments/{id}/gateway/link
+func (h *GatewayHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	env, ok := ownedEnvironment(w, r, h.service, mux.Vars(r)["id"], "the gateway")
+	if !ok {
+		return
+	}
+	link, err := h.service.GatewayLink(env)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondWithSuccess(w, http.StatusOK, "Gateway link created successfully", link)
+}
+
+// proxy forwards a request for a workspace host to the workspace IDE once
+// the caller has proven they own it. The workspace is only looked up after
+// that, so callers who have not learn nothing about it.
+func (h *GatewayHandler) proxy(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	gateway := h.service.Gateway()
+
+	if r.URL.Path == services.GatewayAuthPath {
+		if err := gateway.VerifyLink(r.URL.Query().Get("token"), workspaceID); err != nil {
+			proxyError(w, err)
+			return
+		}
+		session, expires := gateway.Session(workspaceID)
+		setSessionCookie(w, services.GatewayCookie, session, expires)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	if err := h.authenticate(r, workspaceID); err != nil {
+		log.Printf("🔒 Rejected %s %s on workspace %s: %v", r.Method, r.URL.Path, workspaceID, err)
+		proxyError(w, err)
+		return
+	}
+
+	_, target, err := h.service.GatewayTarget(r.Context(), workspaceID)
+	if err != nil {
+		proxyError(w, err)
+		return
+	}
+
+	proxyToWorkspace(w, r, h.transport, target, fmt.Sprintf("Workspace %s", workspaceID), func(out *http.Request) {
+		removeCookie(out, services.GatewayCookie)
+		out.Header.Del("Authorization")
+		out.Header.Del("X-API-Key")
+	})
+}
+
+// authenticate accepts the owner's API credentials or a gateway session.
+// Another user's workspace answers like a missing one.
+func (h *GatewayHandler) authenticate(r *http.Request, workspaceID string) error {
+	if h.authenticator != nil && (r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "") {
+		principal, err := h.authenticator.Authenticate(r)
+		if err != nil {
+			return models.ErrUnauthorized(err.Error())
+		}
+		if principal.Admin {
+			return nil
+		}
+
+		env, err := h.service.GetEnvironment(r.Context(), workspaceID)
+		var appErr *models.AppError
+		if err != nil && (!errors.As(err, &appErr) || appErr.Code != models.CodeNotFound) {
+			return err
+		}
+		if err != nil || principal.Subject != env.UserID {
+			return models.ErrNotFound(fmt.Sprintf("workspace %s not found", workspaceID))
+		}
+		return nil
+	}
+
+	cookie, err := r.Cookie(services.GatewayCookie)
+	if err != nil {
+		return models.ErrUnauthorized("sign in to this workspace from Dev8")
+	}
+	return h.service.Gateway().VerifySession(cookie.Value, workspaceID)
+}
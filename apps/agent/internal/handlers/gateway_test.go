@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// echoUpstream is a workspace IDE that reports the credentials it received
+// and echoes WebSocket-style upgraded connections
+func echoUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			fmt.Fprintf(w, "cookie=%q authorization=%q", r.Header.Get("Cookie"), r.Header.Get("Authorization"))
+			return
+		}
+
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Hijack() error = %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+		line, _ := buf.ReadString('\n')
+		buf.WriteString(line)
+		buf.Flush()
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func TestGatewayHandler(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping lifecycle test in short mode")
+	}
+
+	upstream := echoUpstream(t)
+	service, _ := newFakeService(t, func(cfg *config.Config) {
+		cfg.StatePath = filepath.Join(t.TempDir(), "agent.db")
+		cfg.Gateway = config.GatewayConfig{Domain: "ws.dev8.test", Secret: "test-secret", LinkTTL: time.Minute, SessionTTL: time.Hour}
+	})
+	authenticator, err := middleware.NewAuthenticator(middleware.AuthOptions{JWTSecret: "jwt-secret", AdminScope: "dev8:admin"})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	gateway := NewGatewayHandler(service, authenticator)
+	// Every workspace IDE answers on the upstream test server
+	gateway.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, upstream.Listener.Addr().String())
+		},
+	}
+	router := fakeRouter(service)
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware.DisabledAuthMiddleware())
+	gateway.RegisterRoutes(api)
+	handler := gateway.Wrap(router)
+	workspaceID := "550e8400-e29b-41d4-a716-446655440032"
+	host := workspaceID + ".ws.dev8.test"
+
+	created := awaitOperation(t, handler, doJSON(t, handler, "POST", "/api/v1/environments", models.CreateEnvironmentRequest{
+		WorkspaceID: workspaceID,
+		UserID:      "user-1",
+		Name:        "Gateway Test",
+		CloudRegion: "eastus",
+		CPUCores:    2,
+		MemoryGB:    4,
+		StorageGB:   20,
+	}))
+	if created.Status != models.OperationSucceeded {
+		t.Fatalf("create operation status = %v, want %v: %+v", created.Status, models.OperationSucceeded, created.Error)
+	}
+	urls := created.Environment.ConnectionURLs
+	if urls.GatewayURL != "https://"+host {
+		t.Errorf("gateway URL = %q, want %q", urls.GatewayURL, "https://"+host)
+	}
+	if !strings.HasPrefix(urls.VSCodeWebURL, "http://") {
+		t.Errorf("VS Code web URL = %q, want the plain-HTTP IDE port", urls.VSCodeWebURL)
+	}
+
+	browse := func(host string, header http.Header, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://"+host+"/", nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	bearer := func(subject string) http.Header {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": subject,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("jwt-secret"))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		header   http.Header
+		wantCode int
+	}{
+		{"no credentials", host, nil, http.StatusUnauthorized},
+		{"invalid token", host, http.Header{"Authorization": {"Bearer not-a-token"}}, http.StatusUnauthorized},
+		{"another user", host, bearer("user-2"), http.StatusNotFound},
+		{"owner", host, bearer("user-1"), http.StatusOK},
+		{"unknown workspace", "550e8400-e29b-41d4-a716-446655440099.ws.dev8.test", bearer("user-1"), http.StatusNotFound},
+		// Unauthenticated callers cannot tell a missing workspace from an existing one
+		{"unknown workspace without credentials", "550e8400-e29b-41d4-a716-446655440099.ws.dev8.test", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := browse(tt.host, tt.header)
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %v, want %v: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if w.Code == http.StatusOK && w.Body.String() != `cookie="" authorization=""` {
+				t.Errorf("IDE received %s, want no agent credentials", w.Body.String())
+			}
+		})
+	}
+
+	// Browsers sign in with a link minted through the API
+	w := doJSON(t, handler, "POST", "/api/v1/environments/"+workspaceID+"/gateway/link", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create link status = %v, want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var linked struct {
+		Data models.AccessLink `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &linked); err != nil {
+		t.Fatalf("link response is not valid JSON: %v", err)
+	}
+	link, err := url.Parse(linked.Data.URL)
+	if err != nil || link.Host != host {
+		t.Fatalf("link URL = %q, want a URL on %s", linked.Data.URL, host)
+	}
+
+	req := httptest.NewRequest("GET", linked.Data.URL, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("open link status = %v, want %v: %s", w.Code, http.StatusFound, w.Body.String())
+	}
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == services.GatewayCookie {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatalf("open link set no %s cookie", services.GatewayCookie)
+	}
+	if w := browse(host, nil, session); w.Code != http.StatusOK || w.Body.String() != `cookie="" authorization=""` {
+		t.Errorf("browse with session = %v %s, want the IDE without the session cookie", w.Code, w.Body.String())
+	}
+
+	// WebSocket upgrades pass through the gateway
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nCookie: %s\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n", host, session.String())
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("upgrade status = %v, want %v: %s", resp.StatusCode, http.StatusSwitchingProtocols, body)
+	}
+	fmt.Fprint(conn, "ping\n")
+	if line, err := reader.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Errorf("echo = %q, %v, want %q", line, err, "ping\n")
+	}
+}
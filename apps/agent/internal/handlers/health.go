@@ -3,11 +3,15 @@ package handlers
 import (
 	"net/http"
 	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
 )
 
 // HealthHandler handles health check requests
 type HealthHandler struct {
 	startTime time.Time
+	providers *provider.Registry // Optional; region health is reported on /ready
 }
 
 // NewHealthHandler creates a new health handler
@@ -17,6 +21,11 @@ func NewHealthHandler() *HealthHandler {
 	}
 }
 
+// SetProviders makes /ready report the region health of the compute providers
+func (h *HealthHandler) SetProviders(providers *provider.Registry) {
+	h.providers = providers
+}
+
 // HealthCheck handles GET /health
 func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	uptime := time.Since(h.startTime)
@@ -29,12 +38,48 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ReadinessCheck handles GET /ready
+// ReadinessCheck handles GET /ready. The agent is degraded while some regions'
+// circuit breakers are open and not ready when every region of a provider is.
 func (h *HealthHandler) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
-	// TODO: Check if all dependencies are ready (database, Azure services, etc.)
-	respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"status": "ready",
-	})
+	status, code := "ready", http.StatusOK
+	regions := make(map[models.CloudProvider][]models.RegionHealth)
+
+	if h.providers != nil {
+		for _, cloud := range h.providers.Providers() {
+			computeProvider, err := h.providers.Get(cloud)
+			if err != nil {
+				continue
+			}
+			reporter, ok := computeProvider.(provider.RegionHealthReporter)
+			if !ok {
+				continue
+			}
+
+			health := reporter.RegionHealth()
+			regions[cloud] = health
+
+			unhealthy := 0
+			for _, region := range health {
+				if !region.Healthy {
+					unhealthy++
+				}
+			}
+			switch {
+			case unhealthy > 0 && unhealthy == len(health):
+				status, code = "not ready", http.StatusServiceUnavailable
+			case unhealthy > 0 && code == http.StatusOK:
+				status = "degraded"
+			}
+		}
+	}
+
+	response := map[string]interface{}{
+		"status": status,
+	}
+	if len(regions) > 0 {
+		response["regions"] = regions
+	}
+	respondWithJSON(w, code, response)
 }
 
 // LivenessCheck handles GET /live
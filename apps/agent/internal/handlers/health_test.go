@@ -1,11 +1,24 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
 )
 
+// healthReportingProvider reports fixed region health on top of the fake provider
+type healthReportingProvider struct {
+	*fake.Provider
+	health []models.RegionHealth
+}
+
+func (p *healthReportingProvider) RegionHealth() []models.RegionHealth { return p.health }
+
 func TestHealthHandler_HealthCheck(t *testing.T) {
 	handler := NewHealthHandler()
 
@@ -37,6 +50,53 @@ func TestHealthHandler_ReadinessCheck(t *testing.T) {
 	}
 }
 
+func TestHealthHandler_ReadinessCheck_RegionHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		healthy    []bool
+		wantStatus int
+		wantState  string
+	}{
+		{name: "all regions healthy", healthy: []bool{true, true}, wantStatus: http.StatusOK, wantState: "ready"},
+		{name: "one region open", healthy: []bool{true, false}, wantStatus: http.StatusOK, wantState: "degraded"},
+		{name: "every region open", healthy: []bool{false, false}, wantStatus: http.StatusServiceUnavailable, wantState: "not ready"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporting := &healthReportingProvider{Provider: fake.NewProvider(fake.Options{Regions: []string{"eastus", "westeurope"}})}
+			for i, region := range []string{"eastus", "westeurope"} {
+				reporting.health = append(reporting.health, models.RegionHealth{Region: region, Healthy: tt.healthy[i]})
+			}
+			providers := provider.NewRegistry(models.ProviderAzure)
+			providers.Register(models.ProviderAzure, reporting)
+
+			handler := NewHealthHandler()
+			handler.SetProviders(providers)
+
+			w := httptest.NewRecorder()
+			handler.ReadinessCheck(w, httptest.NewRequest("GET", "/ready", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("ReadinessCheck() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			var resp struct {
+				Status  string                                         `json:"status"`
+				Regions map[models.CloudProvider][]models.RegionHealth `json:"regions"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Status != tt.wantState {
+				t.Errorf("ReadinessCheck() status field = %v, want %v", resp.Status, tt.wantState)
+			}
+			if len(resp.Regions[models.ProviderAzure]) != 2 {
+				t.Errorf("ReadinessCheck() regions = %v, want 2 Azure regions", resp.Regions)
+			}
+		})
+	}
+}
+
 func TestHealthHandler_LivenessCheck(t *testing.T) {
 	handler := NewHealthHandler()
 
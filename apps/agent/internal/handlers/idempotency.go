@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// Idempotency headers
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxIdempotencyKeyLength bounds the keys kept in memory
+const maxIdempotencyKeyLength = 255
+
+// lifecycleFunc validates a lifecycle request and starts its operation
+type lifecycleFunc func(r *http.Request) (*models.Operation, error)
+
+// invalidBodyError is returned by decodeBody for malformed JSON
+type invalidBodyError struct {
+	err error
+}
+
+func (e *invalidBodyError) Error() string {
+	return e.err.Error()
+}
+
+// decodeBody decodes the JSON request body into v
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &invalidBodyError{err: err}
+	}
+	return nil
+}
+
+// runLifecycle starts a lifecycle operation and responds 202 with it. With an
+// Idempotency-Key header a retry of the same request gets the operation the
+// first attempt started (in flight or completed) instead of starting another,
+// and reusing the key for a different request fails with 409.
+func (h *EnvironmentHandler) runLifecycle(w http.ResponseWriter, r *http.Request, message string, start lifecycleFunc) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		op, err := start(r)
+		respondWithLifecycle(w, message, op, err)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		handleServiceError(w, models.ErrInvalidRequest(fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", "Please check your JSON payload", err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	claim, op, err := h.service.Idempotency().Begin(r.Context(), idempotencyScope(r, key), requestHash(r, body))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	if claim == nil {
+		w.Header().Set(IdempotencyReplayedHeader, "true")
+		respondWithOperation(w, message, op)
+		return
+	}
+
+	op, err = start(r)
+	if err != nil {
+		claim.Abort()
+	} else {
+		claim.Complete(op)
+	}
+	respondWithLifecycle(w, message, op, err)
+}
+
+// respondWithLifecycle responds with the started operation or the error that prevented it
+func respondWithLifecycle(w http.ResponseWriter, message string, op *models.Operation, err error) {
+	var bodyErr *invalidBodyError
+	switch {
+	case errors.As(err, &bodyErr):
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", "Please check your JSON payload", err)
+	case err != nil:
+		handleServiceError(w, err)
+	default:
+		respondWithOperation(w, message, op)
+	}
+}
+
+// idempotencyScope namespaces keys by caller so users cannot replay each other's requests
+func idempotencyScope(r *http.Request, key string) string {
+	if principal, ok := middleware.PrincipalFromContext(r.Context()); ok {
+		return principal.Subject + "\x00" + key
+	}
+	return key
+}
+
+// requestHash identifies a request by endpoint and JSON body. Valid JSON is
+// re-encoded first so formatting and key order do not matter.
+func requestHash(r *http.Request, body []byte) string {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			body = canonical
+		}
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
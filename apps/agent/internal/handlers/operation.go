@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// sseHeartbeatInterval keeps idle event streams open through proxies
+const sseHeartbeatInterval = 15 * time.Second
+
+// OperationHandler handles long-running operation requests
+type OperationHandler struct {
+	operations *services.OperationManager
+}
+
+// NewOperationHandler creates a new operation handler
+func NewOperationHandler(operations *services.OperationManager) *OperationHandler {
+	return &OperationHandler{
+		operations: operations,
+	}
+}
+
+// RegisterRoutes registers the operation routes on the API v1 subrouter
+func (h *OperationHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/operations/{id}", h.GetOperation).Methods("GET")
+	api.HandleFunc("/operations/{id}/events", h.StreamOperation).Methods("GET")
+}
+
+// GetOperation handles GET /api/v1/operations/{id}
+func (h *OperationHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	op, err := h.operations.Get(mux.Vars(r)["id"])
+	if err == nil {
+		// The result carries the workspace credentials
+		err = authorizeUser(r, op.UserID)
+	}
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, "Operation retrieved successfully", map[string]interface{}{
+		"operation": op,
+	})
+}
+
+// StreamOperation handles GET /api/v1/operations/{id}/events as Server-Sent Events.
+// Every change is sent as a "progress" event carrying the full operation; the
+// stream ends with a "done" event once the operation has succeeded or failed.
+func (h *OperationHandler) StreamOperation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	op, updates, unsubscribe, err := h.operations.Subscribe(id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	if err := authorizeUser(r, op.UserID); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	// The stream outlives the server's WriteTimeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to clear write deadline for operation %s stream: %v", id, err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeSSE(w, rc, "progress", op); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case snapshot, ok := <-updates:
+			if !ok {
+				// Completed - send the final state, which a slow stream may have missed
+				if final, err := h.operations.Get(id); err == nil {
+					op = final
+				}
+				_ = writeSSE(w, rc, "done", op)
+				return
+			}
+			op = snapshot
+			if err := writeSSE(w, rc, "progress", op); err != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeSSE writes a single named event with a JSON payload and flushes it
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, event string, op *models.Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		log.Printf("❌ Error marshaling operation %s: %v", op.ID, err)
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", event, op.UpdatedAt.UnixNano(), data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
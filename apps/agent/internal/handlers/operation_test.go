@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func newOperationRouter(operations *services.OperationManager) *mux.Router {
+	router := mux.NewRouter()
+	NewOperationHandler(operations).RegisterRoutes(router.PathPrefix("/api/v1").Subrouter())
+	return router
+}
+
+func TestOperationHandler_GetOperation(t *testing.T) {
+	operations := services.NewOperationManager(time.Minute, time.Hour)
+	op := operations.Start(models.OperationStop, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		return nil, nil
+	})
+	router := newOperationRouter(operations)
+
+	tests := []struct {
+		name      string
+		id        string
+		principal *middleware.Principal
+		wantCode  int
+	}{
+		{name: "unauthenticated", id: op.ID, wantCode: http.StatusUnauthorized},
+		{name: "unknown operation", id: "op-missing", wantCode: http.StatusNotFound},
+		{name: "owner", id: op.ID, principal: &middleware.Principal{Subject: "user-1"}, wantCode: http.StatusOK},
+		{name: "admin", id: op.ID, principal: &middleware.Principal{Subject: "service", Admin: true}, wantCode: http.StatusOK},
+		{name: "other user", id: op.ID, principal: &middleware.Principal{Subject: "user-2"}, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/operations/"+tt.id, nil)
+			if tt.principal != nil {
+				req = req.WithContext(middleware.WithPrincipal(req.Context(), tt.principal))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("GetOperation() status = %v, want %v", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestOperationHandler_StreamOperation(t *testing.T) {
+	operations := services.NewOperationManager(time.Minute, time.Hour)
+	release := make(chan struct{})
+	op := operations.Start(models.OperationCreate, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		<-release
+		return &models.Environment{ID: "ws-1"}, nil
+	})
+
+	server := httptest.NewServer(middleware.DisabledAuthMiddleware()(newOperationRouter(operations)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/operations/" + op.ID + "/events")
+	if err != nil {
+		t.Fatalf("GET events error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %v, want text/event-stream", got)
+	}
+	close(release)
+
+	// Collect events until the stream ends
+	var events []string
+	var last models.Operation
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &last); err != nil {
+				t.Fatalf("event data is not valid JSON: %v", err)
+			}
+		}
+	}
+
+	if len(events) < 2 || events[0] != "progress" || events[len(events)-1] != "done" {
+		t.Errorf("events = %v, want progress ... done", events)
+	}
+	if last.Status != models.OperationSucceeded || last.Environment == nil {
+		t.Errorf("final event = %+v, want SUCCEEDED with environment", last)
+	}
+}
+
+func TestOperationHandler_StreamUnknownOperation(t *testing.T) {
+	router := newOperationRouter(services.NewOperationManager(time.Minute, time.Hour))
+
+	req := httptest.NewRequest("GET", "/api/v1/operations/op-missing/events", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("StreamOperation() status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
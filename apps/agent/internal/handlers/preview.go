@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// PreviewHandler serves preview URLs of workspace app ports and the API
+// minting links to private ones
+type PreviewHandler struct {
+	service   *services.EnvironmentService
+	transport http.RoundTripper // Reaches workspace apps; nil uses http.DefaultTransport
+}
+
+// NewPreviewHandler creates a new preview handler
+func NewPreviewHandler(service *services.EnvironmentService) *PreviewHandler {
+	return &PreviewHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the preview routes on the API v1 subrouter
+func (h *PreviewHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/environments/{id}/previews/{port}/link", h.CreateLink).Methods("POST")
+}
+
+// Wrap serves requests for preview hosts and passes every other request to next.
+// Preview hosts are matched before any API middleware: browsers reach them
+// without API credentials.
+func (h *PreviewHandler) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workspaceID, port, ok := h.service.Previews().ParseHost(r.Host)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.proxy(w, r, workspaceID, port)
+	})
+}
+
+// CreateLink handles POST /api/v1/environments/{id}/previews/{port}/link
+func (h *PreviewHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	port, err := strconv.Atoi(vars["port"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid Request", "port must be a number", err)
+		return
+	}
+
+	env, ok := ownedEnvironment(w, r, h.service, vars["id"], "previews")
+	if !ok {
+		return
+	}
+	link, err := h.service.PreviewLink(env, port)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondWithSuccess(w, http.StatusOK, "Preview link created successfully", link)
+}
+
+// proxy forwards a preview request to the workspace app port, signing the
+// owner in first when the port is private
+func (h *PreviewHandler) proxy(w http.ResponseWriter, r *http.Request, workspaceID string, port int) {
+	env, target, err := h.service.PreviewTarget(r.Context(), workspaceID, port)
+	if err != nil {
+		proxyError(w, err)
+		return
+	}
+	previews := h.service.Previews()
+
+	if r.URL.Path == services.PreviewAuthPath {
+		if err := previews.VerifyLink(r.URL.Query().Get("token"), workspaceID, port); err != nil {
+			proxyError(w, err)
+			return
+		}
+		session, expires := previews.Session(workspaceID, port)
+		setSessionCookie(w, services.PreviewCookie, session, expires)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	if !slices.Contains(env.PublicPorts, port) {
+		cookie, err := r.Cookie(services.PreviewCookie)
+		if err != nil {
+			proxyError(w, models.ErrUnauthorized("this preview is private - open it from Dev8"))
+			return
+		}
+		if err := previews.VerifySession(cookie.Value, workspaceID, port); err != nil {
+			proxyError(w, err)
+			return
+		}
+	}
+
+	name := fmt.Sprintf("Port %d of workspace %s", port, workspaceID)
+	proxyToWorkspace(w, r, h.transport, target, name, func(out *http.Request) {
+		removeCookie(out, services.PreviewCookie)
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+)
+
+// ownedEnvironment returns the recorded workspace if the caller may act on it,
+// and otherwise writes the error response. feature names what needs the record.
+func ownedEnvironment(w http.ResponseWriter, r *http.Request, service *services.EnvironmentService, workspaceID, feature string) (*models.Environment, bool) {
+	env, err := service.GetEnvironment(r.Context(), workspaceID)
+	if errors.Is(err, services.ErrStateless) {
+		respondWithError(w, http.StatusNotImplemented, "Not Supported", fmt.Sprintf("This agent doesn't store state, so it cannot serve %s.", feature), err)
+		return nil, false
+	}
+	if err == nil {
+		err = authorizeUser(r, env.UserID)
+	}
+	if err != nil {
+		handleServiceError(w, err)
+		return nil, false
+	}
+	return env, true
+}
+
+// proxyToWorkspace forwards a browser request to a workspace origin. rewrite
+// drops the agent's own credentials from the outgoing request; name says what
+// is proxied in errors, e.g. "Port 3000 of workspace ws-1".
+func proxyToWorkspace(w http.ResponseWriter, r *http.Request, transport http.RoundTripper, target, name string, rewrite func(out *http.Request)) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		proxyError(w, models.ErrInternalServer(fmt.Sprintf("invalid URL %q for %s: %v", target, name, err)))
+		return
+	}
+
+	// Workspaces stream and hold WebSockets open (IDE, dev server hot reload),
+	// so the server's write timeout must not cut them off
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Warning: failed to clear write deadline for %s: %v", name, err)
+	}
+
+	// ReverseProxy carries WebSocket upgrades as well as plain HTTP
+	proxy := &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(targetURL)
+			pr.SetXForwarded()
+			rewrite(pr.Out)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Warning: proxying to %s failed: %v", name, err)
+			http.Error(w, name+" is not answering yet", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// setSessionCookie stores an access session in a host-only cookie
+func setSessionCookie(w http.ResponseWriter, name, session string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    session,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// removeCookie keeps an agent session cookie from reaching the workspace
+func removeCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	var kept []string
+	for _, cookie := range cookies {
+		if cookie.Name != name {
+			kept = append(kept, cookie.String())
+		}
+	}
+	if len(kept) > 0 {
+		r.Header.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
+
+// proxyError writes a plain-text error for a browser opening a workspace host
+func proxyError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "Workspace unavailable"
+
+	var appErr *models.AppError
+	if errors.As(err, &appErr) {
+		message = appErr.Message
+		switch appErr.Code {
+		case models.CodeNotFound:
+			status = http.StatusNotFound
+		case models.CodeUnauthorized:
+			status = http.StatusUnauthorized
+		case models.CodeForbidden:
+			status = http.StatusForbidden
+		case models.CodeCloudUnavailable:
+			status = http.StatusServiceUnavailable
+		}
+	}
+	http.Error(w, message, status)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// QuotaHandler exposes quota usage
+type QuotaHandler struct {
+	quotas *services.QuotaManager
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(quotas *services.QuotaManager) *QuotaHandler {
+	return &QuotaHandler{
+		quotas: quotas,
+	}
+}
+
+// RegisterRoutes registers the quota routes on the API v1 subrouter
+func (h *QuotaHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/quotas", h.GetUsage).Methods("GET")
+}
+
+// GetUsage handles GET /api/v1/quotas?userId=...&orgId=...
+func (h *QuotaHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	userID, err := resolveUserID(r, query.Get("userId"))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	// Organisation membership is not known to the agent
+	orgID := query.Get("orgId")
+	if orgID != "" {
+		if err := requireAdmin(r); err != nil {
+			handleServiceError(w, err)
+			return
+		}
+	}
+
+	usage, err := h.quotas.Usage(userID, orgID)
+	if errors.Is(err, services.ErrStateless) {
+		respondWithError(w, http.StatusNotImplemented, "Quotas Not Supported", "This agent doesn't store state, so it has no quota usage.", err)
+		return
+	}
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, "Quota usage retrieved successfully", usage)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// ReconcileHandler exposes the orphaned-resource reconciler
+type ReconcileHandler struct {
+	reconciler *services.Reconciler
+}
+
+// NewReconcileHandler creates a new reconcile handler
+func NewReconcileHandler(reconciler *services.Reconciler) *ReconcileHandler {
+	return &ReconcileHandler{
+		reconciler: reconciler,
+	}
+}
+
+// RegisterRoutes registers the reconciliation routes on the API v1 subrouter
+func (h *ReconcileHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/reconciliation", h.GetReport).Methods("GET")
+	api.HandleFunc("/reconciliation", h.RunReconciliation).Methods("POST")
+}
+
+// GetReport handles GET /api/v1/reconciliation
+func (h *ReconcileHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	if err := requireAdmin(r); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	report := h.reconciler.LastReport()
+	if report == nil {
+		handleServiceError(w, models.ErrNotFound("no reconciliation has run yet"))
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, "Reconciliation report retrieved successfully", map[string]interface{}{
+		"report": report,
+	})
+}
+
+// RunReconciliation handles POST /api/v1/reconciliation by running a pass immediately
+func (h *ReconcileHandler) RunReconciliation(w http.ResponseWriter, r *http.Request) {
+	if err := requireAdmin(r); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	report := h.reconciler.Reconcile(r.Context())
+
+	respondWithSuccess(w, http.StatusOK, "Reconciliation completed", map[string]interface{}{
+		"report": report,
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func TestReconcileHandler_Routes(t *testing.T) {
+	service, err := services.NewEnvironmentService(&config.Config{}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	defer service.Close()
+
+	router := mux.NewRouter()
+	reconciler := services.NewReconciler(service, config.ReconcileConfig{})
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware.DisabledAuthMiddleware())
+	NewReconcileHandler(reconciler).RegisterRoutes(api)
+
+	// Requests run in order: no report until a pass has run
+	tests := []struct {
+		name     string
+		method   string
+		wantCode int
+	}{
+		{name: "report before first pass", method: "GET", wantCode: http.StatusNotFound},
+		{name: "run pass", method: "POST", wantCode: http.StatusOK},
+		{name: "report after pass", method: "GET", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/reconciliation", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("%s /reconciliation status = %v, want %v", tt.method, w.Code, tt.wantCode)
+			}
+		})
+	}
+}
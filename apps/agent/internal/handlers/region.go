@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// RegionHandler exposes the regions the agent can place workspaces in
+type RegionHandler struct {
+	service *services.EnvironmentService
+}
+
+// NewRegionHandler creates a new region handler
+func NewRegionHandler(service *services.EnvironmentService) *RegionHandler {
+	return &RegionHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the region routes on the API v1 subrouter
+func (h *RegionHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/regions", h.ListRegions).Methods("GET")
+}
+
+// ListRegions handles GET /api/v1/regions
+func (h *RegionHandler) ListRegions(w http.ResponseWriter, r *http.Request) {
+	respondWithSuccess(w, http.StatusOK, "Regions retrieved successfully", map[string]interface{}{
+		"regions": h.service.Regions(),
+	})
+}
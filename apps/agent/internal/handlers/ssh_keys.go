@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// SSHKeyHandler manages the public keys the SSH bastion accepts for each user
+type SSHKeyHandler struct {
+	service *services.EnvironmentService
+}
+
+// NewSSHKeyHandler creates a new SSH key handler
+func NewSSHKeyHandler(service *services.EnvironmentService) *SSHKeyHandler {
+	return &SSHKeyHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the SSH key routes on the API v1 subrouter
+func (h *SSHKeyHandler) RegisterRoutes(api *mux.Router) {
+	api.HandleFunc("/users/{userId}/ssh-keys", h.GetKeys).Methods("GET")
+	api.HandleFunc("/users/{userId}/ssh-keys", h.PutKeys).Methods("PUT")
+}
+
+// GetKeys handles GET /api/v1/users/{userId}/ssh-keys
+func (h *SSHKeyHandler) GetKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := resolveUserID(r, mux.Vars(r)["userId"])
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	keys, err := h.service.SSHKeys(userID)
+	if h.stateless(w, err) {
+		return
+	}
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, "SSH keys retrieved successfully", models.SSHKeys{Keys: keys})
+}
+
+// PutKeys handles PUT /api/v1/users/{userId}/ssh-keys, replacing every key of the user
+func (h *SSHKeyHandler) PutKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := resolveUserID(r, mux.Vars(r)["userId"])
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	var req models.SSHKeys
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid Request Body", "Please check your JSON payload", err)
+		return
+	}
+
+	keys, err := h.service.SetSSHKeys(userID, req.Keys)
+	if h.stateless(w, err) {
+		return
+	}
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, "SSH keys updated successfully", models.SSHKeys{Keys: keys})
+}
+
+// stateless writes the response for agents without a state store
+func (h *SSHKeyHandler) stateless(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, services.ErrStateless) {
+		return false
+	}
+	respondWithError(w, http.StatusNotImplemented, "SSH Keys Not Supported", "This agent doesn't store state, so it cannot keep SSH keys.", err)
+	return true
+}
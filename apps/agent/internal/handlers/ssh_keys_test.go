@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+func TestSSHKeyHandler(t *testing.T) {
+	const key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIL0C401iOT3bQvt2bqCIB+hblO2Dk+tciYKBFJ1w7SSF laptop"
+
+	statefulService, _ := newFakeService(t, func(cfg *config.Config) {
+		cfg.StatePath = filepath.Join(t.TempDir(), "agent.db")
+	})
+	stateful := fakeRouter(statefulService)
+	stateless, _ := newFakeRouter(t)
+
+	tests := []struct {
+		name     string
+		router   http.Handler
+		method   string
+		body     interface{}
+		wantCode int
+		wantKeys []string
+	}{
+		{name: "none registered", router: stateful, method: "GET", wantCode: http.StatusOK, wantKeys: []string{}},
+		{name: "register", router: stateful, method: "PUT", body: models.SSHKeys{Keys: []string{key}}, wantCode: http.StatusOK, wantKeys: []string{key}},
+		{name: "registered", router: stateful, method: "GET", wantCode: http.StatusOK, wantKeys: []string{key}},
+		{name: "invalid key", router: stateful, method: "PUT", body: models.SSHKeys{Keys: []string{"not a key"}}, wantCode: http.StatusBadRequest},
+		{name: "stateless", router: stateless, method: "GET", wantCode: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doJSON(t, tt.router, tt.method, "/api/v1/users/user-1/ssh-keys", tt.body)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %v, want %v: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantKeys == nil {
+				return
+			}
+
+			var resp struct {
+				Data models.SSHKeys `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not valid JSON: %v", err)
+			}
+			if !slices.Equal(resp.Data.Keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", resp.Data.Keys, tt.wantKeys)
+			}
+		})
+	}
+}
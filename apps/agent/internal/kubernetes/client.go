@@ -0,0 +1,33 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// NewClientset creates a Kubernetes clientset from a kubeconfig file,
+// falling back to the in-cluster service account when kubeconfig is empty
+func NewClientset(kubeconfig string) (clientset.Interface, error) {
+	var (
+		restConfig *rest.Config
+		err        error
+	)
+
+	if kubeconfig != "" {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	} else {
+		restConfig, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kubernetes config: %w", err)
+	}
+
+	client, err := clientset.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	return client, nil
+}
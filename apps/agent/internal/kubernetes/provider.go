@@ -0,0 +1,535 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// namedPort is a container port with the name its Service port targets
+type namedPort struct {
+	name string
+	port int32
+}
+
+// workspacePortNames names the ports served by the workspace image
+var workspacePortNames = map[int]string{
+	provider.PortIDE:        "ide",
+	provider.PortSSH:        "ssh",
+	provider.PortSupervisor: "supervisor",
+}
+
+// namedPorts returns the spec's ports named for the pod and Service; app
+// ports are named app-{port}
+func namedPorts(spec provider.ContainerGroupSpec) []namedPort {
+	var ports []namedPort
+	for _, port := range spec.Ports() {
+		name, ok := workspacePortNames[port]
+		if !ok {
+			name = fmt.Sprintf("app-%d", port)
+		}
+		ports = append(ports, namedPort{name: name, port: int32(port)})
+	}
+	return ports
+}
+
+const (
+	// regionLabel records which agent region a pod or PVC belongs to
+	regionLabel = "dev8.dev/region"
+	// runtimeLabel selects the workspace pod from its Service
+	runtimeLabel = "dev8.dev/runtime"
+	// homeMountPath is where the workspace volume is mounted
+	homeMountPath = "/home/dev8"
+)
+
+// Options configures the Kubernetes provider
+type Options struct {
+	// Namespace workspaces are created in
+	Namespace string
+	// Regions served by this cluster (agent region names)
+	Regions []string
+	// StorageClass for workspace PVCs (empty uses the cluster default)
+	StorageClass string
+	// ServiceType is ClusterIP (default), NodePort or LoadBalancer
+	ServiceType corev1.ServiceType
+	// PublicHost is the node host name clients use to reach NodePort services
+	PublicHost string
+}
+
+// Provider implements provider.ComputeProvider on a Kubernetes cluster.
+// A workspace is a Pod plus a Service for its ports; the fs-{id} file share
+// becomes a PersistentVolumeClaim mounted at /home/dev8.
+type Provider struct {
+	client clientset.Interface
+	opts   Options
+}
+
+// NewProvider creates a Kubernetes provider
+func NewProvider(client clientset.Interface, opts Options) *Provider {
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	if opts.ServiceType == "" {
+		opts.ServiceType = corev1.ServiceTypeClusterIP
+	}
+	return &Provider{client: client, opts: opts}
+}
+
+// Name returns the backend name
+func (p *Provider) Name() string {
+	return "kubernetes"
+}
+
+// HasRegion reports whether the region is served by this cluster
+func (p *Provider) HasRegion(region string) bool {
+	for _, r := range p.opts.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// Regions returns the regions served by this cluster
+func (p *Provider) Regions() []string {
+	return append([]string(nil), p.opts.Regions...)
+}
+
+// CreateVolume creates the workspace PersistentVolumeClaim
+func (p *Provider) CreateVolume(ctx context.Context, region, name string, quotaGB int32) error {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.opts.Namespace,
+			Labels: map[string]string{
+				"managed-by": "dev8-agent",
+				regionLabel:  region,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: *resource.NewQuantity(int64(quotaGB)*1024*1024*1024, resource.BinarySI),
+				},
+			},
+		},
+	}
+	if p.opts.StorageClass != "" {
+		pvc.Spec.StorageClassName = &p.opts.StorageClass
+	}
+
+	if _, err := p.client.CoreV1().PersistentVolumeClaims(p.opts.Namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create persistent volume claim: %w", err)
+	}
+
+	log.Printf("☸️  [kubernetes] Created PVC %s/%s (%dGi)", p.opts.Namespace, name, quotaGB)
+	return nil
+}
+
+// VolumeExists checks whether the workspace PersistentVolumeClaim exists
+func (p *Provider) VolumeExists(ctx context.Context, region, name string) (bool, error) {
+	_, err := p.client.CoreV1().PersistentVolumeClaims(p.opts.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check persistent volume claim: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteVolume deletes the workspace PersistentVolumeClaim
+func (p *Provider) DeleteVolume(ctx context.Context, region, name string) error {
+	if err := p.client.CoreV1().PersistentVolumeClaims(p.opts.Namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("failed to delete persistent volume claim: %w", err)
+	}
+	return nil
+}
+
+// ListVolumes lists the workspace PersistentVolumeClaims created for the region
+func (p *Provider) ListVolumes(ctx context.Context, region string) ([]provider.Volume, error) {
+	selector := labels.SelectorFromSet(labels.Set{"managed-by": "dev8-agent", regionLabel: region})
+	pvcs, err := p.client.CoreV1().PersistentVolumeClaims(p.opts.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volume claims: %w", err)
+	}
+
+	volumes := make([]provider.Volume, 0, len(pvcs.Items))
+	for _, pvc := range pvcs.Items {
+		if !strings.HasPrefix(pvc.Name, provider.VolumePrefix) {
+			continue
+		}
+		volumes = append(volumes, provider.Volume{
+			Name:      pvc.Name,
+			Region:    region,
+			CreatedAt: pvc.CreationTimestamp.Time,
+		})
+	}
+	return volumes, nil
+}
+
+// CreateRuntime creates the workspace secrets, Pod and Service
+func (p *Provider) CreateRuntime(ctx context.Context, region, name string, spec provider.ContainerGroupSpec) error {
+	labels := p.runtimeLabels(region, name, spec)
+
+	envSecret := p.envSecret(name, labels, spec)
+	if err := p.applySecret(ctx, envSecret); err != nil {
+		return fmt.Errorf("failed to create environment secret: %w", err)
+	}
+
+	var pullSecret *corev1.Secret
+	if spec.RegistryUsername != "" {
+		var err error
+		pullSecret, err = registrySecret(name+"-registry", p.opts.Namespace, labels, spec)
+		if err != nil {
+			return err
+		}
+		if err := p.applySecret(ctx, pullSecret); err != nil {
+			return fmt.Errorf("failed to create registry secret: %w", err)
+		}
+	}
+
+	pod := p.pod(name, labels, spec, envSecret, pullSecret)
+	if _, err := p.client.CoreV1().Pods(p.opts.Namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		p.deleteSecrets(ctx, name)
+		return fmt.Errorf("failed to create pod: %w", err)
+	}
+
+	if _, err := p.client.CoreV1().Services(p.opts.Namespace).Create(ctx, p.service(name, labels, spec), metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			// Don't leave a pod behind that nothing can reach
+			_ = p.client.CoreV1().Pods(p.opts.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+			p.deleteSecrets(ctx, name)
+			return fmt.Errorf("failed to create service: %w", err)
+		}
+	}
+
+	log.Printf("☸️  [kubernetes] Created pod %s/%s (%s)", p.opts.Namespace, name, spec.Image)
+	return nil
+}
+
+// GetRuntime returns the workspace pod and its service address
+func (p *Provider) GetRuntime(ctx context.Context, region, name string) (*provider.Runtime, error) {
+	pod, err := p.client.CoreV1().Pods(p.opts.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, provider.ErrRuntimeNotFound
+		}
+		return nil, fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	service, err := p.client.CoreV1().Services(p.opts.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		service = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get service: %w", err)
+	}
+
+	runtime := p.podToRuntime(pod, service, region)
+	return &runtime, nil
+}
+
+// DeleteRuntime deletes the workspace Pod, Service and secrets; the PVC is kept
+func (p *Provider) DeleteRuntime(ctx context.Context, region, name string) error {
+	if err := p.client.CoreV1().Pods(p.opts.Namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return provider.ErrRuntimeNotFound
+		}
+		return fmt.Errorf("failed to delete pod: %w", err)
+	}
+
+	if err := p.client.CoreV1().Services(p.opts.Namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Warning: failed to delete service %s: %v", name, err)
+	}
+	p.deleteSecrets(ctx, name)
+
+	return nil
+}
+
+// ListRuntimes lists agent-managed workspace pods for the region
+func (p *Provider) ListRuntimes(ctx context.Context, region string) ([]provider.Runtime, error) {
+	selector := labels.SelectorFromSet(labels.Set{"managed-by": "dev8-agent", regionLabel: region})
+	pods, err := p.client.CoreV1().Pods(p.opts.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	runtimes := make([]provider.Runtime, 0, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+
+		service, err := p.client.CoreV1().Services(p.opts.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			service = nil
+		}
+		runtimes = append(runtimes, p.podToRuntime(pod, service, region))
+	}
+	return runtimes, nil
+}
+
+// runtimeLabels mirrors the ACI tags and adds the labels used for selection
+func (p *Provider) runtimeLabels(region, name string, spec provider.ContainerGroupSpec) map[string]string {
+	labels := spec.Labels()
+	labels[regionLabel] = region
+	labels[runtimeLabel] = name
+	return labels
+}
+
+// envSecret holds the secure environment variables so they never appear in the pod spec
+func (p *Provider) envSecret(name string, labels map[string]string, spec provider.ContainerGroupSpec) *corev1.Secret {
+	data := make(map[string]string)
+	for _, envVar := range spec.EnvVars() {
+		if envVar.Secure {
+			data[envVar.Name] = envVar.Value
+		}
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-env",
+			Namespace: p.opts.Namespace,
+			Labels:    labels,
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: data,
+	}
+}
+
+// registrySecret builds an image pull secret for private registries
+func registrySecret(name, namespace string, labels map[string]string, spec provider.ContainerGroupSpec) (*corev1.Secret, error) {
+	server := spec.RegistryServer
+	if server == "" || server == "index.docker.io" {
+		server = "https://index.docker.io/v1/"
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(spec.RegistryUsername + ":" + spec.RegistryPassword))
+	dockerConfig, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			server: map[string]string{
+				"username": spec.RegistryUsername,
+				"password": spec.RegistryPassword,
+				"auth":     auth,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode registry credentials: %w", err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig},
+	}, nil
+}
+
+// applySecret creates a secret, replacing one left behind by a previous runtime
+func (p *Provider) applySecret(ctx context.Context, secret *corev1.Secret) error {
+	secrets := p.client.CoreV1().Secrets(p.opts.Namespace)
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// deleteSecrets removes the per-runtime secrets, ignoring ones that do not exist
+func (p *Provider) deleteSecrets(ctx context.Context, name string) {
+	for _, secretName := range []string{name + "-env", name + "-registry"} {
+		err := p.client.CoreV1().Secrets(p.opts.Namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("Warning: failed to delete secret %s: %v", secretName, err)
+		}
+	}
+}
+
+// pod maps a ContainerGroupSpec onto the workspace Pod
+func (p *Provider) pod(name string, labels map[string]string, spec provider.ContainerGroupSpec, envSecret, pullSecret *corev1.Secret) *corev1.Pod {
+	var env []corev1.EnvVar
+	for _, envVar := range spec.EnvVars() {
+		if !envVar.Secure {
+			env = append(env, corev1.EnvVar{Name: envVar.Name, Value: envVar.Value})
+			continue
+		}
+		env = append(env, corev1.EnvVar{
+			Name: envVar.Name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: envSecret.Name},
+					Key:                  envVar.Name,
+				},
+			},
+		})
+	}
+
+	var ports []corev1.ContainerPort
+	for _, port := range namedPorts(spec) {
+		ports = append(ports, corev1.ContainerPort{Name: port.name, ContainerPort: port.port, Protocol: corev1.ProtocolTCP})
+	}
+
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewQuantity(int64(spec.CPUCores), resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(int64(spec.MemoryGB)*1024*1024*1024, resource.BinarySI),
+	}
+
+	containerName := spec.ContainerName
+	if containerName == "" {
+		containerName = "workspace"
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.opts.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PodSpec{
+			Hostname:      spec.DNSNameLabel,
+			RestartPolicy: corev1.RestartPolicyAlways,
+			Containers: []corev1.Container{
+				{
+					Name:  containerName,
+					Image: spec.Image,
+					Env:   env,
+					Ports: ports,
+					Resources: corev1.ResourceRequirements{
+						Requests: resources,
+						Limits:   resources,
+					},
+				},
+			},
+		},
+	}
+
+	if pullSecret != nil {
+		pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: pullSecret.Name}}
+	}
+
+	if spec.FileShareName != "" {
+		// Single volume: Home directory (/home/dev8) - stores everything
+		pod.Spec.Volumes = []corev1.Volume{
+			{
+				Name: "home",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: spec.FileShareName},
+				},
+			},
+		}
+		pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "home", MountPath: homeMountPath}}
+	}
+
+	return pod
+}
+
+// service exposes the IDE, SSH, supervisor and app ports of the workspace pod
+func (p *Provider) service(name string, labels map[string]string, spec provider.ContainerGroupSpec) *corev1.Service {
+	var ports []corev1.ServicePort
+	for _, port := range namedPorts(spec) {
+		ports = append(ports, corev1.ServicePort{
+			Name:       port.name,
+			Port:       port.port,
+			TargetPort: intstr.FromString(port.name),
+			Protocol:   corev1.ProtocolTCP,
+		})
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.opts.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     p.opts.ServiceType,
+			Selector: map[string]string{runtimeLabel: name},
+			Ports:    ports,
+		},
+	}
+}
+
+// podToRuntime converts a pod (and its service, if any) into a provider-neutral runtime
+func (p *Provider) podToRuntime(pod *corev1.Pod, service *corev1.Service, region string) provider.Runtime {
+	runtime := provider.Runtime{
+		Name:          pod.Name,
+		Region:        region,
+		ResourceGroup: pod.Namespace,
+		IPAddress:     pod.Status.PodIP,
+		State:         mapPodState(pod),
+		Tags:          make(map[string]string),
+		Ports:         make(map[int]int),
+	}
+
+	for key, value := range pod.Labels {
+		runtime.Tags[key] = value
+	}
+	runtime.EnvironmentID = runtime.Tags["environment"]
+	runtime.UserID = runtime.Tags["userId"]
+
+	if service == nil {
+		return runtime
+	}
+
+	switch service.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		// The FQDN stays empty until the load balancer has been provisioned
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ingress.Hostname != "" {
+				runtime.FQDN = ingress.Hostname
+			} else {
+				runtime.FQDN = ingress.IP
+			}
+			if ingress.IP != "" {
+				runtime.IPAddress = ingress.IP
+			}
+			break
+		}
+	case corev1.ServiceTypeNodePort:
+		runtime.FQDN = p.opts.PublicHost
+		for _, port := range service.Spec.Ports {
+			if port.NodePort != 0 {
+				runtime.Ports[int(port.Port)] = int(port.NodePort)
+			}
+		}
+	default:
+		runtime.FQDN = fmt.Sprintf("%s.%s.svc.cluster.local", service.Name, service.Namespace)
+	}
+
+	return runtime
+}
+
+// mapPodState maps a pod phase to a runtime state
+func mapPodState(pod *corev1.Pod) provider.RuntimeState {
+	if pod.DeletionTimestamp != nil {
+		return provider.StateStopped
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodPending:
+		return provider.StatePending
+	case corev1.PodRunning:
+		return provider.StateRunning
+	case corev1.PodSucceeded:
+		return provider.StateStopped
+	case corev1.PodFailed:
+		return provider.StateFailed
+	default:
+		return provider.StateUnknown
+	}
+}
@@ -0,0 +1,323 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestProvider(serviceType corev1.ServiceType) (*Provider, *fake.Clientset) {
+	client := fake.NewClientset()
+	p := NewProvider(client, Options{
+		Namespace:    "dev8-workspaces",
+		Regions:      []string{"eastus"},
+		StorageClass: "premium-rwo",
+		ServiceType:  serviceType,
+		PublicHost:   "nodes.dev8.test",
+	})
+	return p, client
+}
+
+func testSpec() provider.ContainerGroupSpec {
+	return provider.ContainerGroupSpec{
+		Image:              "vaibhavsing/dev8-workspace:latest",
+		CPUCores:           2,
+		MemoryGB:           4,
+		DNSNameLabel:       "ws-1",
+		FileShareName:      "fs-ws-1",
+		EnvironmentID:      "ws-1",
+		UserID:             "user-1",
+		CodeServerPassword: "secret",
+		GitUserName:        "dev8",
+		RegistryServer:     "registry.dev8.test",
+		RegistryUsername:   "robot",
+		RegistryPassword:   "token",
+	}
+}
+
+func TestProvider_Volumes(t *testing.T) {
+	p, client := newTestProvider(corev1.ServiceTypeClusterIP)
+	ctx := context.Background()
+
+	exists, err := p.VolumeExists(ctx, "eastus", "fs-ws-1")
+	if err != nil || exists {
+		t.Fatalf("VolumeExists() = %v, %v, want false", exists, err)
+	}
+
+	if err := p.CreateVolume(ctx, "eastus", "fs-ws-1", 25); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := p.CreateVolume(ctx, "eastus", "fs-ws-1", 25); err == nil {
+		t.Error("CreateVolume() should fail for an existing claim")
+	}
+
+	pvc, err := client.CoreV1().PersistentVolumeClaims("dev8-workspaces").Get(ctx, "fs-ws-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get PVC error = %v", err)
+	}
+	if got := pvc.Spec.Resources.Requests.Storage().String(); got != "25Gi" {
+		t.Errorf("storage request = %v, want 25Gi", got)
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != "premium-rwo" {
+		t.Errorf("StorageClassName = %v, want premium-rwo", pvc.Spec.StorageClassName)
+	}
+	if pvc.Labels["managed-by"] != "dev8-agent" || pvc.Labels[regionLabel] != "eastus" {
+		t.Errorf("Labels = %v, want managed-by and region labels", pvc.Labels)
+	}
+
+	exists, err = p.VolumeExists(ctx, "eastus", "fs-ws-1")
+	if err != nil || !exists {
+		t.Fatalf("VolumeExists() = %v, %v, want true", exists, err)
+	}
+
+	volumes, err := p.ListVolumes(ctx, "eastus")
+	if err != nil || len(volumes) != 1 || volumes[0].Name != "fs-ws-1" {
+		t.Errorf("ListVolumes(eastus) = %v, %v, want [fs-ws-1]", volumes, err)
+	}
+	if volumes, _ := p.ListVolumes(ctx, "westus"); len(volumes) != 0 {
+		t.Errorf("ListVolumes(westus) = %v, want none", volumes)
+	}
+
+	if err := p.DeleteVolume(ctx, "eastus", "fs-ws-1"); err != nil {
+		t.Fatalf("DeleteVolume() error = %v", err)
+	}
+	if exists, _ := p.VolumeExists(ctx, "eastus", "fs-ws-1"); exists {
+		t.Error("VolumeExists() after delete = true, want false")
+	}
+}
+
+func TestProvider_CreateRuntime(t *testing.T) {
+	p, client := newTestProvider(corev1.ServiceTypeClusterIP)
+	ctx := context.Background()
+
+	if err := p.CreateRuntime(ctx, "eastus", "aci-ws-1", testSpec()); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	pod, err := client.CoreV1().Pods("dev8-workspaces").Get(ctx, "aci-ws-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get pod error = %v", err)
+	}
+
+	for key, want := range map[string]string{"environment": "ws-1", "userId": "user-1", "managed-by": "dev8-agent", regionLabel: "eastus"} {
+		if pod.Labels[key] != want {
+			t.Errorf("pod label %s = %q, want %q", key, pod.Labels[key], want)
+		}
+	}
+
+	container := pod.Spec.Containers[0]
+	if got := container.Resources.Limits.Cpu().String(); got != "2" {
+		t.Errorf("CPU limit = %v, want 2", got)
+	}
+	if got := container.Resources.Limits.Memory().String(); got != "4Gi" {
+		t.Errorf("memory limit = %v, want 4Gi", got)
+	}
+	if len(container.Ports) != 3 {
+		t.Errorf("container ports = %v, want ide/ssh/supervisor", container.Ports)
+	}
+	if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].MountPath != "/home/dev8" {
+		t.Errorf("VolumeMounts = %v, want /home/dev8", container.VolumeMounts)
+	}
+	if claim := pod.Spec.Volumes[0].PersistentVolumeClaim; claim == nil || claim.ClaimName != "fs-ws-1" {
+		t.Errorf("volume = %+v, want PVC fs-ws-1", pod.Spec.Volumes[0])
+	}
+	if len(pod.Spec.ImagePullSecrets) != 1 || pod.Spec.ImagePullSecrets[0].Name != "aci-ws-1-registry" {
+		t.Errorf("ImagePullSecrets = %v, want aci-ws-1-registry", pod.Spec.ImagePullSecrets)
+	}
+
+	// Secure values must come from the secret, plain values stay inline
+	for _, env := range container.Env {
+		switch env.Name {
+		case "CODE_SERVER_PASSWORD":
+			if env.Value != "" || env.ValueFrom == nil || env.ValueFrom.SecretKeyRef.Name != "aci-ws-1-env" {
+				t.Errorf("CODE_SERVER_PASSWORD = %+v, want secret reference", env)
+			}
+		case "GIT_USER_NAME":
+			if env.Value != "dev8" {
+				t.Errorf("GIT_USER_NAME = %q, want dev8", env.Value)
+			}
+		}
+	}
+
+	secret, err := client.CoreV1().Secrets("dev8-workspaces").Get(ctx, "aci-ws-1-env", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get env secret error = %v", err)
+	}
+	if secret.StringData["CODE_SERVER_PASSWORD"] != "secret" {
+		t.Errorf("secret data = %v, want CODE_SERVER_PASSWORD", secret.StringData)
+	}
+	if _, ok := secret.StringData["GIT_USER_NAME"]; ok {
+		t.Error("non-secure variables should not be stored in the secret")
+	}
+
+	service, err := client.CoreV1().Services("dev8-workspaces").Get(ctx, "aci-ws-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get service error = %v", err)
+	}
+	if service.Spec.Selector[runtimeLabel] != "aci-ws-1" || len(service.Spec.Ports) != 3 {
+		t.Errorf("service spec = %+v, want selector on runtime and 3 ports", service.Spec)
+	}
+}
+
+func TestProvider_RuntimeLifecycle(t *testing.T) {
+	p, client := newTestProvider(corev1.ServiceTypeClusterIP)
+	ctx := context.Background()
+
+	if _, err := p.GetRuntime(ctx, "eastus", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Fatalf("GetRuntime() error = %v, want ErrRuntimeNotFound", err)
+	}
+
+	if err := p.CreateRuntime(ctx, "eastus", "aci-ws-1", testSpec()); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	// The fake clientset has no kubelet; simulate the pod being scheduled
+	pod, _ := client.CoreV1().Pods("dev8-workspaces").Get(ctx, "aci-ws-1", metav1.GetOptions{})
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.PodIP = "10.244.0.12"
+	if _, err := client.CoreV1().Pods("dev8-workspaces").UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+
+	runtime, err := p.GetRuntime(ctx, "eastus", "aci-ws-1")
+	if err != nil {
+		t.Fatalf("GetRuntime() error = %v", err)
+	}
+	if runtime.State != provider.StateRunning {
+		t.Errorf("State = %v, want %v", runtime.State, provider.StateRunning)
+	}
+	if runtime.FQDN != "aci-ws-1.dev8-workspaces.svc.cluster.local" {
+		t.Errorf("FQDN = %v, want cluster service DNS name", runtime.FQDN)
+	}
+	if runtime.IPAddress != "10.244.0.12" || runtime.ResourceGroup != "dev8-workspaces" {
+		t.Errorf("IPAddress/ResourceGroup = %v/%v", runtime.IPAddress, runtime.ResourceGroup)
+	}
+	if runtime.EnvironmentID != "ws-1" || runtime.UserID != "user-1" {
+		t.Errorf("EnvironmentID/UserID = %v/%v", runtime.EnvironmentID, runtime.UserID)
+	}
+
+	runtimes, err := p.ListRuntimes(ctx, "eastus")
+	if err != nil || len(runtimes) != 1 {
+		t.Fatalf("ListRuntimes() = %v, %v, want 1 runtime", runtimes, err)
+	}
+	if runtimes, _ := p.ListRuntimes(ctx, "westus"); len(runtimes) != 0 {
+		t.Errorf("ListRuntimes(westus) = %v, want none", runtimes)
+	}
+
+	if err := p.DeleteRuntime(ctx, "eastus", "aci-ws-1"); err != nil {
+		t.Fatalf("DeleteRuntime() error = %v", err)
+	}
+	if _, err := client.CoreV1().Services("dev8-workspaces").Get(ctx, "aci-ws-1", metav1.GetOptions{}); err == nil {
+		t.Error("service should be deleted with the runtime")
+	}
+	if secrets, _ := client.CoreV1().Secrets("dev8-workspaces").List(ctx, metav1.ListOptions{}); len(secrets.Items) != 0 {
+		t.Errorf("secrets left behind: %d", len(secrets.Items))
+	}
+	if err := p.DeleteRuntime(ctx, "eastus", "aci-ws-1"); !errors.Is(err, provider.ErrRuntimeNotFound) {
+		t.Errorf("DeleteRuntime() twice error = %v, want ErrRuntimeNotFound", err)
+	}
+
+	// Restarting recreates the runtime against the kept volume
+	if err := p.CreateRuntime(ctx, "eastus", "aci-ws-1", testSpec()); err != nil {
+		t.Fatalf("CreateRuntime() restart error = %v", err)
+	}
+}
+
+func TestProvider_ServiceTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceType corev1.ServiceType
+		status      corev1.ServiceStatus
+		nodePorts   map[string]int32
+		wantFQDN    string
+		wantIDEPort int
+	}{
+		{
+			name:        "load balancer hostname",
+			serviceType: corev1.ServiceTypeLoadBalancer,
+			status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{Hostname: "ws-1.elb.example.com"}},
+			}},
+			wantFQDN:    "ws-1.elb.example.com",
+			wantIDEPort: 8080,
+		},
+		{
+			name:        "load balancer pending",
+			serviceType: corev1.ServiceTypeLoadBalancer,
+			wantFQDN:    "",
+			wantIDEPort: 8080,
+		},
+		{
+			name:        "node port",
+			serviceType: corev1.ServiceTypeNodePort,
+			nodePorts:   map[string]int32{"ide": 30080, "ssh": 30022},
+			wantFQDN:    "nodes.dev8.test",
+			wantIDEPort: 30080,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, client := newTestProvider(tt.serviceType)
+			ctx := context.Background()
+
+			if err := p.CreateRuntime(ctx, "eastus", "aci-ws-1", testSpec()); err != nil {
+				t.Fatalf("CreateRuntime() error = %v", err)
+			}
+
+			// The fake clientset does not allocate node ports or load balancers
+			services := client.CoreV1().Services("dev8-workspaces")
+			service, _ := services.Get(ctx, "aci-ws-1", metav1.GetOptions{})
+			for i, port := range service.Spec.Ports {
+				service.Spec.Ports[i].NodePort = tt.nodePorts[port.Name]
+			}
+			service.Status = tt.status
+			if _, err := services.Update(ctx, service, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("Update service error = %v", err)
+			}
+			if _, err := services.UpdateStatus(ctx, service, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("UpdateStatus service error = %v", err)
+			}
+
+			runtime, err := p.GetRuntime(ctx, "eastus", "aci-ws-1")
+			if err != nil {
+				t.Fatalf("GetRuntime() error = %v", err)
+			}
+			if runtime.FQDN != tt.wantFQDN {
+				t.Errorf("FQDN = %q, want %q", runtime.FQDN, tt.wantFQDN)
+			}
+			if got := runtime.PublicPort(8080); got != tt.wantIDEPort {
+				t.Errorf("PublicPort(8080) = %v, want %v", got, tt.wantIDEPort)
+			}
+		})
+	}
+}
+
+func TestMapPodState(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name string
+		pod  corev1.Pod
+		want provider.RuntimeState
+	}{
+		{"pending", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}}, provider.StatePending},
+		{"running", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}}, provider.StateRunning},
+		{"succeeded", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}, provider.StateStopped},
+		{"failed", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed}}, provider.StateFailed},
+		{"terminating", corev1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}, Status: corev1.PodStatus{Phase: corev1.PodRunning}}, provider.StateStopped},
+		{"unknown", corev1.Pod{}, provider.StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapPodState(&tt.pod); got != tt.want {
+				t.Errorf("mapPodState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
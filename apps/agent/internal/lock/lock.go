@@ -0,0 +1,210 @@
+// Package lock serialises lifecycle operations on a workspace. The local
+// locker guards a single agent; the lease locker shares the locks between
+// agent replicas through expiring leases (Azure Blob leases in production).
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrLeaseHeld is returned by LeaseClient.Acquire while another holder has the lease
+var ErrLeaseHeld = errors.New("lease is held")
+
+// ErrLockLost is the cancellation cause of a Handle's contexts once its lock is lost
+var ErrLockLost = errors.New("workspace lock lost")
+
+// Locker grants one lifecycle operation at a time exclusive use of a workspace
+type Locker interface {
+	// TryLock locks the workspace for the operation without waiting. It fails
+	// with a *LockedError (match it with errors.As) while another operation
+	// holds the lock; call Unlock on the returned handle once the operation
+	// has finished.
+	TryLock(ctx context.Context, workspaceID, operation string) (*Handle, error)
+}
+
+// Handle is a held workspace lock. Run the operation under Context so it
+// stops if the lock is lost, e.g. when its lease could not be renewed and
+// another agent replica may take the workspace over.
+type Handle struct {
+	lost   context.Context
+	lose   context.CancelCauseFunc
+	unlock func()
+	once   sync.Once
+}
+
+func newHandle(unlock func()) *Handle {
+	lost, lose := context.WithCancelCause(context.Background())
+	return &Handle{lost: lost, lose: lose, unlock: unlock}
+}
+
+// Context returns a copy of parent that is also cancelled, with the cause
+// ErrLockLost, once the lock is lost. Call cancel when the operation is done.
+func (h *Handle) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	stop := context.AfterFunc(h.lost, func() { cancel(context.Cause(h.lost)) })
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// Lost is closed once the lock is lost
+func (h *Handle) Lost() <-chan struct{} {
+	return h.lost.Done()
+}
+
+// Unlock releases the lock; unlocking twice is harmless
+func (h *Handle) Unlock() {
+	h.once.Do(h.unlock)
+}
+
+// LockedError reports that another operation holds the workspace lock
+type LockedError struct {
+	WorkspaceID string
+	Operation   string // Operation holding the lock, if known
+}
+
+func (e *LockedError) Error() string {
+	if e.Operation != "" {
+		return fmt.Sprintf("workspace %s is busy: a %s operation is in progress", e.WorkspaceID, e.Operation)
+	}
+	return fmt.Sprintf("workspace %s is busy: another operation is in progress", e.WorkspaceID)
+}
+
+// LocalLocker locks workspaces within this agent process
+type LocalLocker struct {
+	mu     sync.Mutex
+	holder map[string]string // Workspace ID -> operation holding the lock
+}
+
+// NewLocalLocker creates an in-process locker
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{holder: make(map[string]string)}
+}
+
+// TryLock implements Locker. A local lock is never lost.
+func (l *LocalLocker) TryLock(ctx context.Context, workspaceID, operation string) (*Handle, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if holder, ok := l.holder[workspaceID]; ok {
+		return nil, &LockedError{WorkspaceID: workspaceID, Operation: holder}
+	}
+	l.holder[workspaceID] = operation
+
+	return newHandle(func() {
+		l.mu.Lock()
+		delete(l.holder, workspaceID)
+		l.mu.Unlock()
+	}), nil
+}
+
+// LeaseClient acquires exclusive, expiring leases on named objects
+type LeaseClient interface {
+	// Acquire takes the lease for duration and returns its ID, or ErrLeaseHeld
+	Acquire(ctx context.Context, name string, duration time.Duration) (string, error)
+	// Renew extends a lease the caller holds by its original duration
+	Renew(ctx context.Context, name, leaseID string) error
+	// Release gives up a lease the caller holds
+	Release(ctx context.Context, name, leaseID string) error
+}
+
+// LeaseLocker locks workspaces across agent replicas with expiring leases.
+// Held locks are renewed in the background; a crashed replica's locks expire
+// after the lease duration. A lock whose lease cannot be renewed before it
+// expires is lost. Within the process a local locker runs first so
+// the holder's operation is reported and the lease store is only asked once.
+type LeaseLocker struct {
+	client   LeaseClient
+	duration time.Duration
+	local    *LocalLocker
+}
+
+// NewLeaseLocker creates a locker backed by leases of the given duration
+func NewLeaseLocker(client LeaseClient, duration time.Duration) *LeaseLocker {
+	return &LeaseLocker{
+		client:   client,
+		duration: duration,
+		local:    NewLocalLocker(),
+	}
+}
+
+// TryLock implements Locker
+func (l *LeaseLocker) TryLock(ctx context.Context, workspaceID, operation string) (*Handle, error) {
+	local, err := l.local.TryLock(ctx, workspaceID, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	name := leaseName(workspaceID)
+	leaseID, err := l.client.Acquire(ctx, name, l.duration)
+	if err != nil {
+		local.Unlock()
+		if errors.Is(err, ErrLeaseHeld) {
+			return nil, &LockedError{WorkspaceID: workspaceID}
+		}
+		return nil, fmt.Errorf("failed to lock workspace %s: %w", workspaceID, err)
+	}
+
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	handle := newHandle(func() {
+		close(stop)
+		<-renewed
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := l.client.Release(ctx, name, leaseID); err != nil {
+			// The lease expires on its own
+			log.Printf("Warning: failed to release lock for workspace %s: %v", workspaceID, err)
+		}
+		local.Unlock()
+	})
+	go l.renew(handle, name, leaseID, stop, renewed)
+	return handle, nil
+}
+
+// renew keeps the lease alive until stop is closed. Failed renewals are
+// retried while the lease lasts; once the next attempt would come too late,
+// the handle is marked lost so its operation stops before another replica
+// can acquire the lease.
+func (l *LeaseLocker) renew(handle *Handle, name, leaseID string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	interval := l.duration / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := l.client.Renew(ctx, name, leaseID)
+			cancel()
+			if err == nil {
+				renewedAt = time.Now()
+				continue
+			}
+
+			if time.Since(renewedAt)+interval >= l.duration {
+				log.Printf("Warning: lost lock %s: %v", name, err)
+				handle.lose(fmt.Errorf("%w: failed to renew lease %s: %v", ErrLockLost, name, err))
+				return
+			}
+			log.Printf("Warning: failed to renew lock %s: %v", name, err)
+		}
+	}
+}
+
+// leaseName is the name of the object leased for a workspace
+func leaseName(workspaceID string) string {
+	return "workspace-" + workspaceID
+}
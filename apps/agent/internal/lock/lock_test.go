@@ -0,0 +1,128 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocalLocker(t *testing.T) {
+	locker := NewLocalLocker()
+	ctx := context.Background()
+
+	held, err := locker.TryLock(ctx, "ws-1", "stop")
+	if err != nil {
+		t.Fatalf("TryLock() error = %v", err)
+	}
+
+	_, err = locker.TryLock(ctx, "ws-1", "start")
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Operation != "stop" {
+		t.Errorf("TryLock(locked) error = %v, want LockedError held by stop", err)
+	}
+
+	if _, err := locker.TryLock(ctx, "ws-2", "start"); err != nil {
+		t.Errorf("TryLock(other workspace) error = %v", err)
+	}
+
+	held.Unlock()
+	held.Unlock() // Unlocking twice is harmless
+	if _, err := locker.TryLock(ctx, "ws-1", "start"); err != nil {
+		t.Errorf("TryLock(after unlock) error = %v", err)
+	}
+}
+
+func TestLeaseLocker_Replicas(t *testing.T) {
+	leases := NewMemoryLeases()
+	replicaA := NewLeaseLocker(leases, 30*time.Millisecond)
+	replicaB := NewLeaseLocker(leases, 30*time.Millisecond)
+	ctx := context.Background()
+
+	held, err := replicaA.TryLock(ctx, "ws-1", "stop")
+	if err != nil {
+		t.Fatalf("TryLock() error = %v", err)
+	}
+
+	// Renewal keeps the lease held well past its duration
+	time.Sleep(100 * time.Millisecond)
+	var locked *LockedError
+	if _, err := replicaB.TryLock(ctx, "ws-1", "start"); !errors.As(err, &locked) {
+		t.Errorf("TryLock(other replica) error = %v, want LockedError", err)
+	}
+
+	select {
+	case <-held.Lost():
+		t.Error("renewed lock reported lost")
+	default:
+	}
+
+	held.Unlock()
+	heldB, err := replicaB.TryLock(ctx, "ws-1", "start")
+	if err != nil {
+		t.Fatalf("TryLock(after release) error = %v", err)
+	}
+	heldB.Unlock()
+}
+
+// flakyLeases fails every renewal while failing is set
+type flakyLeases struct {
+	*MemoryLeases
+	failing atomic.Bool
+}
+
+func (f *flakyLeases) Renew(ctx context.Context, name, leaseID string) error {
+	if f.failing.Load() {
+		return errors.New("storage unavailable")
+	}
+	return f.MemoryLeases.Renew(ctx, name, leaseID)
+}
+
+func TestLeaseLocker_LostLease(t *testing.T) {
+	leases := &flakyLeases{MemoryLeases: NewMemoryLeases()}
+	locker := NewLeaseLocker(leases, 60*time.Millisecond)
+
+	held, err := locker.TryLock(context.Background(), "ws-1", "start")
+	if err != nil {
+		t.Fatalf("TryLock() error = %v", err)
+	}
+	defer held.Unlock()
+	ctx, cancel := held.Context(context.Background())
+	defer cancel()
+
+	leases.failing.Store(true)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("operation context not cancelled after the lease could not be renewed")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrLockLost) {
+		t.Errorf("context.Cause() = %v, want %v", cause, ErrLockLost)
+	}
+	select {
+	case <-held.Lost():
+	default:
+		t.Error("Lost() not closed")
+	}
+}
+
+func TestMemoryLeases_Expire(t *testing.T) {
+	leases := NewMemoryLeases()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	leases.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// A replica that crashed never releases its lease
+	if _, err := leases.Acquire(ctx, "workspace-ws-1", 30*time.Second); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := leases.Acquire(ctx, "workspace-ws-1", 30*time.Second); !errors.Is(err, ErrLeaseHeld) {
+		t.Errorf("Acquire(held) error = %v, want ErrLeaseHeld", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := leases.Acquire(ctx, "workspace-ws-1", 30*time.Second); err != nil {
+		t.Errorf("Acquire(expired) error = %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryLeases is an in-memory LeaseClient with the semantics of Azure Blob
+// leases. Lease lockers sharing one MemoryLeases behave like agent replicas
+// sharing a storage account, which makes it the local stand-in for tests.
+type MemoryLeases struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	id       string
+	duration time.Duration
+	expires  time.Time
+}
+
+// NewMemoryLeases creates an empty in-memory lease store
+func NewMemoryLeases() *MemoryLeases {
+	return &MemoryLeases{
+		now:    time.Now,
+		leases: make(map[string]memoryLease),
+	}
+}
+
+// Acquire implements LeaseClient
+func (m *MemoryLeases) Acquire(ctx context.Context, name string, duration time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if lease, ok := m.leases[name]; ok && now.Before(lease.expires) {
+		return "", ErrLeaseHeld
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lease ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	m.leases[name] = memoryLease{id: id, duration: duration, expires: now.Add(duration)}
+	return id, nil
+}
+
+// Renew implements LeaseClient
+func (m *MemoryLeases) Renew(ctx context.Context, name, leaseID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lease, ok := m.leases[name]
+	if !ok || lease.id != leaseID {
+		return fmt.Errorf("lease %s on %s is not held", leaseID, name)
+	}
+	// Like Azure, an expired lease can be renewed until someone else acquires it
+	lease.expires = m.now().Add(lease.duration)
+	m.leases[name] = lease
+	return nil
+}
+
+// Release implements LeaseClient
+func (m *MemoryLeases) Release(ctx context.Context, name, leaseID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lease, ok := m.leases[name]
+	if !ok || lease.id != leaseID {
+		return fmt.Errorf("lease %s on %s is not held", leaseID, name)
+	}
+	delete(m.leases, name)
+	return nil
+}
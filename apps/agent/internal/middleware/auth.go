@@ -0,0 +1,306 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Authentication methods recorded on the principal
+const (
+	AuthMethodAPIKey = "api-key"
+	AuthMethodJWT    = "jwt"
+	AuthMethodNone   = "none" // Authentication is disabled
+)
+
+// ServicePrincipal is the subject of callers authenticated with a shared API key
+const ServicePrincipal = "service"
+
+// AnonymousPrincipal is the subject of every caller when authentication is disabled
+const AnonymousPrincipal = "anonymous"
+
+// Principal is the authenticated caller of an API request
+type Principal struct {
+	Subject string // User ID for JWTs, ServicePrincipal for API keys
+	Scopes  []string
+	Admin   bool // May act on behalf of any user
+	Method  string
+}
+
+// HasScope reports whether the principal was granted the scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal, if any. Requests
+// that did not pass AuthMiddleware or DisabledAuthMiddleware carry none and
+// must be denied.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// AuthOptions configures the accepted credentials
+type AuthOptions struct {
+	APIKeys    []string // Shared service keys; callers get the admin scope
+	JWTSecret  string   // HS256 signing secret
+	JWKSFile   string   // JSON Web Key Set with the RS256 public keys
+	Issuer     string   // Required "iss" claim, if set
+	Audience   string   // Required "aud" claim, if set
+	AdminScope string   // Scope that allows acting on behalf of any user
+}
+
+// Authenticator verifies API keys and JWTs
+type Authenticator struct {
+	apiKeys    [][]byte
+	hmacSecret []byte
+	rsaKeys    map[string]*rsa.PublicKey // By key ID
+	parser     *jwt.Parser
+	adminScope string
+}
+
+// NewAuthenticator creates an authenticator, loading the JWKS file if configured
+func NewAuthenticator(opts AuthOptions) (*Authenticator, error) {
+	a := &Authenticator{
+		rsaKeys:    make(map[string]*rsa.PublicKey),
+		adminScope: opts.AdminScope,
+	}
+	for _, key := range opts.APIKeys {
+		a.apiKeys = append(a.apiKeys, []byte(key))
+	}
+	if opts.JWTSecret != "" {
+		a.hmacSecret = []byte(opts.JWTSecret)
+	}
+	if opts.JWKSFile != "" {
+		keys, err := loadJWKS(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.rsaKeys = keys
+	}
+
+	var methods []string
+	if a.hmacSecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(a.rsaKeys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	parserOpts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	a.parser = jwt.NewParser(parserOpts...)
+
+	return a, nil
+}
+
+// Authenticate returns the principal for the request's credentials.
+// Credentials are read from "Authorization: Bearer <key-or-jwt>" or "X-API-Key".
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil, errors.New("missing bearer token or API key")
+		}
+		credential = strings.TrimSpace(token)
+	}
+
+	for _, key := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(credential), key) == 1 {
+			return &Principal{
+				Subject: ServicePrincipal,
+				Scopes:  []string{a.adminScope},
+				Admin:   true,
+				Method:  AuthMethodAPIKey,
+			}, nil
+		}
+	}
+
+	// Anything that is not a known key must be a JWT
+	if strings.Count(credential, ".") != 2 || (a.hmacSecret == nil && len(a.rsaKeys) == 0) {
+		return nil, errors.New("invalid API key")
+	}
+	return a.authenticateJWT(credential)
+}
+
+// authenticateJWT verifies a token's signature and claims
+func (a *Authenticator) authenticateJWT(tokenString string) (*Principal, error) {
+	claims := &tokenClaims{}
+	_, err := a.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			if a.hmacSecret == nil {
+				return nil, errors.New("HS256 tokens are not accepted")
+			}
+			return a.hmacSecret, nil
+		case jwt.SigningMethodRS256.Alg():
+			kid, _ := token.Header["kid"].(string)
+			if key, ok := a.rsaKeys[kid]; ok {
+				return key, nil
+			}
+			// A single key set does not need key IDs
+			if kid == "" && len(a.rsaKeys) == 1 {
+				for _, key := range a.rsaKeys {
+					return key, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid token: missing subject")
+	}
+
+	principal := &Principal{
+		Subject: claims.Subject,
+		Scopes:  claims.scopes(),
+		Method:  AuthMethodJWT,
+	}
+	principal.Admin = a.adminScope != "" && principal.HasScope(a.adminScope)
+	return principal, nil
+}
+
+// tokenClaims accepts both the OAuth "scope" string and "scp"/"scopes" arrays
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope  string   `json:"scope,omitempty"`
+	Scp    []string `json:"scp,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+func (c *tokenClaims) scopes() []string {
+	scopes := strings.Fields(c.Scope)
+	scopes = append(scopes, c.Scp...)
+	return append(scopes, c.Scopes...)
+}
+
+// AuthMiddleware rejects requests without valid credentials and stores the
+// authenticated principal in the request context
+func AuthMiddleware(authenticator *Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// CORS preflights carry no credentials
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				log.Printf("🔒 Rejected %s %s: %v", r.Method, r.URL.Path, err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="dev8-agent"`)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(models.ErrorResponse{
+					Success: false,
+					Error:   "Unauthorized",
+					Message: "Valid API key or bearer token required",
+					Code:    fmt.Sprintf("ERR_%d", http.StatusUnauthorized),
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// DisabledAuthMiddleware trusts every request as an anonymous admin. Use it
+// instead of AuthMiddleware when no credentials are configured, so handlers
+// can tell a deliberately open API from a route that skipped authentication.
+func DisabledAuthMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := &Principal{
+				Subject: AnonymousPrincipal,
+				Admin:   true,
+				Method:  AuthMethodNone,
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// jsonWebKey is the subset of RFC 7517 needed for RSA signature keys
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// loadJWKS reads the RSA signing keys of a JSON Web Key Set file
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %q: %w", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %q: %w", jwk.Kid, err)
+		}
+
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s contains no RSA signing keys", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return token
+}
+
+// writeJWKS writes a JWKS file with the public half of key under kid
+func writeJWKS(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	set := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	auth, err := NewAuthenticator(AuthOptions{
+		APIKeys:    []string{"service-key"},
+		JWTSecret:  testSecret,
+		JWKSFile:   writeJWKS(t, "key-1", rsaKey),
+		Issuer:     "dev8",
+		AdminScope: "dev8:admin",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	valid := jwt.MapClaims{"sub": "user-1", "iss": "dev8", "exp": time.Now().Add(time.Hour).Unix()}
+	admin := jwt.MapClaims{"sub": "user-2", "iss": "dev8", "exp": time.Now().Add(time.Hour).Unix(), "scope": "read dev8:admin"}
+	expired := jwt.MapClaims{"sub": "user-1", "iss": "dev8", "exp": time.Now().Add(-time.Hour).Unix()}
+	noExpiry := jwt.MapClaims{"sub": "user-1", "iss": "dev8"}
+	wrongIssuer := jwt.MapClaims{"sub": "user-1", "iss": "other", "exp": time.Now().Add(time.Hour).Unix()}
+
+	rsaToken := jwt.NewWithClaims(jwt.SigningMethodRS256, valid)
+	rsaToken.Header["kid"] = "key-1"
+	signedRSA, err := rsaToken.SignedString(rsaKey)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		wantSubject string
+		wantAdmin   bool
+		wantErr     bool
+	}{
+		{name: "API key header", header: "X-API-Key", value: "service-key", wantSubject: ServicePrincipal, wantAdmin: true},
+		{name: "API key bearer", header: "Authorization", value: "Bearer service-key", wantSubject: ServicePrincipal, wantAdmin: true},
+		{name: "wrong API key", header: "X-API-Key", value: "other-key", wantErr: true},
+		{name: "HS256 token", header: "Authorization", value: "Bearer " + signHS256(t, testSecret, valid), wantSubject: "user-1"},
+		{name: "HS256 admin token", header: "Authorization", value: "Bearer " + signHS256(t, testSecret, admin), wantSubject: "user-2", wantAdmin: true},
+		{name: "RS256 token", header: "Authorization", value: "Bearer " + signedRSA, wantSubject: "user-1"},
+		{name: "wrong secret", header: "Authorization", value: "Bearer " + signHS256(t, "other-secret", valid), wantErr: true},
+		{name: "expired token", header: "Authorization", value: "Bearer " + signHS256(t, testSecret, expired), wantErr: true},
+		{name: "token without expiry", header: "Authorization", value: "Bearer " + signHS256(t, testSecret, noExpiry), wantErr: true},
+		{name: "wrong issuer", header: "Authorization", value: "Bearer " + signHS256(t, testSecret, wrongIssuer), wantErr: true},
+		{name: "basic auth", header: "Authorization", value: "Basic dXNlcjpwYXNz", wantErr: true},
+		{name: "no credentials", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/environments", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			principal, err := auth.Authenticate(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if principal.Subject != tt.wantSubject || principal.Admin != tt.wantAdmin {
+				t.Errorf("Authenticate() = %+v, want subject %v admin %v", principal, tt.wantSubject, tt.wantAdmin)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_RejectsTokensWithoutVerificationKeys(t *testing.T) {
+	auth, err := NewAuthenticator(AuthOptions{APIKeys: []string{"service-key"}})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	// Signed with an empty secret: must not verify when no secret is configured
+	token := signHS256(t, "", jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if principal, err := auth.Authenticate(req); err == nil {
+		t.Errorf("Authenticate() = %+v, want error", principal)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	auth, err := NewAuthenticator(AuthOptions{APIKeys: []string{"service-key"}})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	var got *Principal
+	handler := AuthMiddleware(auth)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name          string
+		method        string
+		apiKey        string
+		wantCode      int
+		wantPrincipal bool
+	}{
+		{name: "valid key", method: "GET", apiKey: "service-key", wantCode: http.StatusOK, wantPrincipal: true},
+		{name: "invalid key", method: "GET", apiKey: "wrong", wantCode: http.StatusUnauthorized},
+		{name: "missing key", method: "POST", wantCode: http.StatusUnauthorized},
+		{name: "preflight", method: "OPTIONS", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(tt.method, "/api/v1/environments", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("AuthMiddleware() status = %v, want %v", w.Code, tt.wantCode)
+			}
+			if (got != nil) != tt.wantPrincipal {
+				t.Errorf("AuthMiddleware() principal = %+v, want present %v", got, tt.wantPrincipal)
+			}
+			if tt.wantCode == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("AuthMiddleware() missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestDisabledAuthMiddleware(t *testing.T) {
+	var got *Principal
+	handler := DisabledAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/environments", nil))
+	if got == nil || !got.Admin || got.Subject != AnonymousPrincipal || got.Method != AuthMethodNone {
+		t.Errorf("DisabledAuthMiddleware() principal = %+v, want anonymous admin", got)
+	}
+}
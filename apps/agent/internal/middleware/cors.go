@@ -29,7 +29,7 @@ func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 
 			// Set other CORS headers
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, Idempotency-Key")
 			w.Header().Set("Access-Control-Max-Age", "3600")
 
 			// Handle preflight requests
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -70,6 +71,13 @@ func TestCORSMiddleware(t *testing.T) {
 			if tt.method == "OPTIONS" && w.Code != http.StatusOK {
 				t.Errorf("Preflight request status = %v, want %v", w.Code, http.StatusOK)
 			}
+
+			allowHeaders := w.Header().Get("Access-Control-Allow-Headers")
+			for _, header := range []string{"Authorization", "X-API-Key", "Idempotency-Key"} {
+				if !strings.Contains(allowHeaders, header) {
+					t.Errorf("Access-Control-Allow-Headers = %q, want it to contain %s", allowHeaders, header)
+				}
+			}
 		})
 	}
 }
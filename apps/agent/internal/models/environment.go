@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // EnvironmentStatus represents the current status of an environment
 type EnvironmentStatus string
@@ -27,19 +31,28 @@ const (
 // ConnectionURLs contains all connection endpoints for the workspace
 type ConnectionURLs struct {
 	SSHURL             string `json:"sshUrl"`             // ssh://user@ws-{uuid}.region.azurecontainer.io:2222
-	VSCodeWebURL       string `json:"vscodeWebUrl"`       // https://ws-{uuid}.region.azurecontainer.io:8080
+	VSCodeWebURL       string `json:"vscodeWebUrl"`       // http://ws-{uuid}.region.azurecontainer.io:8080
 	VSCodeDesktopURL   string `json:"vscodeDesktopUrl"`   // vscode-remote://ssh-remote+user@ws-{uuid}...:2222/home/dev8/workspace
 	SupervisorURL      string `json:"supervisorUrl"`      // http://ws-{uuid}.region.azurecontainer.io:9000
 	CodeServerPassword string `json:"codeServerPassword"` // Generated password for VS Code auth
+
+	AppURLs  map[int]string     `json:"appUrls,omitempty"`  // App port -> http://ws-{uuid}.region.azurecontainer.io:{port}
+	Previews map[int]PreviewURL `json:"previews,omitempty"` // App port -> preview URL, when PREVIEW_DOMAIN is set
+
+	GatewayURL string `json:"gatewayUrl,omitempty"` // https://{uuid}.{GATEWAY_DOMAIN}, the IDE behind the authenticated gateway
 }
 
 // Environment represents a cloud development environment
 type Environment struct {
 	ID     string            `json:"id"` // Same as WorkspaceID (UUID from DB)
 	UserID string            `json:"userId"`
+	OrgID  string            `json:"orgId,omitempty"` // Organisation charged for quotas, if any
 	Name   string            `json:"name"`
 	Status EnvironmentStatus `json:"status"`
 
+	// Why the workspace is STARTING (not yet serving traffic) or in ERROR
+	StatusReason string `json:"statusReason,omitempty"`
+
 	// Cloud Configuration
 	CloudProvider CloudProvider `json:"cloudProvider"`
 	CloudRegion   string        `json:"cloudRegion"`
@@ -50,21 +63,35 @@ type Environment struct {
 	StorageGB int    `json:"storageGB"`
 	BaseImage string `json:"baseImage"`
 
+	// App ports exposed besides the IDE, SSH and supervisor
+	Ports       []int `json:"ports,omitempty"`
+	PublicPorts []int `json:"publicPorts,omitempty"` // Ports with a public preview
+
 	// Azure Resource Identifiers (all based on UUID)
 	AzureResourceGroup  string `json:"azureResourceGroup"`  // e.g., "dev8-eastus-rg"
 	AzureContainerGroup string `json:"azureContainerGroup"` // e.g., "aci-clxxx-yyyy-zzzz"
 	AzureFileShare      string `json:"azureFileShare"`      // e.g., "fs-clxxx-yyyy-zzzz" (unified volume for home + workspace)
 	AzureFQDN           string `json:"azureFqdn"`           // e.g., "ws-clxxx-yyyy-zzzz.eastus.azurecontainer.io"
 
+	// host:port of the workspace's own SSH server, where the SSH bastion forwards to
+	SSHAddress string `json:"sshAddress,omitempty"`
+
 	// Connection Information (all contain UUID)
 	ConnectionURLs ConnectionURLs `json:"connectionUrls"`
 
+	// Idle policy
+	IdleTimeoutMinutes *int       `json:"idleTimeoutMinutes,omitempty"` // Per-workspace override; nil uses the user/global timeout
+	StopReason         StopReason `json:"stopReason,omitempty"`         // Why the workspace was last stopped
+
 	// Timestamps
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedAt"`
 	LastAccessedAt time.Time `json:"lastAccessedAt,omitempty"`
 }
 
+// RegionAuto asks the agent to pick the region of a new workspace
+const RegionAuto = "auto"
+
 // CreateEnvironmentRequest represents a request to create a new environment
 type CreateEnvironmentRequest struct {
 	// CRITICAL: WorkspaceID is the UUID from Next.js database (Prisma cuid)
@@ -72,14 +99,24 @@ type CreateEnvironmentRequest struct {
 	WorkspaceID string `json:"workspaceId"` // e.g., "clxxx-yyyy-zzzz"
 
 	UserID        string        `json:"userId"`
+	OrgID         string        `json:"orgId,omitempty"` // Optional organisation for quotas
 	Name          string        `json:"name"`
 	CloudProvider CloudProvider `json:"cloudProvider"`
-	CloudRegion   string        `json:"cloudRegion"`
+	CloudRegion   string        `json:"cloudRegion"` // A configured region, or "auto"/omitted to let the agent pick
 	CPUCores      int           `json:"cpuCores"`
 	MemoryGB      int           `json:"memoryGB"`
 	StorageGB     int           `json:"storageGB"`
 	BaseImage     string        `json:"baseImage"`
 
+	// App ports to expose besides the IDE, SSH and supervisor; the preview of
+	// a public port can be opened by anyone, the others only by the owner
+	Ports       []int `json:"ports,omitempty"`
+	PublicPorts []int `json:"publicPorts,omitempty"`
+
+	// Where the client is (a region, location or part of one, e.g. "europe");
+	// automatic placement prefers matching regions
+	LocationHint string `json:"locationHint,omitempty"`
+
 	// Optional per-workspace dynamic values
 	GitHubToken        string `json:"githubToken,omitempty"`
 	CodeServerPassword string `json:"codeServerPassword,omitempty"`
@@ -89,21 +126,30 @@ type CreateEnvironmentRequest struct {
 	AnthropicAPIKey    string `json:"anthropicApiKey,omitempty"`
 	OpenAIAPIKey       string `json:"openaiApiKey,omitempty"`
 	GeminiAPIKey       string `json:"geminiApiKey,omitempty"`
+
+	// Optional idle timeout override in minutes (0 = never stop for inactivity)
+	IdleTimeoutMinutes *int `json:"idleTimeoutMinutes,omitempty"`
 }
 
 // StartEnvironmentRequest represents a request to start a stopped environment
 type StartEnvironmentRequest struct {
-	WorkspaceID string `json:"workspaceId"`
-	CloudRegion string `json:"cloudRegion"`
+	WorkspaceID   string        `json:"workspaceId"`
+	CloudProvider CloudProvider `json:"cloudProvider,omitempty"` // Defaults to the agent's default provider
+	CloudRegion   string        `json:"cloudRegion"`
 
 	// Required for container recreation
 	UserID    string `json:"userId"`
+	OrgID     string `json:"orgId,omitempty"` // Defaults to the recorded organisation
 	Name      string `json:"name"`
 	CPUCores  int    `json:"cpuCores"`
 	MemoryGB  int    `json:"memoryGB"`
 	StorageGB int    `json:"storageGB"`
 	BaseImage string `json:"baseImage"`
 
+	// App ports to expose; both default to the recorded ports when ports is omitted
+	Ports       []int `json:"ports,omitempty"`
+	PublicPorts []int `json:"publicPorts,omitempty"`
+
 	// Optional per-workspace secrets
 	GitHubToken        string `json:"githubToken,omitempty"`
 	CodeServerPassword string `json:"codeServerPassword,omitempty"`
@@ -113,12 +159,17 @@ type StartEnvironmentRequest struct {
 	AnthropicAPIKey    string `json:"anthropicApiKey,omitempty"`
 	OpenAIAPIKey       string `json:"openaiApiKey,omitempty"`
 	GeminiAPIKey       string `json:"geminiApiKey,omitempty"`
+
+	// Optional idle timeout override in minutes (0 = never stop for inactivity)
+	IdleTimeoutMinutes *int `json:"idleTimeoutMinutes,omitempty"`
 }
 
 // StopEnvironmentRequest represents a request to stop an environment
 type StopEnvironmentRequest struct {
-	WorkspaceID string `json:"workspaceId"`
-	CloudRegion string `json:"cloudRegion"`
+	WorkspaceID   string        `json:"workspaceId"`
+	CloudProvider CloudProvider `json:"cloudProvider,omitempty"`
+	CloudRegion   string        `json:"cloudRegion"`
+	Reason        StopReason    `json:"reason,omitempty"` // Defaults to REQUESTED
 }
 
 // GetEnvironmentStatusRequest represents a request to check environment status
@@ -140,9 +191,10 @@ func (r *GetEnvironmentStatusRequest) Validate() error {
 
 // DeleteEnvironmentRequest represents a request to delete an environment
 type DeleteEnvironmentRequest struct {
-	WorkspaceID string `json:"workspaceId"`
-	CloudRegion string `json:"cloudRegion"`
-	Force       bool   `json:"force,omitempty"` // Force delete even if running
+	WorkspaceID   string        `json:"workspaceId"`
+	CloudProvider CloudProvider `json:"cloudProvider,omitempty"`
+	CloudRegion   string        `json:"cloudRegion"`
+	Force         bool          `json:"force,omitempty"` // Force delete even if running
 }
 
 // UpdateEnvironmentRequest represents a request to update an environment
@@ -158,6 +210,48 @@ type EnvironmentResponse struct {
 	Error       string       `json:"error,omitempty"`
 }
 
+// ListEnvironmentsRequest holds the query parameters for listing environments
+type ListEnvironmentsRequest struct {
+	UserID      string
+	Status      EnvironmentStatus
+	CloudRegion string
+	Page        int
+	PageSize    int
+}
+
+// Paging defaults for ListEnvironments
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Normalize applies paging defaults and validates the filters
+func (r *ListEnvironmentsRequest) Normalize() error {
+	if r.Page == 0 {
+		r.Page = 1
+	}
+	if r.PageSize == 0 {
+		r.PageSize = DefaultPageSize
+	}
+	if r.Page < 0 {
+		return ErrInvalidRequest("page must be positive")
+	}
+	if r.PageSize < 0 || r.PageSize > MaxPageSize {
+		return ErrInvalidRequest(fmt.Sprintf("pageSize must be between 1 and %d", MaxPageSize))
+	}
+
+	if r.Status != "" {
+		r.Status = EnvironmentStatus(strings.ToUpper(string(r.Status)))
+		switch r.Status {
+		case StatusCreating, StatusStarting, StatusRunning, StatusStopping, StatusStopped, StatusError, StatusDeleting:
+		default:
+			return ErrInvalidRequest(fmt.Sprintf("unknown status %q", r.Status))
+		}
+	}
+
+	return nil
+}
+
 // EnvironmentListResponse represents the response for listing environments
 type EnvironmentListResponse struct {
 	Environments []Environment `json:"environments"`
@@ -174,6 +268,14 @@ type ActivitySnapshot struct {
 	ActiveSSH       int       `json:"activeSSHConnections"`
 }
 
+// LastActivity returns the most recent IDE or SSH activity time
+func (s ActivitySnapshot) LastActivity() time.Time {
+	if s.LastSSHActivity.After(s.LastIDEActivity) {
+		return s.LastSSHActivity
+	}
+	return s.LastIDEActivity
+}
+
 // ActivityReport represents a workspace supervisor activity update.
 type ActivityReport struct {
 	EnvironmentID string           `json:"environmentId"`
@@ -221,7 +323,7 @@ func (r *CreateEnvironmentRequest) Validate() error {
 		return ErrInvalidRequest("name is required")
 	}
 	if r.CloudRegion == "" {
-		return ErrInvalidRequest("cloudRegion is required")
+		r.CloudRegion = RegionAuto
 	}
 	if r.CPUCores < 1 || r.CPUCores > 4 {
 		return ErrInvalidRequest("cpuCores must be between 1 and 4")
@@ -232,6 +334,12 @@ func (r *CreateEnvironmentRequest) Validate() error {
 	if r.StorageGB < 10 || r.StorageGB > 100 {
 		return ErrInvalidRequest("storageGB must be between 10 and 100")
 	}
+	if r.IdleTimeoutMinutes != nil && *r.IdleTimeoutMinutes < 0 {
+		return ErrInvalidRequest("idleTimeoutMinutes must not be negative")
+	}
+	if err := validatePorts(r.Ports, r.PublicPorts); err != nil {
+		return err
+	}
 	if r.BaseImage == "" {
 		r.BaseImage = "node" // Default to Node.js
 	}
@@ -258,12 +366,40 @@ func (r *StartEnvironmentRequest) Validate() error {
 	if r.MemoryGB < 2 || r.MemoryGB > 16 {
 		return ErrInvalidRequest("memoryGB must be between 2 and 16")
 	}
+	if r.IdleTimeoutMinutes != nil && *r.IdleTimeoutMinutes < 0 {
+		return ErrInvalidRequest("idleTimeoutMinutes must not be negative")
+	}
+	if err := validatePorts(r.Ports, r.PublicPorts); err != nil {
+		return err
+	}
 	if r.BaseImage == "" {
 		r.BaseImage = "node"
 	}
 	return nil
 }
 
+// validatePorts checks requested app ports are valid TCP ports without
+// duplicates and public ports are among them; the agent checks them against
+// its allowlist
+func validatePorts(ports, publicPorts []int) error {
+	seen := make(map[int]bool, len(ports))
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return ErrInvalidRequest(fmt.Sprintf("port %d is not a valid TCP port", port))
+		}
+		if seen[port] {
+			return ErrInvalidRequest(fmt.Sprintf("port %d is listed more than once", port))
+		}
+		seen[port] = true
+	}
+	for _, port := range publicPorts {
+		if !seen[port] {
+			return ErrInvalidRequest(fmt.Sprintf("public port %d is not in ports", port))
+		}
+	}
+	return nil
+}
+
 // Validate validates the stop environment request
 func (r *StopEnvironmentRequest) Validate() error {
 	if r.WorkspaceID == "" {
@@ -272,6 +408,9 @@ func (r *StopEnvironmentRequest) Validate() error {
 	if r.CloudRegion == "" {
 		return ErrInvalidRequest("cloudRegion is required")
 	}
+	if r.Reason == "" {
+		r.Reason = StopReasonRequested
+	}
 	return nil
 }
 
@@ -288,10 +427,12 @@ func (r *DeleteEnvironmentRequest) Validate() error {
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
-	Message string `json:"message"`
-	Code    string `json:"code,omitempty"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Code      string `json:"code,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"` // Stable machine code, e.g. CLOUD_QUOTA_EXCEEDED
+	Retryable bool   `json:"retryable,omitempty"` // Repeating the request later may succeed
 }
 
 // SuccessResponse represents a successful operation response
@@ -301,33 +442,79 @@ type SuccessResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// Stable machine codes carried by AppError. Clients switch on these, so they
+// must not change.
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeNotFound           = "NOT_FOUND"
+	CodeInternalServer     = "INTERNAL_SERVER_ERROR"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeConflict           = "CONFLICT"
+	CodeForbidden          = "FORBIDDEN"
+	CodeQuotaExceeded      = "QUOTA_EXCEEDED"       // Agent-enforced user/org quota
+	CodeCloudQuotaExceeded = "CLOUD_QUOTA_EXCEEDED" // Cloud subscription or region out of capacity
+	CodeThrottled          = "THROTTLED"            // Cloud API rate limit
+	CodeCloudAuthFailed    = "CLOUD_AUTH_FAILED"    // Agent credentials rejected by the cloud
+	CodeCloudUnavailable   = "CLOUD_UNAVAILABLE"    // Transient cloud or network failure
+)
+
 // Custom error types
 type AppError struct {
-	Message string
-	Code    string
+	Message   string
+	Code      string
+	Retryable bool  // Repeating the operation later may succeed
+	Err       error // Underlying cause, if any
 }
 
 func (e *AppError) Error() string {
 	return e.Message
 }
 
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
 // Error constructors with better messages
 func ErrInvalidRequest(message string) error {
-	return &AppError{Message: message, Code: "INVALID_REQUEST"}
+	return &AppError{Message: message, Code: CodeInvalidRequest}
 }
 
 func ErrNotFound(message string) error {
-	return &AppError{Message: message, Code: "NOT_FOUND"}
+	return &AppError{Message: message, Code: CodeNotFound}
 }
 
 func ErrInternalServer(message string) error {
-	return &AppError{Message: message, Code: "INTERNAL_SERVER_ERROR"}
+	return &AppError{Message: message, Code: CodeInternalServer}
 }
 
 func ErrUnauthorized(message string) error {
-	return &AppError{Message: message, Code: "UNAUTHORIZED"}
+	return &AppError{Message: message, Code: CodeUnauthorized}
 }
 
 func ErrConflict(message string) error {
-	return &AppError{Message: message, Code: "CONFLICT"}
+	return &AppError{Message: message, Code: CodeConflict}
+}
+
+func ErrForbidden(message string) error {
+	return &AppError{Message: message, Code: CodeForbidden}
+}
+
+func ErrQuotaExceeded(message string) error {
+	return &AppError{Message: message, Code: CodeQuotaExceeded}
+}
+
+func ErrCloudQuotaExceeded(message string) error {
+	return &AppError{Message: message, Code: CodeCloudQuotaExceeded}
+}
+
+func ErrThrottled(message string) error {
+	return &AppError{Message: message, Code: CodeThrottled, Retryable: true}
+}
+
+func ErrCloudAuthFailed(message string) error {
+	return &AppError{Message: message, Code: CodeCloudAuthFailed}
+}
+
+func ErrCloudUnavailable(message string) error {
+	return &AppError{Message: message, Code: CodeCloudUnavailable, Retryable: true}
 }
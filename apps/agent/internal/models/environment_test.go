@@ -35,14 +35,15 @@ func TestCreateEnvironmentRequest_Validate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "missing region",
+			name: "omitted region is placed automatically",
 			req: CreateEnvironmentRequest{
-				Name:      "test-env",
-				CPUCores:  2,
-				MemoryGB:  4,
-				StorageGB: 100,
+				WorkspaceID: "550e8400-e29b-41d4-a716-446655440000",
+				Name:        "test-env",
+				CPUCores:    2,
+				MemoryGB:    4,
+				StorageGB:   100,
 			},
-			wantErr: true,
+			wantErr: false,
 		},
 		{
 			name: "invalid CPU cores too low",
@@ -88,6 +89,59 @@ func TestCreateEnvironmentRequest_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "app ports",
+			req: CreateEnvironmentRequest{
+				WorkspaceID: "550e8400-e29b-41d4-a716-446655440000",
+				Name:        "test-env",
+				CloudRegion: "eastus",
+				CPUCores:    2,
+				MemoryGB:    4,
+				StorageGB:   100,
+				Ports:       []int{3000, 5173},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid port",
+			req: CreateEnvironmentRequest{
+				WorkspaceID: "550e8400-e29b-41d4-a716-446655440000",
+				Name:        "test-env",
+				CloudRegion: "eastus",
+				CPUCores:    2,
+				MemoryGB:    4,
+				StorageGB:   100,
+				Ports:       []int{70000},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate port",
+			req: CreateEnvironmentRequest{
+				WorkspaceID: "550e8400-e29b-41d4-a716-446655440000",
+				Name:        "test-env",
+				CloudRegion: "eastus",
+				CPUCores:    2,
+				MemoryGB:    4,
+				StorageGB:   100,
+				Ports:       []int{3000, 3000},
+			},
+			wantErr: true,
+		},
+		{
+			name: "public port not exposed",
+			req: CreateEnvironmentRequest{
+				WorkspaceID: "550e8400-e29b-41d4-a716-446655440000",
+				Name:        "test-env",
+				CloudRegion: "eastus",
+				CPUCores:    2,
+				MemoryGB:    4,
+				StorageGB:   100,
+				Ports:       []int{3000},
+				PublicPorts: []int{5173},
+			},
+			wantErr: true,
+		},
 		{
 			name: "default base image",
 			req: CreateEnvironmentRequest{
@@ -250,6 +304,18 @@ func TestAppError(t *testing.T) {
 			message:  "unauthorized",
 			wantCode: "UNAUTHORIZED",
 		},
+		{
+			name:     "forbidden error",
+			errFunc:  ErrForbidden,
+			message:  "forbidden",
+			wantCode: "FORBIDDEN",
+		},
+		{
+			name:     "quota exceeded error",
+			errFunc:  ErrQuotaExceeded,
+			message:  "quota exceeded",
+			wantCode: "QUOTA_EXCEEDED",
+		},
 	}
 
 	for _, tt := range tests {
@@ -302,3 +368,61 @@ func TestActivitySnapshot(t *testing.T) {
 		t.Error("LastIDEActivity should not be zero")
 	}
 }
+
+func TestActivitySnapshot_LastActivity(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		snapshot ActivitySnapshot
+		want     time.Time
+	}{
+		{name: "IDE more recent", snapshot: ActivitySnapshot{LastIDEActivity: now, LastSSHActivity: now.Add(-time.Minute)}, want: now},
+		{name: "SSH more recent", snapshot: ActivitySnapshot{LastIDEActivity: now.Add(-time.Minute), LastSSHActivity: now}, want: now},
+		{name: "no activity", snapshot: ActivitySnapshot{}, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.snapshot.LastActivity(); !got.Equal(tt.want) {
+				t.Errorf("LastActivity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEnvironmentsRequest_Normalize(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          ListEnvironmentsRequest
+		wantErr      bool
+		wantPage     int
+		wantPageSize int
+		wantStatus   EnvironmentStatus
+	}{
+		{name: "defaults", req: ListEnvironmentsRequest{}, wantPage: 1, wantPageSize: DefaultPageSize},
+		{name: "explicit paging", req: ListEnvironmentsRequest{Page: 3, PageSize: 50}, wantPage: 3, wantPageSize: 50},
+		{name: "lowercase status", req: ListEnvironmentsRequest{Status: "running"}, wantPage: 1, wantPageSize: DefaultPageSize, wantStatus: StatusRunning},
+		{name: "unknown status", req: ListEnvironmentsRequest{Status: "sleeping"}, wantErr: true},
+		{name: "negative page", req: ListEnvironmentsRequest{Page: -1}, wantErr: true},
+		{name: "page size too large", req: ListEnvironmentsRequest{PageSize: MaxPageSize + 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.req.Page != tt.wantPage || tt.req.PageSize != tt.wantPageSize {
+				t.Errorf("Normalize() page = %d/%d, want %d/%d", tt.req.Page, tt.req.PageSize, tt.wantPage, tt.wantPageSize)
+			}
+			if tt.req.Status != tt.wantStatus {
+				t.Errorf("Normalize() status = %v, want %v", tt.req.Status, tt.wantStatus)
+			}
+		})
+	}
+}
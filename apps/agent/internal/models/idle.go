@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// StopReason records why a workspace was stopped
+type StopReason string
+
+const (
+	StopReasonRequested StopReason = "REQUESTED"    // Stopped through the API
+	StopReasonIdle      StopReason = "IDLE_TIMEOUT" // Stopped by the idle policy
+)
+
+// IdleStatus is the idle policy's view of a workspace, returned to the
+// supervisor with every activity report
+type IdleStatus struct {
+	WorkspaceID    string     `json:"workspaceId"`
+	TimeoutSeconds int64      `json:"idleTimeoutSeconds"` // 0 = never stopped for inactivity
+	LastActivity   time.Time  `json:"lastActivity"`
+	StopAt         *time.Time `json:"stopAt,omitempty"` // When the workspace will be stopped if it stays idle
+	Warning        bool       `json:"warning"`          // The stop is imminent
+}
+
+// IdleEventWarning is the type of the event sent when an idle stop becomes imminent
+const IdleEventWarning = "workspace.idle_warning"
+
+// IdleEvent is posted to IDLE_WEBHOOK_URL so the dashboard can tell the user
+// before the idle policy stops their workspace
+type IdleEvent struct {
+	Type   string     `json:"type"`
+	UserID string     `json:"userId,omitempty"`
+	Idle   IdleStatus `json:"idle"`
+	SentAt time.Time  `json:"sentAt"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// OperationType identifies the lifecycle action a long-running operation performs
+type OperationType string
+
+const (
+	OperationCreate OperationType = "CREATE"
+	OperationStart  OperationType = "START"
+	OperationStop   OperationType = "STOP"
+	OperationDelete OperationType = "DELETE"
+)
+
+// OperationStatus represents the overall state of a long-running operation
+type OperationStatus string
+
+const (
+	OperationPending   OperationStatus = "PENDING"
+	OperationRunning   OperationStatus = "RUNNING"
+	OperationSucceeded OperationStatus = "SUCCEEDED"
+	OperationFailed    OperationStatus = "FAILED"
+)
+
+// StepStatus represents the state of a single operation step
+type StepStatus string
+
+const (
+	StepPending StepStatus = "PENDING"
+	StepRunning StepStatus = "RUNNING"
+	StepDone    StepStatus = "DONE"
+	StepFailed  StepStatus = "FAILED"
+	StepSkipped StepStatus = "SKIPPED"
+)
+
+// Operation step names reported to clients
+const (
+	StepVolumeCreated     = "VOLUME_CREATED"
+	StepVolumeVerified    = "VOLUME_VERIFIED"
+	StepContainerCreated  = "CONTAINER_CREATED"
+	StepRuntimeRunning    = "RUNTIME_RUNNING"
+	StepFQDNAssigned      = "FQDN_ASSIGNED"
+	StepIDEReachable      = "IDE_REACHABLE"
+	StepSupervisorHealthy = "SUPERVISOR_HEALTHY"
+	StepContainerDeleted  = "CONTAINER_DELETED"
+	StepVolumeDeleted     = "VOLUME_DELETED"
+)
+
+// OperationSteps lists the steps each operation type reports, in order
+var OperationSteps = map[OperationType][]string{
+	OperationCreate: {StepVolumeCreated, StepContainerCreated, StepRuntimeRunning, StepFQDNAssigned, StepIDEReachable, StepSupervisorHealthy},
+	OperationStart:  {StepVolumeVerified, StepContainerCreated, StepRuntimeRunning, StepFQDNAssigned, StepIDEReachable, StepSupervisorHealthy},
+	OperationStop:   {StepContainerDeleted},
+	OperationDelete: {StepContainerDeleted, StepVolumeDeleted},
+}
+
+// OperationStep is the progress of one step of an operation
+type OperationStep struct {
+	Name        string     `json:"name"`
+	Status      StepStatus `json:"status"`
+	Message     string     `json:"message,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// OperationError describes why an operation failed
+type OperationError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+// Operation is a long-running create/start/stop/delete request running in the background
+type Operation struct {
+	ID          string          `json:"id"`
+	Type        OperationType   `json:"type"`
+	WorkspaceID string          `json:"workspaceId"`
+	UserID      string          `json:"userId,omitempty"` // Owner of the workspace; only they and admins may read the operation
+	Status      OperationStatus `json:"status"`
+	Steps       []OperationStep `json:"steps"`
+
+	// Result (set once the operation completes)
+	Environment *Environment    `json:"environment,omitempty"` // create/start only
+	Error       *OperationError `json:"error,omitempty"`
+
+	// Timestamps
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// Done reports whether the operation has finished, successfully or not
+func (o *Operation) Done() bool {
+	return o.Status == OperationSucceeded || o.Status == OperationFailed
+}
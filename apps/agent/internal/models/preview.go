@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// PreviewVisibility controls who may open a preview URL
+type PreviewVisibility string
+
+const (
+	PreviewPrivate PreviewVisibility = "PRIVATE" // Only the owner, through a signed link from the API
+	PreviewPublic  PreviewVisibility = "PUBLIC"  // Anyone with the URL
+)
+
+// PreviewURL is the browser URL of a workspace app port
+type PreviewURL struct {
+	URL        string            `json:"url"` // https://{port}-{workspaceId}.preview.{domain}
+	Visibility PreviewVisibility `json:"visibility"`
+}
+
+// AccessLink is a short-lived link signing the owner's browser in to a private
+// preview or a workspace behind the gateway
+type AccessLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
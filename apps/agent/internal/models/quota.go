@@ -0,0 +1,31 @@
+package models
+
+// QuotaScope identifies who a quota applies to
+type QuotaScope string
+
+const (
+	QuotaScopeUser QuotaScope = "USER"
+	QuotaScopeOrg  QuotaScope = "ORG"
+)
+
+// QuotaResources is an amount of quota-controlled resources
+type QuotaResources struct {
+	RunningWorkspaces int `json:"runningWorkspaces"`
+	CPUCores          int `json:"cpuCores"`
+	MemoryGB          int `json:"memoryGB"`
+	StorageGB         int `json:"storageGB"` // Includes stopped workspaces
+}
+
+// QuotaStatus is the current usage of a user or organisation against its limits
+type QuotaStatus struct {
+	Scope  QuotaScope     `json:"scope"`
+	ID     string         `json:"id"`
+	Limits QuotaResources `json:"limits"` // 0 = unlimited
+	Usage  QuotaResources `json:"usage"`
+}
+
+// QuotaUsageResponse is returned by GET /api/v1/quotas
+type QuotaUsageResponse struct {
+	User *QuotaStatus `json:"user"`
+	Org  *QuotaStatus `json:"org,omitempty"`
+}
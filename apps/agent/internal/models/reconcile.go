@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// OrphanKind identifies the kind of leaked resource found by the reconciler
+type OrphanKind string
+
+const (
+	OrphanRuntimeWithoutVolume OrphanKind = "RUNTIME_WITHOUT_VOLUME" // aci-{id} whose fs-{id} is gone
+	OrphanVolumeWithoutOwner   OrphanKind = "VOLUME_WITHOUT_WORKSPACE"
+	OrphanFailedRuntime        OrphanKind = "FAILED_RUNTIME" // aci-{id} stuck in a failed state
+)
+
+// ReconcileAction records what the reconciler did about a finding
+type ReconcileAction string
+
+const (
+	ActionReported ReconcileAction = "REPORTED" // Cleanup disabled
+	ActionDeleted  ReconcileAction = "DELETED"
+	ActionFailed   ReconcileAction = "FAILED"
+)
+
+// ReconcileFinding is a single leaked resource
+type ReconcileFinding struct {
+	Kind          OrphanKind      `json:"kind"`
+	CloudProvider CloudProvider   `json:"cloudProvider"`
+	Region        string          `json:"region"`
+	WorkspaceID   string          `json:"workspaceId"`
+	Resource      string          `json:"resource"`
+	Action        ReconcileAction `json:"action"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// ReconcileReport is the outcome of one reconciliation pass
+type ReconcileReport struct {
+	StartedAt   time.Time          `json:"startedAt"`
+	CompletedAt time.Time          `json:"completedAt"`
+	Cleanup     bool               `json:"cleanup"`
+	Runtimes    int                `json:"runtimes"` // Managed runtimes inspected
+	Volumes     int                `json:"volumes"`  // Workspace volumes inspected
+	Findings    []ReconcileFinding `json:"findings"`
+	Errors      []string           `json:"errors,omitempty"` // Regions that could not be listed
+}
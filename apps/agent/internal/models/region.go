@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// RegionHealth is the health of a provider region as tracked by its circuit breaker
+type RegionHealth struct {
+	Region    string     `json:"region"`
+	Healthy   bool       `json:"healthy"`             // Calls to the region are let through
+	State     string     `json:"state"`               // closed, open or half-open
+	Failures  int        `json:"failures"`            // Consecutive failed calls
+	OpenUntil *time.Time `json:"openUntil,omitempty"` // When an open breaker next probes the region
+}
+
+// Region describes a configured region for clients building region pickers
+type Region struct {
+	Name              string         `json:"name"`
+	Location          string         `json:"location"` // Display name, e.g. "West Europe"
+	Enabled           bool           `json:"enabled"`
+	Health            RegionHealth   `json:"health"`
+	Tiers             []ResourceTier `json:"tiers"`             // Workspace sizes the region accepts; empty when disabled
+	StorageConfigured bool           `json:"storageConfigured"` // The region has a storage account for volumes
+}
+
+// ResourceTier is a named workspace size
+type ResourceTier struct {
+	Name     string `json:"name"`
+	CPUCores int    `json:"cpuCores"`
+	MemoryGB int    `json:"memoryGB"`
+}
+
+// ResourceTiers are the workspace sizes offered to clients, within the limits
+// CreateEnvironmentRequest.Validate accepts
+var ResourceTiers = []ResourceTier{
+	{Name: "micro", CPUCores: 1, MemoryGB: 2},
+	{Name: "small", CPUCores: 2, MemoryGB: 4},
+	{Name: "medium", CPUCores: 4, MemoryGB: 8},
+	{Name: "large", CPUCores: 4, MemoryGB: 16},
+}
@@ -0,0 +1,7 @@
+package models
+
+// SSHKeys are the SSH public keys a user signs in to the SSH bastion with,
+// one authorized_keys line each (e.g. "ssh-ed25519 AAAA... laptop")
+type SSHKeys struct {
+	Keys []string `json:"keys"`
+}
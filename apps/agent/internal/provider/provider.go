@@ -0,0 +1,315 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// Resource name prefixes; a workspace {id} owns fs-{id} and aci-{id} on every backend
+const (
+	VolumePrefix  = "fs-"
+	RuntimePrefix = "aci-"
+)
+
+// Ports served by every workspace image
+const (
+	PortIDE        = 8080 // code-server
+	PortSSH        = 2222
+	PortSupervisor = 9000 // Supervisor /health and activity
+)
+
+// WorkspacePorts are exposed on every workspace runtime, before any app ports
+var WorkspacePorts = []int{PortIDE, PortSSH, PortSupervisor}
+
+// ErrRuntimeNotFound is returned by GetRuntime when the workspace runtime does not exist
+var ErrRuntimeNotFound = errors.New("workspace runtime not found")
+
+// RuntimeState represents the provider-neutral state of a workspace runtime
+type RuntimeState string
+
+const (
+	StatePending RuntimeState = "Pending"
+	StateRunning RuntimeState = "Running"
+	StateStopped RuntimeState = "Stopped"
+	StateFailed  RuntimeState = "Failed"
+	StateUnknown RuntimeState = "Unknown"
+)
+
+// Runtime describes a workspace runtime (ACI container group, container, pod, task...)
+type Runtime struct {
+	Name          string
+	Region        string
+	ResourceGroup string // Backend-specific grouping (resource group, namespace, cluster)
+	EnvironmentID string
+	UserID        string
+	FQDN          string
+	IPAddress     string
+	State         RuntimeState
+	Tags          map[string]string
+
+	// Ports maps container ports to the externally reachable port when they differ
+	// (e.g. Docker publishing 8080 on a random host port)
+	Ports map[int]int
+
+	// Scheme is the URL scheme HTTP ports are served over; empty means "http"
+	Scheme string
+
+	// Reachable lists the only container ports the backend routes traffic to;
+	// nil means every port the workspace opens (e.g. Cloud Run only routes the IDE)
+	Reachable []int
+}
+
+// PublicPort returns the externally reachable port for a container port
+func (r *Runtime) PublicPort(containerPort int) int {
+	if port, ok := r.Ports[containerPort]; ok && port != 0 {
+		return port
+	}
+	return containerPort
+}
+
+// Exposes reports whether the backend routes traffic to a container port
+func (r *Runtime) Exposes(containerPort int) bool {
+	return r.Reachable == nil || slices.Contains(r.Reachable, containerPort)
+}
+
+// HTTPURL returns the URL serving an HTTP container port, leaving out the
+// scheme's default port
+func (r *Runtime) HTTPURL(containerPort int) string {
+	scheme, port := r.Scheme, r.PublicPort(containerPort)
+	if scheme == "" {
+		scheme = "http"
+	}
+	if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		return scheme + "://" + r.FQDN
+	}
+	return scheme + "://" + net.JoinHostPort(r.FQDN, strconv.Itoa(port))
+}
+
+// Volume describes a workspace persistent volume (file share, named volume, PVC...)
+type Volume struct {
+	Name   string
+	Region string
+	// CreatedAt is when the volume was created, or last modified where the
+	// backend does not track creation; zero if unknown
+	CreatedAt time.Time
+}
+
+// ComputeProvider runs workspace containers and their persistent volumes on a backend
+type ComputeProvider interface {
+	// Name returns a short human-readable backend name used in logs
+	Name() string
+
+	// HasRegion reports whether the backend can serve the given region
+	HasRegion(region string) bool
+
+	// Regions returns the regions the backend is configured to serve
+	Regions() []string
+
+	// CreateVolume creates the persistent volume mounted at /home/dev8
+	CreateVolume(ctx context.Context, region, name string, quotaGB int32) error
+	// VolumeExists checks whether the persistent volume exists
+	VolumeExists(ctx context.Context, region, name string) (bool, error)
+	// DeleteVolume permanently deletes the persistent volume
+	DeleteVolume(ctx context.Context, region, name string) error
+
+	// ListVolumes returns all workspace volumes (fs-*) in a region
+	ListVolumes(ctx context.Context, region string) ([]Volume, error)
+
+	// CreateRuntime creates and starts the workspace runtime
+	CreateRuntime(ctx context.Context, region, name string, spec ContainerGroupSpec) error
+	// GetRuntime returns the runtime details or ErrRuntimeNotFound
+	GetRuntime(ctx context.Context, region, name string) (*Runtime, error)
+	// DeleteRuntime deletes the workspace runtime (volumes are kept)
+	DeleteRuntime(ctx context.Context, region, name string) error
+	// ListRuntimes returns all runtimes managed by the agent in a region
+	ListRuntimes(ctx context.Context, region string) ([]Runtime, error)
+}
+
+// RegionHealthReporter is implemented by providers that track the health of
+// their regions, e.g. with circuit breakers around cloud API calls
+type RegionHealthReporter interface {
+	// RegionHealth returns the health of every region the provider serves
+	RegionHealth() []models.RegionHealth
+}
+
+// RegionHealthy reports whether the provider currently lets calls to the
+// region through. Providers that do not track region health are always healthy.
+func RegionHealthy(p ComputeProvider, region string) bool {
+	reporter, ok := p.(RegionHealthReporter)
+	if !ok {
+		return true
+	}
+	for _, health := range reporter.RegionHealth() {
+		if health.Region == region {
+			return health.Healthy
+		}
+	}
+	return true
+}
+
+// ContainerGroupSpec defines the specification for creating a workspace runtime
+type ContainerGroupSpec struct {
+	ContainerName      string
+	Image              string
+	CPUCores           int
+	MemoryGB           int
+	DNSNameLabel       string
+	SubnetID           string // Azure: deploy into this delegated subnet with a private IP, without DNSNameLabel
+	FileShareName      string // Single file share for all persistent data - mounts to /home/dev8 (includes workspace subdirectory)
+	StorageAccountName string
+	StorageAccountKey  string
+	EnvironmentID      string
+	UserID             string
+	AppPorts           []int // User-requested ports exposed besides WorkspacePorts
+
+	// Container Registry Credentials (static from Agent config)
+	RegistryServer   string
+	RegistryUsername string
+	RegistryPassword string
+
+	// Dynamic per-workspace values (from API request)
+	AgentBaseURL       string
+	SupervisorToken    string // Authenticates the supervisor's activity reports
+	GitHubToken        string
+	GitUserName        string
+	GitUserEmail       string
+	SSHPublicKey       string
+	CodeServerPassword string
+	AnthropicAPIKey    string
+	OpenAIAPIKey       string
+	GeminiAPIKey       string
+}
+
+// Ports returns the container ports the runtime must expose: the workspace
+// image ports followed by the app ports
+func (s ContainerGroupSpec) Ports() []int {
+	ports := make([]int, 0, len(WorkspacePorts)+len(s.AppPorts))
+	ports = append(ports, WorkspacePorts...)
+	return append(ports, s.AppPorts...)
+}
+
+// EnvVar is a container environment variable; Secure values must not be exposed in logs or APIs
+type EnvVar struct {
+	Name   string
+	Value  string
+	Secure bool
+}
+
+// EnvVars returns the container environment for the workspace image
+func (s ContainerGroupSpec) EnvVars() []EnvVar {
+	envVars := []EnvVar{
+		// Always required
+		{Name: "WORKSPACE_ID", Value: s.EnvironmentID},
+		{Name: "USER_ID", Value: s.UserID},
+		{Name: "WORKSPACE_DIR", Value: "/home/dev8/workspace"},
+		{Name: "AGENT_BASE_URL", Value: s.AgentBaseURL},
+		{Name: "AGENT_ENABLED", Value: "true"},
+		{Name: "MONITOR_INTERVAL", Value: "30s"},
+		{Name: "LOG_FILE_PATH", Value: "/var/log/supervisor.log"},
+		{Name: "SUPERVISOR_HTTP_ADDR", Value: fmt.Sprintf("0.0.0.0:%d", PortSupervisor)}, // Probed by the agent's readiness checks
+	}
+
+	// Add optional environment variables only if provided
+	optional := []EnvVar{
+		{Name: "SUPERVISOR_AGENT_API_KEY", Value: s.SupervisorToken, Secure: true},
+		{Name: "GITHUB_TOKEN", Value: s.GitHubToken, Secure: true},
+		{Name: "CODE_SERVER_PASSWORD", Value: s.CodeServerPassword, Secure: true},
+		{Name: "SSH_PUBLIC_KEY", Value: s.SSHPublicKey},
+		{Name: "GIT_USER_NAME", Value: s.GitUserName},
+		{Name: "GIT_USER_EMAIL", Value: s.GitUserEmail},
+		{Name: "ANTHROPIC_API_KEY", Value: s.AnthropicAPIKey, Secure: true},
+		{Name: "OPENAI_API_KEY", Value: s.OpenAIAPIKey, Secure: true},
+		{Name: "GEMINI_API_KEY", Value: s.GeminiAPIKey, Secure: true},
+	}
+	for _, envVar := range optional {
+		if envVar.Value != "" {
+			envVars = append(envVars, envVar)
+		}
+	}
+
+	// Backup configuration (always enabled when backed by a storage account)
+	if s.StorageAccountName != "" {
+		envVars = append(envVars,
+			EnvVar{Name: "BACKUP_ENABLED", Value: "true"},
+			EnvVar{Name: "BACKUP_INTERVAL", Value: "1h"},
+			EnvVar{Name: "BACKUP_STORAGE_ACCOUNT", Value: s.StorageAccountName},
+			EnvVar{Name: "BACKUP_CONTAINER", Value: "backups"},
+		)
+	}
+
+	return envVars
+}
+
+// Labels returns the labels/tags identifying a workspace runtime as agent-managed
+func (s ContainerGroupSpec) Labels() map[string]string {
+	return map[string]string{
+		"environment": s.EnvironmentID,
+		"userId":      s.UserID,
+		"managed-by":  "dev8-agent",
+	}
+}
+
+// Registry resolves compute providers by cloud provider
+type Registry struct {
+	mu              sync.RWMutex
+	providers       map[models.CloudProvider]ComputeProvider
+	defaultProvider models.CloudProvider
+}
+
+// NewRegistry creates a registry; defaultProvider is used when a request does not specify one
+func NewRegistry(defaultProvider models.CloudProvider) *Registry {
+	return &Registry{
+		providers:       make(map[models.CloudProvider]ComputeProvider),
+		defaultProvider: defaultProvider,
+	}
+}
+
+// Register adds (or replaces) the provider serving the given cloud provider
+func (r *Registry) Register(cloud models.CloudProvider, p ComputeProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[cloud] = p
+}
+
+// Get returns the provider for the given cloud provider (empty means default)
+func (r *Registry) Get(cloud models.CloudProvider) (ComputeProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if cloud == "" {
+		cloud = r.defaultProvider
+	}
+
+	p, ok := r.providers[cloud]
+	if !ok {
+		return nil, models.ErrInvalidRequest(fmt.Sprintf("cloud provider %s is not enabled", cloud))
+	}
+	return p, nil
+}
+
+// Default returns the default cloud provider
+func (r *Registry) Default() models.CloudProvider {
+	return r.defaultProvider
+}
+
+// Providers returns all registered cloud providers
+func (r *Registry) Providers() []models.CloudProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clouds := make([]models.CloudProvider, 0, len(r.providers))
+	for cloud := range r.providers {
+		clouds = append(clouds, cloud)
+	}
+	sort.Slice(clouds, func(i, j int) bool { return clouds[i] < clouds[j] })
+	return clouds
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+type stubProvider struct {
+	name string
+}
+
+func (s *stubProvider) Name() string                 { return s.name }
+func (s *stubProvider) HasRegion(region string) bool { return region == "eastus" }
+func (s *stubProvider) Regions() []string            { return []string{"eastus"} }
+func (s *stubProvider) CreateVolume(ctx context.Context, region, name string, quotaGB int32) error {
+	return nil
+}
+func (s *stubProvider) VolumeExists(ctx context.Context, region, name string) (bool, error) {
+	return true, nil
+}
+func (s *stubProvider) DeleteVolume(ctx context.Context, region, name string) error { return nil }
+func (s *stubProvider) ListVolumes(ctx context.Context, region string) ([]Volume, error) {
+	return nil, nil
+}
+func (s *stubProvider) CreateRuntime(ctx context.Context, region, name string, spec ContainerGroupSpec) error {
+	return nil
+}
+func (s *stubProvider) GetRuntime(ctx context.Context, region, name string) (*Runtime, error) {
+	return nil, ErrRuntimeNotFound
+}
+func (s *stubProvider) DeleteRuntime(ctx context.Context, region, name string) error { return nil }
+func (s *stubProvider) ListRuntimes(ctx context.Context, region string) ([]Runtime, error) {
+	return nil, nil
+}
+
+func TestRegistry_Get(t *testing.T) {
+	registry := NewRegistry(models.ProviderAzure)
+	registry.Register(models.ProviderAzure, &stubProvider{name: "azure"})
+	registry.Register(models.ProviderAWS, &stubProvider{name: "aws"})
+
+	tests := []struct {
+		name     string
+		cloud    models.CloudProvider
+		wantName string
+		wantErr  bool
+	}{
+		{name: "empty uses default", cloud: "", wantName: "azure"},
+		{name: "explicit azure", cloud: models.ProviderAzure, wantName: "azure"},
+		{name: "explicit aws", cloud: models.ProviderAWS, wantName: "aws"},
+		{name: "unregistered gcp", cloud: models.ProviderGCP, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := registry.Get(tt.cloud)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get(%q) error = %v, wantErr %v", tt.cloud, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if appErr, ok := err.(*models.AppError); !ok || appErr.Code != "INVALID_REQUEST" {
+					t.Errorf("Get(%q) error = %v, want INVALID_REQUEST AppError", tt.cloud, err)
+				}
+				return
+			}
+			if p.Name() != tt.wantName {
+				t.Errorf("Get(%q) = %v, want %v", tt.cloud, p.Name(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestRegistry_Providers(t *testing.T) {
+	registry := NewRegistry(models.ProviderAzure)
+	registry.Register(models.ProviderGCP, &stubProvider{name: "gcp"})
+	registry.Register(models.ProviderAzure, &stubProvider{name: "azure"})
+
+	got := registry.Providers()
+	if len(got) != 2 || got[0] != models.ProviderAzure || got[1] != models.ProviderGCP {
+		t.Errorf("Providers() = %v, want [AZURE GCP]", got)
+	}
+
+	if registry.Default() != models.ProviderAzure {
+		t.Errorf("Default() = %v, want %v", registry.Default(), models.ProviderAzure)
+	}
+}
+
+type healthReportingProvider struct {
+	stubProvider
+	health []models.RegionHealth
+}
+
+func (p *healthReportingProvider) RegionHealth() []models.RegionHealth { return p.health }
+
+func TestRegionHealthy(t *testing.T) {
+	reporting := &healthReportingProvider{health: []models.RegionHealth{
+		{Region: "eastus", Healthy: true, State: "closed"},
+		{Region: "westeurope", Healthy: false, State: "open"},
+	}}
+
+	tests := []struct {
+		name     string
+		provider ComputeProvider
+		region   string
+		want     bool
+	}{
+		{name: "healthy region", provider: reporting, region: "eastus", want: true},
+		{name: "open breaker", provider: reporting, region: "westeurope", want: false},
+		{name: "unknown region", provider: reporting, region: "centralindia", want: true},
+		{name: "provider without health", provider: &stubProvider{name: "docker"}, region: "eastus", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RegionHealthy(tt.provider, tt.region); got != tt.want {
+				t.Errorf("RegionHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package resilience
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Breaker.Allow while the breaker short-circuits calls
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker
+type State string
+
+const (
+	StateClosed   State = "closed"    // Calls flow normally
+	StateOpen     State = "open"      // Calls fail fast until the cooldown ends
+	StateHalfOpen State = "half-open" // One probe call decides whether to close again
+)
+
+// Status is a point-in-time view of a breaker
+type Status struct {
+	Name      string
+	State     State
+	Failures  int       // Consecutive failures counted towards the threshold
+	OpenUntil time.Time // When an open breaker lets a probe through; zero unless open
+}
+
+// Breaker opens after threshold consecutive failures and stays open for
+// cooldown. It then lets a single probe through: success closes it, failure
+// opens it for another cooldown.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker creates a closed breaker
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     StateClosed,
+	}
+}
+
+// Allow reports whether a call may proceed, returning ErrOpen if not. Every
+// allowed call must be followed by Success, Failure or Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Before(b.openedAt.Add(b.cooldown)) {
+			return ErrOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success records a call that reached a healthy backend
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a call that failed because the backend is unhealthy
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Release records an allowed call that ended without telling anything about
+// the backend (e.g. cancelled by the caller)
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Status returns the breaker's current state. An open breaker whose cooldown
+// has ended reports half-open.
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := Status{Name: b.name, State: b.state, Failures: b.failures}
+	if b.state == StateOpen {
+		status.OpenUntil = b.openedAt.Add(b.cooldown)
+		if !b.now().Before(status.OpenUntil) {
+			// The next call is the probe
+			status.State, status.OpenUntil = StateHalfOpen, time.Time{}
+		}
+	}
+	return status
+}
+
+// Breakers holds one breaker per name (e.g. per region), created on first use
+type Breakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewBreakers creates a set of breakers sharing threshold and cooldown
+func NewBreakers(threshold int, cooldown time.Duration) *Breakers {
+	return &Breakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  make(map[string]*Breaker),
+	}
+}
+
+// Get returns the breaker for name
+func (b *Breakers) Get(name string) *Breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.breakers[name]
+	if !ok {
+		breaker = NewBreaker(name, b.threshold, b.cooldown)
+		b.breakers[name] = breaker
+	}
+	return breaker
+}
+
+// Statuses returns the status of every breaker, sorted by name
+func (b *Breakers) Statuses() []Status {
+	b.mu.Lock()
+	breakers := make([]*Breaker, 0, len(b.breakers))
+	for _, breaker := range b.breakers {
+		breakers = append(breakers, breaker)
+	}
+	b.mu.Unlock()
+
+	statuses := make([]Status, 0, len(breakers))
+	for _, breaker := range breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
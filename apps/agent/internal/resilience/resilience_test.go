@@ -0,0 +1,198 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 2, min: 100 * time.Millisecond, max: 200 * time.Millisecond},
+		{attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := backoff.Delay(tt.attempt); got < tt.min || got > tt.max {
+				t.Errorf("Delay(%d) = %v, want between %v and %v", tt.attempt, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+	backoff := Backoff{Base: time.Millisecond, Max: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		results   []error
+		attempts  int
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "succeeds first time",
+			results:   []error{nil},
+			attempts:  3,
+			wantCalls: 1,
+		},
+		{
+			name:      "retries transient errors",
+			results:   []error{errTransient, errTransient, nil},
+			attempts:  3,
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after attempts",
+			results:   []error{errTransient, errTransient, errTransient, nil},
+			attempts:  3,
+			wantErr:   errTransient,
+			wantCalls: 3,
+		},
+		{
+			name:      "does not retry permanent errors",
+			results:   []error{errPermanent, nil},
+			attempts:  3,
+			wantErr:   errPermanent,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Retry(context.Background(), tt.attempts, backoff, retryable, func(ctx context.Context) error {
+				calls++
+				return tt.results[calls-1]
+			})
+
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Retry() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Retry() calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetry_StopsBeforeDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	errTransient := errors.New("transient")
+	calls := 0
+	start := time.Now()
+	err := Retry(ctx, 5, Backoff{Base: time.Second, Max: time.Second}, func(error) bool { return true }, func(ctx context.Context) error {
+		calls++
+		return errTransient
+	})
+
+	if !errors.Is(err, errTransient) {
+		t.Errorf("Retry() error = %v, want %v", err, errTransient)
+	}
+	if calls != 1 {
+		t.Errorf("Retry() calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("Retry() waited %v for a backoff past the deadline", elapsed)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := NewBreaker("eastus", 2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	// Closed until the threshold is reached
+	for i := 0; i < 2; i++ {
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("Allow() error = %v while closed", err)
+		}
+		breaker.Failure()
+	}
+	if got := breaker.Status().State; got != StateOpen {
+		t.Fatalf("State = %v after 2 failures, want %v", got, StateOpen)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("Allow() error = %v while open, want %v", err, ErrOpen)
+	}
+
+	// After the cooldown one probe goes through
+	now = now.Add(time.Minute)
+	if got := breaker.Status().State; got != StateHalfOpen {
+		t.Errorf("State = %v after cooldown, want %v", got, StateHalfOpen)
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() error = %v for the probe", err)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("Allow() error = %v during the probe, want %v", err, ErrOpen)
+	}
+
+	// A failed probe reopens it
+	breaker.Failure()
+	if got := breaker.Status().State; got != StateOpen {
+		t.Fatalf("State = %v after failed probe, want %v", got, StateOpen)
+	}
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() error = %v for the probe", err)
+	}
+	breaker.Success()
+	if status := breaker.Status(); status.State != StateClosed || status.Failures != 0 {
+		t.Errorf("Status() = %+v after successful probe, want closed with 0 failures", status)
+	}
+}
+
+func TestBreaker_ReleaseFreesProbe(t *testing.T) {
+	now := time.Now()
+	breaker := NewBreaker("eastus", 1, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	breaker.Failure()
+	now = now.Add(time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() error = %v for the probe", err)
+	}
+	breaker.Release()
+
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() error = %v after the probe was released", err)
+	}
+}
+
+func TestBreakers_Statuses(t *testing.T) {
+	breakers := NewBreakers(1, time.Minute)
+	breakers.Get("westeurope").Failure()
+	breakers.Get("eastus")
+
+	statuses := breakers.Statuses()
+	if len(statuses) != 2 {
+		t.Fatalf("Statuses() returned %d breakers, want 2", len(statuses))
+	}
+	if statuses[0].Name != "eastus" || statuses[0].State != StateClosed {
+		t.Errorf("Statuses()[0] = %+v, want eastus closed", statuses[0])
+	}
+	if statuses[1].Name != "westeurope" || statuses[1].State != StateOpen {
+		t.Errorf("Statuses()[1] = %+v, want westeurope open", statuses[1])
+	}
+	if breakers.Get("eastus") != breakers.Get("eastus") {
+		t.Error("Get() returned different breakers for the same name")
+	}
+}
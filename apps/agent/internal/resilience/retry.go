@@ -0,0 +1,65 @@
+// Package resilience retries failed cloud calls with backoff and stops calling
+// an unhealthy backend through circuit breakers.
+package resilience
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes bounded exponential delays with jitter
+type Backoff struct {
+	Base time.Duration // Delay before the first retry, before jitter
+	Max  time.Duration // Upper bound for any delay
+}
+
+// Delay returns the wait before retry number attempt (starting at 1). The
+// exponential delay is capped at Max; half of it is randomised so callers
+// retrying together spread out.
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Base <= 0 {
+		return 0
+	}
+
+	delay := b.Base
+	for i := 1; i < attempt && (b.Max <= 0 || delay < b.Max); i++ {
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Retry calls fn until it succeeds or returns an error retryable rejects, at
+// most attempts times. It gives up early when ctx is done or would be done
+// before the next attempt, returning the last error.
+func Retry(ctx context.Context, attempts int, backoff Backoff, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		delay := backoff.Delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"golang.org/x/crypto/ssh"
+)
+
+// wakePollInterval is how often WakeEnvironment checks a workspace another operation is starting
+const wakePollInterval = 2 * time.Second
+
+// SetBastionKey makes every workspace created or started from now on accept the
+// SSH bastion's client key. Call it before serving requests.
+func (s *EnvironmentService) SetBastionKey(authorizedKey string) {
+	s.bastionKey = strings.TrimSpace(authorizedKey)
+}
+
+// authorizedKeys returns the workspace's authorized_keys: the requested key and the bastion's
+func (s *EnvironmentService) authorizedKeys(userKey string) string {
+	userKey = strings.TrimSpace(userKey)
+	switch {
+	case s.bastionKey == "":
+		return userKey
+	case userKey == "":
+		return s.bastionKey
+	}
+	return userKey + "\n" + s.bastionKey
+}
+
+// routeSSHThroughBastion points a workspace's SSH URLs at the bastion, so they
+// stay the same across restarts and regions. Runtimes whose backend does not
+// expose SSH have no SSH URL and get no route.
+func (s *EnvironmentService) routeSSHThroughBastion(urls *models.ConnectionURLs, workspaceID string) {
+	if !s.config.SSHBastion.Enabled() || urls.SSHURL == "" {
+		return
+	}
+	host := s.config.SSHBastion.Host
+	urls.SSHURL = fmt.Sprintf("ssh://%s@%s", workspaceID, host)
+	urls.VSCodeDesktopURL = fmt.Sprintf("vscode-remote://ssh-remote+%s@%s/home/dev8/workspace", workspaceID, host)
+}
+
+// sshAddress returns the address of a runtime's own SSH server
+func sshAddress(runtime *provider.Runtime) string {
+	if runtime == nil || runtime.FQDN == "" || !runtime.Exposes(provider.PortSSH) {
+		return ""
+	}
+	return net.JoinHostPort(runtime.FQDN, strconv.Itoa(runtime.PublicPort(provider.PortSSH)))
+}
+
+// SSHKeys returns the SSH public keys registered for a user
+func (s *EnvironmentService) SSHKeys(userID string) ([]string, error) {
+	if s.store == nil {
+		return nil, ErrStateless
+	}
+	keys, err := s.store.SSHKeys(userID)
+	if err != nil {
+		return nil, models.ErrInternalServer(err.Error())
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	return keys, nil
+}
+
+// SetSSHKeys replaces the SSH public keys registered for a user and returns
+// them normalised to one authorized_keys line each
+func (s *EnvironmentService) SetSSHKeys(userID string, keys []string) ([]string, error) {
+	if s.store == nil {
+		return nil, ErrStateless
+	}
+	if userID == "" {
+		return nil, models.ErrInvalidRequest("userId is required")
+	}
+
+	normalised := []string{}
+	for i, key := range keys {
+		publicKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+		if err != nil {
+			return nil, models.ErrInvalidRequest(fmt.Sprintf("keys[%d] is not an SSH public key: %v", i, err))
+		}
+		line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+		if comment != "" {
+			line += " " + comment
+		}
+		normalised = append(normalised, line)
+	}
+
+	if err := s.store.PutSSHKeys(userID, normalised); err != nil {
+		return nil, models.ErrInternalServer(err.Error())
+	}
+	log.Printf("🔑 Registered %d SSH key(s) for user %s", len(normalised), userID)
+	return normalised, nil
+}
+
+// WakeEnvironment returns a running workspace, starting it from its recorded
+// settings and code-server password when stopped. Other secrets such as the
+// GitHub token are not recorded, so a workspace woken this way runs without
+// them until the owner restarts it.
+func (s *EnvironmentService) WakeEnvironment(ctx context.Context, workspaceID string) (*models.Environment, error) {
+	for {
+		env, err := s.GetEnvironment(ctx, workspaceID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch env.Status {
+		case models.StatusRunning:
+			return env, nil
+
+		case models.StatusStopped:
+			// Never expose the IDE with code-server's public default password
+			password := s.recordedCodeServerPassword(workspaceID)
+			if password == "" {
+				return nil, models.ErrForbidden(fmt.Sprintf("workspace %s has no recorded IDE password - start it from Dev8", workspaceID))
+			}
+
+			log.Printf("⏰ Waking workspace %s for an SSH connection", workspaceID)
+			op, err := s.StartEnvironmentAsync(ctx, &models.StartEnvironmentRequest{
+				WorkspaceID:   env.ID,
+				CloudProvider: env.CloudProvider,
+				CloudRegion:   env.CloudRegion,
+				UserID:        env.UserID,
+				OrgID:         env.OrgID,
+				Name:          env.Name,
+				CPUCores:      env.CPUCores,
+				MemoryGB:      env.MemoryGB,
+				StorageGB:     env.StorageGB,
+				BaseImage:     env.BaseImage,
+
+				CodeServerPassword: password,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if op, err = s.operations.Wait(ctx, op.ID); err != nil {
+				return nil, models.ErrCloudUnavailable(fmt.Sprintf("workspace %s did not start in time", workspaceID))
+			}
+			if op.Status != models.OperationSucceeded {
+				return nil, models.ErrCloudUnavailable(fmt.Sprintf("workspace %s failed to start", workspaceID))
+			}
+
+		case models.StatusCreating, models.StatusStarting:
+			// Another operation is bringing the workspace up. Its runtime may
+			// accept SSH before the operation finishes its readiness checks,
+			// or fail without the recorded status catching up.
+			reachable, err := s.reachableSSH(ctx, env)
+			if err != nil {
+				return nil, err
+			}
+			if reachable != nil {
+				return reachable, nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil, models.ErrCloudUnavailable(fmt.Sprintf("workspace %s did not start in time", workspaceID))
+			case <-time.After(wakePollInterval):
+			}
+
+		default:
+			return nil, models.ErrCloudUnavailable(fmt.Sprintf("workspace %s is %s", workspaceID, strings.ToLower(string(env.Status))))
+		}
+	}
+}
+
+// reachableSSH returns env pointing at its runtime's SSH server once the
+// runtime is running and sshd accepts connections, or nil while it is not
+// there yet. A failed runtime is an error, so callers stop waiting for it.
+func (s *EnvironmentService) reachableSSH(ctx context.Context, env *models.Environment) (*models.Environment, error) {
+	computeProvider, err := s.resolveProvider(env.CloudProvider, env.CloudRegion)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime, err := computeProvider.GetRuntime(ctx, env.CloudRegion, fmt.Sprintf("aci-%s", env.ID))
+	if err != nil {
+		if !errors.Is(err, provider.ErrRuntimeNotFound) {
+			log.Printf("Warning: failed to check runtime of waking workspace %s: %v", env.ID, err)
+		}
+		return nil, nil // Not created yet, or a transient error: check again on the next poll
+	}
+
+	switch runtime.State {
+	case provider.StateRunning:
+	case provider.StateFailed:
+		return nil, models.ErrCloudUnavailable(fmt.Sprintf("workspace %s failed to start", env.ID))
+	default:
+		return nil, nil
+	}
+
+	if !runtime.Exposes(provider.PortSSH) {
+		return nil, models.ErrCloudUnavailable(fmt.Sprintf("workspace %s does not expose SSH", env.ID))
+	}
+	address := sshAddress(runtime)
+	if address == "" {
+		return nil, nil // No FQDN assigned yet
+	}
+	conn, err := s.dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, nil // sshd is still starting
+	}
+	conn.Close()
+
+	reachable := *env
+	reachable.SSHAddress = address
+	return &reachable, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+const testSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIL0C401iOT3bQvt2bqCIB+hblO2Dk+tciYKBFJ1w7SSF"
+
+func TestEnvironmentService_SetSSHKeys(t *testing.T) {
+	service, err := NewEnvironmentService(&config.Config{
+		StatePath: filepath.Join(t.TempDir(), "state.db"),
+	}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	t.Cleanup(service.Close)
+
+	tests := []struct {
+		name     string
+		keys     []string
+		wantKeys []string
+		wantErr  bool
+	}{
+		{name: "normalised", keys: []string{"  " + testSSHKey + "   laptop\n"}, wantKeys: []string{testSSHKey + " laptop"}},
+		{name: "without comment", keys: []string{testSSHKey}, wantKeys: []string{testSSHKey}},
+		{name: "not a key", keys: []string{testSSHKey, "ssh-ed25519 not-base64"}, wantErr: true},
+		{name: "cleared", keys: nil, wantKeys: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := service.SSHKeys("user-1")
+			keys, err := service.SetSSHKeys("user-1", tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetSSHKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			stored, _ := service.SSHKeys("user-1")
+			if tt.wantErr {
+				if !slices.Equal(stored, before) {
+					t.Errorf("SSHKeys() = %v after a rejected update, want %v", stored, before)
+				}
+				return
+			}
+			if !slices.Equal(keys, tt.wantKeys) || !slices.Equal(stored, tt.wantKeys) {
+				t.Errorf("SetSSHKeys() = %v, stored %v, want %v", keys, stored, tt.wantKeys)
+			}
+		})
+	}
+
+	stateless := &EnvironmentService{config: &config.Config{}}
+	if _, err := stateless.SetSSHKeys("user-1", []string{testSSHKey}); !errors.Is(err, ErrStateless) {
+		t.Errorf("SetSSHKeys() without a store error = %v, want %v", err, ErrStateless)
+	}
+}
+
+func TestEnvironmentService_BastionRouting(t *testing.T) {
+	tests := []struct {
+		name        string
+		host        string
+		userKey     string
+		wantKeys    string
+		wantSSHURL  string
+		wantDesktop string
+	}{
+		{
+			name:        "bastion disabled",
+			userKey:     testSSHKey,
+			wantKeys:    testSSHKey,
+			wantSSHURL:  "ssh://dev8@ws-1.example.com:2222",
+			wantDesktop: "vscode-remote://ssh-remote+dev8@ws-1.example.com:2222/home/dev8/workspace",
+		},
+		{
+			name:        "bastion with user key",
+			host:        "ssh.dev8.test",
+			userKey:     testSSHKey + "\n",
+			wantKeys:    testSSHKey + "\nssh-ed25519 BASTION dev8-bastion",
+			wantSSHURL:  "ssh://ws-1@ssh.dev8.test",
+			wantDesktop: "vscode-remote://ssh-remote+ws-1@ssh.dev8.test/home/dev8/workspace",
+		},
+		{
+			name:        "bastion only",
+			host:        "ssh.dev8.test",
+			wantKeys:    "ssh-ed25519 BASTION dev8-bastion",
+			wantSSHURL:  "ssh://ws-1@ssh.dev8.test",
+			wantDesktop: "vscode-remote://ssh-remote+ws-1@ssh.dev8.test/home/dev8/workspace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &EnvironmentService{config: &config.Config{SSHBastion: config.SSHBastionConfig{Host: tt.host}}}
+			if tt.host != "" {
+				service.SetBastionKey("ssh-ed25519 BASTION dev8-bastion\n")
+			}
+
+			if got := service.authorizedKeys(tt.userKey); got != tt.wantKeys {
+				t.Errorf("authorizedKeys() = %q, want %q", got, tt.wantKeys)
+			}
+			urls := models.ConnectionURLs{
+				SSHURL:           "ssh://dev8@ws-1.example.com:2222",
+				VSCodeDesktopURL: "vscode-remote://ssh-remote+dev8@ws-1.example.com:2222/home/dev8/workspace",
+			}
+			service.routeSSHThroughBastion(&urls, "ws-1")
+			if urls.SSHURL != tt.wantSSHURL || urls.VSCodeDesktopURL != tt.wantDesktop {
+				t.Errorf("routeSSHThroughBastion() = %q, %q, want %q, %q", urls.SSHURL, urls.VSCodeDesktopURL, tt.wantSSHURL, tt.wantDesktop)
+			}
+		})
+	}
+}
+
+func TestEnvironmentService_WakeEnvironment(t *testing.T) {
+	service, fakeProvider, _ := newIdleFixture(t, config.IdleConfig{})
+	ctx := context.Background()
+
+	if err := fakeProvider.CreateVolume(ctx, "eastus", "fs-ws-1", 10); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	service.saveEnvironment(&models.Environment{
+		ID:            "ws-1",
+		UserID:        "user-1",
+		Name:          "workspace",
+		Status:        models.StatusStopped,
+		CloudProvider: models.ProviderAzure,
+		CloudRegion:   "eastus",
+		CPUCores:      2,
+		MemoryGB:      4,
+	})
+
+	// Without a recorded password code-server would use its public default
+	var appErr *models.AppError
+	if _, err := service.WakeEnvironment(ctx, "ws-1"); !errors.As(err, &appErr) || appErr.Code != models.CodeForbidden {
+		t.Fatalf("WakeEnvironment() without a password error = %v, want %s", err, models.CodeForbidden)
+	}
+	if _, ok := fakeProvider.Spec("eastus", "aci-ws-1"); ok {
+		t.Fatal("WakeEnvironment() started a workspace without a password")
+	}
+
+	if err := service.store.PutCodeServerPassword("ws-1", "recorded-secret"); err != nil {
+		t.Fatalf("PutCodeServerPassword() error = %v", err)
+	}
+	env, err := service.WakeEnvironment(ctx, "ws-1")
+	if err != nil || env.Status != models.StatusRunning {
+		t.Fatalf("WakeEnvironment() = %+v, %v, want RUNNING", env, err)
+	}
+	if spec, _ := fakeProvider.Spec("eastus", "aci-ws-1"); spec.CodeServerPassword != "recorded-secret" {
+		t.Errorf("woken runtime code-server password = %q, want the recorded one", spec.CodeServerPassword)
+	}
+}
+
+func TestEnvironmentService_WakeEnvironmentWhileStarting(t *testing.T) {
+	service, fakeProvider, _ := newIdleFixture(t, config.IdleConfig{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var sshdUp atomic.Bool
+	service.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != "ws-ws-1.eastus.localhost:2222" {
+			t.Errorf("dialed %q, want the runtime's sshd", address)
+		}
+		if !sshdUp.Load() {
+			return nil, errors.New("connection refused")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	// Recorded as starting by an operation that has not finished its readiness checks
+	spec := provider.ContainerGroupSpec{EnvironmentID: "ws-1", UserID: "user-1", DNSNameLabel: "ws-ws-1"}
+	if err := fakeProvider.CreateRuntime(ctx, "eastus", "aci-ws-1", spec); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+	service.saveEnvironment(&models.Environment{
+		ID:            "ws-1",
+		UserID:        "user-1",
+		Status:        models.StatusStarting,
+		CloudProvider: models.ProviderAzure,
+		CloudRegion:   "eastus",
+	})
+
+	go func() {
+		time.Sleep(wakePollInterval / 2)
+		sshdUp.Store(true)
+	}()
+	woken, err := service.WakeEnvironment(ctx, "ws-1")
+	if err != nil || woken.SSHAddress != "ws-ws-1.eastus.localhost:2222" {
+		t.Fatalf("WakeEnvironment() = %+v, %v, want the address of the reachable sshd", woken, err)
+	}
+
+	// A failed runtime ends the wait instead of running out the timeout
+	fakeProvider.SetRuntimeState("eastus", "aci-ws-1", provider.StateFailed)
+	var appErr *models.AppError
+	if _, err := service.WakeEnvironment(ctx, "ws-1"); !errors.As(err, &appErr) || appErr.Code != models.CodeCloudUnavailable {
+		t.Fatalf("WakeEnvironment() of a failed runtime error = %v, want %s", err, models.CodeCloudUnavailable)
+	}
+	if ctx.Err() != nil {
+		t.Error("WakeEnvironment() waited for the timeout")
+	}
+}
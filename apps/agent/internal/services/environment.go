@@ -2,85 +2,287 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/azure"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/lock"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/store"
 )
 
 // EnvironmentService handles environment lifecycle operations
 type EnvironmentService struct {
-	config         *config.Config
-	azureClient    *azure.Client
-	storageClients map[string]*azure.StorageClient
+	config      *config.Config
+	providers   *provider.Registry
+	operations  *OperationManager
+	locks       lock.Locker // One lifecycle operation per workspace at a time
+	idempotency *IdempotencyManager
+	idle        *IdlePolicy
+	tokens      *SupervisorTokens
+	quotas      *QuotaManager
+	placer      *RegionPlacer
+	previews    *Previews
+	gateway     *Gateway
+	bastionKey  string       // authorized_keys line of the SSH bastion, added to every workspace
+	store       *store.Store // nil when the agent runs stateless
+
+	// dial opens TCP connections to workspaces, e.g. to check sshd is up
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
 }
 
 // NewEnvironmentService creates a new environment service
-func NewEnvironmentService(cfg *config.Config, azureClient *azure.Client) (*EnvironmentService, error) {
-	// No database requirement - Agent is stateless
+func NewEnvironmentService(cfg *config.Config, providers *provider.Registry) (*EnvironmentService, error) {
+	if providers == nil {
+		return nil, fmt.Errorf("compute provider registry is required")
+	}
+
 	service := &EnvironmentService{
-		config:         cfg,
-		azureClient:    azureClient,
-		storageClients: make(map[string]*azure.StorageClient),
+		config:     cfg,
+		providers:  providers,
+		operations: NewOperationManager(cfg.OperationTimeout, cfg.OperationRetention),
+		locks:      lock.NewLocalLocker(),
+		dial:       (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+	}
+	service.idempotency = NewIdempotencyManager(service.operations, cfg.IdempotencyTTL)
+	service.idle = NewIdlePolicy(service, cfg.Idle)
+	service.quotas = NewQuotaManager(service, cfg.Quota)
+	service.placer = NewRegionPlacer(service)
+
+	tokens, err := NewSupervisorTokens(cfg.SupervisorToken)
+	if err != nil {
+		return nil, err
+	}
+	service.tokens = tokens
+
+	previews, err := NewPreviews(cfg.Preview)
+	if err != nil {
+		return nil, err
+	}
+	service.previews = previews
+
+	gateway, err := NewGateway(cfg.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	service.gateway = gateway
+
+	// No database requirement - the embedded state store is optional
+	if cfg.StatePath != "" {
+		states, err := store.Open(cfg.StatePath)
+		if err != nil {
+			return nil, err
+		}
+		service.store = states
+		service.tokens.store = states
 	}
 
-	// Initialize storage clients for all regions
+	// Quota usage is accounted from the recorded workspaces
+	if cfg.Quota.Enabled() && service.store == nil {
+		return nil, fmt.Errorf("quotas require the state store - set AGENT_STATE_PATH")
+	}
+	// The preview proxy and gateway find workspaces in the state store
+	if cfg.Preview.Enabled() && service.store == nil {
+		return nil, fmt.Errorf("PREVIEW_DOMAIN requires the state store - set AGENT_STATE_PATH")
+	}
+	if cfg.Gateway.Enabled() && service.store == nil {
+		return nil, fmt.Errorf("GATEWAY_DOMAIN requires the state store - set AGENT_STATE_PATH")
+	}
 	for _, region := range cfg.Azure.Regions {
-		if region.Enabled && region.StorageAccount != "" {
-			storageClient, err := azure.NewStorageClient(region.StorageAccount, cfg.Azure.StorageAccountKey)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create storage client for region %s: %w", region.Name, err)
-			}
-			service.storageClients[region.Name] = storageClient
+		if region.MaxCores > 0 && service.store == nil {
+			return nil, fmt.Errorf("AZURE_REGION_MAX_CORES requires the state store - set AGENT_STATE_PATH")
 		}
 	}
 
 	return service, nil
 }
 
+// Operations returns the manager tracking asynchronous lifecycle operations
+func (s *EnvironmentService) Operations() *OperationManager {
+	return s.operations
+}
+
+// SupervisorTokens returns the issuer of per-workspace supervisor credentials
+func (s *EnvironmentService) SupervisorTokens() *SupervisorTokens {
+	return s.tokens
+}
+
+// Idempotency returns the record of operations started with an Idempotency-Key
+func (s *EnvironmentService) Idempotency() *IdempotencyManager {
+	return s.idempotency
+}
+
+// Quotas returns the per-user and per-organisation quota manager
+func (s *EnvironmentService) Quotas() *QuotaManager {
+	return s.quotas
+}
+
+// SetLocker replaces the in-process workspace locker, e.g. with lease-based
+// locks shared by every agent replica. Call it before serving requests.
+func (s *EnvironmentService) SetLocker(locker lock.Locker) {
+	s.locks = locker
+}
+
+// Previews returns the preview URL builder and signer
+func (s *EnvironmentService) Previews() *Previews {
+	return s.previews
+}
+
+// Gateway returns the workspace gateway URL builder and signer
+func (s *EnvironmentService) Gateway() *Gateway {
+	return s.gateway
+}
+
+// Idle returns the idle auto-stop policy
+func (s *EnvironmentService) Idle() *IdlePolicy {
+	return s.idle
+}
+
 // Close releases service resources.
 func (s *EnvironmentService) Close() {
-	// Nothing to close - stateless!
+	if s.store != nil {
+		if err := s.store.Close(); err != nil {
+			log.Printf("Warning: failed to close state store: %v", err)
+		}
+	}
 }
 
 // CreateEnvironment creates a new cloud development environment
 func (s *EnvironmentService) CreateEnvironment(ctx context.Context, req *models.CreateEnvironmentRequest) (*models.Environment, error) {
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationCreate)
+	if err != nil {
+		return nil, err
+	}
+	defer held.Unlock()
+
+	computeProvider, err := s.prepareCreate(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := held.Context(ctx)
+	defer cancel()
+	env, err := s.createEnvironment(ctx, computeProvider, req)
+	return env, lockLost(ctx, err)
+}
+
+// CreateEnvironmentAsync validates the request and creates the environment in the background
+func (s *EnvironmentService) CreateEnvironmentAsync(ctx context.Context, req *models.CreateEnvironmentRequest) (*models.Operation, error) {
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	computeProvider, err := s.prepareCreate(req)
+	if err != nil {
+		held.Unlock()
+		return nil, err
+	}
+	return s.operations.Start(models.OperationCreate, req.WorkspaceID, req.UserID, func(ctx context.Context) (*models.Environment, error) {
+		defer held.Unlock()
+		ctx, cancel := held.Context(ctx)
+		defer cancel()
+		env, err := s.createEnvironment(ctx, computeProvider, req)
+		return env, lockLost(ctx, err)
+	}), nil
+}
+
+// prepareCreate validates a create request and resolves its compute provider
+func (s *EnvironmentService) prepareCreate(req *models.CreateEnvironmentRequest) (computeProvider provider.ComputeProvider, err error) {
 	// CRITICAL: workspaceId (UUID) comes from Next.js (already created in DB)
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.checkAppPorts(req.Ports); err != nil {
+		return nil, err
+	}
+
+	// Without a password code-server falls back to a public default
+	if req.CodeServerPassword == "" {
+		if req.CodeServerPassword, err = newCodeServerPassword(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve compute provider
+	computeProvider, err = s.providers.Get(req.CloudProvider)
+	if err != nil {
+		return nil, err
+	}
 
-	// Validate region
-	regionConfig := s.config.GetRegion(req.CloudRegion)
-	if regionConfig == nil {
+	// Pick or validate the region; createEnvironment releases an automatic placement
+	if req.CloudRegion == models.RegionAuto {
+		region, err := s.placer.Place(computeProvider, req)
+		if err != nil {
+			return nil, err
+		}
+		req.CloudRegion = region
+		defer func() {
+			if err != nil {
+				s.placer.Release(req.WorkspaceID)
+			}
+		}()
+	}
+	if !computeProvider.HasRegion(req.CloudRegion) {
 		return nil, models.ErrInvalidRequest(fmt.Sprintf("region %s is not available", req.CloudRegion))
 	}
+	if err := checkRegionHealth(computeProvider, req.CloudRegion); err != nil {
+		return nil, err
+	}
 
-	// Get storage client for region
-	storageClient, ok := s.storageClients[req.CloudRegion]
-	if !ok {
-		return nil, models.ErrInternalServer(fmt.Sprintf("storage client not found for region %s", req.CloudRegion))
+	// Check quotas last: createEnvironment releases the reservation
+	err = s.quotas.Reserve(&models.Environment{
+		ID:        req.WorkspaceID,
+		UserID:    req.UserID,
+		OrgID:     req.OrgID,
+		CPUCores:  req.CPUCores,
+		MemoryGB:  req.MemoryGB,
+		StorageGB: req.StorageGB,
+	}, true)
+	if err != nil {
+		return nil, err
 	}
 
-	// IMPORTANT: Use workspaceId for all Azure resource names
+	return computeProvider, nil
+}
+
+func (s *EnvironmentService) createEnvironment(ctx context.Context, computeProvider provider.ComputeProvider, req *models.CreateEnvironmentRequest) (*models.Environment, error) {
+	// IMPORTANT: Use workspaceId for all resource names
 	workspaceID := req.WorkspaceID // UUID from database (e.g., "clxxx-yyyy-zzzz")
+	defer s.quotas.Release(workspaceID)
+	defer s.placer.Release(workspaceID)
 
-	log.Printf("🚀 Creating workspace %s (region: %s)", workspaceID, req.CloudRegion)
+	log.Printf("🚀 Creating workspace %s (provider: %s, region: %s)", workspaceID, computeProvider.Name(), req.CloudRegion)
 	overallStartTime := time.Now()
 
-	// Azure resource names based on UUID
+	s.saveEnvironment(&models.Environment{
+		ID:            workspaceID,
+		Name:          req.Name,
+		UserID:        req.UserID,
+		OrgID:         req.OrgID,
+		Status:        models.StatusCreating,
+		CloudProvider: s.cloudProvider(req.CloudProvider),
+		CloudRegion:   req.CloudRegion,
+		CPUCores:      req.CPUCores,
+		MemoryGB:      req.MemoryGB,
+		StorageGB:     req.StorageGB,
+		BaseImage:     req.BaseImage,
+		CreatedAt:     overallStartTime,
+		UpdatedAt:     overallStartTime,
+	})
+
+	// Resource names based on UUID
 	fileShareName := fmt.Sprintf("fs-%s", workspaceID)       // fs-clxxx-yyyy-zzzz (unified volume)
 	containerGroupName := fmt.Sprintf("aci-%s", workspaceID) // aci-clxxx-yyyy-zzzz
 	dnsLabel := fmt.Sprintf("ws-%s", workspaceID)            // ws-clxxx-yyyy-zzzz
 
-	resourceGroup := regionConfig.ResourceGroupName
-	if resourceGroup == "" {
-		resourceGroup = s.config.Azure.ResourceGroupName
-	}
-
 	// Log image source
 	containerImage := s.getContainerImage(req.BaseImage)
 	if s.config.Azure.ContainerRegistry != "" {
@@ -101,38 +303,47 @@ func (s *EnvironmentService) CreateEnvironment(ctx context.Context, req *models.
 
 	volumeChan := make(chan operationResult, 1)
 	aciChan := make(chan operationResult, 1)
+	volumeReady := make(chan error, 1) // Tells the container goroutine the share exists (nil) or failed
 
 	// Goroutine 1: Create unified file share (includes workspace + home subdirectories)
 	go func() {
 		totalQuotaGB := int32(req.StorageGB + 5) // workspace quota + 5GB for home
 		log.Printf("📁 [1/2] Creating unified volume: %s (%dGB) - contains workspace/ and home/", fileShareName, totalQuotaGB)
-		err := storageClient.CreateFileShare(ctx, fileShareName, totalQuotaGB)
+		reportStep(ctx, models.StepVolumeCreated, models.StepRunning, "")
+		err := computeProvider.CreateVolume(ctx, req.CloudRegion, fileShareName, totalQuotaGB)
+		if err == nil {
+			reportStep(ctx, models.StepVolumeCreated, models.StepDone, fileShareName)
+		}
+		volumeReady <- err
 		volumeChan <- operationResult{name: "unified-volume", err: err}
 	}()
 
-	// Goroutine 2: Create ACI container (starts IMMEDIATELY, doesn't wait for share)
+	// Goroutine 2: Create ACI container as soon as the share it mounts exists
 	go func() {
-		// Small delay to let share start first (Azure may need it)
-		time.Sleep(500 * time.Millisecond)
+		if err := <-volumeReady; err != nil {
+			reportStep(ctx, models.StepContainerCreated, models.StepSkipped, "unified volume was not created")
+			aciChan <- operationResult{name: "aci-container", err: fmt.Errorf("skipped: unified volume was not created")}
+			return
+		}
 
-		containerSpec := azure.ContainerGroupSpec{
+		containerSpec := provider.ContainerGroupSpec{
 			ContainerName:      "vscode-server",
 			Image:              containerImage,
 			CPUCores:           req.CPUCores,
 			MemoryGB:           req.MemoryGB,
 			DNSNameLabel:       dnsLabel,
 			FileShareName:      fileShareName,
-			StorageAccountName: regionConfig.StorageAccount,
-			StorageAccountKey:  s.config.Azure.StorageAccountKey,
 			EnvironmentID:      workspaceID,
 			UserID:             req.UserID,
+			AppPorts:           req.Ports,
 			RegistryServer:     s.getRegistryServer(),
 			RegistryUsername:   s.config.RegistryUsername,
 			RegistryPassword:   s.config.RegistryPassword,
 			AgentBaseURL:       s.config.AgentBaseURL,
+			SupervisorToken:    s.tokens.Mint(workspaceID),
 			GitHubToken:        req.GitHubToken,
 			CodeServerPassword: req.CodeServerPassword,
-			SSHPublicKey:       req.SSHPublicKey,
+			SSHPublicKey:       s.authorizedKeys(req.SSHPublicKey),
 			GitUserName:        req.GitUserName,
 			GitUserEmail:       req.GitUserEmail,
 			AnthropicAPIKey:    req.AnthropicAPIKey,
@@ -140,8 +351,12 @@ func (s *EnvironmentService) CreateEnvironment(ctx context.Context, req *models.
 			GeminiAPIKey:       req.GeminiAPIKey,
 		}
 
-		log.Printf("📦 [2/2] Creating ACI container: %s", containerGroupName)
-		err := s.azureClient.CreateContainerGroup(ctx, req.CloudRegion, resourceGroup, containerGroupName, containerSpec)
+		log.Printf("📦 [2/2] Creating container: %s", containerGroupName)
+		reportStep(ctx, models.StepContainerCreated, models.StepRunning, "")
+		err := computeProvider.CreateRuntime(ctx, req.CloudRegion, containerGroupName, containerSpec)
+		if err == nil {
+			reportStep(ctx, models.StepContainerCreated, models.StepDone, containerGroupName)
+		}
 		aciChan <- operationResult{name: "aci-container", err: err}
 	}()
 
@@ -155,63 +370,86 @@ func (s *EnvironmentService) CreateEnvironment(ctx context.Context, req *models.
 	// Check for errors (cleanup on failure)
 	if volumeResult.err != nil {
 		// Try to cleanup what succeeded
-		_ = s.azureClient.DeleteContainerGroup(ctx, req.CloudRegion, resourceGroup, containerGroupName)
-		return nil, fmt.Errorf("failed to create unified file share: %w", volumeResult.err)
+		_ = computeProvider.DeleteRuntime(ctx, req.CloudRegion, containerGroupName)
+		s.setStatus(workspaceID, models.StatusError)
+		return nil, providerError("failed to create unified file share", volumeResult.err)
 	}
 	if aciResult.err != nil {
-		// Cleanup file share
-		_ = storageClient.DeleteFileShare(ctx, fileShareName)
-		return nil, fmt.Errorf("failed to create container group: %w", aciResult.err)
+		// A create that timed out may still have left the runtime behind
+		_ = computeProvider.DeleteRuntime(ctx, req.CloudRegion, containerGroupName)
+		_ = computeProvider.DeleteVolume(ctx, req.CloudRegion, fileShareName)
+		s.setStatus(workspaceID, models.StatusError)
+		return nil, providerError("failed to create container group", aciResult.err)
 	}
 
-	// Wait for container to get FQDN
-	time.Sleep(3 * time.Second)
-
-	// Get container details
-	containerDetails, err := s.azureClient.GetContainerGroup(ctx, req.CloudRegion, resourceGroup, containerGroupName)
-	if err != nil {
-		log.Printf("Warning: failed to get container details: %v", err)
+	// Wait until the container runs with an FQDN and serves the IDE and supervisor
+	ready := s.awaitReady(ctx, computeProvider, req.CloudRegion, containerGroupName)
+	if ready.status == models.StatusError {
+		// Nothing of a new workspace is worth keeping
+		if s.discardFailedRuntime(ctx, computeProvider, req.CloudRegion, containerGroupName) {
+			ready.runtime = nil
+		}
+		_ = computeProvider.DeleteVolume(ctx, req.CloudRegion, fileShareName)
 	}
+	runtime := ready.runtime
 
-	// Extract FQDN (will be ws-{workspaceId}.{region}.azurecontainer.io)
-	var fqdn string
-	if containerDetails != nil &&
-		containerDetails.Properties != nil &&
-		containerDetails.Properties.IPAddress != nil &&
-		containerDetails.Properties.IPAddress.Fqdn != nil {
-		fqdn = *containerDetails.Properties.IPAddress.Fqdn
+	// Extract FQDN (will be ws-{workspaceId}.{region}.azurecontainer.io on ACI)
+	var fqdn, resourceGroup string
+	if runtime != nil {
+		fqdn = runtime.FQDN
+		resourceGroup = runtime.ResourceGroup
 	}
 
 	// Generate connection URLs (all contain UUID via FQDN)
-	connectionURLs := generateConnectionURLs(fqdn, "")
+	connectionURLs := generateConnectionURLs(runtime, req.CodeServerPassword, req.Ports)
+	connectionURLs.Previews = s.previews.URLs(workspaceID, req.Ports, req.PublicPorts)
+	connectionURLs.GatewayURL = s.gateway.URL(workspaceID)
+	s.routeSSHThroughBastion(&connectionURLs, workspaceID)
 
 	// Build environment response
 	env := &models.Environment{
-		ID:          workspaceID, // CRITICAL: Return the UUID from request
-		Name:        req.Name,
-		UserID:      req.UserID,
-		Status:      "running",
-		CloudRegion: req.CloudRegion,
-		CPUCores:    req.CPUCores,
-		MemoryGB:    req.MemoryGB,
-		StorageGB:   req.StorageGB,
-		BaseImage:   req.BaseImage,
-
-		// Azure resource identifiers (all based on UUID)
+		ID:            workspaceID, // CRITICAL: Return the UUID from request
+		Name:          req.Name,
+		UserID:        req.UserID,
+		Status:        ready.status,
+		StatusReason:  ready.reason,
+		CloudProvider: s.cloudProvider(req.CloudProvider),
+		CloudRegion:   req.CloudRegion,
+		CPUCores:      req.CPUCores,
+		MemoryGB:      req.MemoryGB,
+		StorageGB:     req.StorageGB,
+		BaseImage:     req.BaseImage,
+		Ports:         req.Ports,
+		PublicPorts:   req.PublicPorts,
+
+		// Resource identifiers (all based on UUID)
 		AzureResourceGroup:  resourceGroup,
 		AzureContainerGroup: containerGroupName, // aci-clxxx-yyyy-zzzz
 		AzureFileShare:      fileShareName,      // fs-clxxx-yyyy-zzzz
 		AzureFQDN:           fqdn,               // ws-clxxx-yyyy-zzzz.eastus.azurecontainer.io
+		SSHAddress:          sshAddress(runtime),
 
 		// Connection URLs (contain UUID)
 		ConnectionURLs: connectionURLs,
 
+		IdleTimeoutMinutes: req.IdleTimeoutMinutes,
+
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
+	s.saveEnvironment(env)
+	if ready.status == models.StatusError {
+		return nil, models.ErrInternalServer(fmt.Sprintf("workspace %s failed to start: %s", workspaceID, ready.reason))
+	}
+	s.idle.Track(env)
+
 	totalDuration := time.Since(overallStartTime)
-	log.Printf("⚡⚡⚡ WORKSPACE READY in %s (all operations ran concurrently!)", totalDuration)
+	if ready.status == models.StatusRunning {
+		log.Printf("⚡⚡⚡ WORKSPACE READY in %s (all operations ran concurrently!)", totalDuration)
+	} else {
+		log.Printf("⏳ Workspace %s created in %s but still starting: %s", workspaceID, totalDuration, ready.reason)
+	}
 	log.Printf("✅ Workspace %s: %s", workspaceID, fqdn)
 
 	// ❌ NO DATABASE OPERATIONS - Next.js will update the workspace with these details
@@ -220,33 +458,60 @@ func (s *EnvironmentService) CreateEnvironment(ctx context.Context, req *models.
 
 // StartEnvironment recreates container with existing volumes (fast restart)
 func (s *EnvironmentService) StartEnvironment(ctx context.Context, req *models.StartEnvironmentRequest) (*models.Environment, error) {
-	// Validate region
-	regionConfig := s.config.GetRegion(req.CloudRegion)
-	if regionConfig == nil {
-		return nil, models.ErrNotFound(fmt.Sprintf("region %s is not available", req.CloudRegion))
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationStart)
+	if err != nil {
+		return nil, err
 	}
+	defer held.Unlock()
 
-	storageClient, ok := s.storageClients[req.CloudRegion]
-	if !ok {
-		return nil, models.ErrInternalServer(fmt.Sprintf("storage client not found for region %s", req.CloudRegion))
+	computeProvider, err := s.prepareStart(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := held.Context(ctx)
+	defer cancel()
+	env, err := s.startEnvironment(ctx, computeProvider, req)
+	return env, lockLost(ctx, err)
+}
+
+// StartEnvironmentAsync checks the workspace can be started and starts it in the background
+func (s *EnvironmentService) StartEnvironmentAsync(ctx context.Context, req *models.StartEnvironmentRequest) (*models.Operation, error) {
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationStart)
+	if err != nil {
+		return nil, err
+	}
+
+	computeProvider, err := s.prepareStart(ctx, req)
+	if err != nil {
+		held.Unlock()
+		return nil, err
+	}
+	return s.operations.Start(models.OperationStart, req.WorkspaceID, req.UserID, func(ctx context.Context) (*models.Environment, error) {
+		defer held.Unlock()
+		ctx, cancel := held.Context(ctx)
+		defer cancel()
+		env, err := s.startEnvironment(ctx, computeProvider, req)
+		return env, lockLost(ctx, err)
+	}), nil
+}
+
+// prepareStart verifies the volume exists and no container is running
+func (s *EnvironmentService) prepareStart(ctx context.Context, req *models.StartEnvironmentRequest) (provider.ComputeProvider, error) {
+	computeProvider, err := s.resolveProvider(req.CloudProvider, req.CloudRegion)
+	if err != nil {
+		return nil, err
 	}
 
 	workspaceID := req.WorkspaceID
 	fileShareName := fmt.Sprintf("fs-%s", workspaceID)
 	containerGroupName := fmt.Sprintf("aci-%s", workspaceID)
-	dnsLabel := fmt.Sprintf("ws-%s", workspaceID)
-
-	resourceGroup := regionConfig.ResourceGroupName
-	if resourceGroup == "" {
-		resourceGroup = s.config.Azure.ResourceGroupName
-	}
 
 	log.Printf("🚀 Starting workspace %s (checking volume...)", workspaceID)
 
 	// Verify unified volume exists
-	volumeExists, err := storageClient.FileShareExists(ctx, fileShareName)
+	volumeExists, err := computeProvider.VolumeExists(ctx, req.CloudRegion, fileShareName)
 	if err != nil {
-		return nil, models.ErrInternalServer(fmt.Sprintf("failed to check volume: %v", err))
+		return nil, providerError("failed to check volume", err)
 	}
 	if !volumeExists {
 		return nil, models.ErrNotFound(fmt.Sprintf("unified volume not found: %s. Create environment first.", fileShareName))
@@ -255,38 +520,92 @@ func (s *EnvironmentService) StartEnvironment(ctx context.Context, req *models.S
 	log.Printf("✅ Unified volume verified: %s", fileShareName)
 
 	// Check if container already exists
-	existingContainer, err := s.azureClient.GetContainerGroup(ctx, req.CloudRegion, resourceGroup, containerGroupName)
+	existingContainer, err := computeProvider.GetRuntime(ctx, req.CloudRegion, containerGroupName)
 	if err == nil && existingContainer != nil {
 		return nil, models.ErrInvalidRequest(fmt.Sprintf("container already exists for workspace %s. Use stop first if needed.", workspaceID))
 	}
 
+	// Reopen the ports the workspace was created with unless the request changes them
+	if req.Ports == nil {
+		req.Ports, req.PublicPorts = s.recordedPorts(workspaceID)
+	}
+	if err := s.checkAppPorts(req.Ports); err != nil {
+		return nil, err
+	}
+
+	// Keep the workspace's password unless the request changes it; without
+	// one code-server falls back to a public default
+	if req.CodeServerPassword == "" {
+		req.CodeServerPassword = s.recordedCodeServerPassword(workspaceID)
+	}
+	if req.CodeServerPassword == "" {
+		if req.CodeServerPassword, err = newCodeServerPassword(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep the workspace's idle timeout override unless the request changes it
+	if req.IdleTimeoutMinutes == nil {
+		req.IdleTimeoutMinutes = s.recordedIdleTimeout(workspaceID)
+	}
+
+	// Check quotas last: startEnvironment releases the reservation
+	if req.OrgID == "" {
+		req.OrgID = s.recordedOrgID(workspaceID)
+	}
+	err = s.quotas.Reserve(&models.Environment{
+		ID:        workspaceID,
+		UserID:    req.UserID,
+		OrgID:     req.OrgID,
+		CPUCores:  req.CPUCores,
+		MemoryGB:  req.MemoryGB,
+		StorageGB: req.StorageGB,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeProvider, nil
+}
+
+func (s *EnvironmentService) startEnvironment(ctx context.Context, computeProvider provider.ComputeProvider, req *models.StartEnvironmentRequest) (*models.Environment, error) {
+	workspaceID := req.WorkspaceID
+	fileShareName := fmt.Sprintf("fs-%s", workspaceID)
+	containerGroupName := fmt.Sprintf("aci-%s", workspaceID)
+	dnsLabel := fmt.Sprintf("ws-%s", workspaceID)
+	defer s.quotas.Release(workspaceID)
+
+	// prepareStart has already checked the volume
+	reportStep(ctx, models.StepVolumeVerified, models.StepDone, fileShareName)
+	s.setStatus(workspaceID, models.StatusStarting)
+
 	// Recreate container with existing volumes (fast!)
 	log.Printf("📦 Creating new container instance with existing volumes...")
 
-	containerSpec := azure.ContainerGroupSpec{
-		ContainerName:      "vscode-server",
-		Image:              s.getContainerImage(req.BaseImage),
-		CPUCores:           req.CPUCores,
-		MemoryGB:           req.MemoryGB,
-		DNSNameLabel:       dnsLabel,
-		FileShareName:      fileShareName,
-		StorageAccountName: regionConfig.StorageAccount,
-		StorageAccountKey:  s.config.Azure.StorageAccountKey,
-		EnvironmentID:      workspaceID,
-		UserID:             req.UserID,
+	containerSpec := provider.ContainerGroupSpec{
+		ContainerName: "vscode-server",
+		Image:         s.getContainerImage(req.BaseImage),
+		CPUCores:      req.CPUCores,
+		MemoryGB:      req.MemoryGB,
+		DNSNameLabel:  dnsLabel,
+		FileShareName: fileShareName,
+		EnvironmentID: workspaceID,
+		UserID:        req.UserID,
+		AppPorts:      req.Ports,
 
 		// Registry credentials
 		RegistryServer:   s.getRegistryServer(),
 		RegistryUsername: s.config.RegistryUsername,
 		RegistryPassword: s.config.RegistryPassword,
 
-		// Agent URL
-		AgentBaseURL: s.config.AgentBaseURL,
+		// Agent URL and a fresh supervisor token (revokes the previous one)
+		AgentBaseURL:    s.config.AgentBaseURL,
+		SupervisorToken: s.tokens.Mint(workspaceID),
 
 		// Per-workspace secrets
 		GitHubToken:        req.GitHubToken,
 		CodeServerPassword: req.CodeServerPassword,
-		SSHPublicKey:       req.SSHPublicKey,
+		SSHPublicKey:       s.authorizedKeys(req.SSHPublicKey),
 		GitUserName:        req.GitUserName,
 		GitUserEmail:       req.GitUserEmail,
 		AnthropicAPIKey:    req.AnthropicAPIKey,
@@ -294,164 +613,445 @@ func (s *EnvironmentService) StartEnvironment(ctx context.Context, req *models.S
 		GeminiAPIKey:       req.GeminiAPIKey,
 	}
 
-	if err := s.azureClient.CreateContainerGroup(ctx, req.CloudRegion, resourceGroup, containerGroupName, containerSpec); err != nil {
-		return nil, models.ErrInternalServer(fmt.Sprintf("failed to create container group: %v", err))
+	reportStep(ctx, models.StepContainerCreated, models.StepRunning, "")
+	if err := computeProvider.CreateRuntime(ctx, req.CloudRegion, containerGroupName, containerSpec); err != nil {
+		s.setStatus(workspaceID, models.StatusError)
+		return nil, providerError("failed to create container group", err)
 	}
-
-	// Wait for FQDN
-	time.Sleep(3 * time.Second)
-
-	containerDetails, err := s.azureClient.GetContainerGroup(ctx, req.CloudRegion, resourceGroup, containerGroupName)
-	if err != nil {
-		log.Printf("Warning: failed to get container details: %v", err)
+	reportStep(ctx, models.StepContainerCreated, models.StepDone, containerGroupName)
+
+	// Wait until the container runs with an FQDN and serves the IDE and supervisor
+	ready := s.awaitReady(ctx, computeProvider, req.CloudRegion, containerGroupName)
+	if ready.status == models.StatusError {
+		// The volume holds the user's files and is kept for the next start
+		if s.discardFailedRuntime(ctx, computeProvider, req.CloudRegion, containerGroupName) {
+			ready.runtime = nil
+		}
 	}
+	runtime := ready.runtime
 
-	var fqdn string
-	if containerDetails != nil &&
-		containerDetails.Properties != nil &&
-		containerDetails.Properties.IPAddress != nil &&
-		containerDetails.Properties.IPAddress.Fqdn != nil {
-		fqdn = *containerDetails.Properties.IPAddress.Fqdn
+	var fqdn, resourceGroup string
+	if runtime != nil {
+		fqdn = runtime.FQDN
+		resourceGroup = runtime.ResourceGroup
 	}
 
-	connectionURLs := generateConnectionURLs(fqdn, req.CodeServerPassword)
+	connectionURLs := generateConnectionURLs(runtime, req.CodeServerPassword, req.Ports)
+	connectionURLs.Previews = s.previews.URLs(workspaceID, req.Ports, req.PublicPorts)
+	connectionURLs.GatewayURL = s.gateway.URL(workspaceID)
+	s.routeSSHThroughBastion(&connectionURLs, workspaceID)
 
 	env := &models.Environment{
 		ID:                  workspaceID,
 		Name:                req.Name,
 		UserID:              req.UserID,
-		Status:              models.StatusRunning,
+		OrgID:               req.OrgID,
+		Status:              ready.status,
+		StatusReason:        ready.reason,
+		CloudProvider:       s.cloudProvider(req.CloudProvider),
 		CloudRegion:         req.CloudRegion,
 		CPUCores:            req.CPUCores,
 		MemoryGB:            req.MemoryGB,
 		StorageGB:           req.StorageGB,
 		BaseImage:           req.BaseImage,
+		Ports:               req.Ports,
+		PublicPorts:         req.PublicPorts,
 		AzureResourceGroup:  resourceGroup,
 		AzureContainerGroup: containerGroupName,
 		AzureFileShare:      fileShareName,
 		AzureFQDN:           fqdn,
+		SSHAddress:          sshAddress(runtime),
 		ConnectionURLs:      connectionURLs,
+		IdleTimeoutMinutes:  req.IdleTimeoutMinutes,
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 	}
 
+	s.saveEnvironment(env)
+	if ready.status == models.StatusError {
+		return nil, models.ErrInternalServer(fmt.Sprintf("workspace %s failed to start: %s", workspaceID, ready.reason))
+	}
+	s.idle.Track(env)
+
+	if ready.status != models.StatusRunning {
+		log.Printf("⏳ Workspace %s started but still starting: %s", workspaceID, ready.reason)
+		return env, nil
+	}
 	log.Printf("✅ Workspace %s started successfully (reused existing unified volume)", workspaceID)
 	return env, nil
 }
 
-// StopEnvironment deletes ACI instance but KEEPS volumes (cost optimization)
-func (s *EnvironmentService) StopEnvironment(ctx context.Context, workspaceID, region string) error {
-	regionConfig := s.config.GetRegion(region)
-	if regionConfig == nil {
-		return models.ErrNotFound(fmt.Sprintf("region %s is not available", region))
+// discardFailedRuntime deletes a runtime that failed to become ready, so an
+// ERROR workspace does not keep a billed runtime around, and reports whether
+// it is gone. One that cannot be deleted is left to the reconciler.
+func (s *EnvironmentService) discardFailedRuntime(ctx context.Context, computeProvider provider.ComputeProvider, region, name string) bool {
+	if err := computeProvider.DeleteRuntime(ctx, region, name); err != nil && !errors.Is(err, provider.ErrRuntimeNotFound) {
+		log.Printf("Warning: failed to delete failed runtime %s: %v", name, err)
+		return false
 	}
+	log.Printf("🗑️  Deleted failed runtime %s", name)
+	return true
+}
 
-	resourceGroup := regionConfig.ResourceGroupName
-	if resourceGroup == "" {
-		resourceGroup = s.config.Azure.ResourceGroupName
+// StopEnvironment deletes the container instance but KEEPS volumes (cost optimization)
+func (s *EnvironmentService) StopEnvironment(ctx context.Context, req *models.StopEnvironmentRequest) error {
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationStop)
+	if err != nil {
+		return err
 	}
+	defer held.Unlock()
 
-	containerGroupName := fmt.Sprintf("aci-%s", workspaceID)
+	computeProvider, err := s.prepareStop(ctx, req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := held.Context(ctx)
+	defer cancel()
+	return lockLost(ctx, s.stopEnvironment(ctx, computeProvider, req))
+}
 
-	log.Printf("🛑 Stopping workspace %s: DELETING container (keeping volumes)", workspaceID)
+// StopEnvironmentAsync checks the workspace is running and stops it in the background
+func (s *EnvironmentService) StopEnvironmentAsync(ctx context.Context, req *models.StopEnvironmentRequest) (*models.Operation, error) {
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationStop)
+	if err != nil {
+		return nil, err
+	}
 
-	// Check if container exists
-	_, err := s.azureClient.GetContainerGroup(ctx, region, resourceGroup, containerGroupName)
+	computeProvider, err := s.prepareStop(ctx, req)
+	if err != nil {
+		held.Unlock()
+		return nil, err
+	}
+	owner, err := s.WorkspaceOwner(ctx, req.CloudProvider, req.CloudRegion, req.WorkspaceID)
 	if err != nil {
-		return models.ErrNotFound(fmt.Sprintf("container not found for workspace %s. Already stopped?", workspaceID))
+		held.Unlock()
+		return nil, err
 	}
+	return s.operations.Start(models.OperationStop, req.WorkspaceID, owner, func(ctx context.Context) (*models.Environment, error) {
+		defer held.Unlock()
+		ctx, cancel := held.Context(ctx)
+		defer cancel()
+		return nil, lockLost(ctx, s.stopEnvironment(ctx, computeProvider, req))
+	}), nil
+}
+
+// prepareStop verifies the workspace container exists
+func (s *EnvironmentService) prepareStop(ctx context.Context, req *models.StopEnvironmentRequest) (provider.ComputeProvider, error) {
+	workspaceID, region := req.WorkspaceID, req.CloudRegion
+
+	computeProvider, err := s.resolveProvider(req.CloudProvider, region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if container exists; only a missing one means the workspace is stopped
+	if _, err := computeProvider.GetRuntime(ctx, region, fmt.Sprintf("aci-%s", workspaceID)); err != nil {
+		if errors.Is(err, provider.ErrRuntimeNotFound) {
+			return nil, models.ErrNotFound(fmt.Sprintf("container not found for workspace %s. Already stopped?", workspaceID))
+		}
+		return nil, providerError("failed to check container group", err)
+	}
+
+	return computeProvider, nil
+}
+
+func (s *EnvironmentService) stopEnvironment(ctx context.Context, computeProvider provider.ComputeProvider, req *models.StopEnvironmentRequest) error {
+	workspaceID, region := req.WorkspaceID, req.CloudRegion
+	containerGroupName := fmt.Sprintf("aci-%s", workspaceID)
+
+	log.Printf("🛑 Stopping workspace %s: DELETING container (keeping volumes)", workspaceID)
 
 	// DELETE container instance (not stop) - saves 95% of running costs
-	if err := s.azureClient.DeleteContainerGroup(ctx, region, resourceGroup, containerGroupName); err != nil {
-		return models.ErrInternalServer(fmt.Sprintf("failed to delete container group: %v", err))
+	s.setStatus(workspaceID, models.StatusStopping)
+	reportStep(ctx, models.StepContainerDeleted, models.StepRunning, "")
+	if err := computeProvider.DeleteRuntime(ctx, region, containerGroupName); err != nil {
+		s.setStatus(workspaceID, models.StatusError)
+		return providerError("failed to delete container group", err)
 	}
+	reportStep(ctx, models.StepContainerDeleted, models.StepDone, containerGroupName)
+	s.idle.Forget(workspaceID)
 
-	log.Printf("✅ Workspace %s stopped (container deleted, unified volume persisted for fast restart)", workspaceID)
+	reason := req.Reason
+	if reason == "" {
+		reason = models.StopReasonRequested
+	}
+
+	// The FQDN and connection URLs die with the container
+	s.updateEnvironment(workspaceID, func(env *models.Environment) {
+		env.Status = models.StatusStopped
+		env.StatusReason = ""
+		env.StopReason = reason
+		env.AzureFQDN = ""
+		env.ConnectionURLs = models.ConnectionURLs{}
+	})
+
+	log.Printf("✅ Workspace %s stopped: %s (container deleted, unified volume persisted for fast restart)", workspaceID, reason)
 	return nil
 }
 
 // DeleteEnvironment permanently deletes environment and all resources
-func (s *EnvironmentService) DeleteEnvironment(ctx context.Context, workspaceID, region string, force bool) error {
-	regionConfig := s.config.GetRegion(region)
-	if regionConfig == nil {
-		return models.ErrNotFound(fmt.Sprintf("region %s is not available", region))
+func (s *EnvironmentService) DeleteEnvironment(ctx context.Context, req *models.DeleteEnvironmentRequest) error {
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationDelete)
+	if err != nil {
+		return err
 	}
+	defer held.Unlock()
 
-	resourceGroup := regionConfig.ResourceGroupName
-	if resourceGroup == "" {
-		resourceGroup = s.config.Azure.ResourceGroupName
+	computeProvider, running, err := s.prepareDelete(ctx, req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := held.Context(ctx)
+	defer cancel()
+	return lockLost(ctx, s.deleteEnvironment(ctx, computeProvider, req, running))
+}
+
+// DeleteEnvironmentAsync checks the workspace can be deleted and deletes it in the background
+func (s *EnvironmentService) DeleteEnvironmentAsync(ctx context.Context, req *models.DeleteEnvironmentRequest) (*models.Operation, error) {
+	held, err := s.lockWorkspace(ctx, req.WorkspaceID, models.OperationDelete)
+	if err != nil {
+		return nil, err
 	}
 
+	computeProvider, running, err := s.prepareDelete(ctx, req)
+	if err != nil {
+		held.Unlock()
+		return nil, err
+	}
+	owner, err := s.WorkspaceOwner(ctx, req.CloudProvider, req.CloudRegion, req.WorkspaceID)
+	if err != nil {
+		held.Unlock()
+		return nil, err
+	}
+	return s.operations.Start(models.OperationDelete, req.WorkspaceID, owner, func(ctx context.Context) (*models.Environment, error) {
+		defer held.Unlock()
+		ctx, cancel := held.Context(ctx)
+		defer cancel()
+		return nil, lockLost(ctx, s.deleteEnvironment(ctx, computeProvider, req, running))
+	}), nil
+}
+
+// prepareDelete refuses to delete a running workspace unless forced and reports whether it is running
+func (s *EnvironmentService) prepareDelete(ctx context.Context, req *models.DeleteEnvironmentRequest) (provider.ComputeProvider, bool, error) {
+	workspaceID, region := req.WorkspaceID, req.CloudRegion
+
+	computeProvider, err := s.resolveProvider(req.CloudProvider, region)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Check if container is running
+	container, err := computeProvider.GetRuntime(ctx, region, fmt.Sprintf("aci-%s", workspaceID))
+	running := err == nil && container != nil
+	if running && !req.Force {
+		return nil, false, models.ErrInvalidRequest(fmt.Sprintf("workspace %s is still running. Stop it first or use force=true", workspaceID))
+	}
+
+	return computeProvider, running, nil
+}
+
+func (s *EnvironmentService) deleteEnvironment(ctx context.Context, computeProvider provider.ComputeProvider, req *models.DeleteEnvironmentRequest, running bool) error {
+	workspaceID, region := req.WorkspaceID, req.CloudRegion
 	containerGroupName := fmt.Sprintf("aci-%s", workspaceID)
 	fileShareName := fmt.Sprintf("fs-%s", workspaceID)
 
 	log.Printf("🗑️  Deleting workspace %s permanently", workspaceID)
+	s.setStatus(workspaceID, models.StatusDeleting)
 
-	// Check if container is running
-	container, err := s.azureClient.GetContainerGroup(ctx, region, resourceGroup, containerGroupName)
-	if err == nil && container != nil {
-		if !force {
-			return models.ErrInvalidRequest(fmt.Sprintf("workspace %s is still running. Stop it first or use force=true", workspaceID))
-		}
+	if running {
 		// Force delete - stop container first
 		log.Printf("⚠️  Force deleting running container for workspace %s", workspaceID)
-		if err := s.azureClient.DeleteContainerGroup(ctx, region, resourceGroup, containerGroupName); err != nil {
+		reportStep(ctx, models.StepContainerDeleted, models.StepRunning, "")
+		if err := computeProvider.DeleteRuntime(ctx, region, containerGroupName); err != nil {
 			log.Printf("Warning: failed to delete container group %s: %v", containerGroupName, err)
+			reportStep(ctx, models.StepContainerDeleted, models.StepSkipped, fmt.Sprintf("failed to delete container group: %v", err))
+		} else {
+			reportStep(ctx, models.StepContainerDeleted, models.StepDone, containerGroupName)
 		}
+	} else {
+		reportStep(ctx, models.StepContainerDeleted, models.StepSkipped, "workspace was not running")
 	}
 
-	// Delete unified file share (permanent data loss!)
-	storageClient, ok := s.storageClients[region]
-	if !ok {
-		return models.ErrInternalServer(fmt.Sprintf("storage client not found for region %s", region))
-	}
-
-	// Delete unified volume (contains both workspace/ and home/ subdirectories)
-	if err := storageClient.DeleteFileShare(ctx, fileShareName); err != nil {
+	// Delete unified volume (permanent data loss!) - contains both workspace/ and home/ subdirectories
+	reportStep(ctx, models.StepVolumeDeleted, models.StepRunning, "")
+	if err := computeProvider.DeleteVolume(ctx, region, fileShareName); err != nil {
 		log.Printf("Warning: failed to delete unified file share %s: %v", fileShareName, err)
+		reportStep(ctx, models.StepVolumeDeleted, models.StepSkipped, fmt.Sprintf("failed to delete unified file share: %v", err))
 	} else {
 		log.Printf("✅ Deleted unified volume: %s (workspace + home)", fileShareName)
+		reportStep(ctx, models.StepVolumeDeleted, models.StepDone, fileShareName)
 	}
 
+	s.forgetEnvironment(workspaceID)
+	s.idle.Forget(workspaceID)
+
 	log.Printf("✅ Workspace %s permanently deleted (all data removed)", workspaceID)
 	return nil
 }
 
-// RecordActivity updates persistence with the latest activity snapshot.
-func (s *EnvironmentService) RecordActivity(ctx context.Context, report *models.ActivityReport) error {
+// RecordActivity updates persistence and the idle policy with the latest
+// activity snapshot. It returns the workspace's idle status, or nil when the
+// idle policy is disabled or cannot find the workspace.
+func (s *EnvironmentService) RecordActivity(ctx context.Context, report *models.ActivityReport) (*models.IdleStatus, error) {
 	if report == nil {
-		return models.ErrInvalidRequest("activity payload is required")
+		return nil, models.ErrInvalidRequest("activity payload is required")
 	}
 
-	// Just log activity for MVP
-	// Later: forward to Next.js webhook
+	// Record last activity when the state store is enabled. A supervisor that
+	// reports is up, so a workspace left STARTING by its readiness checks is running.
+	lastActivity := report.Snapshot.LastActivity()
+	finishedStarting := !s.operations.Active(report.EnvironmentID)
+	s.updateEnvironment(report.EnvironmentID, func(env *models.Environment) {
+		if lastActivity.After(env.LastAccessedAt) {
+			env.LastAccessedAt = lastActivity
+		}
+		if env.Status == models.StatusStarting && finishedStarting {
+			env.Status = models.StatusRunning
+			env.StatusReason = ""
+		}
+	})
+
 	log.Printf("Activity recorded for environment %s: IDE=%d SSH=%d",
 		report.EnvironmentID,
 		report.Snapshot.ActiveIDE,
 		report.Snapshot.ActiveSSH)
 
-	return nil
+	return s.idle.Observe(ctx, report), nil
 }
 
 // Helper functions
 
-func generateConnectionURLs(fqdn, password string) models.ConnectionURLs {
-	if fqdn == "" {
-		return models.ConnectionURLs{}
+// providerError reports a failed provider call. Errors the provider already
+// classified (quota, throttling, conflicts...) keep their code so clients can
+// act on them, deadlines stay deadlines, anything else is an internal error.
+func providerError(action string, err error) error {
+	var appErr *models.AppError
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	return models.ErrInternalServer(fmt.Sprintf("%s: %v", action, err))
+}
+
+// lockWorkspace takes the workspace lock for a lifecycle operation. A workspace
+// busy with another operation, here or on another agent replica, fails with CONFLICT.
+// Run the operation under the handle's Context so it stops if the lock is lost.
+func (s *EnvironmentService) lockWorkspace(ctx context.Context, workspaceID string, op models.OperationType) (*lock.Handle, error) {
+	if workspaceID == "" {
+		return nil, models.ErrInvalidRequest("workspaceId is required")
+	}
+
+	held, err := s.locks.TryLock(ctx, workspaceID, strings.ToLower(string(op)))
+	if err != nil {
+		var locked *lock.LockedError
+		if errors.As(err, &locked) {
+			return nil, models.ErrConflict(locked.Error())
+		}
+		return nil, models.ErrInternalServer(err.Error())
+	}
+	return held, nil
+}
+
+// lockLost reports an operation that failed because its workspace lock was
+// lost as a CONFLICT: another agent replica may now own the workspace
+func lockLost(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), lock.ErrLockLost) {
+		return models.ErrConflict(fmt.Sprintf("operation stopped: %v", context.Cause(ctx)))
+	}
+	return err
+}
+
+// resolveProvider returns the compute provider for a lifecycle request and validates its region
+func (s *EnvironmentService) resolveProvider(cloud models.CloudProvider, region string) (provider.ComputeProvider, error) {
+	computeProvider, err := s.providers.Get(cloud)
+	if err != nil {
+		return nil, err
+	}
+
+	if !computeProvider.HasRegion(region) {
+		return nil, models.ErrNotFound(fmt.Sprintf("region %s is not available", region))
+	}
+	if err := checkRegionHealth(computeProvider, region); err != nil {
+		return nil, err
+	}
+
+	return computeProvider, nil
+}
+
+// checkRegionHealth fails fast while the provider's circuit breaker for the
+// region is open, before any work is queued
+func checkRegionHealth(computeProvider provider.ComputeProvider, region string) error {
+	if !provider.RegionHealthy(computeProvider, region) {
+		return models.ErrCloudUnavailable(fmt.Sprintf("region %s is temporarily unavailable after repeated cloud failures; retry later or choose another region", region))
 	}
+	return nil
+}
 
-	// Generate a secure password if not provided
-	if password == "" {
-		password = fmt.Sprintf("dev8-%d", time.Now().UnixNano()%100000)
+// checkAppPorts checks requested app ports against the agent's allowlist
+func (s *EnvironmentService) checkAppPorts(ports []int) error {
+	if len(ports) > s.config.Ports.MaxPerWorkspace {
+		return models.ErrInvalidRequest(fmt.Sprintf("at most %d app ports may be exposed, got %d", s.config.Ports.MaxPerWorkspace, len(ports)))
+	}
+	for _, port := range ports {
+		if slices.Contains(provider.WorkspacePorts, port) {
+			return models.ErrInvalidRequest(fmt.Sprintf("port %d is reserved for the workspace's IDE, SSH or supervisor", port))
+		}
+		if !s.config.Ports.Allows(port) {
+			return models.ErrInvalidRequest(fmt.Sprintf("port %d is not an allowed app port", port))
+		}
 	}
+	return nil
+}
+
+// cloudProvider returns the effective cloud provider for a request
+func (s *EnvironmentService) cloudProvider(cloud models.CloudProvider) models.CloudProvider {
+	if cloud == "" {
+		return s.providers.Default()
+	}
+	return cloud
+}
+
+// generateConnectionURLs returns the URLs of the ports the runtime exposes
+func generateConnectionURLs(runtime *provider.Runtime, password string, appPorts []int) models.ConnectionURLs {
+	if runtime == nil || runtime.FQDN == "" {
+		return models.ConnectionURLs{}
+	}
+	fqdn := runtime.FQDN
+
+	// Only ports the backend routes get a URL
+	urls := models.ConnectionURLs{CodeServerPassword: password}
+	if runtime.Exposes(provider.PortSSH) {
+		sshPort := runtime.PublicPort(provider.PortSSH)
+		urls.SSHURL = fmt.Sprintf("ssh://user@%s:%d", fqdn, sshPort)
+		urls.VSCodeDesktopURL = fmt.Sprintf("vscode-remote://ssh-remote+user@%s:%d/home/dev8/workspace", fqdn, sshPort)
+	}
+	if runtime.Exposes(provider.PortIDE) {
+		urls.VSCodeWebURL = runtime.HTTPURL(provider.PortIDE)
+	}
+	if runtime.Exposes(provider.PortSupervisor) {
+		urls.SupervisorURL = runtime.HTTPURL(provider.PortSupervisor)
+	}
+	for _, port := range appPorts {
+		if !runtime.Exposes(port) {
+			continue
+		}
+		if urls.AppURLs == nil {
+			urls.AppURLs = make(map[int]string, len(appPorts))
+		}
+		urls.AppURLs[port] = runtime.HTTPURL(port)
+	}
+	return urls
+}
 
-	return models.ConnectionURLs{
-		SSHURL:             fmt.Sprintf("ssh://user@%s:2222", fqdn),
-		VSCodeWebURL:       fmt.Sprintf("https://%s:8080", fqdn),
-		VSCodeDesktopURL:   fmt.Sprintf("vscode-remote://ssh-remote+user@%s:2222/home/dev8/workspace", fqdn),
-		SupervisorURL:      fmt.Sprintf("http://%s:9000", fqdn),
-		CodeServerPassword: password,
+// newCodeServerPassword generates a random code-server password
+func newCodeServerPassword() (string, error) {
+	secret := make([]byte, 18)
+	if _, err := rand.Read(secret); err != nil {
+		return "", models.ErrInternalServer(fmt.Sprintf("failed to generate code-server password: %v", err))
 	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
 }
 
 func (s *EnvironmentService) getContainerImage(baseImage string) string {
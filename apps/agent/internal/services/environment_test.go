@@ -1,9 +1,15 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
 )
 
 func TestGetContainerImage(t *testing.T) {
@@ -60,3 +66,143 @@ func TestGetContainerImage(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateConnectionURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		runtime *provider.Runtime
+		want    models.ConnectionURLs
+	}{
+		{
+			name:    "azure",
+			runtime: &provider.Runtime{FQDN: "aci-ws-1.eastus.azurecontainer.io"},
+			want: models.ConnectionURLs{
+				SSHURL:           "ssh://user@aci-ws-1.eastus.azurecontainer.io:2222",
+				VSCodeWebURL:     "http://aci-ws-1.eastus.azurecontainer.io:8080",
+				VSCodeDesktopURL: "vscode-remote://ssh-remote+user@aci-ws-1.eastus.azurecontainer.io:2222/home/dev8/workspace",
+				SupervisorURL:    "http://aci-ws-1.eastus.azurecontainer.io:9000",
+				AppURLs:          map[int]string{3000: "http://aci-ws-1.eastus.azurecontainer.io:3000"},
+			},
+		},
+		{
+			name:    "docker published ports",
+			runtime: &provider.Runtime{FQDN: "localhost", Ports: map[int]int{8080: 32768, 2222: 32769, 9000: 32770, 3000: 32771}},
+			want: models.ConnectionURLs{
+				SSHURL:           "ssh://user@localhost:32769",
+				VSCodeWebURL:     "http://localhost:32768",
+				VSCodeDesktopURL: "vscode-remote://ssh-remote+user@localhost:32769/home/dev8/workspace",
+				SupervisorURL:    "http://localhost:32770",
+				AppURLs:          map[int]string{3000: "http://localhost:32771"},
+			},
+		},
+		{
+			name: "gcp routes only the IDE over https",
+			runtime: &provider.Runtime{
+				FQDN:      "aci-ws-1-abc123-uc.a.run.app",
+				Ports:     map[int]int{8080: 443},
+				Scheme:    "https",
+				Reachable: []int{8080},
+			},
+			want: models.ConnectionURLs{VSCodeWebURL: "https://aci-ws-1-abc123-uc.a.run.app"},
+		},
+		{
+			name:    "no FQDN",
+			runtime: &provider.Runtime{},
+			want:    models.ConnectionURLs{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := generateConnectionURLs(tt.runtime, "", []int{3000})
+			if got.SSHURL != tt.want.SSHURL || got.VSCodeWebURL != tt.want.VSCodeWebURL ||
+				got.VSCodeDesktopURL != tt.want.VSCodeDesktopURL || got.SupervisorURL != tt.want.SupervisorURL {
+				t.Errorf("generateConnectionURLs() = %+v, want %+v", got, tt.want)
+			}
+			if len(got.AppURLs) != len(tt.want.AppURLs) || got.AppURLs[3000] != tt.want.AppURLs[3000] {
+				t.Errorf("AppURLs = %v, want %v", got.AppURLs, tt.want.AppURLs)
+			}
+		})
+	}
+}
+
+func TestStartEnvironment_DeletesFailedRuntime(t *testing.T) {
+	service, fakeProvider, _ := newIdleFixture(t, config.IdleConfig{})
+	service.config.Readiness = config.ReadinessConfig{PollInterval: 10 * time.Millisecond, RuntimeTimeout: 5 * time.Second}
+	ctx := context.Background()
+
+	if err := fakeProvider.CreateVolume(ctx, "eastus", "fs-ws-1", 10); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	service.saveEnvironment(&models.Environment{
+		ID:            "ws-1",
+		UserID:        "user-1",
+		Status:        models.StatusStopped,
+		CloudProvider: models.ProviderAzure,
+		CloudRegion:   "eastus",
+	})
+
+	// Hide the runtime from readiness until it has crashed
+	fakeProvider.SetFailure(fake.OpGetRuntime, errors.New("not yet"))
+	go func() {
+		for {
+			if runtimes, _ := fakeProvider.ListRuntimes(ctx, "eastus"); len(runtimes) == 1 {
+				fakeProvider.SetRuntimeState("eastus", "aci-ws-1", provider.StateFailed)
+				fakeProvider.SetFailure(fake.OpGetRuntime, nil)
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	_, err := service.StartEnvironment(ctx, &models.StartEnvironmentRequest{
+		WorkspaceID: "ws-1",
+		CloudRegion: "eastus",
+		UserID:      "user-1",
+		Name:        "workspace",
+		CPUCores:    2,
+		MemoryGB:    4,
+	})
+	if err == nil {
+		t.Fatal("StartEnvironment() error = nil, want the failed runtime reported")
+	}
+
+	if runtimes, _ := fakeProvider.ListRuntimes(ctx, "eastus"); len(runtimes) != 0 {
+		t.Errorf("runtimes after failed start = %+v, want the failed runtime deleted", runtimes)
+	}
+	if exists, _ := fakeProvider.VolumeExists(ctx, "eastus", "fs-ws-1"); !exists {
+		t.Error("volume was deleted after a failed start, want it kept")
+	}
+	env, err := service.GetEnvironment(ctx, "ws-1")
+	if err != nil || env.Status != models.StatusError || env.AzureFQDN != "" {
+		t.Errorf("GetEnvironment() = %+v, %v, want ERROR without an FQDN", env, err)
+	}
+}
+
+func TestCreateEnvironment_UnknownProvider(t *testing.T) {
+	service, err := NewEnvironmentService(&config.Config{}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+
+	_, err = service.CreateEnvironment(context.Background(), &models.CreateEnvironmentRequest{
+		WorkspaceID:   "550e8400-e29b-41d4-a716-446655440000",
+		Name:          "test",
+		CloudProvider: models.ProviderGCP,
+		CloudRegion:   "us-central1",
+		CPUCores:      2,
+		MemoryGB:      4,
+		StorageGB:     20,
+	})
+
+	appErr, ok := err.(*models.AppError)
+	if !ok || appErr.Code != "INVALID_REQUEST" {
+		t.Errorf("CreateEnvironment() error = %v, want INVALID_REQUEST", err)
+	}
+}
+
+func TestNewEnvironmentService_RequiresRegistry(t *testing.T) {
+	if _, err := NewEnvironmentService(&config.Config{}, nil); err == nil {
+		t.Error("NewEnvironmentService() should fail without a provider registry")
+	}
+}
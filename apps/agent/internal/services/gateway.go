@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// Gateway token kinds, signed for the IDE port
+const (
+	gatewayKindLink    = "gateway-link"
+	gatewayKindSession = "gateway-session"
+)
+
+const (
+	// GatewayAuthPath is the path on a workspace host that trades a link token for a session
+	GatewayAuthPath = "/__dev8/gateway-auth"
+	// GatewayCookie holds the gateway session of a workspace
+	GatewayCookie = "dev8_gateway"
+)
+
+// Gateway builds the gateway URLs of workspace IDEs, https://{workspaceId}.{domain},
+// and signs the links and sessions that let the owner's browser in
+type Gateway struct {
+	domain     string
+	linkTTL    time.Duration
+	sessionTTL time.Duration
+	signer     *tokenSigner
+}
+
+// NewGateway creates the gateway signer, generating a random key when none is configured
+func NewGateway(cfg config.GatewayConfig) (*Gateway, error) {
+	signer, err := newTokenSigner(cfg.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Enabled() && cfg.Secret == "" {
+		log.Printf("Warning: GATEWAY_SECRET not set - gateway sessions end when the agent restarts")
+	}
+
+	return &Gateway{
+		domain:     cfg.Domain,
+		linkTTL:    cfg.LinkTTL,
+		sessionTTL: cfg.SessionTTL,
+		signer:     signer,
+	}, nil
+}
+
+// Enabled reports whether the gateway is served
+func (g *Gateway) Enabled() bool {
+	return g.domain != ""
+}
+
+// URL returns the gateway URL of a workspace IDE, or "" when the gateway is disabled
+func (g *Gateway) URL(workspaceID string) string {
+	if !g.Enabled() {
+		return ""
+	}
+	return fmt.Sprintf("https://%s.%s", workspaceID, g.domain)
+}
+
+// ParseHost returns the workspace a gateway host name points to
+func (g *Gateway) ParseHost(host string) (workspaceID string, ok bool) {
+	if !g.Enabled() {
+		return "", false
+	}
+	workspaceID, ok = hostLabel(host, g.domain)
+	return workspaceID, ok && workspaceID != ""
+}
+
+// Link returns a short-lived link that signs the owner's browser in to a workspace
+func (g *Gateway) Link(workspaceID string) models.AccessLink {
+	token, expiry := g.signer.mintAccess(gatewayKindLink, workspaceID, provider.PortIDE, g.linkTTL)
+	return accessLink(g.URL(workspaceID)+GatewayAuthPath, token, expiry)
+}
+
+// Session returns a session token for the gateway cookie and when it expires
+func (g *Gateway) Session(workspaceID string) (string, time.Time) {
+	return g.signer.mintAccess(gatewayKindSession, workspaceID, provider.PortIDE, g.sessionTTL)
+}
+
+// VerifyLink checks a link token was minted for the workspace and has not expired
+func (g *Gateway) VerifyLink(token, workspaceID string) error {
+	return g.signer.verifyAccess(gatewayKindLink, token, workspaceID, provider.PortIDE)
+}
+
+// VerifySession checks a session token was minted for the workspace and has not expired
+func (g *Gateway) VerifySession(token, workspaceID string) error {
+	return g.signer.verifyAccess(gatewayKindSession, token, workspaceID, provider.PortIDE)
+}
+
+// hostLabel returns the single DNS label a host name adds to domain, e.g.
+// "ws-1" for "ws-1.dev8.app:443" under "dev8.app"
+func hostLabel(host, domain string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, found := strings.CutSuffix(strings.ToLower(host), "."+domain)
+	if !found || strings.Contains(label, ".") {
+		return "", false
+	}
+	return label, true
+}
+
+// GatewayTarget returns the recorded workspace behind a gateway host and the
+// IDE URL it forwards to
+func (s *EnvironmentService) GatewayTarget(ctx context.Context, workspaceID string) (*models.Environment, string, error) {
+	if !s.gateway.Enabled() {
+		return nil, "", models.ErrNotFound("the gateway is not enabled")
+	}
+	env, err := s.GetEnvironment(ctx, workspaceID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	target := env.ConnectionURLs.VSCodeWebURL
+	if env.Status != models.StatusRunning || target == "" {
+		return nil, "", models.ErrCloudUnavailable(fmt.Sprintf("workspace %s is not running", workspaceID))
+	}
+	// Records written by older agents claim https for the plain-HTTP IDE
+	// port; backends that really serve HTTPS (Cloud Run) use the default port
+	if u, err := url.Parse(target); err == nil && u.Scheme == "https" && u.Port() != "" && u.Port() != "443" {
+		target = "http://" + strings.TrimPrefix(target, "https://")
+	}
+	return env, target, nil
+}
+
+// GatewayLink returns a link signing the owner in to the workspace through the gateway
+func (s *EnvironmentService) GatewayLink(env *models.Environment) (*models.AccessLink, error) {
+	if !s.gateway.Enabled() {
+		return nil, models.ErrInvalidRequest("the gateway is not enabled - set GATEWAY_DOMAIN")
+	}
+	link := s.gateway.Link(env.ID)
+	return &link, nil
+}
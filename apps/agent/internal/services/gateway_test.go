@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+)
+
+func TestGateway_ParseHost(t *testing.T) {
+	gateway, err := NewGateway(config.GatewayConfig{Domain: "ws.dev8.test", Secret: "test-secret", LinkTTL: time.Minute, SessionTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewGateway() error = %v", err)
+	}
+
+	tests := []struct {
+		host            string
+		wantWorkspaceID string
+		wantOK          bool
+	}{
+		{"ws-1.ws.dev8.test", "ws-1", true},
+		{"WS-1.ws.dev8.test:8443", "ws-1", true},
+		{"ws.dev8.test", "", false},
+		{".ws.dev8.test", "", false},
+		{"3000-ws-1.preview.ws.dev8.test", "", false},
+		{"ws-1.other.test", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			workspaceID, ok := gateway.ParseHost(tt.host)
+			if workspaceID != tt.wantWorkspaceID || ok != tt.wantOK {
+				t.Errorf("ParseHost() = %q, %v, want %q, %v", workspaceID, ok, tt.wantWorkspaceID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGateway_Tokens(t *testing.T) {
+	gateway, err := NewGateway(config.GatewayConfig{Domain: "ws.dev8.test", Secret: "test-secret", LinkTTL: time.Minute, SessionTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewGateway() error = %v", err)
+	}
+	session, _ := gateway.Session("ws-1")
+
+	if err := gateway.VerifySession(session, "ws-1"); err != nil {
+		t.Errorf("VerifySession() error = %v, want nil", err)
+	}
+	if err := gateway.VerifySession(session, "ws-2"); err == nil {
+		t.Errorf("VerifySession() for another workspace error = nil, want error")
+	}
+	if err := gateway.VerifyLink(session, "ws-1"); err == nil {
+		t.Errorf("VerifyLink() with a session token error = nil, want error")
+	}
+
+	// Preview tokens of the same workspace don't open the gateway
+	previews, err := NewPreviews(config.PreviewConfig{Domain: "dev8.test", Secret: "test-secret", LinkTTL: time.Minute, SessionTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewPreviews() error = %v", err)
+	}
+	previewSession, _ := previews.Session("ws-1", 8080)
+	if err := gateway.VerifySession(previewSession, "ws-1"); err == nil {
+		t.Errorf("VerifySession() with a preview session error = nil, want error")
+	}
+}
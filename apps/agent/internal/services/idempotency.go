@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// defaultIdempotencyTTL applies when IDEMPOTENCY_TTL is not positive
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyManager remembers which operation each Idempotency-Key started so
+// a retried lifecycle request attaches to it instead of starting another.
+// Records live in memory for the TTL; the final state of the operation is kept
+// with the record so replays outlive OPERATION_RETENTION.
+type IdempotencyManager struct {
+	operations *OperationManager
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	records map[string]*idempotencyRecord
+}
+
+// idempotencyRecord is the outcome of the first request with a key
+type idempotencyRecord struct {
+	requestHash string
+	createdAt   time.Time
+	operationID string            // Empty while the first attempt is being validated
+	result      *models.Operation // Final state once the operation completes
+	ready       chan struct{}     // Closed when the first attempt started an operation or failed
+}
+
+// IdempotencyClaim is held by the first request with a key until it either
+// starts its operation (Complete) or fails before starting one (Abort)
+type IdempotencyClaim struct {
+	manager *IdempotencyManager
+	key     string
+	record  *idempotencyRecord
+}
+
+// NewIdempotencyManager creates an idempotency manager for the operations
+func NewIdempotencyManager(operations *OperationManager, ttl time.Duration) *IdempotencyManager {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	return &IdempotencyManager{
+		operations: operations,
+		ttl:        ttl,
+		now:        time.Now,
+		records:    make(map[string]*idempotencyRecord),
+	}
+}
+
+// Begin looks up a key. A new key returns a claim the caller must resolve; a
+// known key with the same request hash returns the operation it started,
+// waiting for the first attempt if it has not started one yet. A known key
+// with a different request fails with CONFLICT.
+func (m *IdempotencyManager) Begin(ctx context.Context, key, requestHash string) (*IdempotencyClaim, *models.Operation, error) {
+	for {
+		m.mu.Lock()
+		m.purgeExpired()
+
+		record, ok := m.records[key]
+		if !ok {
+			record = &idempotencyRecord{
+				requestHash: requestHash,
+				createdAt:   m.now(),
+				ready:       make(chan struct{}),
+			}
+			m.records[key] = record
+			m.mu.Unlock()
+			return &IdempotencyClaim{manager: m, key: key, record: record}, nil, nil
+		}
+
+		if record.requestHash != requestHash {
+			m.mu.Unlock()
+			return nil, nil, models.ErrConflict("Idempotency-Key was already used with a different request")
+		}
+
+		if record.operationID == "" {
+			// The first attempt is still validating; it either starts an operation or gives the key up
+			ready := record.ready
+			m.mu.Unlock()
+			select {
+			case <-ready:
+				continue
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+
+		operationID, result := record.operationID, record.result
+		m.mu.Unlock()
+
+		if op, err := m.operations.Get(operationID); err == nil {
+			return nil, op, nil
+		}
+		if result != nil {
+			return nil, result, nil
+		}
+		return nil, nil, models.ErrInternalServer("operation for Idempotency-Key is no longer available")
+	}
+}
+
+// Complete records the operation started for the key and keeps its final state once it completes
+func (c *IdempotencyClaim) Complete(op *models.Operation) {
+	m := c.manager
+
+	m.mu.Lock()
+	c.record.operationID = op.ID
+	close(c.record.ready)
+	m.mu.Unlock()
+
+	go func() {
+		final, err := m.operations.Wait(context.Background(), op.ID)
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		c.record.result = final
+		m.mu.Unlock()
+	}()
+}
+
+// Abort releases the key after the request failed without starting an operation,
+// so a retry runs the request again
+func (c *IdempotencyClaim) Abort() {
+	m := c.manager
+
+	m.mu.Lock()
+	if m.records[c.key] == c.record {
+		delete(m.records, c.key)
+	}
+	close(c.record.ready)
+	m.mu.Unlock()
+}
+
+// purgeExpired drops records older than the TTL. Callers must hold m.mu.
+func (m *IdempotencyManager) purgeExpired() {
+	now := m.now()
+	for key, record := range m.records {
+		if record.operationID != "" && now.Sub(record.createdAt) > m.ttl {
+			delete(m.records, key)
+		}
+	}
+}
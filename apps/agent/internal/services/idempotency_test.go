@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+func TestIdempotencyManager_ReplaysAndConflicts(t *testing.T) {
+	operations := NewOperationManager(time.Minute, time.Hour)
+	m := NewIdempotencyManager(operations, time.Hour)
+	ctx := context.Background()
+
+	claim, op, err := m.Begin(ctx, "key-1", "hash-a")
+	if err != nil || claim == nil || op != nil {
+		t.Fatalf("Begin(new key) = %v, %v, %v, want a claim", claim, op, err)
+	}
+
+	release := make(chan struct{})
+	started := operations.Start(models.OperationStop, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		<-release
+		return nil, nil
+	})
+	claim.Complete(started)
+
+	// Retry while the operation is in flight attaches to it
+	if claim, op, err := m.Begin(ctx, "key-1", "hash-a"); err != nil || claim != nil || op == nil || op.ID != started.ID {
+		t.Errorf("Begin(in flight) = %v, %+v, %v, want operation %s", claim, op, err, started.ID)
+	}
+
+	// Same key, different request
+	_, _, err = m.Begin(ctx, "key-1", "hash-b")
+	if appErr, ok := err.(*models.AppError); !ok || appErr.Code != "CONFLICT" {
+		t.Errorf("Begin(different request) error = %v, want CONFLICT", err)
+	}
+
+	close(release)
+	waitFor(t, operations, started.ID)
+
+	// Completed operations are replayed with their final state
+	if _, op, err := m.Begin(ctx, "key-1", "hash-a"); err != nil || op == nil || op.Status != models.OperationSucceeded {
+		t.Errorf("Begin(completed) = %+v, %v, want succeeded operation", op, err)
+	}
+}
+
+func TestIdempotencyManager_AbortReleasesKey(t *testing.T) {
+	m := NewIdempotencyManager(NewOperationManager(time.Minute, time.Hour), time.Hour)
+	ctx := context.Background()
+
+	first, _, err := m.Begin(ctx, "key-1", "hash-a")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+
+	// A concurrent retry waits for the first attempt to resolve the key
+	retried := make(chan *IdempotencyClaim)
+	go func() {
+		claim, _, _ := m.Begin(ctx, "key-1", "hash-a")
+		retried <- claim
+	}()
+
+	select {
+	case <-retried:
+		t.Fatal("Begin() returned before the first attempt resolved the key")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// The first attempt failed validation: the retry runs the request itself
+	first.Abort()
+	select {
+	case claim := <-retried:
+		if claim == nil {
+			t.Error("Begin() after Abort() returned no claim")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Begin() did not return after Abort()")
+	}
+}
+
+func TestIdempotencyManager_KeysExpire(t *testing.T) {
+	operations := NewOperationManager(time.Minute, time.Hour)
+	m := NewIdempotencyManager(operations, time.Hour)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	claim, _, _ := m.Begin(ctx, "key-1", "hash-a")
+	claim.Complete(operations.Start(models.OperationStop, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		return nil, nil
+	}))
+
+	now = now.Add(2 * time.Hour)
+	if claim, _, err := m.Begin(ctx, "key-1", "hash-b"); err != nil || claim == nil {
+		t.Errorf("Begin(expired key) = %v, %v, want a new claim", claim, err)
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/store"
+)
+
+// IdlePolicy tracks the last IDE/SSH activity of every running workspace and
+// stops workspaces that stay idle past their timeout. The timeout comes from
+// the workspace's idleTimeoutMinutes, else IDLE_USER_TIMEOUTS, else
+// IDLE_TIMEOUT; 0 never stops the workspace. Warnings of an imminent stop go
+// to IDLE_WEBHOOK_URL.
+type IdlePolicy struct {
+	service *EnvironmentService
+	config  config.IdleConfig
+	now     func() time.Time
+	webhook *webhook // nil when no webhook is configured
+
+	mu         sync.Mutex
+	workspaces map[string]*idleWorkspace
+}
+
+// idleWorkspace is the policy's record of a running workspace
+type idleWorkspace struct {
+	id           string
+	userID       string
+	cloud        models.CloudProvider
+	region       string
+	override     *time.Duration // Per-workspace timeout
+	lastActivity time.Time
+	warned       bool
+}
+
+// NewIdlePolicy creates the idle policy for the service's workspaces
+func NewIdlePolicy(service *EnvironmentService, cfg config.IdleConfig) *IdlePolicy {
+	return &IdlePolicy{
+		service:    service,
+		config:     cfg,
+		now:        time.Now,
+		webhook:    newWebhook(cfg.WebhookURL, cfg.WebhookSecret),
+		workspaces: make(map[string]*idleWorkspace),
+	}
+}
+
+// enabled reports whether the policy checks workspaces at all
+func (p *IdlePolicy) enabled() bool {
+	return p.config.CheckInterval > 0
+}
+
+// Run checks for idle workspaces on every interval tick until ctx is cancelled.
+// Running workspaces recorded in the state store are tracked from startup.
+func (p *IdlePolicy) Run(ctx context.Context) {
+	if !p.enabled() {
+		log.Printf("💤 Idle policy disabled (IDLE_CHECK_INTERVAL=0)")
+		return
+	}
+
+	p.seed()
+	log.Printf("💤 Idle policy checking every %s (default timeout: %s, warning: %s)", p.config.CheckInterval, p.config.Timeout, p.config.Warning)
+
+	ticker := time.NewTicker(p.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Check(ctx)
+		}
+	}
+}
+
+// Track starts the idle clock of a workspace that has just been created or started
+func (p *IdlePolicy) Track(env *models.Environment) {
+	if !p.enabled() {
+		return
+	}
+
+	workspace := &idleWorkspace{
+		id:           env.ID,
+		userID:       env.UserID,
+		cloud:        env.CloudProvider,
+		region:       env.CloudRegion,
+		lastActivity: p.now(),
+	}
+	if !env.LastAccessedAt.IsZero() && env.LastAccessedAt.After(workspace.lastActivity) {
+		workspace.lastActivity = env.LastAccessedAt
+	}
+	if env.IdleTimeoutMinutes != nil {
+		override := time.Duration(*env.IdleTimeoutMinutes) * time.Minute
+		workspace.override = &override
+	}
+
+	p.mu.Lock()
+	p.workspaces[env.ID] = workspace
+	p.mu.Unlock()
+}
+
+// Forget stops tracking a workspace that was stopped or deleted
+func (p *IdlePolicy) Forget(workspaceID string) {
+	p.mu.Lock()
+	delete(p.workspaces, workspaceID)
+	p.mu.Unlock()
+}
+
+// Observe records a supervisor activity report and returns the workspace's idle status.
+// Open IDE or SSH connections count as activity at the time of the report.
+func (p *IdlePolicy) Observe(ctx context.Context, report *models.ActivityReport) *models.IdleStatus {
+	if !p.enabled() {
+		return nil
+	}
+
+	p.mu.Lock()
+	_, tracked := p.workspaces[report.EnvironmentID]
+	p.mu.Unlock()
+
+	// The agent restarted (or the workspace was started elsewhere): find it first
+	if !tracked {
+		env, err := p.service.locateEnvironment(ctx, report.EnvironmentID)
+		if err != nil {
+			log.Printf("Warning: idle policy cannot track workspace %s: %v", report.EnvironmentID, err)
+			return nil
+		}
+		p.Track(env)
+	}
+
+	now := p.now()
+	activity := report.Snapshot.LastActivity()
+	if report.Snapshot.ActiveIDE > 0 || report.Snapshot.ActiveSSH > 0 {
+		activity = now
+	}
+	if activity.After(now) {
+		activity = now // Supervisor clock skew
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	workspace, ok := p.workspaces[report.EnvironmentID]
+	if !ok {
+		return nil
+	}
+	if activity.After(workspace.lastActivity) {
+		workspace.lastActivity = activity
+		workspace.warned = false
+	}
+	return p.status(workspace, now)
+}
+
+// Status returns the idle status of a tracked workspace, or nil
+func (p *IdlePolicy) Status(workspaceID string) *models.IdleStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	workspace, ok := p.workspaces[workspaceID]
+	if !ok {
+		return nil
+	}
+	return p.status(workspace, p.now())
+}
+
+// Check warns workspaces approaching their idle timeout and stops the ones past it
+func (p *IdlePolicy) Check(ctx context.Context) {
+	now := p.now()
+
+	var due []idleWorkspace
+	var warnings []models.IdleEvent
+	p.mu.Lock()
+	for _, workspace := range p.workspaces {
+		timeout := p.timeout(workspace)
+		if timeout <= 0 {
+			continue
+		}
+
+		idleFor := now.Sub(workspace.lastActivity)
+		switch {
+		case idleFor >= timeout:
+			due = append(due, *workspace)
+		case idleFor >= timeout-p.config.Warning && !workspace.warned:
+			workspace.warned = true
+			log.Printf("⏰ Workspace %s idle for %s - stopping in %s unless there is activity",
+				workspace.id, idleFor.Round(time.Second), (timeout - idleFor).Round(time.Second))
+			warnings = append(warnings, models.IdleEvent{
+				Type:   models.IdleEventWarning,
+				UserID: workspace.userID,
+				Idle:   *p.status(workspace, now),
+				SentAt: now,
+			})
+		}
+	}
+	p.mu.Unlock()
+
+	for _, event := range warnings {
+		go p.warn(ctx, event)
+	}
+
+	for _, workspace := range due {
+		p.stop(ctx, workspace, now.Sub(workspace.lastActivity))
+	}
+}
+
+// warn sends a warning event to the webhook so the user hears of the stop
+func (p *IdlePolicy) warn(ctx context.Context, event models.IdleEvent) {
+	if p.webhook == nil {
+		return
+	}
+	if err := p.webhook.post(ctx, event); err != nil {
+		log.Printf("Warning: failed to send idle warning for workspace %s: %v", event.Idle.WorkspaceID, err)
+	}
+}
+
+// stop stops an idle workspace through the regular StopEnvironment path
+func (p *IdlePolicy) stop(ctx context.Context, workspace idleWorkspace, idleFor time.Duration) {
+	if p.service.operations.Active(workspace.id) {
+		return // A start/stop/delete is already in flight
+	}
+
+	log.Printf("💤 Stopping workspace %s: idle for %s (timeout %s)", workspace.id, idleFor.Round(time.Second), p.timeout(&workspace))
+	_, err := p.service.StopEnvironmentAsync(ctx, &models.StopEnvironmentRequest{
+		WorkspaceID:   workspace.id,
+		CloudProvider: workspace.cloud,
+		CloudRegion:   workspace.region,
+		Reason:        models.StopReasonIdle,
+	})
+
+	var appErr *models.AppError
+	switch {
+	case err == nil:
+		p.Forget(workspace.id)
+	case errors.As(err, &appErr) && appErr.Code == models.CodeNotFound:
+		// Already stopped (or deleted) outside the agent
+		p.Forget(workspace.id)
+	case errors.As(err, &appErr) && appErr.Code == models.CodeConflict:
+		// Busy with an operation on another agent replica; retried on the next check
+	default:
+		log.Printf("Warning: failed to stop idle workspace %s: %v", workspace.id, err)
+	}
+}
+
+// seed tracks the running workspaces recorded in the state store
+func (p *IdlePolicy) seed() {
+	if p.service.store == nil {
+		return
+	}
+
+	envs, _, err := p.service.store.List(store.Filter{Status: models.StatusRunning})
+	if err != nil {
+		log.Printf("Warning: idle policy could not load running workspaces: %v", err)
+		return
+	}
+	for i := range envs {
+		p.Track(&envs[i])
+	}
+}
+
+// timeout resolves the idle timeout of a workspace
+func (p *IdlePolicy) timeout(workspace *idleWorkspace) time.Duration {
+	if workspace.override != nil {
+		return *workspace.override
+	}
+	return p.config.TimeoutFor(workspace.userID)
+}
+
+// status builds the idle status of a workspace. Callers must hold p.mu.
+func (p *IdlePolicy) status(workspace *idleWorkspace, now time.Time) *models.IdleStatus {
+	timeout := p.timeout(workspace)
+	status := &models.IdleStatus{
+		WorkspaceID:    workspace.id,
+		TimeoutSeconds: int64(timeout / time.Second),
+		LastActivity:   workspace.lastActivity,
+	}
+	if timeout > 0 {
+		stopAt := workspace.lastActivity.Add(timeout)
+		status.StopAt = &stopAt
+		status.Warning = stopAt.Sub(now) <= p.config.Warning
+	}
+	return status
+}
+
+// locateEnvironment finds where a running workspace lives, from the state
+// store if available, otherwise by looking for its runtime in every region
+func (s *EnvironmentService) locateEnvironment(ctx context.Context, workspaceID string) (*models.Environment, error) {
+	if s.store != nil {
+		env, err := s.store.Get(workspaceID)
+		if err == nil {
+			return env, nil
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	runtimeName := fmt.Sprintf("aci-%s", workspaceID)
+	for _, cloud := range s.providers.Providers() {
+		computeProvider, err := s.providers.Get(cloud)
+		if err != nil {
+			continue
+		}
+		for _, region := range computeProvider.Regions() {
+			runtime, err := computeProvider.GetRuntime(ctx, region, runtimeName)
+			if err != nil {
+				continue
+			}
+			return &models.Environment{
+				ID:            workspaceID,
+				UserID:        runtime.UserID,
+				CloudProvider: cloud,
+				CloudRegion:   region,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no running container found")
+}
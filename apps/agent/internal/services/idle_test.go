@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// newIdleFixture returns a stateful service whose idle policy reads the returned clock
+func newIdleFixture(t *testing.T, idle config.IdleConfig) (*EnvironmentService, *fake.Provider, *time.Time) {
+	t.Helper()
+
+	fakeProvider := fake.NewProvider(fake.Options{Regions: []string{"eastus"}})
+	providers := provider.NewRegistry(models.ProviderAzure)
+	providers.Register(models.ProviderAzure, fakeProvider)
+
+	service, err := NewEnvironmentService(&config.Config{
+		StatePath: filepath.Join(t.TempDir(), "state.db"),
+		Idle:      idle,
+	}, providers)
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	t.Cleanup(service.Close)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.idle.now = func() time.Time { return now }
+	return service, fakeProvider, &now
+}
+
+// runWorkspace creates a running workspace on the fake provider and records it
+func runWorkspace(t *testing.T, service *EnvironmentService, fakeProvider *fake.Provider, env *models.Environment) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := fakeProvider.CreateVolume(ctx, "eastus", "fs-"+env.ID, 10); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	spec := provider.ContainerGroupSpec{EnvironmentID: env.ID, UserID: env.UserID}
+	if err := fakeProvider.CreateRuntime(ctx, "eastus", "aci-"+env.ID, spec); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	env.Status = models.StatusRunning
+	env.CloudProvider = models.ProviderAzure
+	env.CloudRegion = "eastus"
+	service.saveEnvironment(env)
+}
+
+func TestIdlePolicy_WarnsThenStops(t *testing.T) {
+	service, fakeProvider, now := newIdleFixture(t, config.IdleConfig{
+		Timeout:       30 * time.Minute,
+		Warning:       5 * time.Minute,
+		CheckInterval: time.Minute,
+	})
+	ctx := context.Background()
+
+	env := &models.Environment{ID: "ws-idle", UserID: "user-1"}
+	runWorkspace(t, service, fakeProvider, env)
+	service.idle.Track(env)
+
+	// Inside the warning window
+	*now = now.Add(26 * time.Minute)
+	service.idle.Check(ctx)
+	if status := service.idle.Status("ws-idle"); status == nil || !status.Warning {
+		t.Fatalf("Status() = %+v, want warning", status)
+	}
+
+	// Activity resets the idle clock
+	status, err := service.RecordActivity(ctx, &models.ActivityReport{
+		EnvironmentID: "ws-idle",
+		Snapshot:      models.ActivitySnapshot{ActiveIDE: 1},
+	})
+	if err != nil || status == nil || status.Warning || !status.StopAt.Equal(now.Add(30*time.Minute)) {
+		t.Fatalf("RecordActivity() = %+v, %v, want stop 30m from now without warning", status, err)
+	}
+
+	*now = now.Add(29 * time.Minute)
+	service.idle.Check(ctx)
+	if _, err := fakeProvider.GetRuntime(ctx, "eastus", "aci-ws-idle"); err != nil {
+		t.Fatalf("workspace stopped before its timeout: %v", err)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	service.idle.Check(ctx)
+	waitForIdle(t, service, "ws-idle")
+
+	if _, err := fakeProvider.GetRuntime(ctx, "eastus", "aci-ws-idle"); err == nil {
+		t.Error("idle workspace runtime still exists")
+	}
+	recorded, err := service.GetEnvironment(ctx, "ws-idle")
+	if err != nil || recorded.Status != models.StatusStopped || recorded.StopReason != models.StopReasonIdle {
+		t.Errorf("GetEnvironment() = %+v, %v, want STOPPED by %v", recorded, err, models.StopReasonIdle)
+	}
+	if service.idle.Status("ws-idle") != nil {
+		t.Error("stopped workspace is still tracked")
+	}
+}
+
+func TestIdlePolicy_PostsWarningToWebhook(t *testing.T) {
+	deliveries := make(chan models.IdleEvent, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event models.IdleEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("webhook body %q: %v", body, err)
+		}
+		mac := hmac.New(sha256.New, []byte("hook-secret"))
+		mac.Write(body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(webhookSignatureHeader) != want {
+			t.Errorf("signature = %q, want %q", r.Header.Get(webhookSignatureHeader), want)
+		}
+		deliveries <- event
+	}))
+	defer server.Close()
+
+	service, fakeProvider, now := newIdleFixture(t, config.IdleConfig{
+		Timeout:       30 * time.Minute,
+		Warning:       5 * time.Minute,
+		CheckInterval: time.Minute,
+		WebhookURL:    server.URL,
+		WebhookSecret: "hook-secret",
+	})
+	ctx := context.Background()
+
+	env := &models.Environment{ID: "ws-idle", UserID: "user-1"}
+	runWorkspace(t, service, fakeProvider, env)
+	service.idle.Track(env)
+
+	*now = now.Add(26 * time.Minute)
+	service.idle.Check(ctx)
+
+	select {
+	case event := <-deliveries:
+		if event.Type != models.IdleEventWarning || event.UserID != "user-1" || event.Idle.WorkspaceID != "ws-idle" ||
+			!event.Idle.Warning || event.Idle.StopAt == nil || !event.Idle.StopAt.Equal(now.Add(4*time.Minute)) {
+			t.Errorf("webhook event = %+v, want a warning for ws-idle stopping in 4m", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no idle warning was posted")
+	}
+
+	// The warning is sent once per idle period
+	*now = now.Add(time.Minute)
+	service.idle.Check(ctx)
+	select {
+	case event := <-deliveries:
+		t.Errorf("second warning posted: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestIdlePolicy_KeepsTrackingOnTransientErrors(t *testing.T) {
+	service, fakeProvider, now := newIdleFixture(t, config.IdleConfig{
+		Timeout:       30 * time.Minute,
+		CheckInterval: time.Minute,
+	})
+	ctx := context.Background()
+
+	env := &models.Environment{ID: "ws-idle", UserID: "user-1"}
+	runWorkspace(t, service, fakeProvider, env)
+	service.idle.Track(env)
+
+	// The backend cannot be reached, which says nothing about the runtime
+	fakeProvider.FailNext(fake.OpGetRuntime, errors.New("connection reset by peer"))
+	*now = now.Add(31 * time.Minute)
+	service.idle.Check(ctx)
+	if service.idle.Status("ws-idle") == nil {
+		t.Fatal("workspace was forgotten after a transient GetRuntime error")
+	}
+
+	// The next check stops it
+	service.idle.Check(ctx)
+	waitForIdle(t, service, "ws-idle")
+	if _, err := fakeProvider.GetRuntime(ctx, "eastus", "aci-ws-idle"); err == nil {
+		t.Error("idle workspace runtime still exists")
+	}
+}
+
+func TestIdlePolicy_Timeouts(t *testing.T) {
+	never, hour := 0, 60
+
+	tests := []struct {
+		name     string
+		userID   string
+		override *int
+		want     time.Duration
+	}{
+		{name: "global", userID: "user-1", want: 30 * time.Minute},
+		{name: "per user", userID: "user-2", want: 2 * time.Hour},
+		{name: "per workspace", userID: "user-2", override: &hour, want: time.Hour},
+		{name: "workspace never stops", userID: "user-1", override: &never, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := newIdleFixture(t, config.IdleConfig{
+				Timeout:       30 * time.Minute,
+				UserTimeouts:  map[string]time.Duration{"user-2": 2 * time.Hour},
+				CheckInterval: time.Minute,
+			})
+
+			service.idle.Track(&models.Environment{ID: "ws-1", UserID: tt.userID, IdleTimeoutMinutes: tt.override})
+			status := service.idle.Status("ws-1")
+			if status == nil || status.TimeoutSeconds != int64(tt.want/time.Second) {
+				t.Errorf("Status() = %+v, want timeout %v", status, tt.want)
+			}
+			if (status.StopAt == nil) != (tt.want == 0) {
+				t.Errorf("StopAt = %v, want set only with a timeout", status.StopAt)
+			}
+		})
+	}
+}
+
+func TestIdlePolicy_ObserveLocatesUntrackedWorkspace(t *testing.T) {
+	service, fakeProvider, _ := newIdleFixture(t, config.IdleConfig{Timeout: time.Hour, CheckInterval: time.Minute})
+	ctx := context.Background()
+
+	// Running before the agent restarted, so only the provider knows about it
+	spec := provider.ContainerGroupSpec{EnvironmentID: "ws-restarted", UserID: "user-1"}
+	if err := fakeProvider.CreateRuntime(ctx, "eastus", "aci-ws-restarted", spec); err != nil {
+		t.Fatalf("CreateRuntime() error = %v", err)
+	}
+
+	status, err := service.RecordActivity(ctx, &models.ActivityReport{EnvironmentID: "ws-restarted"})
+	if err != nil || status == nil || status.TimeoutSeconds != 3600 {
+		t.Errorf("RecordActivity() = %+v, %v, want a tracked workspace", status, err)
+	}
+
+	if status, _ := service.RecordActivity(ctx, &models.ActivityReport{EnvironmentID: "ws-unknown"}); status != nil {
+		t.Errorf("RecordActivity(unknown) = %+v, want nil", status)
+	}
+}
+
+func TestIdlePolicy_Disabled(t *testing.T) {
+	service, _, _ := newIdleFixture(t, config.IdleConfig{Timeout: time.Minute})
+
+	service.idle.Track(&models.Environment{ID: "ws-1"})
+	if status := service.idle.Status("ws-1"); status != nil {
+		t.Errorf("Status() = %+v, want nil when IDLE_CHECK_INTERVAL=0", status)
+	}
+	service.idle.Run(context.Background()) // Returns immediately
+}
+
+// waitForIdle waits for the idle stop operation of a workspace to finish
+func waitForIdle(t *testing.T, service *EnvironmentService, workspaceID string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for service.operations.Active(workspaceID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stop operation for %s did not finish", workspaceID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIdlePolicy_StartKeepsRecordedOverride(t *testing.T) {
+	service, fakeProvider, now := newIdleFixture(t, config.IdleConfig{
+		Timeout:       30 * time.Minute,
+		CheckInterval: time.Minute,
+	})
+	ctx := context.Background()
+
+	hour := 60
+	if err := fakeProvider.CreateVolume(ctx, "eastus", "fs-ws-idle", 10); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	service.saveEnvironment(&models.Environment{
+		ID:                 "ws-idle",
+		UserID:             "user-1",
+		Status:             models.StatusStopped,
+		CloudProvider:      models.ProviderAzure,
+		CloudRegion:        "eastus",
+		IdleTimeoutMinutes: &hour,
+	})
+
+	// A start without idleTimeoutMinutes, like a bastion wake
+	_, err := service.StartEnvironment(ctx, &models.StartEnvironmentRequest{
+		WorkspaceID: "ws-idle",
+		CloudRegion: "eastus",
+		UserID:      "user-1",
+		Name:        "workspace",
+		CPUCores:    2,
+		MemoryGB:    4,
+	})
+	if err != nil {
+		t.Fatalf("StartEnvironment() error = %v", err)
+	}
+
+	recorded, err := service.GetEnvironment(ctx, "ws-idle")
+	if err != nil || recorded.IdleTimeoutMinutes == nil || *recorded.IdleTimeoutMinutes != hour {
+		t.Fatalf("GetEnvironment() = %+v, %v, want idle timeout override %d", recorded, err, hour)
+	}
+	if status := service.idle.Status("ws-idle"); status == nil || !status.StopAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Status() = %+v, want stop 1h from now", status)
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// OperationFunc performs the work of a long-running operation.
+// Create and start return the environment; stop and delete return nil.
+type OperationFunc func(ctx context.Context) (*models.Environment, error)
+
+// OperationManager runs lifecycle operations in the background and keeps their
+// progress in memory so clients can poll or stream it
+type OperationManager struct {
+	timeout   time.Duration // Deadline for a single operation
+	retention time.Duration // How long completed operations stay queryable
+
+	mu         sync.RWMutex
+	operations map[string]*trackedOperation
+}
+
+// trackedOperation is an operation plus the SSE subscribers waiting on its updates
+type trackedOperation struct {
+	op          models.Operation
+	subscribers map[chan *models.Operation]struct{}
+	done        chan struct{}
+}
+
+// Defaults used when the configuration leaves the operation settings unset
+const (
+	defaultOperationTimeout   = 10 * time.Minute
+	defaultOperationRetention = time.Hour
+)
+
+// NewOperationManager creates an operation manager
+func NewOperationManager(timeout, retention time.Duration) *OperationManager {
+	if timeout <= 0 {
+		timeout = defaultOperationTimeout
+	}
+	if retention <= 0 {
+		retention = defaultOperationRetention
+	}
+
+	return &OperationManager{
+		timeout:    timeout,
+		retention:  retention,
+		operations: make(map[string]*trackedOperation),
+	}
+}
+
+// Start registers a new operation on a workspace owned by userID and runs fn
+// in the background. The returned snapshot is in PENDING state.
+func (m *OperationManager) Start(opType models.OperationType, workspaceID, userID string, fn OperationFunc) *models.Operation {
+	now := time.Now()
+	tracked := &trackedOperation{
+		op: models.Operation{
+			ID:          newOperationID(),
+			Type:        opType,
+			WorkspaceID: workspaceID,
+			UserID:      userID,
+			Status:      models.OperationPending,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		subscribers: make(map[chan *models.Operation]struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, name := range models.OperationSteps[opType] {
+		tracked.op.Steps = append(tracked.op.Steps, models.OperationStep{Name: name, Status: models.StepPending})
+	}
+
+	m.mu.Lock()
+	m.purgeExpired(now)
+	m.operations[tracked.op.ID] = tracked
+	snapshot := tracked.snapshot()
+	m.mu.Unlock()
+
+	log.Printf("⏳ Operation %s started: %s workspace %s", snapshot.ID, opType, workspaceID)
+	go m.run(tracked, fn)
+	return snapshot
+}
+
+// Get returns a snapshot of the operation
+func (m *OperationManager) Get(id string) (*models.Operation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tracked, ok := m.operations[id]
+	if !ok {
+		return nil, models.ErrNotFound(fmt.Sprintf("operation %s not found", id))
+	}
+	return tracked.snapshot(), nil
+}
+
+// Active reports whether an operation for the workspace is still pending or running
+func (m *OperationManager) Active(workspaceID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, tracked := range m.operations {
+		if tracked.op.WorkspaceID == workspaceID && !tracked.op.Done() {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe returns the current snapshot and a channel receiving a snapshot
+// after every change. The channel is closed once the operation completes;
+// call unsubscribe when no longer interested.
+func (m *OperationManager) Subscribe(id string) (*models.Operation, <-chan *models.Operation, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracked, ok := m.operations[id]
+	if !ok {
+		return nil, nil, nil, models.ErrNotFound(fmt.Sprintf("operation %s not found", id))
+	}
+
+	updates := make(chan *models.Operation, 16)
+	if tracked.op.Done() {
+		close(updates)
+		return tracked.snapshot(), updates, func() {}, nil
+	}
+
+	tracked.subscribers[updates] = struct{}{}
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := tracked.subscribers[updates]; ok {
+			delete(tracked.subscribers, updates)
+			close(updates)
+		}
+	}
+	return tracked.snapshot(), updates, unsubscribe, nil
+}
+
+// Wait blocks until the operation completes or ctx is done
+func (m *OperationManager) Wait(ctx context.Context, id string) (*models.Operation, error) {
+	m.mu.RLock()
+	tracked, ok := m.operations[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, models.ErrNotFound(fmt.Sprintf("operation %s not found", id))
+	}
+
+	select {
+	case <-tracked.done:
+		return m.Get(id)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run executes the operation with its own deadline; the HTTP request that
+// started it has already returned
+func (m *OperationManager) run(tracked *trackedOperation, fn OperationFunc) {
+	id := tracked.op.ID
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.update(id, func(op *models.Operation) {
+		op.Status = models.OperationRunning
+	})
+
+	env, err := fn(withProgress(ctx, &progress{manager: m, id: id}))
+
+	m.update(id, func(op *models.Operation) {
+		now := time.Now()
+		op.CompletedAt = &now
+
+		if err != nil {
+			op.Status = models.OperationFailed
+			op.Error = operationError(err)
+			// Whatever was in flight is what failed
+			for i := range op.Steps {
+				if op.Steps[i].Status == models.StepRunning {
+					op.Steps[i].Status = models.StepFailed
+					op.Steps[i].Message = err.Error()
+					op.Steps[i].CompletedAt = &now
+				}
+			}
+			return
+		}
+
+		op.Status = models.OperationSucceeded
+		op.Environment = env
+	})
+
+	m.mu.Lock()
+	for updates := range tracked.subscribers {
+		close(updates)
+	}
+	tracked.subscribers = make(map[chan *models.Operation]struct{})
+	close(tracked.done)
+	m.mu.Unlock()
+
+	if err != nil {
+		log.Printf("❌ Operation %s failed: %v", id, err)
+	} else {
+		log.Printf("✅ Operation %s succeeded", id)
+	}
+}
+
+// update applies fn to the operation and notifies subscribers
+func (m *OperationManager) update(id string, fn func(op *models.Operation)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracked, ok := m.operations[id]
+	if !ok {
+		return
+	}
+
+	fn(&tracked.op)
+	tracked.op.UpdatedAt = time.Now()
+
+	snapshot := tracked.snapshot()
+	for updates := range tracked.subscribers {
+		select {
+		case updates <- snapshot:
+		default:
+			// Slow subscriber - it will catch up with the next snapshot
+		}
+	}
+}
+
+// updateStep sets the status and message of a named step
+func (m *OperationManager) updateStep(id, name string, status models.StepStatus, message string) {
+	m.update(id, func(op *models.Operation) {
+		now := time.Now()
+		for i := range op.Steps {
+			step := &op.Steps[i]
+			if step.Name != name {
+				continue
+			}
+
+			step.Status = status
+			step.Message = message
+			if status == models.StepRunning {
+				step.StartedAt = &now
+			} else {
+				if step.StartedAt == nil {
+					step.StartedAt = &now
+				}
+				step.CompletedAt = &now
+			}
+		}
+	})
+}
+
+// purgeExpired drops completed operations older than the retention period.
+// Callers must hold m.mu.
+func (m *OperationManager) purgeExpired(now time.Time) {
+	for id, tracked := range m.operations {
+		if tracked.op.CompletedAt != nil && now.Sub(*tracked.op.CompletedAt) > m.retention {
+			delete(m.operations, id)
+		}
+	}
+}
+
+// snapshot returns a deep copy that is safe to hand out. Callers must hold m.mu.
+func (t *trackedOperation) snapshot() *models.Operation {
+	op := t.op
+	op.Steps = append([]models.OperationStep(nil), t.op.Steps...)
+	return &op
+}
+
+// operationError converts a service error into the error reported on the operation
+func operationError(err error) *models.OperationError {
+	var appErr *models.AppError
+	if errors.As(err, &appErr) {
+		return &models.OperationError{Code: appErr.Code, Message: appErr.Message, Retryable: appErr.Retryable}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &models.OperationError{Code: "TIMEOUT", Message: "operation timed out"}
+	}
+	return &models.OperationError{Code: models.CodeInternalServer, Message: err.Error()}
+}
+
+func newOperationID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("failed to generate operation ID: %v", err))
+	}
+	return "op-" + hex.EncodeToString(b)
+}
+
+// Progress reporting
+//
+// Service methods report step progress through the context so the synchronous
+// methods keep their signatures; without an operation in the context the
+// reports are no-ops.
+
+type progressKey struct{}
+
+type progress struct {
+	manager *OperationManager
+	id      string
+}
+
+func withProgress(ctx context.Context, p *progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// reportStep records a step transition for the operation running in ctx, if any
+func reportStep(ctx context.Context, name string, status models.StepStatus, message string) {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return
+	}
+	p.manager.updateStep(p.id, name, status, message)
+}
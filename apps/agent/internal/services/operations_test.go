@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+func waitFor(t *testing.T, m *OperationManager, id string) *models.Operation {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	op, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	return op
+}
+
+func TestOperationManager_Succeeds(t *testing.T) {
+	m := NewOperationManager(time.Minute, time.Hour)
+
+	op := m.Start(models.OperationCreate, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		reportStep(ctx, models.StepVolumeCreated, models.StepRunning, "")
+		reportStep(ctx, models.StepVolumeCreated, models.StepDone, "fs-ws-1")
+		reportStep(ctx, models.StepIDEReachable, models.StepSkipped, "readiness check disabled")
+		return &models.Environment{ID: "ws-1"}, nil
+	})
+
+	if op.Status != models.OperationPending {
+		t.Errorf("Start() status = %v, want %v", op.Status, models.OperationPending)
+	}
+	if len(op.Steps) != len(models.OperationSteps[models.OperationCreate]) {
+		t.Errorf("Start() steps = %d, want %d", len(op.Steps), len(models.OperationSteps[models.OperationCreate]))
+	}
+
+	done := waitFor(t, m, op.ID)
+	if done.Status != models.OperationSucceeded {
+		t.Errorf("status = %v, want %v", done.Status, models.OperationSucceeded)
+	}
+	if done.Environment == nil || done.Environment.ID != "ws-1" {
+		t.Errorf("environment = %+v, want ws-1", done.Environment)
+	}
+	if done.CompletedAt == nil {
+		t.Error("CompletedAt not set")
+	}
+
+	want := map[string]models.StepStatus{
+		models.StepVolumeCreated:     models.StepDone,
+		models.StepContainerCreated:  models.StepPending,
+		models.StepRuntimeRunning:    models.StepPending,
+		models.StepFQDNAssigned:      models.StepPending,
+		models.StepIDEReachable:      models.StepSkipped,
+		models.StepSupervisorHealthy: models.StepPending,
+	}
+	for _, step := range done.Steps {
+		if step.Status != want[step.Name] {
+			t.Errorf("step %s = %v, want %v", step.Name, step.Status, want[step.Name])
+		}
+	}
+	if done.Steps[0].Message != "fs-ws-1" || done.Steps[0].StartedAt == nil || done.Steps[0].CompletedAt == nil {
+		t.Errorf("step %s = %+v, want message and timestamps", done.Steps[0].Name, done.Steps[0])
+	}
+}
+
+func TestOperationManager_Fails(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+	}{
+		{name: "app error keeps its code", err: models.ErrNotFound("volume missing"), wantCode: "NOT_FOUND"},
+		{name: "wrapped app error", err: errors.Join(models.ErrConflict("busy")), wantCode: "CONFLICT"},
+		{name: "plain error", err: errors.New("boom"), wantCode: "INTERNAL_SERVER_ERROR"},
+		{name: "deadline", err: context.DeadlineExceeded, wantCode: "TIMEOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewOperationManager(time.Minute, time.Hour)
+			op := m.Start(models.OperationStop, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+				reportStep(ctx, models.StepContainerDeleted, models.StepRunning, "")
+				return nil, tt.err
+			})
+
+			done := waitFor(t, m, op.ID)
+			if done.Status != models.OperationFailed {
+				t.Errorf("status = %v, want %v", done.Status, models.OperationFailed)
+			}
+			if done.Error == nil || done.Error.Code != tt.wantCode {
+				t.Errorf("error = %+v, want code %v", done.Error, tt.wantCode)
+			}
+			if done.Steps[0].Status != models.StepFailed {
+				t.Errorf("running step = %v, want %v", done.Steps[0].Status, models.StepFailed)
+			}
+		})
+	}
+}
+
+func TestOperationManager_Subscribe(t *testing.T) {
+	m := NewOperationManager(time.Minute, time.Hour)
+	release := make(chan struct{})
+
+	op := m.Start(models.OperationStop, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		<-release
+		reportStep(ctx, models.StepContainerDeleted, models.StepDone, "aci-ws-1")
+		return nil, nil
+	})
+
+	_, updates, unsubscribe, err := m.Subscribe(op.ID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer unsubscribe()
+	close(release)
+
+	var last *models.Operation
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case snapshot, ok := <-updates:
+			if !ok {
+				if last == nil || last.Status != models.OperationSucceeded {
+					t.Errorf("last update = %+v, want %v", last, models.OperationSucceeded)
+				}
+
+				// Subscribing after completion returns the final state and a closed channel
+				final, closed, _, err := m.Subscribe(op.ID)
+				if err != nil || final.Status != models.OperationSucceeded {
+					t.Errorf("Subscribe() after completion = %+v, %v", final, err)
+				}
+				if _, ok := <-closed; ok {
+					t.Error("Subscribe() after completion returned an open channel")
+				}
+				return
+			}
+			last = snapshot
+		case <-timeout:
+			t.Fatal("updates channel was not closed")
+		}
+	}
+}
+
+func TestOperationManager_NotFound(t *testing.T) {
+	m := NewOperationManager(time.Minute, time.Hour)
+
+	if _, err := m.Get("op-missing"); err == nil {
+		t.Error("Get() error = nil, want NOT_FOUND")
+	} else if appErr, ok := err.(*models.AppError); !ok || appErr.Code != "NOT_FOUND" {
+		t.Errorf("Get() error = %v, want NOT_FOUND", err)
+	}
+	if _, _, _, err := m.Subscribe("op-missing"); err == nil {
+		t.Error("Subscribe() error = nil, want NOT_FOUND")
+	}
+}
+
+func TestOperationManager_PurgesExpired(t *testing.T) {
+	m := NewOperationManager(time.Minute, time.Millisecond)
+
+	op := m.Start(models.OperationStop, "ws-1", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		return nil, nil
+	})
+	waitFor(t, m, op.ID)
+	time.Sleep(5 * time.Millisecond)
+
+	// Starting another operation drops the expired one
+	m.Start(models.OperationStop, "ws-2", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		return nil, nil
+	})
+	if _, err := m.Get(op.ID); err == nil {
+		t.Errorf("Get(%s) after retention = nil error, want NOT_FOUND", op.ID)
+	}
+}
+
+func TestReportStep_WithoutOperation(t *testing.T) {
+	// Synchronous callers have no operation in the context
+	reportStep(context.Background(), models.StepVolumeCreated, models.StepDone, "")
+}
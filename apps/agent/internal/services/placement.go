@@ -0,0 +1,205 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/store"
+)
+
+// RegionPlacer picks the region of workspaces created with cloudRegion "auto".
+// Among the provider's healthy regions with room for the workspace's cores it
+// prefers those matching the client's location hint, then draws one at random
+// in proportion to its weight and free capacity. A placed workspace holds its
+// cores until its create finishes so concurrent placements see each other.
+type RegionPlacer struct {
+	service *EnvironmentService
+	random  func() float64 // Returns a number in [0, 1)
+
+	mu      sync.Mutex
+	pending map[string]placement // Workspace ID -> placement whose create is in flight
+}
+
+// placement is the region and cores of a workspace counted against region capacity
+type placement struct {
+	region string
+	cores  int
+}
+
+// regionCandidate is a region eligible for a workspace
+type regionCandidate struct {
+	name   string
+	weight float64 // Configured weight scaled by free capacity
+	match  int     // How well the region matches the location hint (0 = not at all)
+}
+
+// NewRegionPlacer creates the placer for the service's workspaces
+func NewRegionPlacer(service *EnvironmentService) *RegionPlacer {
+	return &RegionPlacer{
+		service: service,
+		random:  rand.Float64,
+		pending: make(map[string]placement),
+	}
+}
+
+// Place chooses the region for a new workspace and holds its cores there.
+// Callers must Release the workspace once its create has finished or failed.
+func (p *RegionPlacer) Place(computeProvider provider.ComputeProvider, req *models.CreateEnvironmentRequest) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	usage, err := p.usage()
+	if err != nil {
+		return "", err
+	}
+
+	hint := normaliseLocation(req.LocationHint)
+	var (
+		candidates      []regionCandidate
+		unhealthy, full int
+	)
+	for _, name := range computeProvider.Regions() {
+		weight, maxCores := 1, 0
+		location := name
+		if region := p.service.config.GetRegion(name); region != nil {
+			weight, maxCores, location = region.Weight, region.MaxCores, region.Location
+		}
+		if weight <= 0 {
+			continue
+		}
+		if !provider.RegionHealthy(computeProvider, name) {
+			unhealthy++
+			continue
+		}
+
+		candidate := regionCandidate{name: name, weight: float64(weight), match: locationMatch(hint, name, location)}
+		if maxCores > 0 {
+			if usage[name]+req.CPUCores > maxCores {
+				full++
+				continue
+			}
+			// Spread load: a region with more free cores draws more workspaces
+			candidate.weight *= float64(maxCores-usage[name]) / float64(maxCores)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	if len(candidates) == 0 {
+		switch {
+		case full > 0:
+			return "", models.ErrCloudQuotaExceeded(fmt.Sprintf("no region has capacity for %d more CPU cores", req.CPUCores))
+		case unhealthy > 0:
+			return "", models.ErrCloudUnavailable("no healthy region is available; retry later")
+		}
+		return "", models.ErrInvalidRequest("no region is available for automatic placement; set cloudRegion")
+	}
+
+	region := p.choose(bestMatches(candidates))
+	p.pending[req.WorkspaceID] = placement{region: region, cores: req.CPUCores}
+	log.Printf("📍 Placed workspace %s in region %s (auto, hint %q)", req.WorkspaceID, region, req.LocationHint)
+	return region, nil
+}
+
+// Release drops the hold of a workspace placed by Place
+func (p *RegionPlacer) Release(workspaceID string) {
+	p.mu.Lock()
+	delete(p.pending, workspaceID)
+	p.mu.Unlock()
+}
+
+// usage returns the CPU cores in use per region: recorded workspaces that run
+// or are about to, overlaid with the pending placements. Callers must hold p.mu.
+func (p *RegionPlacer) usage() (map[string]int, error) {
+	workspaces := make(map[string]placement, len(p.pending))
+	if p.service.store != nil {
+		envs, _, err := p.service.store.List(store.Filter{})
+		if err != nil {
+			return nil, models.ErrInternalServer(fmt.Sprintf("failed to read region usage: %v", err))
+		}
+		for _, env := range envs {
+			if consumesCompute(env.Status) {
+				workspaces[env.ID] = placement{region: env.CloudRegion, cores: env.CPUCores}
+			}
+		}
+	}
+	for id, pending := range p.pending {
+		workspaces[id] = pending
+	}
+
+	usage := make(map[string]int)
+	for _, workspace := range workspaces {
+		usage[workspace.region] += workspace.cores
+	}
+	return usage, nil
+}
+
+// choose draws a candidate at random in proportion to its weight
+func (p *RegionPlacer) choose(candidates []regionCandidate) string {
+	var total float64
+	for _, candidate := range candidates {
+		total += candidate.weight
+	}
+
+	draw := p.random() * total
+	for _, candidate := range candidates {
+		if draw < candidate.weight {
+			return candidate.name
+		}
+		draw -= candidate.weight
+	}
+	return candidates[len(candidates)-1].name
+}
+
+// bestMatches keeps the candidates that match the location hint best; all of
+// them when none matches
+func bestMatches(candidates []regionCandidate) []regionCandidate {
+	best := 0
+	for _, candidate := range candidates {
+		if candidate.match > best {
+			best = candidate.match
+		}
+	}
+
+	var matches []regionCandidate
+	for _, candidate := range candidates {
+		if candidate.match == best {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// locationMatch scores a region against a normalised location hint: 2 when the
+// hint names the region or its location, 1 when it is part of either
+// (e.g. "europe" for westeurope), 0 otherwise
+func locationMatch(hint, name, location string) int {
+	if hint == "" {
+		return 0
+	}
+
+	name, location = normaliseLocation(name), normaliseLocation(location)
+	switch {
+	case hint == name || hint == location:
+		return 2
+	case strings.Contains(name, hint) || strings.Contains(location, hint):
+		return 1
+	}
+	return 0
+}
+
+// normaliseLocation lowercases a region or location and drops separators, so
+// "West Europe", "west-europe" and "westeurope" compare equal
+func normaliseLocation(location string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(location)))
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// regionHealthProvider marks some regions of the fake provider unhealthy
+type regionHealthProvider struct {
+	*fake.Provider
+	unhealthy map[string]bool
+}
+
+func (p *regionHealthProvider) RegionHealth() []models.RegionHealth {
+	var health []models.RegionHealth
+	for _, region := range p.Regions() {
+		health = append(health, models.RegionHealth{Region: region, Healthy: !p.unhealthy[region]})
+	}
+	return health
+}
+
+// newPlacementFixture returns a stateful service with eastus, westeurope and centralindia
+func newPlacementFixture(t *testing.T, regions []config.RegionConfig) *EnvironmentService {
+	t.Helper()
+
+	service, err := NewEnvironmentService(&config.Config{
+		StatePath: filepath.Join(t.TempDir(), "state.db"),
+		Azure:     config.AzureConfig{Regions: regions},
+	}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	t.Cleanup(service.Close)
+	return service
+}
+
+func testRegions() []config.RegionConfig {
+	return []config.RegionConfig{
+		{Name: "eastus", Location: "East US", Enabled: true, Weight: 1},
+		{Name: "westeurope", Location: "West Europe", Enabled: true, Weight: 1},
+		{Name: "centralindia", Location: "Central India", Enabled: true, Weight: 1},
+	}
+}
+
+func TestRegionPlacer_Place(t *testing.T) {
+	tests := []struct {
+		name      string
+		regions   func([]config.RegionConfig)
+		unhealthy map[string]bool
+		hint      string
+		random    float64
+		want      string
+		wantCode  string
+	}{
+		{name: "weighted draw picks first", random: 0.1, want: "eastus"},
+		{name: "weighted draw picks last", random: 0.9, want: "centralindia"},
+		{
+			name:    "weights skew the draw",
+			regions: func(r []config.RegionConfig) { r[0].Weight = 8 },
+			random:  0.75,
+			want:    "eastus",
+		},
+		{
+			name:    "zero weight excludes a region",
+			regions: func(r []config.RegionConfig) { r[0].Weight = 0 },
+			random:  0,
+			want:    "westeurope",
+		},
+		{name: "exact hint", hint: "West Europe", random: 0.9, want: "westeurope"},
+		{name: "partial hint", hint: "india", random: 0, want: "centralindia"},
+		{name: "unmatched hint is ignored", hint: "mars", random: 0, want: "eastus"},
+		{name: "unhealthy region is skipped", unhealthy: map[string]bool{"eastus": true}, random: 0, want: "westeurope"},
+		{
+			name:      "hint falls back when its region is unhealthy",
+			unhealthy: map[string]bool{"westeurope": true},
+			hint:      "westeurope",
+			random:    0.9,
+			want:      "centralindia",
+		},
+		{
+			name:      "every region unhealthy",
+			unhealthy: map[string]bool{"eastus": true, "westeurope": true, "centralindia": true},
+			wantCode:  models.CodeCloudUnavailable,
+		},
+		{
+			name: "full region is skipped",
+			regions: func(r []config.RegionConfig) {
+				r[0].MaxCores = 4 // ws-running already uses 4 cores in eastus
+			},
+			random: 0,
+			want:   "westeurope",
+		},
+		{
+			name: "every region full",
+			regions: func(r []config.RegionConfig) {
+				r[0].MaxCores, r[1].MaxCores, r[2].MaxCores = 5, 1, 1
+			},
+			wantCode: models.CodeCloudQuotaExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions := testRegions()
+			if tt.regions != nil {
+				tt.regions(regions)
+			}
+			service := newPlacementFixture(t, regions)
+			service.placer.random = func() float64 { return tt.random }
+			service.saveEnvironment(&models.Environment{ID: "ws-running", CloudRegion: "eastus", Status: models.StatusRunning, CPUCores: 4})
+			service.saveEnvironment(&models.Environment{ID: "ws-stopped", CloudRegion: "westeurope", Status: models.StatusStopped, CPUCores: 4})
+
+			computeProvider := &regionHealthProvider{
+				Provider:  fake.NewProvider(fake.Options{Regions: []string{"eastus", "westeurope", "centralindia"}}),
+				unhealthy: tt.unhealthy,
+			}
+			got, err := service.placer.Place(computeProvider, &models.CreateEnvironmentRequest{
+				WorkspaceID:  "ws-new",
+				CPUCores:     2,
+				LocationHint: tt.hint,
+			})
+
+			if tt.wantCode != "" {
+				var appErr *models.AppError
+				if !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+					t.Fatalf("Place() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Place() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Place() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegionPlacer_HoldsCoresUntilReleased(t *testing.T) {
+	regions := testRegions()[:1]
+	regions[0].MaxCores = 4
+	service := newPlacementFixture(t, regions)
+	computeProvider := fake.NewProvider(fake.Options{Regions: []string{"eastus"}})
+
+	place := func(id string) error {
+		_, err := service.placer.Place(computeProvider, &models.CreateEnvironmentRequest{WorkspaceID: id, CPUCores: 4})
+		return err
+	}
+
+	if err := place("ws-1"); err != nil {
+		t.Fatalf("Place(ws-1) error = %v", err)
+	}
+	if err := place("ws-2"); err == nil {
+		t.Error("Place(ws-2) succeeded while ws-1 holds the region's cores")
+	}
+
+	service.placer.Release("ws-1")
+	if err := place("ws-2"); err != nil {
+		t.Errorf("Place(ws-2) error = %v after ws-1 was released", err)
+	}
+}
+
+func TestNewEnvironmentService_RegionCoresRequireState(t *testing.T) {
+	regions := testRegions()
+	regions[0].MaxCores = 10
+
+	_, err := NewEnvironmentService(&config.Config{Azure: config.AzureConfig{Regions: regions}}, provider.NewRegistry(models.ProviderAzure))
+	if err == nil {
+		t.Error("NewEnvironmentService() succeeded with region core limits but no state store")
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// Preview token kinds: the owner's browser opens a link once and trades it
+// for a session cookie scoped to the preview host
+const (
+	previewKindLink    = "preview-link"
+	previewKindSession = "preview-session"
+)
+
+const (
+	// PreviewAuthPath is the path on a preview host that trades a link token for a session
+	PreviewAuthPath = "/__dev8/preview-auth"
+	// PreviewCookie holds the session of a private preview
+	PreviewCookie = "dev8_preview"
+)
+
+// Previews builds the preview URLs of workspace app ports,
+// https://{port}-{workspaceId}.preview.{domain}, and signs the links and
+// sessions that let owners open private ones
+type Previews struct {
+	domain     string
+	linkTTL    time.Duration
+	sessionTTL time.Duration
+	signer     *tokenSigner
+}
+
+// NewPreviews creates the preview signer, generating a random key when none is configured
+func NewPreviews(cfg config.PreviewConfig) (*Previews, error) {
+	signer, err := newTokenSigner(cfg.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Enabled() && cfg.Secret == "" {
+		log.Printf("Warning: PREVIEW_SECRET not set - private preview sessions end when the agent restarts")
+	}
+
+	return &Previews{
+		domain:     cfg.Domain,
+		linkTTL:    cfg.LinkTTL,
+		sessionTTL: cfg.SessionTTL,
+		signer:     signer,
+	}, nil
+}
+
+// Enabled reports whether preview URLs are served
+func (p *Previews) Enabled() bool {
+	return p.domain != ""
+}
+
+// URL returns the preview URL of a workspace port
+func (p *Previews) URL(workspaceID string, port int) string {
+	return fmt.Sprintf("https://%d-%s.preview.%s", port, workspaceID, p.domain)
+}
+
+// URLs returns the preview URLs of a workspace's app ports, or nil when
+// previews are disabled
+func (p *Previews) URLs(workspaceID string, ports, publicPorts []int) map[int]models.PreviewURL {
+	if !p.Enabled() || len(ports) == 0 {
+		return nil
+	}
+
+	urls := make(map[int]models.PreviewURL, len(ports))
+	for _, port := range ports {
+		visibility := models.PreviewPrivate
+		if slices.Contains(publicPorts, port) {
+			visibility = models.PreviewPublic
+		}
+		urls[port] = models.PreviewURL{URL: p.URL(workspaceID, port), Visibility: visibility}
+	}
+	return urls
+}
+
+// ParseHost returns the workspace and port a preview host name points to
+func (p *Previews) ParseHost(host string) (workspaceID string, port int, ok bool) {
+	if !p.Enabled() {
+		return "", 0, false
+	}
+	label, found := hostLabel(host, "preview."+p.domain)
+	if !found {
+		return "", 0, false
+	}
+	portLabel, workspaceID, found := strings.Cut(label, "-")
+	if !found || workspaceID == "" {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(portLabel)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, false
+	}
+	return workspaceID, port, true
+}
+
+// Link returns a short-lived link that signs the owner's browser in to a preview
+func (p *Previews) Link(workspaceID string, port int) models.AccessLink {
+	token, expiry := p.signer.mintAccess(previewKindLink, workspaceID, port, p.linkTTL)
+	return accessLink(p.URL(workspaceID, port)+PreviewAuthPath, token, expiry)
+}
+
+// Session returns a session token for the preview cookie and when it expires
+func (p *Previews) Session(workspaceID string, port int) (string, time.Time) {
+	return p.signer.mintAccess(previewKindSession, workspaceID, port, p.sessionTTL)
+}
+
+// VerifyLink checks a link token was minted for the workspace port and has not expired
+func (p *Previews) VerifyLink(token, workspaceID string, port int) error {
+	return p.signer.verifyAccess(previewKindLink, token, workspaceID, port)
+}
+
+// VerifySession checks a session token was minted for the workspace port and has not expired
+func (p *Previews) VerifySession(token, workspaceID string, port int) error {
+	return p.signer.verifyAccess(previewKindSession, token, workspaceID, port)
+}
+
+// PreviewTarget returns the recorded workspace behind a preview and the app
+// URL its port forwards to
+func (s *EnvironmentService) PreviewTarget(ctx context.Context, workspaceID string, port int) (*models.Environment, string, error) {
+	if !s.previews.Enabled() {
+		return nil, "", models.ErrNotFound("previews are not enabled")
+	}
+	env, err := s.GetEnvironment(ctx, workspaceID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !slices.Contains(env.Ports, port) {
+		return nil, "", models.ErrNotFound(fmt.Sprintf("port %d of workspace %s is not exposed", port, workspaceID))
+	}
+
+	target := env.ConnectionURLs.AppURLs[port]
+	if env.Status != models.StatusRunning || target == "" {
+		return nil, "", models.ErrCloudUnavailable(fmt.Sprintf("workspace %s is not running", workspaceID))
+	}
+	return env, target, nil
+}
+
+// PreviewLink returns a link signing the owner in to the preview of an exposed port
+func (s *EnvironmentService) PreviewLink(env *models.Environment, port int) (*models.AccessLink, error) {
+	if !s.previews.Enabled() {
+		return nil, models.ErrInvalidRequest("previews are not enabled - set PREVIEW_DOMAIN")
+	}
+	if !slices.Contains(env.Ports, port) {
+		return nil, models.ErrNotFound(fmt.Sprintf("port %d of workspace %s is not exposed", port, env.ID))
+	}
+	link := s.previews.Link(env.ID, port)
+	return &link, nil
+}
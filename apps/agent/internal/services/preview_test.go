@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+)
+
+func newTestPreviews(t *testing.T) *Previews {
+	t.Helper()
+
+	previews, err := NewPreviews(config.PreviewConfig{Domain: "dev8.test", Secret: "test-secret", LinkTTL: time.Minute, SessionTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewPreviews() error = %v", err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	previews.signer.now = func() time.Time { return now }
+	return previews
+}
+
+func TestPreviews_ParseHost(t *testing.T) {
+	previews := newTestPreviews(t)
+
+	tests := []struct {
+		host            string
+		wantWorkspaceID string
+		wantPort        int
+		wantOK          bool
+	}{
+		{"3000-ws-1.preview.dev8.test", "ws-1", 3000, true},
+		{"3000-WS-1.Preview.Dev8.Test:443", "ws-1", 3000, true},
+		{"preview.dev8.test", "", 0, false},
+		{"dev8.test", "", 0, false},
+		{"3000-ws-1.preview.other.test", "", 0, false},
+		{"a.3000-ws-1.preview.dev8.test", "", 0, false},
+		{"ws-1.preview.dev8.test", "", 0, false},
+		{"3000-.preview.dev8.test", "", 0, false},
+		{"70000-ws-1.preview.dev8.test", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			workspaceID, port, ok := previews.ParseHost(tt.host)
+			if workspaceID != tt.wantWorkspaceID || port != tt.wantPort || ok != tt.wantOK {
+				t.Errorf("ParseHost() = %q, %d, %v, want %q, %d, %v", workspaceID, port, ok, tt.wantWorkspaceID, tt.wantPort, tt.wantOK)
+			}
+		})
+	}
+
+	disabled, err := NewPreviews(config.PreviewConfig{})
+	if err != nil {
+		t.Fatalf("NewPreviews() error = %v", err)
+	}
+	if _, _, ok := disabled.ParseHost("3000-ws-1.preview.dev8.test"); ok {
+		t.Errorf("ParseHost() with previews disabled = true, want false")
+	}
+}
+
+func TestPreviews_Tokens(t *testing.T) {
+	previews := newTestPreviews(t)
+	link := previews.Link("ws-1", 3000)
+	session, _ := previews.Session("ws-1", 3000)
+	linkToken := link.URL[len("https://3000-ws-1.preview.dev8.test"+PreviewAuthPath+"?token="):]
+
+	tests := []struct {
+		name    string
+		verify  func(token, workspaceID string, port int) error
+		token   string
+		port    int
+		after   time.Duration
+		wantErr bool
+	}{
+		{"valid link", previews.VerifyLink, linkToken, 3000, 0, false},
+		{"valid session", previews.VerifySession, session, 3000, 0, false},
+		{"link used as session", previews.VerifySession, linkToken, 3000, 0, true},
+		{"session used as link", previews.VerifyLink, session, 3000, 0, true},
+		{"other port", previews.VerifySession, session, 3001, 0, true},
+		{"expired link", previews.VerifyLink, linkToken, 3000, time.Minute, true},
+		{"session outlives link", previews.VerifySession, session, 3000, time.Minute, false},
+		{"expired session", previews.VerifySession, session, 3000, time.Hour, true},
+		{"malformed", previews.VerifySession, "v1.garbage", 3000, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC).Add(tt.after)
+			previews.signer.now = func() time.Time { return now }
+
+			err := tt.verify(tt.token, "ws-1", tt.port)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
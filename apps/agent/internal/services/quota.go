@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/store"
+)
+
+// QuotaManager enforces per-user and per-organisation limits on running
+// workspaces, CPU, memory and storage. Usage is derived from the state store;
+// workspaces being created or started hold a reservation until their
+// operation finishes so concurrent requests cannot overshoot a limit.
+type QuotaManager struct {
+	service *EnvironmentService
+	config  config.QuotaConfig
+
+	mu       sync.Mutex
+	reserved map[string]models.Environment // Workspace ID -> resources of an in-flight create/start
+}
+
+// NewQuotaManager creates the quota manager for the service's workspaces
+func NewQuotaManager(service *EnvironmentService, cfg config.QuotaConfig) *QuotaManager {
+	return &QuotaManager{
+		service:  service,
+		config:   cfg,
+		reserved: make(map[string]models.Environment),
+	}
+}
+
+// Reserve admits a workspace that is about to run, failing with QUOTA_EXCEEDED
+// if it would take its user or organisation over a limit. newVolume is set when
+// the workspace also adds storage (create). Callers must Release the reservation.
+func (q *QuotaManager) Reserve(env *models.Environment, newVolume bool) error {
+	if !q.config.Enabled() {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	workspaces, err := q.workspaces()
+	if err != nil {
+		return err
+	}
+
+	request := *env
+	request.Status = models.StatusStarting
+	if existing, ok := workspaces[env.ID]; ok && existing.StorageGB > request.StorageGB {
+		request.StorageGB = existing.StorageGB
+	}
+	workspaces[env.ID] = request
+
+	delta := models.QuotaResources{RunningWorkspaces: 1, CPUCores: env.CPUCores, MemoryGB: env.MemoryGB}
+	if newVolume {
+		delta.StorageGB = env.StorageGB
+	}
+
+	checks := []*models.QuotaStatus{q.status(workspaces, models.QuotaScopeUser, env.UserID)}
+	if env.OrgID != "" {
+		checks = append(checks, q.status(workspaces, models.QuotaScopeOrg, env.OrgID))
+	}
+	for _, status := range checks {
+		if exceeded := exceededResources(status, delta); len(exceeded) > 0 {
+			return models.ErrQuotaExceeded(fmt.Sprintf("%s %s quota exceeded: %s",
+				strings.ToLower(string(status.Scope)), status.ID, strings.Join(exceeded, ", ")))
+		}
+	}
+
+	q.reserved[env.ID] = request
+	return nil
+}
+
+// Release drops the reservation of a workspace whose create/start has finished
+func (q *QuotaManager) Release(workspaceID string) {
+	q.mu.Lock()
+	delete(q.reserved, workspaceID)
+	q.mu.Unlock()
+}
+
+// Usage returns the current usage of a user (and optionally an organisation) against its limits
+func (q *QuotaManager) Usage(userID, orgID string) (*models.QuotaUsageResponse, error) {
+	if q.service.store == nil {
+		return nil, ErrStateless
+	}
+	if userID == "" {
+		return nil, models.ErrInvalidRequest("userId is required")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	workspaces, err := q.workspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &models.QuotaUsageResponse{User: q.status(workspaces, models.QuotaScopeUser, userID)}
+	if orgID != "" {
+		usage.Org = q.status(workspaces, models.QuotaScopeOrg, orgID)
+	}
+	return usage, nil
+}
+
+// workspaces returns every recorded workspace overlaid with the reservations. Callers must hold q.mu.
+func (q *QuotaManager) workspaces() (map[string]models.Environment, error) {
+	envs, _, err := q.service.store.List(store.Filter{})
+	if err != nil {
+		return nil, models.ErrInternalServer(fmt.Sprintf("failed to read quota usage: %v", err))
+	}
+
+	workspaces := make(map[string]models.Environment, len(envs)+len(q.reserved))
+	for _, env := range envs {
+		workspaces[env.ID] = env
+	}
+	for id, reservation := range q.reserved {
+		if existing, ok := workspaces[id]; ok && existing.StorageGB > reservation.StorageGB {
+			reservation.StorageGB = existing.StorageGB
+		}
+		workspaces[id] = reservation
+	}
+	return workspaces, nil
+}
+
+// status sums the usage of a user or organisation. Callers must hold q.mu.
+func (q *QuotaManager) status(workspaces map[string]models.Environment, scope models.QuotaScope, id string) *models.QuotaStatus {
+	limits := q.config.ForOrg(id)
+	if scope == models.QuotaScopeUser {
+		limits = q.config.ForUser(id)
+	}
+
+	status := &models.QuotaStatus{
+		Scope: scope,
+		ID:    id,
+		Limits: models.QuotaResources{
+			RunningWorkspaces: limits.RunningWorkspaces,
+			CPUCores:          limits.CPUCores,
+			MemoryGB:          limits.MemoryGB,
+			StorageGB:         limits.StorageGB,
+		},
+	}
+
+	for _, env := range workspaces {
+		owner := env.UserID
+		if scope == models.QuotaScopeOrg {
+			owner = env.OrgID
+		}
+		if owner != id {
+			continue
+		}
+
+		status.Usage.StorageGB += env.StorageGB
+		if consumesCompute(env.Status) {
+			status.Usage.RunningWorkspaces++
+			status.Usage.CPUCores += env.CPUCores
+			status.Usage.MemoryGB += env.MemoryGB
+		}
+	}
+	return status
+}
+
+// consumesCompute reports whether a workspace in the status holds a runtime
+func consumesCompute(status models.EnvironmentStatus) bool {
+	switch status {
+	case models.StatusCreating, models.StatusStarting, models.StatusRunning:
+		return true
+	}
+	return false
+}
+
+// exceededResources lists the limits the usage exceeds among the resources the request adds
+func exceededResources(status *models.QuotaStatus, delta models.QuotaResources) []string {
+	checks := []struct {
+		name         string
+		delta        int
+		usage, limit int
+	}{
+		{"runningWorkspaces", delta.RunningWorkspaces, status.Usage.RunningWorkspaces, status.Limits.RunningWorkspaces},
+		{"cpuCores", delta.CPUCores, status.Usage.CPUCores, status.Limits.CPUCores},
+		{"memoryGB", delta.MemoryGB, status.Usage.MemoryGB, status.Limits.MemoryGB},
+		{"storageGB", delta.StorageGB, status.Usage.StorageGB, status.Limits.StorageGB},
+	}
+
+	var exceeded []string
+	for _, check := range checks {
+		if check.delta > 0 && check.limit > 0 && check.usage > check.limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s %d/%d", check.name, check.usage, check.limit))
+		}
+	}
+	return exceeded
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// newQuotaFixture returns a stateful service enforcing the quotas
+func newQuotaFixture(t *testing.T, quota config.QuotaConfig) *EnvironmentService {
+	t.Helper()
+
+	service, err := NewEnvironmentService(&config.Config{
+		StatePath: filepath.Join(t.TempDir(), "state.db"),
+		Quota:     quota,
+	}, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	t.Cleanup(service.Close)
+	return service
+}
+
+func TestQuotaManager_Reserve(t *testing.T) {
+	service := newQuotaFixture(t, config.QuotaConfig{
+		Default: config.QuotaLimits{RunningWorkspaces: 2, CPUCores: 8, StorageGB: 100},
+		Users:   map[string]config.QuotaLimits{"user-big": {CPUCores: 64}},
+		Orgs:    map[string]config.QuotaLimits{"org-1": {MemoryGB: 16}},
+	})
+
+	// user-1 runs a 4-core workspace and has a stopped 50GB one
+	service.saveEnvironment(&models.Environment{ID: "ws-running", UserID: "user-1", OrgID: "org-1", Status: models.StatusRunning, CPUCores: 4, MemoryGB: 8, StorageGB: 20})
+	service.saveEnvironment(&models.Environment{ID: "ws-stopped", UserID: "user-1", Status: models.StatusStopped, CPUCores: 4, MemoryGB: 8, StorageGB: 50})
+
+	tests := []struct {
+		name      string
+		env       models.Environment
+		newVolume bool
+		wantErr   bool
+	}{
+		{name: "within limits", env: models.Environment{ID: "ws-new", UserID: "user-1", CPUCores: 4, MemoryGB: 8, StorageGB: 20}, newVolume: true},
+		{name: "cpu exceeded", env: models.Environment{ID: "ws-new", UserID: "user-1", CPUCores: 8, MemoryGB: 8, StorageGB: 20}, newVolume: true, wantErr: true},
+		{name: "storage exceeded", env: models.Environment{ID: "ws-new", UserID: "user-1", CPUCores: 2, MemoryGB: 4, StorageGB: 40}, newVolume: true, wantErr: true},
+		{name: "start adds no storage", env: models.Environment{ID: "ws-stopped", UserID: "user-1", CPUCores: 4, MemoryGB: 8}},
+		{name: "org memory exceeded", env: models.Environment{ID: "ws-new", UserID: "user-2", OrgID: "org-1", CPUCores: 2, MemoryGB: 16, StorageGB: 10}, newVolume: true, wantErr: true},
+		{name: "user override", env: models.Environment{ID: "ws-new", UserID: "user-big", CPUCores: 32, MemoryGB: 64, StorageGB: 500}, newVolume: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.quotas.Reserve(&tt.env, tt.newVolume)
+			defer service.quotas.Release(tt.env.ID)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reserve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if appErr, ok := err.(*models.AppError); tt.wantErr && (!ok || appErr.Code != "QUOTA_EXCEEDED") {
+				t.Errorf("Reserve() error = %v, want QUOTA_EXCEEDED", err)
+			}
+		})
+	}
+}
+
+func TestQuotaManager_ReservationsCountUntilReleased(t *testing.T) {
+	service := newQuotaFixture(t, config.QuotaConfig{Default: config.QuotaLimits{RunningWorkspaces: 1}})
+
+	first := &models.Environment{ID: "ws-1", UserID: "user-1", CPUCores: 2, MemoryGB: 4, StorageGB: 10}
+	if err := service.quotas.Reserve(first, true); err != nil {
+		t.Fatalf("Reserve(first) error = %v", err)
+	}
+
+	// A concurrent create must not slip past the limit
+	second := &models.Environment{ID: "ws-2", UserID: "user-1", CPUCores: 2, MemoryGB: 4, StorageGB: 10}
+	if err := service.quotas.Reserve(second, true); err == nil {
+		t.Fatal("Reserve(second) error = nil, want QUOTA_EXCEEDED")
+	}
+
+	usage, err := service.quotas.Usage("user-1", "")
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	want := models.QuotaResources{RunningWorkspaces: 1, CPUCores: 2, MemoryGB: 4, StorageGB: 10}
+	if usage.User.Usage != want || usage.User.Limits.RunningWorkspaces != 1 || usage.Org != nil {
+		t.Errorf("Usage() = %+v, want usage %+v", usage.User, want)
+	}
+
+	// The create failed without recording the workspace
+	service.quotas.Release("ws-1")
+	if err := service.quotas.Reserve(second, true); err != nil {
+		t.Errorf("Reserve(second) after release error = %v", err)
+	}
+}
+
+func TestNewEnvironmentService_QuotasRequireStateStore(t *testing.T) {
+	_, err := NewEnvironmentService(&config.Config{
+		Quota: config.QuotaConfig{Default: config.QuotaLimits{CPUCores: 8}},
+	}, provider.NewRegistry(models.ProviderAzure))
+	if err == nil {
+		t.Error("NewEnvironmentService() error = nil, want error without AGENT_STATE_PATH")
+	}
+}
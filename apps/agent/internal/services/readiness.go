@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// Defaults used when the configuration leaves the readiness settings unset
+const (
+	defaultReadinessPollInterval   = 2 * time.Second
+	defaultReadinessRuntimeTimeout = 5 * time.Minute
+)
+
+// readiness is how far a created or started workspace got towards serving traffic
+type readiness struct {
+	runtime *provider.Runtime        // Latest runtime seen; nil if it could never be read
+	status  models.EnvironmentStatus // RUNNING, STARTING (still booting) or ERROR
+	reason  string                   // Why the workspace is not RUNNING
+}
+
+// awaitReady polls the runtime until it is running with an FQDN, then probes
+// the IDE port and the supervisor /health endpoint. Every wait is bounded by
+// its READINESS_* timeout: a timeout leaves the workspace STARTING, a failed
+// runtime puts it in ERROR. Probes after the first unmet one are skipped.
+func (s *EnvironmentService) awaitReady(ctx context.Context, computeProvider provider.ComputeProvider, region, name string) readiness {
+	result := s.waitForRuntime(ctx, computeProvider, region, name)
+	if result.status != models.StatusRunning {
+		skipSteps(ctx, result.reason, models.StepIDEReachable, models.StepSupervisorHealthy)
+		return result
+	}
+
+	if reason := s.waitForIDE(ctx, result.runtime); reason != "" {
+		skipSteps(ctx, reason, models.StepSupervisorHealthy)
+		return readiness{runtime: result.runtime, status: models.StatusStarting, reason: reason}
+	}
+	if reason := s.waitForSupervisor(ctx, result.runtime); reason != "" {
+		return readiness{runtime: result.runtime, status: models.StatusStarting, reason: reason}
+	}
+	return result
+}
+
+// waitForRuntime polls the runtime until it is running and has an FQDN
+func (s *EnvironmentService) waitForRuntime(ctx context.Context, computeProvider provider.ComputeProvider, region, name string) readiness {
+	timeout := s.config.Readiness.RuntimeTimeout
+	if timeout <= 0 {
+		timeout = defaultReadinessRuntimeTimeout
+	}
+
+	var (
+		runtime *provider.Runtime
+		lastErr error
+		running bool
+	)
+	reportStep(ctx, models.StepRuntimeRunning, models.StepRunning, "")
+	err := poll(ctx, s.pollInterval(), timeout, func(ctx context.Context) (bool, error) {
+		current, err := computeProvider.GetRuntime(ctx, region, name)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		runtime = current
+
+		switch current.State {
+		case provider.StateFailed:
+			return false, fmt.Errorf("container group %s failed", name)
+		case provider.StateRunning:
+			if !running {
+				running = true
+				reportStep(ctx, models.StepRuntimeRunning, models.StepDone, string(current.State))
+				reportStep(ctx, models.StepFQDNAssigned, models.StepRunning, "")
+			}
+			return current.FQDN != "", nil
+		}
+		return false, nil
+	})
+
+	switch {
+	case err == nil:
+		reportStep(ctx, models.StepFQDNAssigned, models.StepDone, runtime.FQDN)
+		return readiness{runtime: runtime, status: models.StatusRunning}
+
+	case runtime != nil && runtime.State == provider.StateFailed:
+		reason := err.Error()
+		reportStep(ctx, models.StepRuntimeRunning, models.StepFailed, reason)
+		reportStep(ctx, models.StepFQDNAssigned, models.StepSkipped, reason)
+		return readiness{runtime: runtime, status: models.StatusError, reason: reason}
+
+	case running:
+		reason := fmt.Sprintf("no FQDN assigned within %s", timeout)
+		reportStep(ctx, models.StepFQDNAssigned, models.StepSkipped, reason)
+		return readiness{runtime: runtime, status: models.StatusStarting, reason: reason}
+	}
+
+	reason := fmt.Sprintf("container group not running within %s", timeout)
+	switch {
+	case runtime != nil:
+		reason = fmt.Sprintf("%s (state %s)", reason, runtime.State)
+	case lastErr != nil:
+		reason = fmt.Sprintf("%s (last error: %v)", reason, lastErr)
+	}
+	log.Printf("Warning: workspace runtime %s: %s", name, reason)
+	reportStep(ctx, models.StepRuntimeRunning, models.StepSkipped, reason)
+	reportStep(ctx, models.StepFQDNAssigned, models.StepSkipped, reason)
+	return readiness{runtime: runtime, status: models.StatusStarting, reason: reason}
+}
+
+// waitForIDE waits until the IDE port accepts connections and returns why it
+// did not. IDE_READY_TIMEOUT=0, or a backend that does not route the port,
+// skips the probe.
+func (s *EnvironmentService) waitForIDE(ctx context.Context, runtime *provider.Runtime) string {
+	timeout := s.config.Readiness.IDETimeout
+	if timeout <= 0 {
+		reportStep(ctx, models.StepIDEReachable, models.StepSkipped, "readiness check disabled")
+		return ""
+	}
+	if !runtime.Exposes(provider.PortIDE) {
+		reportStep(ctx, models.StepIDEReachable, models.StepSkipped, "the backend does not expose the IDE port")
+		return ""
+	}
+
+	address := net.JoinHostPort(runtime.FQDN, strconv.Itoa(runtime.PublicPort(provider.PortIDE)))
+	reportStep(ctx, models.StepIDEReachable, models.StepRunning, address)
+
+	if err := waitForPort(ctx, address, timeout, s.pollInterval()); err != nil {
+		log.Printf("Warning: IDE at %s not reachable: %v", address, err)
+		reason := fmt.Sprintf("IDE not reachable within %s; it may still be starting", timeout)
+		reportStep(ctx, models.StepIDEReachable, models.StepSkipped, reason)
+		return reason
+	}
+	reportStep(ctx, models.StepIDEReachable, models.StepDone, address)
+	return ""
+}
+
+// waitForSupervisor waits until the supervisor /health endpoint answers 2xx and
+// returns why it did not. SUPERVISOR_READY_TIMEOUT=0, or a backend that does
+// not route the port, skips the probe.
+func (s *EnvironmentService) waitForSupervisor(ctx context.Context, runtime *provider.Runtime) string {
+	timeout := s.config.Readiness.SupervisorTimeout
+	if timeout <= 0 {
+		reportStep(ctx, models.StepSupervisorHealthy, models.StepSkipped, "readiness check disabled")
+		return ""
+	}
+	if !runtime.Exposes(provider.PortSupervisor) {
+		reportStep(ctx, models.StepSupervisorHealthy, models.StepSkipped, "the backend does not expose the supervisor port")
+		return ""
+	}
+
+	url := runtime.HTTPURL(provider.PortSupervisor) + "/health"
+	reportStep(ctx, models.StepSupervisorHealthy, models.StepRunning, url)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	var lastErr error
+	err := poll(ctx, s.pollInterval(), timeout, func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			lastErr = fmt.Errorf("status %d", resp.StatusCode)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		log.Printf("Warning: supervisor at %s not healthy: %v (last error: %v)", url, err, lastErr)
+		reason := fmt.Sprintf("supervisor not healthy within %s; it may still be starting", timeout)
+		reportStep(ctx, models.StepSupervisorHealthy, models.StepSkipped, reason)
+		return reason
+	}
+	reportStep(ctx, models.StepSupervisorHealthy, models.StepDone, url)
+	return ""
+}
+
+// pollInterval returns the time between readiness polls
+func (s *EnvironmentService) pollInterval() time.Duration {
+	if s.config.Readiness.PollInterval <= 0 {
+		return defaultReadinessPollInterval
+	}
+	return s.config.Readiness.PollInterval
+}
+
+// skipSteps marks readiness steps that were not reached as skipped
+func skipSteps(ctx context.Context, reason string, steps ...string) {
+	for _, step := range steps {
+		reportStep(ctx, step, models.StepSkipped, reason)
+	}
+}
+
+// waitForPort polls a TCP address until it accepts a connection or timeout elapses
+func waitForPort(ctx context.Context, address string, timeout, interval time.Duration) error {
+	var dialer net.Dialer
+	var lastErr error
+
+	err := poll(ctx, interval, timeout, func(ctx context.Context) (bool, error) {
+		dialCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		conn, err := dialer.DialContext(dialCtx, "tcp", address)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+	if err != nil && lastErr != nil {
+		return fmt.Errorf("%w (last error: %v)", err, lastErr)
+	}
+	return err
+}
+
+// poll calls check every interval until it reports done, returns an error or
+// timeout elapses
+func poll(ctx context.Context, interval, timeout time.Duration, check func(ctx context.Context) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		done, err := check(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
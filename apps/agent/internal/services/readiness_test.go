@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+func TestAwaitReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		dnsLabel   string
+		state      provider.RuntimeState
+		create     bool
+		wantStatus models.EnvironmentStatus
+		wantReason string
+	}{
+		{name: "running with FQDN", dnsLabel: "ws-1", create: true, wantStatus: models.StatusRunning},
+		{name: "failed runtime", dnsLabel: "ws-1", state: provider.StateFailed, create: true, wantStatus: models.StatusError, wantReason: "failed"},
+		{name: "still pending", dnsLabel: "ws-1", state: provider.StatePending, create: true, wantStatus: models.StatusStarting, wantReason: "state Pending"},
+		{name: "no FQDN", create: true, wantStatus: models.StatusStarting, wantReason: "no FQDN"},
+		{name: "runtime missing", wantStatus: models.StatusStarting, wantReason: "not running"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeProvider := fake.NewProvider(fake.Options{Regions: []string{"eastus"}})
+			service := &EnvironmentService{config: &config.Config{
+				Readiness: config.ReadinessConfig{PollInterval: 10 * time.Millisecond, RuntimeTimeout: 50 * time.Millisecond},
+			}}
+
+			if tt.create {
+				spec := provider.ContainerGroupSpec{EnvironmentID: "ws-1", DNSNameLabel: tt.dnsLabel}
+				if err := fakeProvider.CreateRuntime(context.Background(), "eastus", "aci-ws-1", spec); err != nil {
+					t.Fatalf("CreateRuntime() error = %v", err)
+				}
+				if tt.state != "" {
+					fakeProvider.SetRuntimeState("eastus", "aci-ws-1", tt.state)
+				}
+			}
+
+			got := service.awaitReady(context.Background(), fakeProvider, "eastus", "aci-ws-1")
+			if got.status != tt.wantStatus {
+				t.Errorf("awaitReady() status = %v, want %v (reason %q)", got.status, tt.wantStatus, got.reason)
+			}
+			if !strings.Contains(got.reason, tt.wantReason) {
+				t.Errorf("awaitReady() reason = %q, want it to contain %q", got.reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestWaitForSupervisor(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	publicPort, _ := strconv.Atoi(port)
+	runtime := &provider.Runtime{FQDN: host, Ports: map[int]int{9000: publicPort}}
+
+	service := &EnvironmentService{config: &config.Config{
+		Readiness: config.ReadinessConfig{PollInterval: 10 * time.Millisecond, SupervisorTimeout: 100 * time.Millisecond},
+	}}
+
+	if reason := service.waitForSupervisor(context.Background(), runtime); reason == "" {
+		t.Error("waitForSupervisor(unhealthy) reason = \"\", want a reason")
+	}
+
+	healthy.Store(true)
+	if reason := service.waitForSupervisor(context.Background(), runtime); reason != "" {
+		t.Errorf("waitForSupervisor(healthy) reason = %q, want none", reason)
+	}
+}
+
+func TestReadiness_SkipsUnexposedPorts(t *testing.T) {
+	// Cloud Run only routes the IDE; probing the supervisor would never succeed
+	runtime := &provider.Runtime{
+		FQDN:      "aci-ws-1-abc123-uc.a.run.app.invalid",
+		Ports:     map[int]int{8080: 443},
+		Scheme:    "https",
+		Reachable: []int{8080},
+	}
+	service := &EnvironmentService{config: &config.Config{
+		Readiness: config.ReadinessConfig{PollInterval: 10 * time.Millisecond, SupervisorTimeout: time.Minute},
+	}}
+
+	start := time.Now()
+	if reason := service.waitForSupervisor(context.Background(), runtime); reason != "" {
+		t.Errorf("waitForSupervisor(unexposed) reason = %q, want none", reason)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waitForSupervisor(unexposed) took %s, want no probe", elapsed)
+	}
+
+	// Nothing but the routed IDE port is probed
+	runtime.Reachable = []int{2222}
+	service.config.Readiness.IDETimeout = time.Minute
+	if reason := service.waitForIDE(context.Background(), runtime); reason != "" {
+		t.Errorf("waitForIDE(unexposed) reason = %q, want none", reason)
+	}
+}
+
+func TestWaitForPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	address := listener.Addr().String()
+
+	if err := waitForPort(context.Background(), address, time.Second, 10*time.Millisecond); err != nil {
+		t.Errorf("waitForPort(open) error = %v", err)
+	}
+
+	listener.Close()
+	if err := waitForPort(context.Background(), address, 100*time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Error("waitForPort(closed) error = nil, want timeout")
+	}
+}
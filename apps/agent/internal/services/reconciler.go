@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// Reconciler periodically cross-references the managed runtimes and workspace
+// volumes of every provider region and reports (or, with cleanup enabled,
+// deletes) the resources that best-effort cleanup leaked:
+//
+//   - runtimes whose volume no longer exists
+//   - runtimes stuck in a failed state
+//   - volumes that belong to no known workspace (needs the state store)
+//
+// Workspaces with an operation in flight are never touched. A volume is only
+// deleted when the state store recorded its workspace's deletion; other
+// ownerless volumes may belong to another replica's store and are reported.
+type Reconciler struct {
+	service *EnvironmentService
+	config  config.ReconcileConfig
+
+	pass sync.Mutex // Serializes passes started by the loop and the API
+
+	mu   sync.RWMutex
+	last *models.ReconcileReport
+}
+
+// NewReconciler creates a reconciler for the service's providers
+func NewReconciler(service *EnvironmentService, cfg config.ReconcileConfig) *Reconciler {
+	return &Reconciler{
+		service: service,
+		config:  cfg,
+	}
+}
+
+// Run reconciles on every interval tick until ctx is cancelled.
+// A zero interval disables the loop.
+func (r *Reconciler) Run(ctx context.Context) {
+	if r.config.Interval <= 0 {
+		log.Printf("🧹 Reconciler disabled (RECONCILE_INTERVAL=0)")
+		return
+	}
+
+	mode := "report only"
+	if r.config.Cleanup {
+		mode = "cleanup enabled"
+	}
+	log.Printf("🧹 Reconciler running every %s (%s)", r.config.Interval, mode)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reconcile(ctx)
+		}
+	}
+}
+
+// LastReport returns the most recent report, or nil before the first pass
+func (r *Reconciler) LastReport() *models.ReconcileReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.last
+}
+
+// Reconcile runs a single pass over every provider region and returns its report
+func (r *Reconciler) Reconcile(ctx context.Context) *models.ReconcileReport {
+	r.pass.Lock()
+	defer r.pass.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.service.operations.timeout)
+	defer cancel()
+
+	report := &models.ReconcileReport{
+		StartedAt: time.Now(),
+		Cleanup:   r.config.Cleanup,
+		Findings:  []models.ReconcileFinding{},
+	}
+
+	for _, cloud := range r.service.providers.Providers() {
+		computeProvider, err := r.service.providers.Get(cloud)
+		if err != nil {
+			continue
+		}
+		for _, region := range computeProvider.Regions() {
+			if err := r.reconcileRegion(ctx, cloud, computeProvider, region, report); err != nil {
+				log.Printf("Warning: reconciler skipped %s/%s: %v", cloud, region, err)
+				report.Errors = append(report.Errors, fmt.Sprintf("%s/%s: %v", cloud, region, err))
+			}
+		}
+	}
+
+	report.CompletedAt = time.Now()
+	if len(report.Findings) > 0 {
+		log.Printf("🧹 Reconciliation found %d orphaned resource(s) across %d runtime(s) and %d volume(s)",
+			len(report.Findings), report.Runtimes, report.Volumes)
+	} else {
+		log.Printf("🧹 Reconciliation clean (%d runtime(s), %d volume(s))", report.Runtimes, report.Volumes)
+	}
+
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+
+	return report
+}
+
+// reconcileRegion inspects one provider region and appends its findings
+func (r *Reconciler) reconcileRegion(ctx context.Context, cloud models.CloudProvider, computeProvider provider.ComputeProvider, region string, report *models.ReconcileReport) error {
+	runtimes, err := computeProvider.ListRuntimes(ctx, region)
+	if err != nil {
+		return fmt.Errorf("failed to list runtimes: %w", err)
+	}
+	volumes, err := computeProvider.ListVolumes(ctx, region)
+	if err != nil {
+		return fmt.Errorf("failed to list volumes: %w", err)
+	}
+	report.Runtimes += len(runtimes)
+	report.Volumes += len(volumes)
+
+	volumeOwners := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		volumeOwners[strings.TrimPrefix(volume.Name, provider.VolumePrefix)] = true
+	}
+
+	runtimeOwners := make(map[string]bool, len(runtimes))
+	for _, runtime := range runtimes {
+		workspaceID := runtimeWorkspaceID(runtime)
+		runtimeOwners[workspaceID] = true
+		if r.service.operations.Active(workspaceID) {
+			continue
+		}
+
+		var kind models.OrphanKind
+		switch {
+		case runtime.State == provider.StateFailed:
+			kind = models.OrphanFailedRuntime
+		case !volumeOwners[workspaceID]:
+			kind = models.OrphanRuntimeWithoutVolume
+		default:
+			continue
+		}
+
+		finding := models.ReconcileFinding{
+			Kind:          kind,
+			CloudProvider: cloud,
+			Region:        region,
+			WorkspaceID:   workspaceID,
+			Resource:      runtime.Name,
+			Action:        models.ActionReported,
+		}
+		if r.config.Cleanup {
+			held, err := r.service.locks.TryLock(ctx, workspaceID, "reconcile")
+			if err != nil {
+				continue // Busy with an operation, possibly on another agent replica
+			}
+			lockCtx, cancel := held.Context(ctx)
+			r.cleanupRuntime(lockCtx, computeProvider, &finding)
+			cancel()
+			held.Unlock()
+		}
+		r.record(report, finding)
+	}
+
+	// A volume without a runtime is a stopped workspace unless the state
+	// store has never heard of it, so orphaned volumes need the store
+	if r.service.store == nil {
+		return nil
+	}
+
+	for _, volume := range volumes {
+		workspaceID := strings.TrimPrefix(volume.Name, provider.VolumePrefix)
+		if runtimeOwners[workspaceID] || r.service.operations.Active(workspaceID) {
+			continue
+		}
+		// Backends that cannot report an age leave CreatedAt zero; those
+		// volumes are reported but never deleted without a recorded deletion
+		if !volume.CreatedAt.IsZero() && time.Since(volume.CreatedAt) < r.config.MinAge {
+			continue
+		}
+		if _, err := r.service.store.Get(workspaceID); err == nil {
+			continue
+		}
+		deletedAt, err := r.service.store.DeletedAt(workspaceID)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+
+		finding := models.ReconcileFinding{
+			Kind:          models.OrphanVolumeWithoutOwner,
+			CloudProvider: cloud,
+			Region:        region,
+			WorkspaceID:   workspaceID,
+			Resource:      volume.Name,
+			Action:        models.ActionReported,
+		}
+		// Only a deletion this store recorded, at least MinAge ago, proves
+		// the volume is a leftover rather than another replica's workspace
+		if r.config.Cleanup && !deletedAt.IsZero() && time.Since(deletedAt) >= r.config.MinAge {
+			held, err := r.service.locks.TryLock(ctx, workspaceID, "reconcile")
+			if err != nil {
+				continue // Busy with an operation, possibly on another agent replica
+			}
+			lockCtx, cancel := held.Context(ctx)
+			if err := computeProvider.DeleteVolume(lockCtx, region, volume.Name); err != nil {
+				finding.Action = models.ActionFailed
+				finding.Error = err.Error()
+			} else {
+				finding.Action = models.ActionDeleted
+			}
+			cancel()
+			held.Unlock()
+		}
+		r.record(report, finding)
+	}
+
+	return nil
+}
+
+// cleanupRuntime deletes an orphaned runtime and records what the workspace was left as
+func (r *Reconciler) cleanupRuntime(ctx context.Context, computeProvider provider.ComputeProvider, finding *models.ReconcileFinding) {
+	if err := computeProvider.DeleteRuntime(ctx, finding.Region, finding.Resource); err != nil {
+		finding.Action = models.ActionFailed
+		finding.Error = err.Error()
+		return
+	}
+	finding.Action = models.ActionDeleted
+
+	// A failed runtime leaves a stopped workspace; one without a volume cannot be started again
+	status := models.StatusStopped
+	if finding.Kind == models.OrphanRuntimeWithoutVolume {
+		status = models.StatusError
+	}
+	r.service.updateEnvironment(finding.WorkspaceID, func(env *models.Environment) {
+		env.Status = status
+		env.AzureFQDN = ""
+		env.ConnectionURLs = models.ConnectionURLs{}
+	})
+}
+
+// record appends a finding to the report and logs it
+func (r *Reconciler) record(report *models.ReconcileReport, finding models.ReconcileFinding) {
+	report.Findings = append(report.Findings, finding)
+
+	if finding.Error != "" {
+		log.Printf("Warning: reconciler failed to delete %s %s/%s: %s", finding.Kind, finding.Region, finding.Resource, finding.Error)
+		return
+	}
+	log.Printf("🧹 %s %s/%s (workspace %s): %s", finding.Kind, finding.Region, finding.Resource, finding.WorkspaceID, finding.Action)
+}
+
+// runtimeWorkspaceID returns the workspace a runtime belongs to, falling back to its aci-{id} name
+func runtimeWorkspaceID(runtime provider.Runtime) string {
+	if runtime.EnvironmentID != "" {
+		return runtime.EnvironmentID
+	}
+	return strings.TrimPrefix(runtime.Name, provider.RuntimePrefix)
+}
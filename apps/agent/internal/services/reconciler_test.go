@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// newReconcileFixture seeds a fake provider with one workspace per finding kind:
+//
+//	ws-ok       volume + running runtime
+//	ws-novol    runtime only
+//	ws-failed   volume + failed runtime
+//	ws-orphan   volume only, unknown to the state store
+//	ws-deleted  volume only, its deletion recorded in the state store
+//	ws-stopped  volume only, recorded in the state store
+func newReconcileFixture(t *testing.T, stateful bool) (*EnvironmentService, *fake.Provider) {
+	t.Helper()
+	ctx := context.Background()
+
+	fakeProvider := fake.NewProvider(fake.Options{Regions: []string{"eastus"}})
+	for _, id := range []string{"ws-ok", "ws-failed", "ws-orphan", "ws-deleted", "ws-stopped"} {
+		if err := fakeProvider.CreateVolume(ctx, "eastus", provider.VolumePrefix+id, 10); err != nil {
+			t.Fatalf("CreateVolume(%s) error = %v", id, err)
+		}
+	}
+	for _, id := range []string{"ws-ok", "ws-novol", "ws-failed"} {
+		spec := provider.ContainerGroupSpec{EnvironmentID: id}
+		if err := fakeProvider.CreateRuntime(ctx, "eastus", provider.RuntimePrefix+id, spec); err != nil {
+			t.Fatalf("CreateRuntime(%s) error = %v", id, err)
+		}
+	}
+	fakeProvider.SetRuntimeState("eastus", "aci-ws-failed", provider.StateFailed)
+
+	cfg := &config.Config{}
+	if stateful {
+		cfg.StatePath = filepath.Join(t.TempDir(), "state.db")
+	}
+	providers := provider.NewRegistry(models.ProviderAzure)
+	providers.Register(models.ProviderAzure, fakeProvider)
+
+	service, err := NewEnvironmentService(cfg, providers)
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	t.Cleanup(service.Close)
+
+	for _, id := range []string{"ws-ok", "ws-novol", "ws-failed", "ws-deleted", "ws-stopped"} {
+		service.saveEnvironment(&models.Environment{ID: id, Status: models.StatusRunning, AzureFQDN: id + ".example"})
+	}
+	// A delete whose volume deletion failed
+	service.forgetEnvironment("ws-deleted")
+	return service, fakeProvider
+}
+
+func findingsByWorkspace(report *models.ReconcileReport) map[string]models.ReconcileFinding {
+	findings := make(map[string]models.ReconcileFinding)
+	for _, finding := range report.Findings {
+		findings[finding.WorkspaceID] = finding
+	}
+	return findings
+}
+
+func TestReconciler_ReportOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		stateful bool
+		want     map[string]models.OrphanKind
+	}{
+		{
+			name:     "stateful",
+			stateful: true,
+			want: map[string]models.OrphanKind{
+				"ws-novol":   models.OrphanRuntimeWithoutVolume,
+				"ws-failed":  models.OrphanFailedRuntime,
+				"ws-orphan":  models.OrphanVolumeWithoutOwner,
+				"ws-deleted": models.OrphanVolumeWithoutOwner,
+			},
+		},
+		{
+			name:     "stateless skips volumes",
+			stateful: false,
+			want: map[string]models.OrphanKind{
+				"ws-novol":  models.OrphanRuntimeWithoutVolume,
+				"ws-failed": models.OrphanFailedRuntime,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, fakeProvider := newReconcileFixture(t, tt.stateful)
+			reconciler := NewReconciler(service, config.ReconcileConfig{})
+
+			if reconciler.LastReport() != nil {
+				t.Error("LastReport() before first pass != nil")
+			}
+
+			report := reconciler.Reconcile(context.Background())
+			if report.Runtimes != 3 || report.Volumes != 5 {
+				t.Errorf("inspected %d runtimes, %d volumes, want 3, 5", report.Runtimes, report.Volumes)
+			}
+
+			findings := findingsByWorkspace(report)
+			if len(findings) != len(tt.want) {
+				t.Errorf("Reconcile() findings = %+v, want %v", report.Findings, tt.want)
+			}
+			for id, kind := range tt.want {
+				if findings[id].Kind != kind || findings[id].Action != models.ActionReported {
+					t.Errorf("finding %s = %+v, want %v REPORTED", id, findings[id], kind)
+				}
+			}
+
+			// Report-only mode leaves every resource in place
+			if runtimes, _ := fakeProvider.ListRuntimes(context.Background(), "eastus"); len(runtimes) != 3 {
+				t.Errorf("runtimes after report = %d, want 3", len(runtimes))
+			}
+			if reconciler.LastReport() != report {
+				t.Error("LastReport() did not return the latest pass")
+			}
+		})
+	}
+}
+
+func TestReconciler_Cleanup(t *testing.T) {
+	service, fakeProvider := newReconcileFixture(t, true)
+	reconciler := NewReconciler(service, config.ReconcileConfig{Cleanup: true})
+	ctx := context.Background()
+
+	report := reconciler.Reconcile(ctx)
+	for _, finding := range report.Findings {
+		want := models.ActionDeleted
+		if finding.WorkspaceID == "ws-orphan" {
+			// No recorded deletion: possibly another replica's workspace
+			want = models.ActionReported
+		}
+		if finding.Action != want {
+			t.Errorf("finding %+v, want %v", finding, want)
+		}
+	}
+
+	runtimes, _ := fakeProvider.ListRuntimes(ctx, "eastus")
+	if len(runtimes) != 1 || runtimes[0].Name != "aci-ws-ok" {
+		t.Errorf("runtimes after cleanup = %+v, want only aci-ws-ok", runtimes)
+	}
+	if exists, _ := fakeProvider.VolumeExists(ctx, "eastus", "fs-ws-deleted"); exists {
+		t.Error("volume of a deleted workspace still exists after cleanup")
+	}
+	if exists, _ := fakeProvider.VolumeExists(ctx, "eastus", "fs-ws-orphan"); !exists {
+		t.Error("volume without a recorded deletion was deleted")
+	}
+	if exists, _ := fakeProvider.VolumeExists(ctx, "eastus", "fs-ws-stopped"); !exists {
+		t.Error("stopped workspace volume was deleted")
+	}
+
+	for id, want := range map[string]models.EnvironmentStatus{
+		"ws-ok":     models.StatusRunning,
+		"ws-failed": models.StatusStopped,
+		"ws-novol":  models.StatusError,
+	} {
+		env, err := service.GetEnvironment(ctx, id)
+		if err != nil || env.Status != want {
+			t.Errorf("GetEnvironment(%s) = %+v, %v, want status %v", id, env, err, want)
+		}
+	}
+
+	// A second pass only reports the volume it may not delete
+	if again := reconciler.Reconcile(ctx); len(again.Findings) != 1 || again.Findings[0].WorkspaceID != "ws-orphan" {
+		t.Errorf("second pass findings = %+v, want only ws-orphan", again.Findings)
+	}
+}
+
+func TestReconciler_SkipsActiveAndYoung(t *testing.T) {
+	service, _ := newReconcileFixture(t, true)
+	reconciler := NewReconciler(service, config.ReconcileConfig{Cleanup: true, MinAge: time.Hour})
+
+	release := make(chan struct{})
+	defer close(release)
+	service.operations.Start(models.OperationCreate, "ws-novol", "user-1", func(ctx context.Context) (*models.Environment, error) {
+		<-release
+		return nil, nil
+	})
+
+	findings := findingsByWorkspace(reconciler.Reconcile(context.Background()))
+	if _, ok := findings["ws-novol"]; ok {
+		t.Error("workspace with an operation in flight was reconciled")
+	}
+	if _, ok := findings["ws-orphan"]; ok {
+		t.Error("volume younger than RECONCILE_MIN_AGE was reconciled")
+	}
+	if finding, ok := findings["ws-deleted"]; ok && finding.Action == models.ActionDeleted {
+		t.Error("volume of a workspace deleted less than RECONCILE_MIN_AGE ago was deleted")
+	}
+	if findings["ws-failed"].Kind != models.OrphanFailedRuntime {
+		t.Errorf("finding ws-failed = %+v, want %v", findings["ws-failed"], models.OrphanFailedRuntime)
+	}
+}
+
+func TestReconciler_RunDisabled(t *testing.T) {
+	service, _ := newReconcileFixture(t, false)
+
+	// A zero interval returns immediately instead of looping
+	NewReconciler(service, config.ReconcileConfig{}).Run(context.Background())
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+// Regions returns every configured Azure region with its live health and the
+// resource tiers it accepts
+func (s *EnvironmentService) Regions() []models.Region {
+	health := make(map[string]models.RegionHealth)
+	if computeProvider, err := s.providers.Get(models.ProviderAzure); err == nil {
+		if reporter, ok := computeProvider.(provider.RegionHealthReporter); ok {
+			for _, h := range reporter.RegionHealth() {
+				health[h.Region] = h
+			}
+		}
+	}
+
+	regions := make([]models.Region, 0, len(s.config.Azure.Regions))
+	for _, cfg := range s.config.Azure.Regions {
+		h, ok := health[cfg.Name]
+		if !ok {
+			// No calls yet, or a provider that does not track region health
+			h = models.RegionHealth{Region: cfg.Name, Healthy: true, State: "closed"}
+		}
+		regions = append(regions, models.Region{
+			Name:              cfg.Name,
+			Location:          cfg.Location,
+			Enabled:           cfg.Enabled,
+			Health:            h,
+			Tiers:             regionTiers(cfg),
+			StorageConfigured: cfg.StorageAccount != "",
+		})
+	}
+	return regions
+}
+
+// regionTiers returns the resource tiers a region accepts: none when it is
+// disabled, otherwise those that fit its core limit
+func regionTiers(region config.RegionConfig) []models.ResourceTier {
+	tiers := []models.ResourceTier{}
+	if !region.Enabled {
+		return tiers
+	}
+	for _, tier := range models.ResourceTiers {
+		if region.MaxCores > 0 && tier.CPUCores > region.MaxCores {
+			continue
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+func TestEnvironmentService_Regions(t *testing.T) {
+	registry := provider.NewRegistry(models.ProviderAzure)
+	registry.Register(models.ProviderAzure, &regionHealthProvider{
+		Provider:  fake.NewProvider(fake.Options{Regions: []string{"eastus", "westeurope"}}),
+		unhealthy: map[string]bool{"westeurope": true},
+	})
+	service, err := NewEnvironmentService(&config.Config{StatePath: filepath.Join(t.TempDir(), "state.db"), Azure: config.AzureConfig{Regions: []config.RegionConfig{
+		{Name: "eastus", Location: "East US", Enabled: true, StorageAccount: "storageeastus"},
+		{Name: "westeurope", Location: "West Europe", Enabled: true, MaxCores: 2},
+		{Name: "westus", Location: "West US", Enabled: false, StorageAccount: "storagewestus"},
+	}}}, registry)
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	defer service.Close()
+
+	regions := service.Regions()
+	if len(regions) != 3 {
+		t.Fatalf("Regions() returned %d regions, want 3", len(regions))
+	}
+
+	tests := []struct {
+		name        string
+		region      models.Region
+		wantHealthy bool
+		wantTiers   []string
+		wantStorage bool
+	}{
+		{name: "healthy region with storage", region: regions[0], wantHealthy: true, wantTiers: []string{"micro", "small", "medium", "large"}, wantStorage: true},
+		{name: "unhealthy region limited to 2 cores", region: regions[1], wantHealthy: false, wantTiers: []string{"micro", "small"}},
+		{name: "disabled region accepts nothing", region: regions[2], wantHealthy: true, wantTiers: []string{}, wantStorage: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.region.Health.Healthy != tt.wantHealthy {
+				t.Errorf("Health.Healthy = %v, want %v", tt.region.Health.Healthy, tt.wantHealthy)
+			}
+			if tt.region.StorageConfigured != tt.wantStorage {
+				t.Errorf("StorageConfigured = %v, want %v", tt.region.StorageConfigured, tt.wantStorage)
+			}
+
+			var tiers []string
+			for _, tier := range tt.region.Tiers {
+				tiers = append(tiers, tier.Name)
+			}
+			if len(tiers) != len(tt.wantTiers) {
+				t.Fatalf("Tiers = %v, want %v", tiers, tt.wantTiers)
+			}
+			for i := range tiers {
+				if tiers[i] != tt.wantTiers[i] {
+					t.Errorf("Tiers = %v, want %v", tiers, tt.wantTiers)
+					break
+				}
+			}
+		})
+	}
+}
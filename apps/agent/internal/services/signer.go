@@ -0,0 +1,129 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+// tokenVersion prefixes every token so the format can change later
+const tokenVersion = "v1"
+
+// Reasons tokenSigner.verify rejects a token; callers turn them into errors
+// that name the token kind
+var (
+	errTokenMalformed = errors.New("malformed token")
+	errTokenInvalid   = errors.New("token signature does not match")
+	errTokenExpired   = errors.New("token expired")
+)
+
+// tokenSigner mints and verifies the stateless tokens the agent hands out:
+// supervisor credentials and the access tokens that sign browsers in to
+// workspace hosts. A token is
+// "v1.<expiry>.<HMAC-SHA256(purpose, subject, expiry)>". The purpose names
+// the token kind, so a token of one kind never verifies as another even when
+// both are signed with the same key.
+type tokenSigner struct {
+	keys [][]byte // The first signs; all verify
+	now  func() time.Time
+}
+
+// newTokenSigner creates a signer for secret that also accepts tokens signed
+// with the previous secrets, generating a random key when secret is empty
+func newTokenSigner(secret string, previous ...string) (*tokenSigner, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+	}
+
+	s := &tokenSigner{keys: [][]byte{key}, now: time.Now}
+	for _, p := range previous {
+		s.keys = append(s.keys, []byte(p))
+	}
+	return s, nil
+}
+
+// mint returns a token for the purpose and subject expiring at expiry (Unix seconds)
+func (s *tokenSigner) mint(purpose, subject string, expiry int64) string {
+	return fmt.Sprintf("%s.%d.%s", tokenVersion, expiry, signToken(s.keys[0], purpose, subject, expiry))
+}
+
+// verify checks a token was minted for the purpose and subject and has not
+// expired, and returns its expiry
+func (s *tokenSigner) verify(purpose, subject, token string) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenVersion {
+		return 0, errTokenMalformed
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errTokenMalformed
+	}
+
+	valid := false
+	for _, key := range s.keys {
+		if hmac.Equal([]byte(parts[2]), []byte(signToken(key, purpose, subject, expiry))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return 0, errTokenInvalid
+	}
+	if s.now().Unix() >= expiry {
+		return 0, errTokenExpired
+	}
+	return expiry, nil
+}
+
+// mintAccess returns an access token of the given kind for a workspace port, valid for ttl
+func (s *tokenSigner) mintAccess(kind, workspaceID string, port int, ttl time.Duration) (string, time.Time) {
+	expiry := s.now().Add(ttl)
+	return s.mint(kind, accessSubject(workspaceID, port), expiry.Unix()), expiry
+}
+
+// verifyAccess checks an access token of the given kind was minted for the workspace port and has not expired
+func (s *tokenSigner) verifyAccess(kind, token, workspaceID string, port int) error {
+	_, err := s.verify(kind, accessSubject(workspaceID, port), token)
+	switch {
+	case errors.Is(err, errTokenMalformed):
+		return models.ErrUnauthorized("malformed access token")
+	case errors.Is(err, errTokenInvalid):
+		return models.ErrUnauthorized(fmt.Sprintf("access token is not valid for port %d of workspace %s", port, workspaceID))
+	case errors.Is(err, errTokenExpired):
+		return models.ErrUnauthorized("access token expired - open the workspace from Dev8 again")
+	}
+	return err
+}
+
+// accessSubject binds an access token to one port of a workspace
+func accessSubject(workspaceID string, port int) string {
+	return fmt.Sprintf("%s\n%d", workspaceID, port)
+}
+
+// accessLink returns the link to authPath carrying token
+func accessLink(authPath, token string, expiry time.Time) models.AccessLink {
+	return models.AccessLink{
+		URL:       authPath + "?token=" + url.QueryEscape(token),
+		ExpiresAt: expiry.UTC().Truncate(time.Second),
+	}
+}
+
+// signToken computes the token MAC over the purpose, subject and expiry
+func signToken(key []byte, purpose, subject string, expiry int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%d", purpose, subject, expiry)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
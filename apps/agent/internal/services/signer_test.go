@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenSigner_Verify(t *testing.T) {
+	signer, err := newTokenSigner("current", "previous")
+	if err != nil {
+		t.Fatalf("newTokenSigner() error = %v", err)
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	signer.now = func() time.Time { return now }
+	previous, _ := newTokenSigner("previous")
+	expiry := now.Add(time.Hour).Unix()
+
+	token := signer.mint(supervisorTokenPurpose, "ws-1", expiry)
+
+	tests := []struct {
+		name    string
+		purpose string
+		subject string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", purpose: supervisorTokenPurpose, subject: "ws-1", token: token},
+		{name: "previous key", purpose: supervisorTokenPurpose, subject: "ws-1", token: previous.mint(supervisorTokenPurpose, "ws-1", expiry)},
+		{name: "other purpose", purpose: gatewayKindSession, subject: "ws-1", token: token, wantErr: errTokenInvalid},
+		{name: "other subject", purpose: supervisorTokenPurpose, subject: "ws-2", token: token, wantErr: errTokenInvalid},
+		{name: "expired", purpose: supervisorTokenPurpose, subject: "ws-1", token: signer.mint(supervisorTokenPurpose, "ws-1", now.Unix()), wantErr: errTokenExpired},
+		{name: "malformed", purpose: supervisorTokenPurpose, subject: "ws-1", token: "v2.1.abc", wantErr: errTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signer.verify(tt.purpose, tt.subject, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != expiry {
+				t.Errorf("verify() expiry = %d, want %d", got, expiry)
+			}
+		})
+	}
+}
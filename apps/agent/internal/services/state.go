@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/store"
+)
+
+// ErrStateless is returned by queries that need the state store when AGENT_STATE_PATH is unset
+var ErrStateless = errors.New("agent is stateless - no state store configured")
+
+// GetEnvironment returns the recorded state of an environment
+func (s *EnvironmentService) GetEnvironment(ctx context.Context, id string) (*models.Environment, error) {
+	if s.store == nil {
+		return nil, ErrStateless
+	}
+
+	env, err := s.store.Get(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, models.ErrNotFound(fmt.Sprintf("environment %s not found", id))
+	}
+	if err != nil {
+		return nil, models.ErrInternalServer(fmt.Sprintf("failed to read environment: %v", err))
+	}
+	return env, nil
+}
+
+// ListEnvironments returns a page of recorded environments matching the filters
+func (s *EnvironmentService) ListEnvironments(ctx context.Context, req *models.ListEnvironmentsRequest) (*models.EnvironmentListResponse, error) {
+	if s.store == nil {
+		return nil, ErrStateless
+	}
+	if err := req.Normalize(); err != nil {
+		return nil, err
+	}
+
+	envs, total, err := s.store.List(store.Filter{
+		UserID:      req.UserID,
+		Status:      req.Status,
+		CloudRegion: req.CloudRegion,
+		Page:        req.Page,
+		PageSize:    req.PageSize,
+	})
+	if err != nil {
+		return nil, models.ErrInternalServer(err.Error())
+	}
+	if envs == nil {
+		envs = []models.Environment{}
+	}
+
+	return &models.EnvironmentListResponse{
+		Environments: envs,
+		Total:        total,
+		Page:         req.Page,
+		PageSize:     req.PageSize,
+	}, nil
+}
+
+// WorkspaceOwner returns the user that owns a workspace, from the state store
+// or the running container's tags. It returns "" when neither knows the owner.
+func (s *EnvironmentService) WorkspaceOwner(ctx context.Context, cloud models.CloudProvider, region, id string) (string, error) {
+	if s.store != nil {
+		env, err := s.store.Get(id)
+		if err == nil && env.UserID != "" {
+			return env.UserID, nil
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return "", models.ErrInternalServer(fmt.Sprintf("failed to read environment: %v", err))
+		}
+	}
+
+	computeProvider, err := s.resolveProvider(cloud, region)
+	if err != nil {
+		return "", err
+	}
+	runtime, err := computeProvider.GetRuntime(ctx, region, fmt.Sprintf("aci-%s", id))
+	if err != nil {
+		return "", nil
+	}
+	return runtime.UserID, nil
+}
+
+// recordedOrgID returns the organisation recorded for a workspace, or ""
+func (s *EnvironmentService) recordedOrgID(id string) string {
+	if s.store == nil {
+		return ""
+	}
+	env, err := s.store.Get(id)
+	if err != nil {
+		return ""
+	}
+	return env.OrgID
+}
+
+// recordedIdleTimeout returns the idle timeout override recorded for a workspace, or nil
+func (s *EnvironmentService) recordedIdleTimeout(id string) *int {
+	if s.store == nil {
+		return nil
+	}
+	env, err := s.store.Get(id)
+	if err != nil {
+		return nil
+	}
+	return env.IdleTimeoutMinutes
+}
+
+// recordedCodeServerPassword returns the code-server password recorded for a workspace, or ""
+func (s *EnvironmentService) recordedCodeServerPassword(id string) string {
+	if s.store == nil {
+		return ""
+	}
+	password, err := s.store.CodeServerPassword(id)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return ""
+	}
+	return password
+}
+
+// recordedPorts returns the app ports and public ports recorded for a workspace, if any
+func (s *EnvironmentService) recordedPorts(id string) (ports, publicPorts []int) {
+	if s.store == nil {
+		return nil, nil
+	}
+	env, err := s.store.Get(id)
+	if err != nil {
+		return nil, nil
+	}
+	return env.Ports, env.PublicPorts
+}
+
+// State recording
+//
+// Lifecycle methods record state best-effort: a store failure is logged and
+// never fails the cloud operation. All helpers are no-ops without a store.
+
+// saveEnvironment records the environment, keeping the original creation and
+// activity times and the organisation, storage size and idle timeout override
+// a start does not repeat
+func (s *EnvironmentService) saveEnvironment(env *models.Environment) {
+	if s.store == nil {
+		return
+	}
+
+	if existing, err := s.store.Get(env.ID); err == nil {
+		env.CreatedAt = existing.CreatedAt
+		if env.LastAccessedAt.IsZero() {
+			env.LastAccessedAt = existing.LastAccessedAt
+		}
+		if env.OrgID == "" {
+			env.OrgID = existing.OrgID
+		}
+		if env.StorageGB == 0 {
+			env.StorageGB = existing.StorageGB
+		}
+		if env.IdleTimeoutMinutes == nil {
+			env.IdleTimeoutMinutes = existing.IdleTimeoutMinutes
+		}
+	}
+
+	if err := s.store.Put(env); err != nil {
+		log.Printf("Warning: failed to record environment %s: %v", env.ID, err)
+	}
+	// Kept apart from the record, so a wake can start the workspace with it
+	if password := env.ConnectionURLs.CodeServerPassword; password != "" {
+		if err := s.store.PutCodeServerPassword(env.ID, password); err != nil {
+			log.Printf("Warning: failed to record code-server password of environment %s: %v", env.ID, err)
+		}
+	}
+}
+
+// updateEnvironment applies fn to a recorded environment; unknown environments are ignored
+func (s *EnvironmentService) updateEnvironment(id string, fn func(env *models.Environment)) {
+	if s.store == nil {
+		return
+	}
+
+	err := s.store.Update(id, func(env *models.Environment) {
+		fn(env)
+		env.UpdatedAt = time.Now()
+	})
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Warning: failed to update environment %s: %v", id, err)
+	}
+}
+
+// setStatus records a status transition
+func (s *EnvironmentService) setStatus(id string, status models.EnvironmentStatus) {
+	s.updateEnvironment(id, func(env *models.Environment) {
+		env.Status = status
+	})
+}
+
+// forgetEnvironment removes a deleted environment's record
+func (s *EnvironmentService) forgetEnvironment(id string) {
+	if s.store == nil {
+		return
+	}
+
+	if err := s.store.Delete(id); err != nil {
+		log.Printf("Warning: failed to remove environment %s from state store: %v", id, err)
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/store"
+)
+
+// supervisorTokenPurpose is signed into supervisor tokens
+const supervisorTokenPurpose = "supervisor"
+
+// defaultSupervisorTokenTTL applies when SUPERVISOR_TOKEN_TTL is not positive
+const defaultSupervisorTokenTTL = 7 * 24 * time.Hour
+
+// SupervisorTokens mints and verifies the per-workspace credentials the
+// workspace supervisor sends with its activity reports. Tokens are signed by
+// a tokenSigner for the workspace ID, so verification needs no state; minting
+// a new token for a workspace (on create and every start) additionally
+// revokes the ones minted before it. The newest expiry of each workspace is
+// recorded in the state store, so revocations survive agent restarts; without
+// a store they last for the life of the agent. Either way they are local to
+// one agent: replicas that do not share the state file each only revoke the
+// tokens they minted themselves.
+type SupervisorTokens struct {
+	signer *tokenSigner
+	ttl    time.Duration
+	store  *store.Store // nil when the agent runs stateless
+
+	mu     sync.Mutex
+	latest map[string]int64 // Workspace ID -> expiry of its newest token
+}
+
+// NewSupervisorTokens creates the token issuer, generating a random key when none is configured
+func NewSupervisorTokens(cfg config.SupervisorTokenConfig) (*SupervisorTokens, error) {
+	signer, err := newTokenSigner(cfg.Secret, cfg.PreviousSecrets...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create supervisor token signer: %w", err)
+	}
+	if cfg.Secret == "" {
+		log.Printf("Warning: SUPERVISOR_TOKEN_SECRET not set - workspaces must be restarted to report activity after an agent restart")
+	}
+
+	t := &SupervisorTokens{
+		signer: signer,
+		ttl:    cfg.TTL,
+		latest: make(map[string]int64),
+	}
+	if t.ttl <= 0 {
+		t.ttl = defaultSupervisorTokenTTL
+	}
+	return t, nil
+}
+
+// Mint returns a new token for the workspace and revokes its earlier tokens
+func (t *SupervisorTokens) Mint(workspaceID string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expiry := t.signer.now().Add(t.ttl).Unix()
+	// Tokens minted within the same second would share an expiry
+	if latest := t.latestExpiry(workspaceID); expiry <= latest {
+		expiry = latest + 1
+	}
+	t.latest[workspaceID] = expiry
+	if t.store != nil {
+		if err := t.store.PutSupervisorTokenExpiry(workspaceID, expiry); err != nil {
+			log.Printf("Warning: failed to record supervisor token of workspace %s - earlier tokens stay valid after an agent restart: %v", workspaceID, err)
+		}
+	}
+
+	return t.signer.mint(supervisorTokenPurpose, workspaceID, expiry)
+}
+
+// latestExpiry returns the expiry of the newest token minted for the
+// workspace, or 0. Callers must hold t.mu.
+func (t *SupervisorTokens) latestExpiry(workspaceID string) int64 {
+	if latest, ok := t.latest[workspaceID]; ok {
+		return latest
+	}
+	if t.store == nil {
+		return 0
+	}
+
+	latest, err := t.store.SupervisorTokenExpiry(workspaceID)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return 0
+	}
+	if latest != 0 {
+		t.latest[workspaceID] = latest
+	}
+	return latest
+}
+
+// Verify checks that the token was minted for the workspace, has not expired
+// and has not been replaced by a newer token
+func (t *SupervisorTokens) Verify(workspaceID, token string) error {
+	expiry, err := t.signer.verify(supervisorTokenPurpose, workspaceID, token)
+	switch {
+	case errors.Is(err, errTokenMalformed):
+		return models.ErrUnauthorized("malformed supervisor token")
+	case errors.Is(err, errTokenInvalid):
+		return models.ErrUnauthorized(fmt.Sprintf("supervisor token is not valid for workspace %s", workspaceID))
+	case errors.Is(err, errTokenExpired):
+		return models.ErrUnauthorized("supervisor token expired - restart the workspace to rotate it")
+	case err != nil:
+		return err
+	}
+
+	t.mu.Lock()
+	latest := t.latestExpiry(workspaceID)
+	t.mu.Unlock()
+	if expiry < latest {
+		return models.ErrUnauthorized("supervisor token was rotated by a workspace restart")
+	}
+	return nil
+}
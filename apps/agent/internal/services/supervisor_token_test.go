@@ -0,0 +1,119 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
+)
+
+func TestSupervisorTokens_Verify(t *testing.T) {
+	tokens, err := NewSupervisorTokens(config.SupervisorTokenConfig{Secret: "current", TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewSupervisorTokens() error = %v", err)
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tokens.signer.now = func() time.Time { return now }
+
+	first := tokens.Mint("ws-1")
+	other := tokens.Mint("ws-2")
+
+	// Minted by an agent still signing with the key being rotated out
+	previous, err := NewSupervisorTokens(config.SupervisorTokenConfig{Secret: "previous", TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("NewSupervisorTokens() error = %v", err)
+	}
+	previous.signer.now = tokens.signer.now
+	fromPrevious := previous.Mint("ws-2")
+
+	tests := []struct {
+		name        string
+		workspaceID string
+		token       string
+		wantErr     bool
+	}{
+		{name: "valid", workspaceID: "ws-1", token: first},
+		{name: "other workspace", workspaceID: "ws-1", token: other, wantErr: true},
+		{name: "previous key not accepted", workspaceID: "ws-2", token: fromPrevious, wantErr: true},
+		{name: "tampered expiry", workspaceID: "ws-1", token: "v1.9999999999" + first[len("v1.")+10:], wantErr: true},
+		{name: "malformed", workspaceID: "ws-1", token: "abc", wantErr: true},
+		{name: "empty", workspaceID: "ws-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tokens.Verify(tt.workspaceID, tt.token); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupervisorTokens_RotationAndExpiry(t *testing.T) {
+	tokens, err := NewSupervisorTokens(config.SupervisorTokenConfig{
+		Secret:          "current",
+		PreviousSecrets: []string{"previous"},
+		TTL:             time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewSupervisorTokens() error = %v", err)
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tokens.signer.now = func() time.Time { return now }
+
+	// A token signed with a previous key keeps working during key rotation
+	previous, _ := NewSupervisorTokens(config.SupervisorTokenConfig{Secret: "previous", TTL: time.Hour})
+	previous.signer.now = tokens.signer.now
+	if err := tokens.Verify("ws-1", previous.Mint("ws-1")); err != nil {
+		t.Errorf("Verify(previous key) error = %v", err)
+	}
+
+	// A restart mints a new token and revokes the old one, even within the same second
+	old := tokens.Mint("ws-1")
+	current := tokens.Mint("ws-1")
+	if err := tokens.Verify("ws-1", old); err == nil {
+		t.Error("Verify(rotated token) error = nil, want error")
+	}
+	if err := tokens.Verify("ws-1", current); err != nil {
+		t.Errorf("Verify(current token) error = %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := tokens.Verify("ws-1", current); err == nil {
+		t.Error("Verify(expired token) error = nil, want error")
+	}
+}
+
+func TestSupervisorTokens_RevocationSurvivesRestart(t *testing.T) {
+	cfg := &config.Config{
+		StatePath:       filepath.Join(t.TempDir(), "state.db"),
+		SupervisorToken: config.SupervisorTokenConfig{Secret: "current", TTL: time.Hour},
+	}
+	service, err := NewEnvironmentService(cfg, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() error = %v", err)
+	}
+	old := service.tokens.Mint("ws-1")
+	current := service.tokens.Mint("ws-1")
+	service.Close()
+
+	restarted, err := NewEnvironmentService(cfg, provider.NewRegistry(models.ProviderAzure))
+	if err != nil {
+		t.Fatalf("NewEnvironmentService() after restart error = %v", err)
+	}
+	defer restarted.Close()
+
+	if err := restarted.tokens.Verify("ws-1", old); err == nil {
+		t.Error("Verify(rotated token) after restart error = nil, want error")
+	}
+	if err := restarted.tokens.Verify("ws-1", current); err != nil {
+		t.Errorf("Verify(current token) after restart error = %v", err)
+	}
+	// A token minted after the restart still replaces the recorded one
+	if next := restarted.tokens.Mint("ws-1"); restarted.tokens.Verify("ws-1", current) == nil || restarted.tokens.Verify("ws-1", next) != nil {
+		t.Error("Mint() after restart did not rotate the recorded token")
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/resilience"
+)
+
+// webhookSignatureHeader carries "sha256=<hex HMAC of the body>" when a secret is set
+const webhookSignatureHeader = "X-Dev8-Signature"
+
+const (
+	webhookTimeout  = 10 * time.Second // Per delivery attempt
+	webhookAttempts = 3
+)
+
+// webhook posts JSON events to a single URL
+type webhook struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// newWebhook returns a webhook for url, or nil when url is empty
+func newWebhook(url, secret string) *webhook {
+	if url == "" {
+		return nil
+	}
+	return &webhook{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// post delivers event, retrying network errors and 5xx responses
+func (w *webhook) post(ctx context.Context, event any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	return resilience.Retry(ctx, webhookAttempts, resilience.Backoff{Base: time.Second, Max: 10 * time.Second}, isRetryableWebhookError, func(ctx context.Context) error {
+		return w.send(ctx, body)
+	})
+}
+
+// send makes a single delivery attempt
+func (w *webhook) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dev8-agent")
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return &webhookStatusError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// webhookStatusError is a delivery the receiver answered with a non-2xx status
+type webhookStatusError struct {
+	status int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook responded %d %s", e.status, http.StatusText(e.status))
+}
+
+// isRetryableWebhookError retries everything except a 4xx the receiver will
+// keep returning
+func isRetryableWebhookError(err error) bool {
+	var statusErr *webhookStatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.status >= 500 || statusErr.status == http.StatusTooManyRequests
+}
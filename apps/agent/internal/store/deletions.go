@@ -0,0 +1,38 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// deletionsBucket records when each workspace ID was deleted, so a volume a
+// failed delete left behind can be told apart from one this store never knew
+var deletionsBucket = []byte("deleted-workspaces")
+
+// DeletedAt returns when the workspace's deletion was recorded, or the zero
+// time if it was not (or the workspace has been created again since)
+func (s *Store) DeletedAt(workspaceID string) (time.Time, error) {
+	var deletedAt time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(deletionsBucket).Get([]byte(workspaceID))
+		if value == nil {
+			return nil
+		}
+		return deletedAt.UnmarshalText(value)
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read deletion of workspace %s: %w", workspaceID, err)
+	}
+	return deletedAt, nil
+}
+
+// putDeletion records that the workspace was deleted at the given time
+func putDeletion(tx *bolt.Tx, workspaceID string, at time.Time) error {
+	value, err := at.UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(deletionsBucket).Put([]byte(workspaceID), value)
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// passwordsBucket holds the code-server password of each workspace ID. It is
+// kept apart from the environment records, which are served to API clients.
+var passwordsBucket = []byte("code-server-passwords")
+
+// CodeServerPassword returns the code-server password recorded for a workspace, or ""
+func (s *Store) CodeServerPassword(workspaceID string) (string, error) {
+	var password string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		password = string(tx.Bucket(passwordsBucket).Get([]byte(workspaceID)))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read code-server password of workspace %s: %w", workspaceID, err)
+	}
+	return password, nil
+}
+
+// PutCodeServerPassword records the code-server password of a workspace; an empty password removes the entry
+func (s *Store) PutCodeServerPassword(workspaceID, password string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(passwordsBucket)
+		if password == "" {
+			return bucket.Delete([]byte(workspaceID))
+		}
+		return bucket.Put([]byte(workspaceID), []byte(password))
+	})
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// sshKeysBucket holds the JSON-encoded authorized SSH public keys of each user ID
+var sshKeysBucket = []byte("ssh-keys")
+
+// SSHKeys returns the SSH public keys registered for a user, in authorized_keys format
+func (s *Store) SSHKeys(userID string) ([]string, error) {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(sshKeysBucket).Get([]byte(userID))
+		if value == nil {
+			return nil
+		}
+		return json.Unmarshal(value, &keys)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH keys of user %s: %w", userID, err)
+	}
+	return keys, nil
+}
+
+// PutSSHKeys replaces the SSH public keys registered for a user; no keys removes the entry
+func (s *Store) PutSSHKeys(userID string, keys []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sshKeysBucket)
+		if len(keys) == 0 {
+			return bucket.Delete([]byte(userID))
+		}
+
+		value, err := json.Marshal(keys)
+		if err != nil {
+			return fmt.Errorf("failed to encode SSH keys of user %s: %w", userID, err)
+		}
+		return bucket.Put([]byte(userID), value)
+	})
+}
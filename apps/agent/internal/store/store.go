@@ -0,0 +1,193 @@
+// Package store persists environment state in an embedded bbolt file so the
+// agent can answer GetEnvironment/ListEnvironments without asking Next.js.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned when no environment is stored under the ID
+var ErrNotFound = errors.New("environment not found")
+
+// environmentsBucket holds one JSON-encoded models.Environment per workspace ID
+var environmentsBucket = []byte("environments")
+
+// Filter selects environments for List. Empty fields match everything.
+type Filter struct {
+	UserID      string
+	Status      models.EnvironmentStatus
+	CloudRegion string
+	Page        int // 1-based
+	PageSize    int // 0 returns every match
+}
+
+// Store is an embedded environment store backed by a single bbolt file
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the store file at path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{environmentsBucket, sshKeysBucket, passwordsBucket, deletionsBucket, supervisorTokensBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize state store: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the store file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put creates or replaces the environment record, clearing any recorded deletion of its ID
+func (s *Store) Put(env *models.Environment) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(deletionsBucket).Delete([]byte(env.ID)); err != nil {
+			return err
+		}
+		return putEnvironment(tx.Bucket(environmentsBucket), env)
+	})
+}
+
+// Get returns the environment record, or ErrNotFound
+func (s *Store) Get(id string) (*models.Environment, error) {
+	var env *models.Environment
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		env, err = getEnvironment(tx.Bucket(environmentsBucket), id)
+		return err
+	})
+	return env, err
+}
+
+// Update applies fn to the stored environment in a single transaction.
+// It returns ErrNotFound if the environment is not stored.
+func (s *Store) Update(id string, fn func(env *models.Environment)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(environmentsBucket)
+
+		env, err := getEnvironment(bucket, id)
+		if err != nil {
+			return err
+		}
+
+		fn(env)
+		return putEnvironment(bucket, env)
+	})
+}
+
+// Delete removes the environment record, its code-server password and
+// supervisor token expiry, and records when the workspace was deleted;
+// deleting a missing record is not an error
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(passwordsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(supervisorTokensBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		if err := putDeletion(tx, id, time.Now()); err != nil {
+			return err
+		}
+		return tx.Bucket(environmentsBucket).Delete([]byte(id))
+	})
+}
+
+// List returns the page of environments matching the filter, newest first,
+// and the total number of matches
+func (s *Store) List(filter Filter) ([]models.Environment, int, error) {
+	var matches []models.Environment
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(environmentsBucket).ForEach(func(_, value []byte) error {
+			var env models.Environment
+			if err := json.Unmarshal(value, &env); err != nil {
+				return err
+			}
+			if filter.matches(&env) {
+				matches = append(matches, env)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list environments: %w", err)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.After(matches[j].CreatedAt)
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	total := len(matches)
+	if filter.PageSize <= 0 {
+		return matches, total, nil
+	}
+
+	page := max(filter.Page, 1)
+	start := min((page-1)*filter.PageSize, total)
+	end := min(start+filter.PageSize, total)
+	return matches[start:end], total, nil
+}
+
+func (f Filter) matches(env *models.Environment) bool {
+	if f.UserID != "" && env.UserID != f.UserID {
+		return false
+	}
+	if f.Status != "" && env.Status != f.Status {
+		return false
+	}
+	if f.CloudRegion != "" && env.CloudRegion != f.CloudRegion {
+		return false
+	}
+	return true
+}
+
+func getEnvironment(bucket *bolt.Bucket, id string) (*models.Environment, error) {
+	value := bucket.Get([]byte(id))
+	if value == nil {
+		return nil, ErrNotFound
+	}
+
+	var env models.Environment
+	if err := json.Unmarshal(value, &env); err != nil {
+		return nil, fmt.Errorf("failed to decode environment %s: %w", id, err)
+	}
+	return &env, nil
+}
+
+func putEnvironment(bucket *bolt.Bucket, env *models.Environment) error {
+	record := *env
+	// The code-server password lives in its own bucket, out of API responses
+	record.ConnectionURLs.CodeServerPassword = ""
+
+	value, err := json.Marshal(&record)
+	if err != nil {
+		return fmt.Errorf("failed to encode environment %s: %w", env.ID, err)
+	}
+	return bucket.Put([]byte(env.ID), value)
+}
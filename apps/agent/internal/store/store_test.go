@@ -0,0 +1,261 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "agent.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_CRUD(t *testing.T) {
+	s := openTestStore(t)
+
+	env := &models.Environment{
+		ID:          "ws-1",
+		UserID:      "user-1",
+		Status:      models.StatusRunning,
+		CloudRegion: "eastus",
+		ConnectionURLs: models.ConnectionURLs{
+			VSCodeWebURL:       "https://ws-1.eastus.azurecontainer.io:8080",
+			CodeServerPassword: "secret",
+		},
+	}
+	if err := s.Put(env); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	got, err := s.Get("ws-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.UserID != "user-1" || got.ConnectionURLs.VSCodeWebURL == "" {
+		t.Errorf("Get() = %+v, want stored environment", got)
+	}
+	if got.ConnectionURLs.CodeServerPassword != "" {
+		t.Error("Get() returned the code-server password, want it not persisted")
+	}
+	if env.ConnectionURLs.CodeServerPassword != "secret" {
+		t.Error("Put() modified the caller's environment")
+	}
+
+	if err := s.Update("ws-1", func(env *models.Environment) { env.Status = models.StatusStopped }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, _ := s.Get("ws-1"); got.Status != models.StatusStopped {
+		t.Errorf("status after Update() = %v, want %v", got.Status, models.StatusStopped)
+	}
+
+	if err := s.Update("missing", func(env *models.Environment) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete("ws-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := s.Get("ws-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete("ws-1"); err != nil {
+		t.Errorf("Delete(missing) error = %v, want nil", err)
+	}
+}
+
+func TestStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.db")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := s.Put(&models.Environment{ID: "ws-1"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.Get("ws-1"); err != nil {
+		t.Errorf("Get() after reopen error = %v", err)
+	}
+}
+
+func TestStore_List(t *testing.T) {
+	s := openTestStore(t)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		env := &models.Environment{
+			ID:          fmt.Sprintf("ws-%d", i),
+			UserID:      "user-1",
+			Status:      models.StatusRunning,
+			CloudRegion: "eastus",
+			CreatedAt:   base.Add(time.Duration(i) * time.Hour),
+		}
+		if i%2 == 1 {
+			env.Status = models.StatusStopped
+		}
+		if i == 4 {
+			env.UserID = "user-2"
+			env.CloudRegion = "westeurope"
+		}
+		if err := s.Put(env); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		filter    Filter
+		wantIDs   []string
+		wantTotal int
+	}{
+		{name: "all newest first", filter: Filter{}, wantIDs: []string{"ws-4", "ws-3", "ws-2", "ws-1", "ws-0"}, wantTotal: 5},
+		{name: "by user", filter: Filter{UserID: "user-2"}, wantIDs: []string{"ws-4"}, wantTotal: 1},
+		{name: "by status", filter: Filter{Status: models.StatusStopped}, wantIDs: []string{"ws-3", "ws-1"}, wantTotal: 2},
+		{name: "by region", filter: Filter{CloudRegion: "eastus", Status: models.StatusRunning}, wantIDs: []string{"ws-2", "ws-0"}, wantTotal: 2},
+		{name: "first page", filter: Filter{Page: 1, PageSize: 2}, wantIDs: []string{"ws-4", "ws-3"}, wantTotal: 5},
+		{name: "last page", filter: Filter{Page: 3, PageSize: 2}, wantIDs: []string{"ws-0"}, wantTotal: 5},
+		{name: "past the end", filter: Filter{Page: 4, PageSize: 2}, wantIDs: nil, wantTotal: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envs, total, err := s.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("List() total = %d, want %d", total, tt.wantTotal)
+			}
+
+			var ids []string
+			for _, env := range envs {
+				ids = append(ids, env.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("List() = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestStore_SSHKeys(t *testing.T) {
+	s := openTestStore(t)
+
+	if keys, err := s.SSHKeys("user-1"); err != nil || keys != nil {
+		t.Fatalf("SSHKeys() of unknown user = %v, %v, want nil, nil", keys, err)
+	}
+
+	want := []string{"ssh-ed25519 AAAA1 laptop", "ssh-ed25519 AAAA2 desktop"}
+	if err := s.PutSSHKeys("user-1", want); err != nil {
+		t.Fatalf("PutSSHKeys() error = %v", err)
+	}
+	if keys, err := s.SSHKeys("user-1"); err != nil || !slices.Equal(keys, want) {
+		t.Errorf("SSHKeys() = %v, %v, want %v", keys, err, want)
+	}
+
+	if err := s.PutSSHKeys("user-1", nil); err != nil {
+		t.Fatalf("PutSSHKeys(nil) error = %v", err)
+	}
+	if keys, err := s.SSHKeys("user-1"); err != nil || keys != nil {
+		t.Errorf("SSHKeys() after removal = %v, %v, want nil, nil", keys, err)
+	}
+}
+
+func TestStore_CodeServerPassword(t *testing.T) {
+	s := openTestStore(t)
+
+	if password, err := s.CodeServerPassword("ws-1"); err != nil || password != "" {
+		t.Fatalf("CodeServerPassword() of unknown workspace = %q, %v, want empty", password, err)
+	}
+
+	env := &models.Environment{ID: "ws-1", ConnectionURLs: models.ConnectionURLs{CodeServerPassword: "secret"}}
+	if err := s.Put(env); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.PutCodeServerPassword("ws-1", "secret"); err != nil {
+		t.Fatalf("PutCodeServerPassword() error = %v", err)
+	}
+	if password, err := s.CodeServerPassword("ws-1"); err != nil || password != "secret" {
+		t.Errorf("CodeServerPassword() = %q, %v, want secret", password, err)
+	}
+	if got, err := s.Get("ws-1"); err != nil || got.ConnectionURLs.CodeServerPassword != "" {
+		t.Errorf("Get() password = %q, %v, want it left out of the record", got.ConnectionURLs.CodeServerPassword, err)
+	}
+
+	// Deleting the workspace forgets its password
+	if err := s.Delete("ws-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if password, err := s.CodeServerPassword("ws-1"); err != nil || password != "" {
+		t.Errorf("CodeServerPassword() after Delete() = %q, %v, want empty", password, err)
+	}
+}
+
+func TestStore_DeletedAt(t *testing.T) {
+	s := openTestStore(t)
+
+	if deletedAt, err := s.DeletedAt("ws-1"); err != nil || !deletedAt.IsZero() {
+		t.Fatalf("DeletedAt() of unknown workspace = %v, %v, want zero", deletedAt, err)
+	}
+
+	if err := s.Put(&models.Environment{ID: "ws-1"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	before := time.Now().Add(-time.Second)
+	if err := s.Delete("ws-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if deletedAt, err := s.DeletedAt("ws-1"); err != nil || deletedAt.Before(before) {
+		t.Errorf("DeletedAt() after Delete() = %v, %v, want the deletion time", deletedAt, err)
+	}
+
+	// Creating the workspace again clears the deletion
+	if err := s.Put(&models.Environment{ID: "ws-1"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if deletedAt, err := s.DeletedAt("ws-1"); err != nil || !deletedAt.IsZero() {
+		t.Errorf("DeletedAt() after re-create = %v, %v, want zero", deletedAt, err)
+	}
+}
+
+func TestStore_SupervisorTokenExpiry(t *testing.T) {
+	s := openTestStore(t)
+
+	if expiry, err := s.SupervisorTokenExpiry("ws-1"); err != nil || expiry != 0 {
+		t.Fatalf("SupervisorTokenExpiry() of unknown workspace = %d, %v, want 0", expiry, err)
+	}
+	if err := s.PutSupervisorTokenExpiry("ws-1", 1735732800); err != nil {
+		t.Fatalf("PutSupervisorTokenExpiry() error = %v", err)
+	}
+	if expiry, err := s.SupervisorTokenExpiry("ws-1"); err != nil || expiry != 1735732800 {
+		t.Errorf("SupervisorTokenExpiry() = %d, %v, want 1735732800", expiry, err)
+	}
+
+	// Deleting the workspace forgets its token
+	if err := s.Delete("ws-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if expiry, err := s.SupervisorTokenExpiry("ws-1"); err != nil || expiry != 0 {
+		t.Errorf("SupervisorTokenExpiry() after Delete() = %d, %v, want 0", expiry, err)
+	}
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// supervisorTokensBucket holds the expiry (Unix seconds) of the newest
+// supervisor token minted for each workspace ID; older tokens are revoked
+var supervisorTokensBucket = []byte("supervisor-tokens")
+
+// SupervisorTokenExpiry returns the expiry of the newest supervisor token
+// recorded for a workspace, or 0 if none was
+func (s *Store) SupervisorTokenExpiry(workspaceID string) (int64, error) {
+	var expiry int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(supervisorTokensBucket).Get([]byte(workspaceID))
+		if value == nil {
+			return nil
+		}
+		var err error
+		expiry, err = strconv.ParseInt(string(value), 10, 64)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read supervisor token of workspace %s: %w", workspaceID, err)
+	}
+	return expiry, nil
+}
+
+// PutSupervisorTokenExpiry records the expiry of the newest supervisor token minted for a workspace
+func (s *Store) PutSupervisorTokenExpiry(workspaceID string, expiry int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(supervisorTokensBucket).Put([]byte(workspaceID), []byte(strconv.FormatInt(expiry, 10)))
+	})
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -9,13 +11,25 @@ import (
 	"syscall"
 	"time"
 
+	awsprovider "github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/aws"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/azure"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/bastion"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/config"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/docker"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/fake"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/gcp"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/handlers"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/kubernetes"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/lock"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/middleware"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/models"
+	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/provider"
 	"github.com/VAIBHAVSING/Dev8.dev/apps/agent/internal/services"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"golang.org/x/oauth2/google"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func main() {
@@ -31,6 +45,10 @@ func main() {
 	log.Printf("🔧 Configuration loaded successfully")
 	log.Printf("📍 Enabled regions: %d", len(cfg.GetEnabledRegions()))
 	for _, region := range cfg.GetEnabledRegions() {
+		if region.Private() {
+			log.Printf("   - %s (%s, private subnet)", region.Name, region.Location)
+			continue
+		}
 		log.Printf("   - %s (%s)", region.Name, region.Location)
 	}
 	log.Printf("🔒 CORS allowed origins: %v", cfg.CORSAllowedOrigins)
@@ -44,24 +62,69 @@ func main() {
 		log.Printf("   Image: %s", cfg.ContainerImage)
 	}
 
-	// Initialize Azure client
-	azureClient, err := azure.NewClient(cfg)
+	// Initialize compute providers
+	providers, err := newProviderRegistry(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create Azure client: %v", err)
+		log.Fatalf("Failed to initialize compute providers: %v", err)
 	}
-	log.Printf("☁️  Azure client initialized successfully")
+	log.Printf("🧩 Compute providers: %v (default: %s)", providers.Providers(), providers.Default())
 
 	// Initialize environment service
-	envService, err := services.NewEnvironmentService(cfg, azureClient)
+	envService, err := services.NewEnvironmentService(cfg, providers)
 	if err != nil {
 		log.Fatalf("Failed to create environment service: %v", err)
 	}
-	log.Printf("🚀 Environment service initialized (stateless)")
-	// No need to defer Close() - nothing to close
+	defer envService.Close()
+
+	// Share workspace locks between agent replicas
+	if cfg.Lock.Backend == config.LockBackendAzureBlob {
+		leases, err := azure.NewBlobLeaseClient(context.Background(), cfg.Azure.StorageAccountName, cfg.Azure.StorageAccountKey, cfg.Lock.Container)
+		if err != nil {
+			log.Fatalf("Failed to initialize workspace locks: %v", err)
+		}
+		envService.SetLocker(lock.NewLeaseLocker(leases, cfg.Lock.LeaseDuration))
+		log.Printf("🔒 Workspace locks: Azure Blob leases (%s/%s, lease %s)", cfg.Azure.StorageAccountName, cfg.Lock.Container, cfg.Lock.LeaseDuration)
+	} else {
+		log.Printf("🔒 Workspace locks: in-process (single agent replica)")
+	}
+
+	if cfg.StatePath != "" {
+		log.Printf("🚀 Environment service initialized (state store: %s)", cfg.StatePath)
+	} else {
+		log.Printf("🚀 Environment service initialized (stateless)")
+	}
+
+	// Workspaces must accept the SSH bastion's key before the first one is created
+	var sshBastion *bastion.Server
+	if cfg.SSHBastion.Enabled() {
+		sshBastion, err = bastion.NewServer(envService, cfg.SSHBastion)
+		if err != nil {
+			log.Fatalf("Failed to initialize SSH bastion: %v", err)
+		}
+		envService.SetBastionKey(sshBastion.ClientKey())
+	}
+
+	// Background loops stop when the server shuts down
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Start the orphaned-resource reconciler
+	reconciler := services.NewReconciler(envService, cfg.Reconcile)
+	go reconciler.Run(backgroundCtx)
+
+	// Start the idle auto-stop policy
+	go envService.Idle().Run(backgroundCtx)
 
 	// Initialize handlers
 	envHandler := handlers.NewEnvironmentHandler(envService)
+	operationHandler := handlers.NewOperationHandler(envService.Operations())
+	reconcileHandler := handlers.NewReconcileHandler(reconciler)
+	quotaHandler := handlers.NewQuotaHandler(envService.Quotas())
+	regionHandler := handlers.NewRegionHandler(envService)
+	previewHandler := handlers.NewPreviewHandler(envService)
+	sshKeyHandler := handlers.NewSSHKeyHandler(envService)
 	healthHandler := handlers.NewHealthHandler()
+	healthHandler.SetProviders(providers)
 
 	// Setup router
 	router := mux.NewRouter()
@@ -75,17 +138,58 @@ func main() {
 	router.HandleFunc("/ready", healthHandler.ReadinessCheck).Methods("GET")
 	router.HandleFunc("/live", healthHandler.LivenessCheck).Methods("GET")
 
+	// Supervisor routes authenticate with per-workspace tokens, not API credentials
+	envHandler.RegisterSupervisorRoutes(router)
+
 	// API v1 routes
 	api := router.PathPrefix("/api/v1").Subrouter()
 
+	// Authenticate every API route; health checks stay open
+	var authenticator *middleware.Authenticator
+	if cfg.Auth.Enabled() {
+		authenticator, err = middleware.NewAuthenticator(middleware.AuthOptions{
+			APIKeys:    cfg.Auth.APIKeys,
+			JWTSecret:  cfg.Auth.JWTSecret,
+			JWKSFile:   cfg.Auth.JWKSFile,
+			Issuer:     cfg.Auth.Issuer,
+			Audience:   cfg.Auth.Audience,
+			AdminScope: cfg.Auth.AdminScope,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize authentication: %v", err)
+		}
+		api.Use(middleware.AuthMiddleware(authenticator))
+		log.Printf("🔐 API authentication enabled (%d API key(s), HS256: %t, JWKS: %t)",
+			len(cfg.Auth.APIKeys), cfg.Auth.JWTSecret != "", cfg.Auth.JWKSFile != "")
+	} else {
+		api.Use(middleware.DisabledAuthMiddleware())
+		log.Printf("Warning: API authentication disabled - set AGENT_API_KEYS, AUTH_JWT_SECRET or AUTH_JWKS_FILE")
+	}
+
 	// Environment routes
-	api.HandleFunc("/environments", envHandler.CreateEnvironment).Methods("POST")
-	api.HandleFunc("/environments", envHandler.ListEnvironments).Methods("GET")
-	api.HandleFunc("/environments/{id}", envHandler.GetEnvironment).Methods("GET")
-	api.HandleFunc("/environments", envHandler.DeleteEnvironment).Methods("DELETE")
-	api.HandleFunc("/environments/start", envHandler.StartEnvironment).Methods("POST")
-	api.HandleFunc("/environments/stop", envHandler.StopEnvironment).Methods("POST")
-	api.HandleFunc("/environments/{id}/activity", envHandler.ReportActivity).Methods("POST")
+	envHandler.RegisterRoutes(api)
+
+	// Long-running operation routes
+	operationHandler.RegisterRoutes(api)
+
+	// Orphaned-resource reconciliation routes
+	reconcileHandler.RegisterRoutes(api)
+
+	// Quota usage routes
+	quotaHandler.RegisterRoutes(api)
+
+	// Region discovery routes
+	regionHandler.RegisterRoutes(api)
+
+	// Preview link routes
+	previewHandler.RegisterRoutes(api)
+
+	// SSH bastion key routes
+	sshKeyHandler.RegisterRoutes(api)
+
+	// Workspace gateway routes
+	gatewayHandler := handlers.NewGatewayHandler(envService, authenticator)
+	gatewayHandler.RegisterRoutes(api)
 
 	// Root route
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -106,7 +210,7 @@ func main() {
 	addr := cfg.Host + ":" + cfg.Port
 	srv := &http.Server{
 		Addr:         addr,
-		Handler:      router,
+		Handler:      previewHandler.Wrap(router),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -124,12 +228,49 @@ func main() {
 		}
 	}()
 
+	// Serve workspace and preview hosts on the gateway listener
+	var gatewaySrv *http.Server
+	if cfg.Gateway.Enabled() {
+		gatewaySrv = &http.Server{
+			Addr:              cfg.Gateway.Addr,
+			Handler:           middleware.LoggingMiddleware(gatewayHandler.Wrap(previewHandler.Wrap(http.NotFoundHandler()))),
+			ReadHeaderTimeout: 15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+		}
+
+		go func() {
+			var err error
+			if cfg.Gateway.TLS() {
+				log.Printf("🛡️  Workspace gateway on https://*.%s (%s)", cfg.Gateway.Domain, cfg.Gateway.Addr)
+				err = gatewaySrv.ListenAndServeTLS(cfg.Gateway.TLSCertFile, cfg.Gateway.TLSKeyFile)
+			} else {
+				log.Printf("Warning: workspace gateway on %s serves plain HTTP - terminate TLS for *.%s in front of it", cfg.Gateway.Addr, cfg.Gateway.Domain)
+				err = gatewaySrv.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start gateway: %v", err)
+			}
+		}()
+	}
+
+	// Route SSH connections to workspaces by username
+	if sshBastion != nil {
+		go func() {
+			log.Printf("🔑 SSH bastion: ssh {workspaceId}@%s (%s)", cfg.SSHBastion.Host, cfg.SSHBastion.Addr)
+			if err := sshBastion.ListenAndServe(cfg.SSHBastion.Addr); err != nil {
+				log.Fatalf("Failed to start SSH bastion: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Println("🛑 Shutting down server...")
+	stopBackground()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -138,6 +279,109 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
+	if gatewaySrv != nil {
+		if err := gatewaySrv.Shutdown(ctx); err != nil {
+			log.Printf("Gateway forced to shutdown: %v", err)
+		}
+	}
+	if sshBastion != nil {
+		if err := sshBastion.Close(); err != nil {
+			log.Printf("SSH bastion failed to close: %v", err)
+		}
+	}
 
 	log.Println("✅ Server stopped")
 }
+
+// newProviderRegistry builds the compute provider registry for the configured backend.
+// Non-Azure backends (fake, docker, kubernetes) serve the default AZURE slot so the web app works unchanged.
+func newProviderRegistry(cfg *config.Config) (*provider.Registry, error) {
+	providers := provider.NewRegistry(models.ProviderAzure)
+
+	switch cfg.Provider {
+	case config.ProviderFake:
+		providers.Register(models.ProviderAzure, fake.NewProvider(fake.Options{
+			Regions:      enabledRegionNames(cfg),
+			VolumeDelay:  cfg.Fake.VolumeDelay,
+			RuntimeDelay: cfg.Fake.RuntimeDelay,
+			DomainSuffix: cfg.Fake.DomainSuffix,
+		}))
+		log.Printf("🧪 Offline mode: workspaces live in memory (AGENT_PROVIDER=fake)")
+
+	case config.ProviderDocker:
+		dockerClient, err := docker.NewClient(cfg.Docker.Host, cfg.Docker.APIVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Docker client: %w", err)
+		}
+
+		providers.Register(models.ProviderAzure, docker.NewProvider(dockerClient, docker.Options{
+			Regions:    enabledRegionNames(cfg),
+			PublicHost: cfg.Docker.PublicHost,
+			Network:    cfg.Docker.Network,
+			AlwaysPull: cfg.Docker.AlwaysPull,
+		}))
+		log.Printf("🐳 Docker Engine provider: %s (public host: %s)", cfg.Docker.Host, cfg.Docker.PublicHost)
+
+	case config.ProviderKubernetes:
+		k8sClient, err := kubernetes.NewClientset(cfg.Kubernetes.Kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+
+		providers.Register(models.ProviderAzure, kubernetes.NewProvider(k8sClient, kubernetes.Options{
+			Namespace:    cfg.Kubernetes.Namespace,
+			Regions:      enabledRegionNames(cfg),
+			StorageClass: cfg.Kubernetes.StorageClass,
+			ServiceType:  corev1.ServiceType(cfg.Kubernetes.ServiceType),
+			PublicHost:   cfg.Kubernetes.PublicHost,
+		}))
+		log.Printf("☸️  Kubernetes provider: namespace %s (service type: %s)", cfg.Kubernetes.Namespace, cfg.Kubernetes.ServiceType)
+
+	default:
+		azureClient, err := azure.NewClient(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+		log.Printf("☁️  Azure client initialized successfully")
+
+		aciProvider, err := azure.NewACIProvider(cfg, azureClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ACI provider: %w", err)
+		}
+		providers.Register(models.ProviderAzure, aciProvider)
+	}
+
+	// AWS Fargate serves cloudProvider "AWS" alongside the primary backend
+	if cfg.AWS.Enabled() {
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS credentials: %w", err)
+		}
+
+		providers.Register(models.ProviderAWS, awsprovider.NewFargateProvider(cfg.AWS, awsCfg))
+		log.Printf("☁️  AWS Fargate provider: %d region(s)", len(cfg.AWS.Regions))
+	}
+
+	// GCP Cloud Run serves cloudProvider "GCP" alongside the primary backend
+	if cfg.GCP.Enabled() {
+		httpClient, err := google.DefaultClient(context.Background(), "https://www.googleapis.com/auth/cloud-platform")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load GCP credentials: %w", err)
+		}
+
+		gcpClient := gcp.NewClient(httpClient, cfg.GCP.RunEndpoint, cfg.GCP.StorageEndpoint, cfg.GCP.SecretsEndpoint)
+		providers.Register(models.ProviderGCP, gcp.NewCloudRunProvider(cfg.GCP, gcpClient))
+		log.Printf("☁️  GCP Cloud Run provider: project %s, %d region(s)", cfg.GCP.ProjectID, len(cfg.GCP.Regions))
+	}
+
+	return providers, nil
+}
+
+// enabledRegionNames returns the names of all enabled agent regions
+func enabledRegionNames(cfg *config.Config) []string {
+	var regions []string
+	for _, region := range cfg.GetEnabledRegions() {
+		regions = append(regions, region.Name)
+	}
+	return regions
+}
@@ -5,9 +5,12 @@
 
 const AGENT_API_URL = process.env.AGENT_API_URL || 'http://localhost:8080';
 const AGENT_API_ENABLED = process.env.AGENT_API_ENABLED === 'true';
+const AGENT_API_KEY = process.env.AGENT_API_KEY; // One of the agent's AGENT_API_KEYS
 const AGENT_HEALTH_TIMEOUT_MS = 8_000; // Cloudflare TLS negotiation sometimes needs a few seconds
 const AGENT_CREATE_TIMEOUT_MS = 300_000; // provisioning can take ~2 minutes
 const AGENT_ACTION_TIMEOUT_MS = 90_000;
+const AGENT_REQUEST_TIMEOUT_MS = 15_000;
+const AGENT_OPERATION_POLL_MS = 2_000;
 
 type AgentSecretPayload = {
   githubToken?: string;
@@ -18,6 +21,8 @@ type AgentSecretPayload = {
   anthropicApiKey?: string;
   openaiApiKey?: string;
   geminiApiKey?: string;
+  idleTimeoutMinutes?: number;
+  orgId?: string;
 };
 
 export interface CreateEnvironmentRequest extends AgentSecretPayload {
@@ -25,11 +30,18 @@ export interface CreateEnvironmentRequest extends AgentSecretPayload {
   userId: string;
   name: string;
   cloudProvider: 'AZURE';
-  cloudRegion: string;
+  // Region name, or 'auto' (the default) to let the agent place the workspace
+  cloudRegion?: string;
+  // Preferred location for automatic placement, e.g. 'europe'
+  locationHint?: string;
   cpuCores: number;
   memoryGB: number;
   storageGB: number;
   baseImage: string;
+  // App ports to expose besides the IDE, SSH and supervisor
+  ports?: number[];
+  // App ports whose preview URL anyone can open
+  publicPorts?: number[];
 }
 
 export interface StartEnvironmentRequest extends AgentSecretPayload {
@@ -41,6 +53,9 @@ export interface StartEnvironmentRequest extends AgentSecretPayload {
   memoryGB: number;
   storageGB: number;
   baseImage: string;
+  // Defaults to the ports the workspace was created with
+  ports?: number[];
+  publicPorts?: number[];
 }
 
 export interface StopEnvironmentRequest {
@@ -60,6 +75,8 @@ type AgentAPIResponse<T> = {
   data?: T;
   error?: string;
   code?: string;
+  errorCode?: string;
+  retryable?: boolean;
 };
 
 export type AgentConnectionURLs = {
@@ -68,6 +85,17 @@ export type AgentConnectionURLs = {
   vscodeDesktopUrl: string;
   supervisorUrl: string;
   codeServerPassword?: string;
+  // App port -> URL
+  appUrls?: Record<string, string>;
+  // App port -> preview URL served by the agent
+  previews?: Record<string, AgentPreviewURL>;
+  // The IDE behind the agent's authenticated gateway
+  gatewayUrl?: string;
+};
+
+export type AgentPreviewURL = {
+  url: string;
+  visibility: 'PRIVATE' | 'PUBLIC';
 };
 
 export interface EnvironmentResponse {
@@ -75,11 +103,14 @@ export interface EnvironmentResponse {
   name: string;
   userId: string;
   status: string;
+  statusReason?: string;
   cloudRegion: string;
   cpuCores: number;
   memoryGB: number;
   storageGB: number;
   baseImage: string;
+  ports?: number[];
+  publicPorts?: number[];
   cloudProvider?: string;
   azureResourceGroup: string;
   azureContainerGroup: string;
@@ -90,14 +121,57 @@ export interface EnvironmentResponse {
   updatedAt: string;
 }
 
-type EnvironmentEnvelope = {
-  environment: EnvironmentResponse;
+export type AgentOperationStep = {
+  name:
+    | 'VOLUME_CREATED'
+    | 'VOLUME_VERIFIED'
+    | 'CONTAINER_CREATED'
+    | 'RUNTIME_RUNNING'
+    | 'FQDN_ASSIGNED'
+    | 'IDE_REACHABLE'
+    | 'SUPERVISOR_HEALTHY'
+    | 'CONTAINER_DELETED'
+    | 'VOLUME_DELETED';
+  status: 'PENDING' | 'RUNNING' | 'DONE' | 'FAILED' | 'SKIPPED';
   message?: string;
+  startedAt?: string;
+  completedAt?: string;
+};
+
+export interface AgentOperation {
+  id: string;
+  type: 'CREATE' | 'START' | 'STOP' | 'DELETE';
+  workspaceId: string;
+  status: 'PENDING' | 'RUNNING' | 'SUCCEEDED' | 'FAILED';
+  steps: AgentOperationStep[];
+  environment?: EnvironmentResponse;
+  error?: { code: string; message: string; retryable?: boolean };
+  createdAt: string;
+  updatedAt: string;
+  completedAt?: string;
+}
+
+type OperationAcceptedEnvelope = {
+  operationId: string;
+  workspaceId: string;
+  operation: AgentOperation;
+  statusUrl: string;
+  eventsUrl: string;
+};
+
+type OperationEnvelope = {
+  operation: AgentOperation;
 };
 
 async function agentRequest<T>(path: string, init: RequestInit, timeoutMs: number): Promise<T> {
+  const headers = new Headers(init.headers);
+  if (AGENT_API_KEY) {
+    headers.set('Authorization', `Bearer ${AGENT_API_KEY}`);
+  }
+
   const response = await fetch(`${AGENT_API_URL}${path}`, {
     ...init,
+    headers,
     signal: AbortSignal.timeout(timeoutMs),
   });
 
@@ -120,6 +194,64 @@ async function agentRequest<T>(path: string, init: RequestInit, timeoutMs: numbe
   return (parsed.data ?? ({} as T)) as T;
 }
 
+/**
+ * Get the current state of a long-running agent operation
+ */
+export async function getOperation(operationId: string): Promise<AgentOperation> {
+  const data = await agentRequest<OperationEnvelope>(
+    `/api/v1/operations/${encodeURIComponent(operationId)}`,
+    { method: 'GET' },
+    AGENT_REQUEST_TIMEOUT_MS,
+  );
+
+  if (!data?.operation) {
+    throw new Error(`Agent API returned an empty payload for operation ${operationId}`);
+  }
+
+  return data.operation;
+}
+
+export type AgentLifecycleOptions = {
+  // Retries with the same key attach to the first attempt's operation instead of starting another
+  idempotencyKey?: string;
+};
+
+/**
+ * Submit a lifecycle request (202 Accepted) and poll its operation until it completes
+ */
+async function runOperation(
+  path: string,
+  init: RequestInit,
+  timeoutMs: number,
+  options: AgentLifecycleOptions = {},
+): Promise<AgentOperation> {
+  const headers = new Headers(init.headers);
+  if (options.idempotencyKey) {
+    headers.set('Idempotency-Key', options.idempotencyKey);
+  }
+
+  const accepted = await agentRequest<OperationAcceptedEnvelope>(path, { ...init, headers }, AGENT_REQUEST_TIMEOUT_MS);
+  if (!accepted?.operationId) {
+    throw new Error('Agent API did not return an operation ID');
+  }
+
+  const deadline = Date.now() + timeoutMs;
+  let operation = accepted.operation;
+  while (operation?.status !== 'SUCCEEDED' && operation?.status !== 'FAILED') {
+    if (Date.now() >= deadline) {
+      throw new Error(`Agent operation ${accepted.operationId} timed out after ${timeoutMs}ms`);
+    }
+    await new Promise((resolve) => setTimeout(resolve, AGENT_OPERATION_POLL_MS));
+    operation = await getOperation(accepted.operationId);
+  }
+
+  if (operation.status === 'FAILED') {
+    throw new Error(`Agent API error: ${operation.error?.message || 'operation failed'}`);
+  }
+
+  return operation;
+}
+
 /**
  * Check if Agent API is enabled and available
  */
@@ -156,9 +288,10 @@ export function isAgentIntegrationEnabled(): boolean {
  * Create a new environment via Agent API
  */
 export async function createEnvironment(
-  request: CreateEnvironmentRequest
+  request: CreateEnvironmentRequest,
+  options: AgentLifecycleOptions = {},
 ): Promise<EnvironmentResponse> {
-  const data = await agentRequest<EnvironmentEnvelope>(
+  const operation = await runOperation(
     '/api/v1/environments',
     {
       method: 'POST',
@@ -166,22 +299,24 @@ export async function createEnvironment(
       body: JSON.stringify(request),
     },
     AGENT_CREATE_TIMEOUT_MS,
+    options,
   );
 
-  if (!data?.environment) {
+  if (!operation.environment) {
     throw new Error('Agent API returned an empty payload while creating workspace');
   }
 
-  return data.environment;
+  return operation.environment;
 }
 
 /**
  * Start an existing environment
  */
 export async function startEnvironment(
-  request: StartEnvironmentRequest
+  request: StartEnvironmentRequest,
+  options: AgentLifecycleOptions = {},
 ): Promise<EnvironmentResponse> {
-  const data = await agentRequest<EnvironmentEnvelope>(
+  const operation = await runOperation(
     '/api/v1/environments/start',
     {
       method: 'POST',
@@ -189,22 +324,24 @@ export async function startEnvironment(
       body: JSON.stringify(request),
     },
     AGENT_ACTION_TIMEOUT_MS,
+    options,
   );
 
-  if (!data?.environment) {
+  if (!operation.environment) {
     throw new Error('Agent API returned an empty payload while starting workspace');
   }
 
-  return data.environment;
+  return operation.environment;
 }
 
 /**
  * Stop a running environment
  */
 export async function stopEnvironment(
-  request: StopEnvironmentRequest
+  request: StopEnvironmentRequest,
+  options: AgentLifecycleOptions = {},
 ): Promise<void> {
-  await agentRequest(
+  await runOperation(
     '/api/v1/environments/stop',
     {
       method: 'POST',
@@ -212,6 +349,7 @@ export async function stopEnvironment(
       body: JSON.stringify(request),
     },
     AGENT_ACTION_TIMEOUT_MS,
+    options,
   );
 }
 
@@ -219,9 +357,10 @@ export async function stopEnvironment(
  * Delete an environment permanently
  */
 export async function deleteEnvironment(
-  request: DeleteEnvironmentRequest
+  request: DeleteEnvironmentRequest,
+  options: AgentLifecycleOptions = {},
 ): Promise<void> {
-  await agentRequest(
+  await runOperation(
     '/api/v1/environments',
     {
       method: 'DELETE',
@@ -229,5 +368,6 @@ export async function deleteEnvironment(
       body: JSON.stringify({ ...request, force: request.force ?? false }),
     },
     AGENT_ACTION_TIMEOUT_MS,
+    options,
   );
 }
@@ -1,12 +1,16 @@
 import type {
   WorkspaceConfig,
   CreateWorkspaceResponse,
+  Operation,
+  OperationAcceptedResponse,
   StartWorkspaceRequest,
   StopWorkspaceRequest,
   DeleteWorkspaceRequest,
   HealthResponse,
   ApiResponse,
-  Environment,
+  Region,
+  AccessLink,
+  SSHKeys,
 } from './types.js';
 
 export class AgentClient {
@@ -50,6 +54,8 @@ export class AgentClient {
           message: data.message || 'Request failed',
           error: data.error,
           code: data.code,
+          errorCode: data.errorCode,
+          retryable: data.retryable,
         };
       }
 
@@ -86,8 +92,8 @@ export class AgentClient {
 
   public async startWorkspace(
     request: StartWorkspaceRequest
-  ): Promise<ApiResponse<{ environment: Environment; message: string }>> {
-    return this.request<{ environment: Environment; message: string }>(
+  ): Promise<ApiResponse<OperationAcceptedResponse>> {
+    return this.request<OperationAcceptedResponse>(
       '/api/v1/environments/start',
       {
         method: 'POST',
@@ -98,8 +104,8 @@ export class AgentClient {
 
   public async stopWorkspace(
     request: StopWorkspaceRequest
-  ): Promise<ApiResponse<{ workspaceId: string; message: string }>> {
-    return this.request<{ workspaceId: string; message: string }>(
+  ): Promise<ApiResponse<OperationAcceptedResponse>> {
+    return this.request<OperationAcceptedResponse>(
       '/api/v1/environments/stop',
       {
         method: 'POST',
@@ -110,8 +116,8 @@ export class AgentClient {
 
   public async deleteWorkspace(
     request: DeleteWorkspaceRequest
-  ): Promise<ApiResponse<{ workspaceId: string; message: string }>> {
-    return this.request<{ workspaceId: string; message: string }>(
+  ): Promise<ApiResponse<OperationAcceptedResponse>> {
+    return this.request<OperationAcceptedResponse>(
       '/api/v1/environments',
       {
         method: 'DELETE',
@@ -120,6 +126,55 @@ export class AgentClient {
     );
   }
 
+  public async getOperation(
+    operationId: string
+  ): Promise<ApiResponse<{ operation: Operation }>> {
+    return this.request<{ operation: Operation }>(
+      `/api/v1/operations/${operationId}`
+    );
+  }
+
+  public async listRegions(): Promise<ApiResponse<{ regions: Region[] }>> {
+    return this.request<{ regions: Region[] }>('/api/v1/regions');
+  }
+
+  public async createPreviewLink(
+    workspaceId: string,
+    port: number
+  ): Promise<ApiResponse<AccessLink>> {
+    return this.request<AccessLink>(
+      `/api/v1/environments/${workspaceId}/previews/${port}/link`,
+      {
+        method: 'POST',
+      }
+    );
+  }
+
+  public async createGatewayLink(
+    workspaceId: string
+  ): Promise<ApiResponse<AccessLink>> {
+    return this.request<AccessLink>(
+      `/api/v1/environments/${workspaceId}/gateway/link`,
+      {
+        method: 'POST',
+      }
+    );
+  }
+
+  public async getSSHKeys(userId: string): Promise<ApiResponse<SSHKeys>> {
+    return this.request<SSHKeys>(`/api/v1/users/${userId}/ssh-keys`);
+  }
+
+  public async setSSHKeys(
+    userId: string,
+    keys: string[]
+  ): Promise<ApiResponse<SSHKeys>> {
+    return this.request<SSHKeys>(`/api/v1/users/${userId}/ssh-keys`, {
+      method: 'PUT',
+      body: JSON.stringify({ keys }),
+    });
+  }
+
   public async reportActivity(
     workspaceId: string
   ): Promise<ApiResponse<{ message: string }>> {
@@ -5,8 +5,17 @@ export type {
   Environment,
   ApiResponse,
   CreateWorkspaceResponse,
+  Operation,
+  OperationStep,
+  OperationAcceptedResponse,
   StartWorkspaceRequest,
   StopWorkspaceRequest,
   DeleteWorkspaceRequest,
   HealthResponse,
+  PreviewUrl,
+  AccessLink,
+  SSHKeys,
+  Region,
+  RegionHealth,
+  ResourceTier,
 } from './types.js';
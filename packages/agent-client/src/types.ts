@@ -1,9 +1,14 @@
 export interface WorkspaceConfig {
   workspaceId: string;
   userId: string;
+  // Organisation whose quota the workspace counts against
+  orgId?: string;
   name: string;
   cloudProvider?: 'AZURE';
-  cloudRegion: string;
+  // Region name, or 'auto' (the default) to let the agent place the workspace
+  cloudRegion?: string;
+  // Preferred location for automatic placement, e.g. 'europe'
+  locationHint?: string;
   cpuCores: number;
   memoryGB: number;
   storageGB: number;
@@ -16,28 +21,63 @@ export interface WorkspaceConfig {
   anthropicApiKey?: string;
   openaiApiKey?: string;
   geminiApiKey?: string;
+  // App ports to expose besides the IDE, SSH and supervisor
+  ports?: number[];
+  // App ports whose preview URL anyone can open
+  publicPorts?: number[];
+  // Idle auto-stop override in minutes; 0 never stops the workspace for inactivity
+  idleTimeoutMinutes?: number;
 }
 
 export interface ConnectionUrls {
   vscode: string;
   ssh?: string;
+  // App port -> preview URL served by the agent
+  previews?: Record<string, PreviewUrl>;
+  // The IDE behind the agent's authenticated gateway
+  gatewayUrl?: string;
+}
+
+export interface PreviewUrl {
+  url: string;
+  visibility: 'PRIVATE' | 'PUBLIC';
+}
+
+// Signs the owner's browser in to a private preview or the workspace gateway; expires quickly
+export interface AccessLink {
+  url: string;
+  expiresAt: string;
+}
+
+// Public keys a user signs in to the SSH bastion with, one authorized_keys line each
+export interface SSHKeys {
+  keys: string[];
 }
 
 export interface Environment {
   id: string;
   name: string;
   userId: string;
+  orgId?: string;
   status: 'RUNNING' | 'STOPPED' | 'CREATING' | 'DELETING';
+  /** Why the workspace is STARTING (not yet serving traffic) or in ERROR */
+  statusReason?: string;
   cloudRegion: string;
   cpuCores: number;
   memoryGB: number;
   storageGB: number;
   baseImage: string;
+  ports?: number[];
+  publicPorts?: number[];
   azureResourceGroup?: string;
   azureContainerGroup?: string;
   azureFileShare?: string;
   azureFqdn?: string;
+  // The workspace's own SSH server when connectionUrls.ssh points at the bastion
+  sshAddress?: string;
   connectionUrls?: ConnectionUrls;
+  idleTimeoutMinutes?: number;
+  stopReason?: 'REQUESTED' | 'IDLE_TIMEOUT';
   createdAt: string;
   updatedAt: string;
 }
@@ -48,13 +88,62 @@ export interface ApiResponse<T> {
   data?: T;
   error?: string;
   code?: string;
+  errorCode?: string;
+  retryable?: boolean;
 }
 
-export interface CreateWorkspaceResponse {
-  environment: Environment;
-  message: string;
+export interface QuotaResources {
+  runningWorkspaces: number;
+  cpuCores: number;
+  memoryGB: number;
+  storageGB: number;
+}
+
+export interface QuotaStatus {
+  scope: 'USER' | 'ORG';
+  id: string;
+  // 0 = unlimited
+  limits: QuotaResources;
+  usage: QuotaResources;
+}
+
+export interface QuotaUsage {
+  user: QuotaStatus;
+  org?: QuotaStatus;
 }
 
+export interface OperationStep {
+  name: string;
+  status: 'PENDING' | 'RUNNING' | 'DONE' | 'FAILED' | 'SKIPPED';
+  message?: string;
+  startedAt?: string;
+  completedAt?: string;
+}
+
+export interface Operation {
+  id: string;
+  type: 'CREATE' | 'START' | 'STOP' | 'DELETE';
+  workspaceId: string;
+  status: 'PENDING' | 'RUNNING' | 'SUCCEEDED' | 'FAILED';
+  steps: OperationStep[];
+  environment?: Environment;
+  error?: { code: string; message: string; retryable?: boolean };
+  createdAt: string;
+  updatedAt: string;
+  completedAt?: string;
+}
+
+// Lifecycle requests return 202 Accepted; poll statusUrl or stream eventsUrl (SSE)
+export interface OperationAcceptedResponse {
+  operationId: string;
+  workspaceId: string;
+  operation: Operation;
+  statusUrl: string;
+  eventsUrl: string;
+}
+
+export type CreateWorkspaceResponse = OperationAcceptedResponse;
+
 export interface StartWorkspaceRequest {
   workspaceId: string;
   cloudRegion: string;
@@ -64,6 +153,9 @@ export interface StartWorkspaceRequest {
   memoryGB: number;
   storageGB: number;
   baseImage: string;
+  // Defaults to the ports the workspace was created with
+  ports?: number[];
+  publicPorts?: number[];
   codeServerPassword?: string;
   githubToken?: string;
 }
@@ -84,3 +176,28 @@ export interface HealthResponse {
   timestamp: string;
   uptime: string;
 }
+
+export interface RegionHealth {
+  region: string;
+  healthy: boolean;
+  state: 'closed' | 'open' | 'half-open';
+  failures: number;
+  openUntil?: string;
+}
+
+export interface ResourceTier {
+  name: string;
+  cpuCores: number;
+  memoryGB: number;
+}
+
+export interface Region {
+  name: string;
+  // Display name, e.g. 'West Europe'
+  location: string;
+  enabled: boolean;
+  health: RegionHealth;
+  // Workspace sizes the region accepts; empty when disabled
+  tiers: ResourceTier[];
+  storageConfigured: boolean;
+}